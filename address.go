@@ -1,12 +1,11 @@
 package enet
 
 import (
-	"unsafe"
+	"net"
+	"net/netip"
+	"strconv"
 )
 
-// #include <enet/enet.h>
-import "C"
-
 // Address specifies a portable internet address structure.
 type Address interface {
 	// SetHostAny()
@@ -15,74 +14,46 @@ type Address interface {
 	SetHost(addressType ENetAddressType, ip string)
 	SetPort(port uint16)
 
+	// String returns the address as host:port, like HostPort, as net.Addr
+	// requires. AddrPort().Addr() is the host alone.
 	String() string
 	GetPort() uint16
-}
 
-// enetAddress is the internal implementation of Address
-type enetAddress struct {
-	cAddr C.struct__ENetAddress
-}
+	// AddrPort returns the address as a netip.AddrPort. IPv4-mapped IPv6
+	// addresses are unmapped, so that the same address always compares
+	// equal and can be used as a map key. IPv6 hosts keep their zone, and
+	// unset addresses have the unspecified IPv6 host.
+	AddrPort() netip.AddrPort
 
-// enetAddressType is the internal implementation of Address
-type ENetAddressType C.ENetAddressType
+	// HostPort returns the address as host:port, with IPv6 hosts and their
+	// zone in brackets
+	HostPort() string
 
-const (
-	ENET_ADDRESS_TYPE_ANY  ENetAddressType = ENetAddressType(C.ENET_ADDRESS_TYPE_ANY)
-	ENET_ADDRESS_TYPE_IPV4 ENetAddressType = ENetAddressType(C.ENET_ADDRESS_TYPE_IPV4)
-	ENET_ADDRESS_TYPE_IPV6 ENetAddressType = ENetAddressType(C.ENET_ADDRESS_TYPE_IPV6)
-)
-
-// cAddress returns the C address of the address
-func (addr *enetAddress) cAddress() *C.struct__ENetAddress {
-	return &addr.cAddr
-}
-
-// BuildAny builds an address that can be used to bind to any host
-func (addr *enetAddress) BuildAny(addressType ENetAddressType) {
-	C.enet_address_build_any(&addr.cAddr, C.ENetAddressType(addressType))
-}
+	// NetAddr returns the address as a net.UDPAddr, for use with the
+	// standard library
+	NetAddr() *net.UDPAddr
 
-/* SetHostAny sets the host of the address to ENET_HOST_ANY
-func (addr *enetAddress) SetHostAny() {
-	addr.cAddr.host = C.ENET_HOST_ANY
-}*/
-
-// SetHost sets the host of the address
-func (addr *enetAddress) SetHost(addressType ENetAddressType, hostname string) {
-	cHostname := C.CString(hostname)
-	C.enet_address_set_host(
-		&addr.cAddr,
-		C.ENetAddressType(addressType),
-		cHostname,
-	)
-	C.free(unsafe.Pointer(cHostname))
+	// Network returns "udp", so that addresses satisfy net.Addr
+	Network() string
 }
 
-// SetPort sets the port number of the address
-func (addr *enetAddress) SetPort(port uint16) {
-	addr.cAddr.port = (C.enet_uint16)(port)
-}
+var (
+	_ net.Addr = Address(nil)
+	_ net.Addr = (*enetAddress)(nil)
+)
 
-// String returns the IP address of the address
-func (addr *enetAddress) String() string {
-	buffer := C.malloc(32)
-	C.enet_address_get_host_ip(
-		&addr.cAddr,
-		(*C.char)(buffer),
-		32,
-	)
-	ret := C.GoString((*C.char)(buffer))
-	C.free(buffer)
-	return ret
-}
+// ENetAddressType is the address family of an address or host
+type ENetAddressType uint32
 
-// GetPort returns the port number of the address
-func (addr *enetAddress) GetPort() uint16 {
-	return uint16(addr.cAddr.port)
-}
+const (
+	ENET_ADDRESS_TYPE_ANY  ENetAddressType = 0
+	ENET_ADDRESS_TYPE_IPV4 ENetAddressType = 1
+	ENET_ADDRESS_TYPE_IPV6 ENetAddressType = 2
+)
 
-// NewAddress creates a new address
+// NewAddress creates a new address, resolving the host if it isn't an IP
+// address. Hosts that can't be resolved leave the address unset; use
+// LookupAddress or ResolveAddress to get an error instead.
 func NewAddress(addressType ENetAddressType, ip string, port uint16) Address {
 	ret := enetAddress{}
 	ret.SetHost(addressType, ip)
@@ -90,7 +61,8 @@ func NewAddress(addressType ENetAddressType, ip string, port uint16) Address {
 	return &ret
 }
 
-// NewListenAddress makes a new address ready for listening on ENET_HOST_ANY
+// NewListenAddress makes a new address ready for listening on ENET_HOST_ANY.
+// With ENET_ADDRESS_TYPE_ANY, it listens on both IPv4 and IPv6.
 func NewListenAddress(addressType ENetAddressType, port uint16) Address {
 	ret := enetAddress{}
 	// ret.BuildAny(addressType)
@@ -102,3 +74,53 @@ func NewListenAddress(addressType ENetAddressType, port uint16) Address {
 	ret.SetPort(port)
 	return &ret
 }
+
+// NewAddressFromAddrPort creates an address from a netip.AddrPort
+func NewAddressFromAddrPort(addrPort netip.AddrPort) Address {
+	ret := enetAddress{}
+	ret.setAddrPort(addrPort)
+	return &ret
+}
+
+// AddressEqual tells whether two addresses have the same host and port,
+// whether or not IPv4 hosts are mapped to IPv6
+func AddressEqual(a, b Address) bool {
+	return a.AddrPort() == b.AddrPort()
+}
+
+// hostPort formats an address as host:port
+func hostPort(addr Address) string {
+	return addr.AddrPort().String()
+}
+
+// zoneIndex returns the interface index of an IPv6 zone, which is either an
+// interface name or an index, or 0 if there is no such interface
+func zoneIndex(zone string) uint32 {
+	if zone == "" {
+		return 0
+	}
+	if index, err := strconv.ParseUint(zone, 10, 32); err == nil {
+		return uint32(index)
+	}
+	if iface, err := net.InterfaceByName(zone); err == nil {
+		return uint32(iface.Index)
+	}
+	return 0
+}
+
+// zoneName returns the IPv6 zone of an interface index, its name if the
+// interface exists or else the index itself
+func zoneName(index uint32) string {
+	if index == 0 {
+		return ""
+	}
+	if iface, err := net.InterfaceByIndex(int(index)); err == nil {
+		return iface.Name
+	}
+	return strconv.FormatUint(uint64(index), 10)
+}
+
+// udpAddr converts an address to a net.UDPAddr
+func udpAddr(addr Address) *net.UDPAddr {
+	return net.UDPAddrFromAddrPort(addr.AddrPort())
+}
@@ -0,0 +1,106 @@
+//go:build cgo && !purego
+
+package enet
+
+import (
+	"net"
+	"net/netip"
+	"unsafe"
+)
+
+// #include <enet/enet.h>
+import "C"
+
+// enetAddress is the internal implementation of Address
+type enetAddress struct {
+	cAddr C.struct__ENetAddress
+}
+
+// Ensure the address types shared with the pure-Go backend match enet.h.
+var (
+	_ = [1]struct{}{}[ENET_ADDRESS_TYPE_ANY-ENetAddressType(C.ENET_ADDRESS_TYPE_ANY)]
+	_ = [1]struct{}{}[ENET_ADDRESS_TYPE_IPV4-ENetAddressType(C.ENET_ADDRESS_TYPE_IPV4)]
+	_ = [1]struct{}{}[ENET_ADDRESS_TYPE_IPV6-ENetAddressType(C.ENET_ADDRESS_TYPE_IPV6)]
+)
+
+// cAddress returns the C address of the address
+func (addr *enetAddress) cAddress() *C.struct__ENetAddress {
+	return &addr.cAddr
+}
+
+// BuildAny builds an address that can be used to bind to any host
+func (addr *enetAddress) BuildAny(addressType ENetAddressType) {
+	C.enet_address_build_any(&addr.cAddr, C.ENetAddressType(addressType))
+}
+
+/* SetHostAny sets the host of the address to ENET_HOST_ANY
+func (addr *enetAddress) SetHostAny() {
+	addr.cAddr.host = C.ENET_HOST_ANY
+}*/
+
+// SetHost sets the host of the address
+func (addr *enetAddress) SetHost(addressType ENetAddressType, hostname string) {
+	if ip, err := netip.ParseAddr(hostname); err == nil && ip.Zone() != "" {
+		addr.setAddrPort(netip.AddrPortFrom(ip, addr.GetPort()))
+		return
+	}
+	cHostname := C.CString(hostname)
+	C.enet_address_set_host(
+		&addr.cAddr,
+		C.ENetAddressType(addressType),
+		cHostname,
+	)
+	C.free(unsafe.Pointer(cHostname))
+}
+
+// SetPort sets the port number of the address
+func (addr *enetAddress) SetPort(port uint16) {
+	addr.cAddr.port = (C.enet_uint16)(port)
+}
+
+// String returns the address as host:port
+func (addr *enetAddress) String() string {
+	return hostPort(addr)
+}
+
+// GetPort returns the port number of the address
+func (addr *enetAddress) GetPort() uint16 {
+	return uint16(addr.cAddr.port)
+}
+
+// host returns the 16 byte host of the address. IPv4 hosts are mapped to
+// IPv6.
+func (addr *enetAddress) host() *[16]byte {
+	return (*[16]byte)(unsafe.Pointer(&addr.cAddr.host))
+}
+
+// setAddrPort sets the host, zone and port of the address
+func (addr *enetAddress) setAddrPort(addrPort netip.AddrPort) {
+	*addr.host() = addrPort.Addr().As16()
+	addr.cAddr.scopeID = C.uint32_t(zoneIndex(addrPort.Addr().Zone()))
+	addr.cAddr.port = (C.enet_uint16)(addrPort.Port())
+}
+
+// AddrPort returns the address as a netip.AddrPort
+func (addr *enetAddress) AddrPort() netip.AddrPort {
+	ip := netip.AddrFrom16(*addr.host()).Unmap()
+	if ip.Is6() {
+		ip = ip.WithZone(zoneName(uint32(addr.cAddr.scopeID)))
+	}
+	return netip.AddrPortFrom(ip, addr.GetPort())
+}
+
+// HostPort returns the address as host:port
+func (addr *enetAddress) HostPort() string {
+	return hostPort(addr)
+}
+
+// NetAddr returns the address as a net.UDPAddr
+func (addr *enetAddress) NetAddr() *net.UDPAddr {
+	return udpAddr(addr)
+}
+
+// Network returns the network of the address, "udp"
+func (addr *enetAddress) Network() string {
+	return "udp"
+}
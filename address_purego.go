@@ -0,0 +1,106 @@
+//go:build !cgo || purego
+
+package enet
+
+import (
+	"net"
+	"net/netip"
+)
+
+// enetAddress is the internal implementation of Address
+type enetAddress struct {
+	addr netip.Addr
+	port uint16
+}
+
+// addrPort returns the address as a netip.AddrPort
+func (addr *enetAddress) addrPort() netip.AddrPort {
+	return netip.AddrPortFrom(addr.addr, addr.port)
+}
+
+// setAddrPort sets the host and port of the address
+func (addr *enetAddress) setAddrPort(addrPort netip.AddrPort) {
+	addr.addr = canonicalZone(addrPort.Addr().Unmap())
+	addr.port = addrPort.Port()
+}
+
+// canonicalZone rewrites the zone of an IPv6 host the way the C backend
+// stores it, as an interface index formatted back by zoneName
+func canonicalZone(ip netip.Addr) netip.Addr {
+	if ip.Zone() == "" {
+		return ip
+	}
+	return ip.WithZone(zoneName(zoneIndex(ip.Zone())))
+}
+
+// AddrPort returns the address as a netip.AddrPort
+func (addr *enetAddress) AddrPort() netip.AddrPort {
+	if !addr.addr.IsValid() {
+		// Like the zeroed host of the C backend
+		return netip.AddrPortFrom(netip.IPv6Unspecified(), addr.port)
+	}
+	return netip.AddrPortFrom(addr.addr.Unmap(), addr.port)
+}
+
+// HostPort returns the address as host:port
+func (addr *enetAddress) HostPort() string {
+	return hostPort(addr)
+}
+
+// NetAddr returns the address as a net.UDPAddr
+func (addr *enetAddress) NetAddr() *net.UDPAddr {
+	return udpAddr(addr)
+}
+
+// Network returns the network of the address, "udp"
+func (addr *enetAddress) Network() string {
+	return "udp"
+}
+
+// BuildAny builds an address that can be used to bind to any host
+func (addr *enetAddress) BuildAny(addressType ENetAddressType) {
+	if addressType == ENET_ADDRESS_TYPE_IPV4 {
+		addr.addr = netip.IPv4Unspecified()
+	} else {
+		addr.addr = netip.IPv6Unspecified()
+	}
+}
+
+// SetHost sets the host of the address, resolving it if it isn't an IP
+// address. The address is left unset if the hostname can't be resolved.
+func (addr *enetAddress) SetHost(addressType ENetAddressType, hostname string) {
+	if ip, err := netip.ParseAddr(hostname); err == nil {
+		addr.addr = canonicalZone(ip.Unmap())
+		return
+	}
+
+	ips, err := net.LookupIP(hostname)
+	if err != nil {
+		return
+	}
+	for _, ip := range ips {
+		is4 := ip.To4() != nil
+		if addressType == ENET_ADDRESS_TYPE_ANY ||
+			(addressType == ENET_ADDRESS_TYPE_IPV4 && is4) ||
+			(addressType == ENET_ADDRESS_TYPE_IPV6 && !is4) {
+			addr.addr, _ = netip.AddrFromSlice(ip)
+			addr.addr = addr.addr.Unmap()
+			return
+		}
+	}
+}
+
+// SetPort sets the port number of the address
+func (addr *enetAddress) SetPort(port uint16) {
+	addr.port = port
+}
+
+// String returns the address as host:port
+func (addr *enetAddress) String() string {
+	return hostPort(addr)
+}
+
+// GetPort returns the port number of the address
+func (addr *enetAddress) GetPort() uint16 {
+	return addr.port
+}
@@ -0,0 +1,91 @@
+package enet_test
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	enet "github.com/eikarna/gotops"
+)
+
+func TestAddressAddrPort(t *testing.T) {
+	for _, tc := range []struct {
+		addrPort string
+		host     string
+		hostPort string
+	}{
+		{"127.0.0.1:17091", "127.0.0.1", "127.0.0.1:17091"},
+		{"[::1]:17091", "::1", "[::1]:17091"},
+		{"[::ffff:10.0.0.1]:80", "10.0.0.1", "10.0.0.1:80"},
+		{"[2001:db8:1234:5678:9abc:def0:1234:5678]:65535", "2001:db8:1234:5678:9abc:def0:1234:5678", "[2001:db8:1234:5678:9abc:def0:1234:5678]:65535"},
+		{"[fe80::1%1]:17091", "fe80::1%" + zone1(), "[fe80::1%" + zone1() + "]:17091"},
+	} {
+		addr := enet.NewAddressFromAddrPort(netip.MustParseAddrPort(tc.addrPort))
+		if got := addr.String(); got != tc.hostPort {
+			t.Errorf("%s: String() = %q, want %q", tc.addrPort, got, tc.hostPort)
+		}
+		if got := addr.AddrPort().Addr().String(); got != tc.host {
+			t.Errorf("%s: host %q, want %q", tc.addrPort, got, tc.host)
+		}
+		if got := addr.HostPort(); got != tc.hostPort {
+			t.Errorf("%s: HostPort() = %q, want %q", tc.addrPort, got, tc.hostPort)
+		}
+		if got := addr.NetAddr().String(); got != tc.hostPort {
+			t.Errorf("%s: NetAddr() = %q, want %q", tc.addrPort, got, tc.hostPort)
+		}
+		if got := addr.AddrPort().String(); got != tc.hostPort {
+			t.Errorf("%s: AddrPort() = %q, want %q", tc.addrPort, got, tc.hostPort)
+		}
+	}
+}
+
+func TestAddressEqual(t *testing.T) {
+	v4 := enet.NewAddress(enet.ENET_ADDRESS_TYPE_IPV4, "127.0.0.1", 17091)
+	mapped := enet.NewAddressFromAddrPort(netip.MustParseAddrPort("[::ffff:127.0.0.1]:17091"))
+	if !enet.AddressEqual(v4, mapped) {
+		t.Errorf("%s and %s differ", v4.HostPort(), mapped.HostPort())
+	}
+	if enet.AddressEqual(v4, enet.NewAddress(enet.ENET_ADDRESS_TYPE_IPV4, "127.0.0.1", 17092)) {
+		t.Error("addresses with different ports are equal")
+	}
+
+	seen := map[netip.AddrPort]bool{v4.AddrPort(): true}
+	if !seen[mapped.AddrPort()] {
+		t.Error("mapped address isn't found in a map keyed by AddrPort")
+	}
+}
+
+// zone1 returns the zone of the interface with index 1, its name if it
+// exists
+func zone1() string {
+	if iface, err := net.InterfaceByIndex(1); err == nil {
+		return iface.Name
+	}
+	return "1"
+}
+
+func TestAddressNetAddr(t *testing.T) {
+	var addr net.Addr = enet.NewAddress(enet.ENET_ADDRESS_TYPE_IPV4, "127.0.0.1", 17091)
+	if addr.Network() != "udp" || addr.String() != "127.0.0.1:17091" {
+		t.Errorf("net.Addr is %s %s, want udp 127.0.0.1:17091", addr.Network(), addr)
+	}
+}
+
+func TestAddressZone(t *testing.T) {
+	addr := enet.NewAddress(enet.ENET_ADDRESS_TYPE_IPV6, "fe80::1%"+zone1(), 17091)
+	if got := addr.AddrPort().Addr().Zone(); got != zone1() {
+		t.Errorf("zone is %q, want %q", got, zone1())
+	}
+	if got := addr.NetAddr().Zone; got != zone1() {
+		t.Errorf("net.UDPAddr zone is %q, want %q", got, zone1())
+	}
+}
+
+// TestAddressUnset checks that both backends format an unset address the
+// same way
+func TestAddressUnset(t *testing.T) {
+	addr := enet.NewAddress(enet.ENET_ADDRESS_TYPE_IPV4, "host.invalid", 17091)
+	if got := addr.String(); got != "[::]:17091" {
+		t.Errorf("unset address is %q, want [::]:17091", got)
+	}
+}
@@ -0,0 +1,21 @@
+package enet
+
+// Outgoing is a packet to send with Host.SendBatch. The data is copied, so
+// the slice may be reused once SendBatch returns; PacketFlagNoAllocate is
+// ignored for the same reason.
+type Outgoing struct {
+	Peer    Peer
+	Channel uint8
+	Data    []byte
+	Flags   PacketFlags
+}
+
+// sendEach sends the packets of a batch one at a time with Peer.SendBytes
+func sendEach(batch []Outgoing) error {
+	for _, out := range batch {
+		if err := out.Peer.SendBytes(out.Data, out.Channel, out.Flags&^PacketFlagNoAllocate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+//go:build cgo && !purego
+
+package enet
+
+/*
+#include <enet/enet.h>
+
+typedef struct {
+	ENetPeer *peer;
+	size_t offset;
+	size_t length;
+	enet_uint32 flags;
+	enet_uint8 channel;
+} gotops_outgoing;
+
+// gotops_send_batch creates and queues count packets, their data at the
+// offsets of data. Packets a peer doesn't take are destroyed. It returns
+// the number of packets created, less than count if one couldn't be.
+static size_t gotops_send_batch(const gotops_outgoing *batch, size_t count, const enet_uint8 *data) {
+	size_t i;
+	for (i = 0; i < count; i++) {
+		ENetPacket *packet = enet_packet_create(data + batch[i].offset, batch[i].length, batch[i].flags);
+		if (packet == NULL) {
+			return i;
+		}
+		if (enet_peer_send(batch[i].peer, batch[i].channel, packet) < 0 && packet->referenceCount == 0) {
+			enet_packet_destroy(packet);
+		}
+	}
+	return count;
+}
+
+// gotops_dispatch_batch moves queued events to events, after the first
+// count, up to max. It returns the new count.
+static int gotops_dispatch_batch(ENetHost *host, ENetEvent *events, int count, int max) {
+	while (count < max && enet_host_check_events(host, &events[count]) > 0) {
+		count++;
+	}
+	return count;
+}
+
+// gotops_service_batch services the host once without waiting and stores
+// up to max events. It returns the number of events.
+static int gotops_service_batch(ENetHost *host, ENetEvent *events, int max) {
+	if (enet_host_service(host, &events[0], 0) <= 0) {
+		return 0;
+	}
+	return gotops_dispatch_batch(host, events, 1, max);
+}
+*/
+import "C"
+import (
+	"errors"
+	"unsafe"
+)
+
+// batchBuffers are the buffers of a host handed to C by SendBatch and
+// ServiceBatch, kept to not allocate them on every call
+type batchBuffers struct {
+	outgoing []C.gotops_outgoing
+	data     []byte
+	events   []C.ENetEvent
+}
+
+// SendBatch queues every packet of batch in a single call into C. Packets
+// to peers of other implementations, such as wrappers, are sent with their
+// SendBytes.
+func (host *enetHost) SendBatch(batch []Outgoing) error {
+	b := &host.batch
+	b.outgoing = b.outgoing[:0]
+	// A byte more than needed, so that there is a first byte to point to.
+	b.data = append(b.data[:0], 0)
+	for _, out := range batch {
+		peer, ok := out.Peer.(enetPeer)
+		if !ok {
+			if err := out.Peer.SendBytes(out.Data, out.Channel, out.Flags&^PacketFlagNoAllocate); err != nil {
+				return err
+			}
+			continue
+		}
+		b.outgoing = append(b.outgoing, C.gotops_outgoing{
+			peer:    peer.cPeer,
+			offset:  C.size_t(len(b.data)),
+			length:  C.size_t(len(out.Data)),
+			flags:   C.enet_uint32(out.Flags &^ PacketFlagNoAllocate),
+			channel: C.enet_uint8(out.Channel),
+		})
+		b.data = append(b.data, out.Data...)
+	}
+	if len(b.outgoing) == 0 {
+		return nil
+	}
+
+	created := C.gotops_send_batch(&b.outgoing[0], C.size_t(len(b.outgoing)), (*C.enet_uint8)(unsafe.Pointer(&b.data[0])))
+	if int(created) != len(b.outgoing) {
+		return errors.New("unable to create packet")
+	}
+	return nil
+}
+
+// ServiceBatch services the host once and returns up to max events in a
+// single call into C
+func (host *enetHost) ServiceBatch(max int) []Event {
+	if max <= 0 {
+		return nil
+	}
+	b := &host.batch
+	if cap(b.events) < max {
+		b.events = make([]C.ENetEvent, max)
+	}
+	events := b.events[:max]
+
+	count := C.gotops_service_batch(host.cHost, &events[0], C.int(max))
+	if count == 0 {
+		return nil
+	}
+
+	ret := make([]Event, count)
+	for i := range ret {
+		ret[i] = host.wrap(&enetEvent{cEvent: events[i]})
+	}
+	return ret
+}
@@ -0,0 +1,218 @@
+package enet_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	enet "github.com/eikarna/gotops"
+)
+
+// wrappedPeer is a peer of another implementation, counting the packets
+// sent through it
+type wrappedPeer struct {
+	enet.Peer
+	sent int
+}
+
+func (peer *wrappedPeer) SendBytes(data []byte, channel uint8, flags enet.PacketFlags) error {
+	peer.sent++
+	return peer.Peer.SendBytes(data, channel, flags)
+}
+
+func TestSendBatch(t *testing.T) {
+	server, clients, serverPeers, _ := connectLoopback(t, 3)
+	wrapped := &wrappedPeer{Peer: serverPeers[0]}
+
+	const count = 5
+	var batch []enet.Outgoing
+	for i := 0; i < count; i++ {
+		for j, peer := range serverPeers {
+			if j == 0 {
+				peer = wrapped
+			}
+			batch = append(batch, enet.Outgoing{
+				Peer:  peer,
+				Data:  []byte(fmt.Sprintf("packet %d", i)),
+				Flags: enet.PacketFlagReliable | enet.PacketFlagNoAllocate,
+			})
+		}
+	}
+	if err := server.SendBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+	// The data was copied.
+	for _, out := range batch {
+		copy(out.Data, "overwritten")
+	}
+	if wrapped.sent != count {
+		t.Errorf("%d packets sent through the wrapped peer, want %d", wrapped.sent, count)
+	}
+	if err := server.SendBatch(nil); err != nil {
+		t.Errorf("sending an empty batch: %v", err)
+	}
+
+	for c, client := range clients {
+		deadline := time.Now().Add(5 * time.Second)
+		for i := 0; i < count; {
+			if time.Now().After(deadline) {
+				t.Fatalf("client %d received %d packets", c, i)
+			}
+			server.Service(0)
+			ev := client.Service(1)
+			if ev.GetType() != enet.EventReceive {
+				continue
+			}
+			if got, want := string(ev.GetPacket().GetData()), fmt.Sprintf("packet %d", i); got != want {
+				t.Errorf("client %d received %q, want %q", c, got, want)
+			}
+			ev.GetPacket().Destroy()
+			i++
+		}
+	}
+}
+
+func TestServiceBatch(t *testing.T) {
+	server, clients, _, clientPeers := connectLoopback(t, 1)
+	if events := server.ServiceBatch(0); events != nil {
+		t.Errorf("%d events serviced with a maximum of 0", len(events))
+	}
+
+	const count, max = 10, 4
+	for i := 0; i < count; i++ {
+		clientPeers[0].SendBytes([]byte{byte(i)}, 0, enet.PacketFlagReliable)
+	}
+	clients[0].Service(0)
+
+	received := 0
+	deadline := time.Now().Add(5 * time.Second)
+	for received < count {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d of %d packets received", received, count)
+		}
+		clients[0].Service(0)
+		events := server.ServiceBatch(max)
+		if len(events) > max {
+			t.Fatalf("%d events serviced with a maximum of %d", len(events), max)
+		}
+		for _, ev := range events {
+			if ev.GetType() != enet.EventReceive {
+				continue
+			}
+			if data := ev.GetPacket().GetData(); len(data) != 1 || int(data[0]) != received {
+				t.Errorf("received %v, want [%d]", data, received)
+			}
+			ev.GetPacket().Destroy()
+			received++
+		}
+		if len(events) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func BenchmarkSendBatch(b *testing.B) {
+	for _, count := range []int{1, 32} {
+		b.Run(fmt.Sprintf("each/%dpeers", count), func(b *testing.B) {
+			benchmarkSend(b, count, func(server enet.Host, batch []enet.Outgoing) {
+				for _, out := range batch {
+					out.Peer.SendBytes(out.Data, out.Channel, out.Flags)
+				}
+			})
+		})
+		b.Run(fmt.Sprintf("batch/%dpeers", count), func(b *testing.B) {
+			benchmarkSend(b, count, func(server enet.Host, batch []enet.Outgoing) {
+				server.SendBatch(batch)
+			})
+		})
+	}
+}
+
+// benchmarkSend measures sending 64 packets of 256 bytes to each of count
+// peers with send
+func benchmarkSend(b *testing.B, count int, send func(server enet.Host, batch []enet.Outgoing)) {
+	server, clients, serverPeers, _ := connectLoopback(b, count)
+	data := make([]byte, 256)
+	var batch []enet.Outgoing
+	for i := 0; i < 64; i++ {
+		for _, peer := range serverPeers {
+			batch = append(batch, enet.Outgoing{Peer: peer, Data: data})
+		}
+	}
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(batch) * len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		send(server, batch)
+		drain(server)
+		for _, client := range clients {
+			drain(client)
+		}
+	}
+}
+
+func BenchmarkServiceBatch(b *testing.B) {
+	b.Run("each", func(b *testing.B) {
+		benchmarkReceive(b, func(server enet.Host) int {
+			n := 0
+			for {
+				ev := server.Service(0)
+				switch ev.GetType() {
+				case enet.EventNone:
+					return n
+				case enet.EventReceive:
+					ev.GetPacket().Destroy()
+					n++
+				}
+			}
+		})
+	})
+	b.Run("batch", func(b *testing.B) {
+		benchmarkReceive(b, func(server enet.Host) int {
+			n := 0
+			for {
+				events := server.ServiceBatch(64)
+				if events == nil {
+					return n
+				}
+				for _, ev := range events {
+					if ev.GetType() == enet.EventReceive {
+						ev.GetPacket().Destroy()
+						n++
+					}
+				}
+			}
+		})
+	})
+}
+
+// benchmarkReceive measures receiving 64 packets with receive, which
+// returns the number of packets it received
+func benchmarkReceive(b *testing.B, receive func(server enet.Host) int) {
+	server, clients, _, peers := connectLoopback(b, 1)
+	data := make([]byte, 64)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 64; j++ {
+			peers[0].SendBytes(data, 0, enet.PacketFlagReliable)
+		}
+		clients[0].Service(0)
+		deadline := time.Now().Add(5 * time.Second)
+		for n := 0; n < 64; {
+			if time.Now().After(deadline) {
+				b.Fatalf("%d of 64 packets received", n)
+			}
+			received := receive(server)
+			if received == 0 {
+				// Block, so that the socket readers of the pure-Go
+				// backend get to run.
+				time.Sleep(10 * time.Microsecond)
+			}
+			n += received
+			clients[0].Service(0)
+		}
+	}
+}
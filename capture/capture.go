@@ -0,0 +1,286 @@
+// Package capture records the traffic of an enet Host to a compact binary
+// file and replays it against a server to reproduce bugs.
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Kind is the type of a captured record
+type Kind uint8
+
+const (
+	// KindConnect is recorded when a peer has connected
+	KindConnect Kind = iota + 1
+
+	// KindDisconnect is recorded when a peer has disconnected
+	KindDisconnect
+
+	// KindReceive is recorded when the host received a packet from a peer
+	KindReceive
+
+	// KindSend is recorded when the host sent a packet to a peer
+	KindSend
+
+	// KindBroadcast is recorded when the host sent a packet to all connected peers
+	KindBroadcast
+)
+
+// String returns the name of the kind
+func (kind Kind) String() string {
+	switch kind {
+	case KindConnect:
+		return "connect"
+	case KindDisconnect:
+		return "disconnect"
+	case KindReceive:
+		return "receive"
+	case KindSend:
+		return "send"
+	case KindBroadcast:
+		return "broadcast"
+	default:
+		return fmt.Sprintf("kind(%d)", uint8(kind))
+	}
+}
+
+// MaxPayloadSize is the largest payload a Reader will accept for a single record
+const MaxPayloadSize = 32 * 1024 * 1024
+
+// maxAddressSize is the largest address string a Reader will accept
+const maxAddressSize = 256
+
+// magic identifies a capture file, followed by the format version
+var magic = [6]byte{'G', 'T', 'C', 'A', 'P', 1}
+
+// ErrBadMagic is returned when reading something that isn't a capture file
+var ErrBadMagic = errors.New("capture: not a capture file")
+
+// Record is a single captured event
+type Record struct {
+	// Kind of the record
+	Kind Kind
+
+	// Time elapsed since the capture was started
+	Time time.Duration
+
+	// PeerID is the connect ID of the peer, or 0 for broadcasts
+	PeerID uint32
+
+	// ChannelID the packet was sent or received on
+	ChannelID uint8
+
+	// Flags of the packet
+	Flags uint32
+
+	// Data is the user supplied data of a connect or disconnect
+	Data uint32
+
+	// Address and Port of the peer, only set on connect
+	Address string
+	Port    uint16
+
+	// Payload of the packet, only set on receive, send and broadcast
+	Payload []byte
+}
+
+// Writer writes records to a capture file. It is safe for concurrent use.
+type Writer struct {
+	mu    sync.Mutex
+	w     *bufio.Writer
+	start time.Time
+	buf   []byte
+}
+
+// NewWriter writes the capture header to w and returns a Writer for it
+func NewWriter(w io.Writer) (*Writer, error) {
+	ret := &Writer{
+		w:     bufio.NewWriter(w),
+		start: time.Now(),
+	}
+
+	var header [len(magic) + 8]byte
+	copy(header[:], magic[:])
+	binary.LittleEndian.PutUint64(header[len(magic):], uint64(ret.start.UnixNano()))
+	if _, err := ret.w.Write(header[:]); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Start returns the time the capture was started
+func (writer *Writer) Start() time.Time {
+	return writer.start
+}
+
+// Write appends a record to the capture. If the record's Time is zero, the
+// time elapsed since the capture was started is used instead.
+func (writer *Writer) Write(rec Record) error {
+	writer.mu.Lock()
+	defer writer.mu.Unlock()
+
+	if rec.Time == 0 {
+		rec.Time = time.Since(writer.start)
+	}
+
+	b := writer.buf[:0]
+	b = append(b, byte(rec.Kind))
+	b = binary.AppendUvarint(b, uint64(rec.Time/time.Microsecond))
+	b = binary.AppendUvarint(b, uint64(rec.PeerID))
+	b = append(b, rec.ChannelID)
+	b = binary.AppendUvarint(b, uint64(rec.Flags))
+	b = binary.AppendUvarint(b, uint64(rec.Data))
+	b = binary.AppendUvarint(b, uint64(len(rec.Address)))
+	b = append(b, rec.Address...)
+	b = binary.AppendUvarint(b, uint64(rec.Port))
+	b = binary.AppendUvarint(b, uint64(len(rec.Payload)))
+	b = append(b, rec.Payload...)
+	writer.buf = b
+
+	_, err := writer.w.Write(b)
+	return err
+}
+
+// Flush writes any buffered records to the underlying writer
+func (writer *Writer) Flush() error {
+	writer.mu.Lock()
+	defer writer.mu.Unlock()
+	return writer.w.Flush()
+}
+
+// Reader reads records from a capture file
+type Reader struct {
+	r     *bufio.Reader
+	start time.Time
+}
+
+// NewReader reads the capture header from r and returns a Reader for it
+func NewReader(r io.Reader) (*Reader, error) {
+	ret := &Reader{
+		r: bufio.NewReader(r),
+	}
+
+	var header [len(magic) + 8]byte
+	if _, err := io.ReadFull(ret.r, header[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrBadMagic
+		}
+		return nil, err
+	}
+	if [len(magic)]byte(header[:len(magic)]) != magic {
+		return nil, ErrBadMagic
+	}
+	ret.start = time.Unix(0, int64(binary.LittleEndian.Uint64(header[len(magic):])))
+	return ret, nil
+}
+
+// Start returns the time the capture was started
+func (reader *Reader) Start() time.Time {
+	return reader.start
+}
+
+// Next returns the next record in the capture, or io.EOF once all records
+// have been read. Corrupt records make it return an error, and the memory
+// of a payload grows with the bytes actually read rather than with the
+// length the record announces.
+func (reader *Reader) Next() (Record, error) {
+	var rec Record
+
+	kind, err := reader.r.ReadByte()
+	if err != nil {
+		return rec, err
+	}
+	rec.Kind = Kind(kind)
+
+	micros, err := reader.uvarint(1<<63 - 1)
+	if err != nil {
+		return rec, err
+	}
+	rec.Time = time.Duration(micros) * time.Microsecond
+
+	peerID, err := reader.uvarint(1<<32 - 1)
+	if err != nil {
+		return rec, err
+	}
+	rec.PeerID = uint32(peerID)
+
+	if rec.ChannelID, err = reader.r.ReadByte(); err != nil {
+		return rec, unexpected(err)
+	}
+
+	flags, err := reader.uvarint(1<<32 - 1)
+	if err != nil {
+		return rec, err
+	}
+	rec.Flags = uint32(flags)
+
+	data, err := reader.uvarint(1<<32 - 1)
+	if err != nil {
+		return rec, err
+	}
+	rec.Data = uint32(data)
+
+	address, err := reader.bytes(maxAddressSize)
+	if err != nil {
+		return rec, err
+	}
+	rec.Address = string(address)
+
+	port, err := reader.uvarint(1<<16 - 1)
+	if err != nil {
+		return rec, err
+	}
+	rec.Port = uint16(port)
+
+	if rec.Payload, err = reader.bytes(MaxPayloadSize); err != nil {
+		return rec, err
+	}
+	return rec, nil
+}
+
+// uvarint reads a varint that must not exceed max
+func (reader *Reader) uvarint(max uint64) (uint64, error) {
+	v, err := binary.ReadUvarint(reader.r)
+	if err != nil {
+		return 0, unexpected(err)
+	}
+	if v > max {
+		return 0, fmt.Errorf("capture: value %d out of range", v)
+	}
+	return v, nil
+}
+
+// bytes reads a length prefixed byte slice of at most max bytes
+func (reader *Reader) bytes(max int) ([]byte, error) {
+	n, err := reader.uvarint(uint64(max))
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	// Grow the slice as data arrives rather than trusting the length, so
+	// that a truncated capture can't make us allocate max bytes.
+	b, err := io.ReadAll(io.LimitReader(reader.r, int64(n)))
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(b)) < n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return b, nil
+}
+
+// unexpected turns an EOF in the middle of a record into io.ErrUnexpectedEOF
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
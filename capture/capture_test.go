@@ -0,0 +1,332 @@
+package capture_test
+
+import (
+	"bytes"
+	"io"
+	"iter"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+
+	enet "github.com/eikarna/gotops"
+	"github.com/eikarna/gotops/capture"
+)
+
+func TestWriterReader(t *testing.T) {
+	records := []capture.Record{
+		{Kind: capture.KindConnect, Time: time.Millisecond, PeerID: 42, Data: 7, Address: "127.0.0.1", Port: 17091},
+		{Kind: capture.KindReceive, Time: 2 * time.Millisecond, PeerID: 42, ChannelID: 1, Flags: uint32(enet.PacketFlagReliable), Payload: []byte{2, 0, 0, 0, 'h', 'i', 0}},
+		{Kind: capture.KindSend, Time: 3 * time.Millisecond, PeerID: 42, Payload: []byte("pong")},
+		{Kind: capture.KindBroadcast, Time: 4 * time.Millisecond, Payload: []byte("all")},
+		{Kind: capture.KindDisconnect, Time: 5 * time.Millisecond, PeerID: 42, Data: 3},
+	}
+
+	var buf bytes.Buffer
+	w, err := capture.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range records {
+		if err := w.Write(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := capture.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Start().Equal(w.Start().Round(0)) {
+		t.Fatalf("expected start %v, got %v", w.Start(), r.Start())
+	}
+	for i, expected := range records {
+		actual, err := r.Next()
+		if err != nil {
+			t.Fatalf("record %d: %s", i, err)
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Fatalf("record %d: expected %+v, got %+v", i, expected, actual)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Fatalf("expected io.EOF after last record, got %v", err)
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	t.Run("bad-magic", func(t *testing.T) {
+		if _, err := capture.NewReader(bytes.NewReader([]byte("not a capture file"))); err != capture.ErrBadMagic {
+			t.Fatalf("expected ErrBadMagic, got %v", err)
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		var buf bytes.Buffer
+		w, _ := capture.NewWriter(&buf)
+		w.Write(capture.Record{Kind: capture.KindReceive, Payload: []byte("truncated payload")})
+		w.Flush()
+
+		r, err := capture.NewReader(bytes.NewReader(buf.Bytes()[:buf.Len()-4]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.Next(); err != io.ErrUnexpectedEOF {
+			t.Fatalf("expected io.ErrUnexpectedEOF, got %v", err)
+		}
+	})
+}
+
+func TestRecorder(t *testing.T) {
+	peer := &fakePeer{connectID: 1234}
+	host := &fakeHost{events: []enet.Event{
+		&fakeEvent{typ: enet.EventConnect, peer: peer, data: 9},
+		&fakeEvent{typ: enet.EventReceive, peer: peer, channel: 2, packet: &fakePacket{data: []byte("ping")}},
+		&fakeEvent{typ: enet.EventDisconnect, peer: peer},
+	}}
+
+	var buf bytes.Buffer
+	w, _ := capture.NewWriter(&buf)
+	rec := capture.NewRecorder(host, w)
+
+	rec.Service(0)
+	ev := rec.Service(0)
+	if err := ev.GetPeer().SendPacket(&fakePacket{data: []byte("pong")}, 2); err != nil {
+		t.Fatal(err)
+	}
+	rec.Service(0)
+	if ev := rec.Service(0); ev.GetType() != enet.EventNone {
+		t.Fatalf("expected no more events, got %v", ev.GetType())
+	}
+	w.Flush()
+
+	if len(peer.sent) != 1 || string(peer.sent[0]) != "pong" {
+		t.Fatalf("expected packet to be passed through to the peer, got %q", peer.sent)
+	}
+
+	r, _ := capture.NewReader(&buf)
+	expected := []capture.Kind{capture.KindConnect, capture.KindReceive, capture.KindSend, capture.KindDisconnect}
+	for _, kind := range expected {
+		actual, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual.Kind != kind || actual.PeerID != 1234 {
+			t.Fatalf("expected %s record of peer 1234, got %s of peer %d", kind, actual.Kind, actual.PeerID)
+		}
+	}
+}
+
+func TestRecorderBatch(t *testing.T) {
+	peer := &fakePeer{connectID: 1234}
+	host := &fakeHost{events: []enet.Event{
+		&fakeEvent{typ: enet.EventConnect, peer: peer},
+		&fakeEvent{typ: enet.EventReceive, peer: peer, packet: &fakePacket{data: []byte("ping")}},
+	}}
+
+	var buf bytes.Buffer
+	w, _ := capture.NewWriter(&buf)
+	rec := capture.NewRecorder(host, w)
+
+	events := rec.ServiceBatch(8)
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	err := rec.SendBatch([]enet.Outgoing{
+		{Peer: events[1].GetPeer(), Data: []byte("pong")},
+		{Peer: peer, Data: []byte("pong again")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+
+	if len(host.batch) != 2 || host.batch[0].Peer != enet.Peer(peer) || host.batch[1].Peer != enet.Peer(peer) {
+		t.Fatalf("expected the batch to be passed through with unwrapped peers, got %+v", host.batch)
+	}
+
+	r, _ := capture.NewReader(&buf)
+	expected := []capture.Kind{capture.KindConnect, capture.KindReceive, capture.KindSend, capture.KindSend}
+	for _, kind := range expected {
+		actual, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual.Kind != kind || actual.PeerID != 1234 {
+			t.Fatalf("expected %s record of peer 1234, got %s of peer %d", kind, actual.Kind, actual.PeerID)
+		}
+	}
+}
+
+func TestRecorderPeerIdentity(t *testing.T) {
+	peer := &fakePeer{connectID: 1234}
+	host := &fakeHost{
+		peers: []enet.Peer{peer},
+		events: []enet.Event{
+			&fakeEvent{typ: enet.EventConnect, peer: peer},
+			&fakeEvent{typ: enet.EventReceive, peer: peer, packet: &fakePacket{data: []byte("ping")}},
+			&fakeEvent{typ: enet.EventDisconnect, peer: peer},
+			&fakeEvent{typ: enet.EventConnect, peer: peer},
+		},
+	}
+	w, _ := capture.NewWriter(io.Discard)
+	rec := capture.NewRecorder(host, w)
+
+	connected := rec.Service(0).GetPeer()
+	byAddress, _ := rec.PeerByAddress(&fakeAddress{})
+	same := map[string]enet.Peer{
+		"receive event":  rec.Service(0).GetPeer(),
+		"ConnectedPeers": rec.ConnectedPeers()[0],
+		"Peers":          rec.Peers(nil)[0],
+		"Peers filtered": rec.Peers(func(p enet.Peer) bool { return p == connected })[0],
+		"PeerByAddress":  byAddress,
+	}
+	for name, p := range same {
+		if p != connected {
+			t.Errorf("%s returned another peer than the connect event", name)
+		}
+	}
+	if err := rec.ConnectedPeers()[0].SendBytes([]byte("pong"), 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(peer.sent) != 1 {
+		t.Errorf("%d packets passed through to the peer, want 1", len(peer.sent))
+	}
+
+	if rec.Service(0).GetPeer() != connected {
+		t.Error("disconnect event returned another peer than the connect event")
+	}
+	if rec.Service(0).GetPeer() == connected {
+		t.Error("a new connection returned the peer of the disconnected one")
+	}
+}
+
+type fakeHost struct {
+	enet.Host
+	events []enet.Event
+	batch  []enet.Outgoing
+	peers  []enet.Peer
+}
+
+func (host *fakeHost) ConnectedPeers() []enet.Peer {
+	return append([]enet.Peer{}, host.peers...)
+}
+
+func (host *fakeHost) AllPeers(filter func(enet.Peer) bool) iter.Seq[enet.Peer] {
+	return func(yield func(enet.Peer) bool) {
+		for _, peer := range host.peers {
+			if (filter == nil || filter(peer)) && !yield(peer) {
+				return
+			}
+		}
+	}
+}
+
+func (host *fakeHost) PeerByAddress(addr enet.Address) (enet.Peer, bool) {
+	if len(host.peers) == 0 {
+		return nil, false
+	}
+	return host.peers[0], true
+}
+
+func (host *fakeHost) ServiceBatch(max int) []enet.Event {
+	if max > len(host.events) {
+		max = len(host.events)
+	}
+	ret := host.events[:max]
+	host.events = host.events[max:]
+	return ret
+}
+
+func (host *fakeHost) SendBatch(batch []enet.Outgoing) error {
+	host.batch = append(host.batch, batch...)
+	return nil
+}
+
+func (host *fakeHost) Service(timeout uint32) enet.Event {
+	if len(host.events) == 0 {
+		return &fakeEvent{typ: enet.EventNone}
+	}
+	ev := host.events[0]
+	host.events = host.events[1:]
+	return ev
+}
+
+type fakeEvent struct {
+	typ     enet.EventType
+	peer    enet.Peer
+	channel uint8
+	data    uint32
+	packet  enet.Packet
+}
+
+func (ev *fakeEvent) GetType() enet.EventType { return ev.typ }
+func (ev *fakeEvent) GetPeer() enet.Peer      { return ev.peer }
+func (ev *fakeEvent) GetChannelID() uint8     { return ev.channel }
+func (ev *fakeEvent) GetData() uint32         { return ev.data }
+func (ev *fakeEvent) GetPacket() enet.Packet  { return ev.packet }
+func (ev *fakeEvent) DisconnectReason() enet.DisconnectReason {
+	return enet.DisconnectReason(ev.data)
+}
+func (ev *fakeEvent) TimedOut() bool { return false }
+
+type fakePeer struct {
+	enet.Peer
+	connectID uint32
+	sent      [][]byte
+}
+
+func (peer *fakePeer) GetConnectID() uint32 { return peer.connectID }
+func (peer *fakePeer) GetAddress() enet.Address {
+	return &fakeAddress{}
+}
+func (peer *fakePeer) SendPacket(packet enet.Packet, channel uint8) error {
+	peer.sent = append(peer.sent, packet.GetData())
+	return nil
+}
+
+type fakeAddress struct {
+	enet.Address
+}
+
+func (addr *fakeAddress) String() string  { return "127.0.0.1:17091" }
+func (addr *fakeAddress) GetPort() uint16 { return 17091 }
+func (addr *fakeAddress) AddrPort() netip.AddrPort {
+	return netip.MustParseAddrPort("127.0.0.1:17091")
+}
+
+type fakePacket struct {
+	data []byte
+}
+
+func (packet *fakePacket) Destroy()                   {}
+func (packet *fakePacket) GetData() []byte            { return packet.data }
+func (packet *fakePacket) GetFlags() enet.PacketFlags { return enet.PacketFlagReliable }
+
+func FuzzReader(f *testing.F) {
+	var buf bytes.Buffer
+	w, _ := capture.NewWriter(&buf)
+	w.Write(capture.Record{Kind: capture.KindConnect, PeerID: 1, Address: "127.0.0.1", Port: 17091})
+	w.Write(capture.Record{Kind: capture.KindReceive, PeerID: 1, ChannelID: 2, Flags: 1, Payload: []byte("payload")})
+	w.Flush()
+	f.Add(buf.Bytes())
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r, err := capture.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		// Every record takes at least a byte, so reading ends.
+		for i := 0; ; i++ {
+			rec, err := r.Next()
+			if err != nil {
+				break
+			}
+			if i >= len(data) || len(rec.Payload) > len(data) {
+				t.Fatalf("record %d of %d bytes read from %d bytes", i, len(rec.Payload), len(data))
+			}
+		}
+	})
+}
@@ -0,0 +1,280 @@
+package capture
+
+import (
+	enet "github.com/eikarna/gotops"
+	"iter"
+)
+
+// Recorder is a Host that records every connect, disconnect and packet going
+// through it to a capture Writer. Packets sent through the peers returned by
+// the recorder are recorded as well. The recorder returns the same peer
+// every time for a peer of the host, until it disconnects, so that peers
+// can be compared and used as map keys.
+type Recorder struct {
+	enet.Host
+	w *Writer
+
+	// connectIDs remembers the connect ID of every connected peer, as peers
+	// are reset by the time their disconnect event is returned.
+	connectIDs map[enet.Peer]uint32
+
+	// peers holds the recording peer returned for every peer of the host
+	peers map[enet.Peer]*recordedPeer
+
+	// OnError is called when a record couldn't be written. The default is to
+	// silently drop the record.
+	OnError func(err error)
+}
+
+// NewRecorder wraps host so that its traffic is recorded to w
+func NewRecorder(host enet.Host, w *Writer) *Recorder {
+	return &Recorder{
+		Host:       host,
+		w:          w,
+		connectIDs: make(map[enet.Peer]uint32),
+		peers:      make(map[enet.Peer]*recordedPeer),
+	}
+}
+
+// Writer returns the capture writer of the recorder
+func (rec *Recorder) Writer() *Writer {
+	return rec.w
+}
+
+// wrap returns the recording peer of a peer of the host
+func (rec *Recorder) wrap(peer enet.Peer) *recordedPeer {
+	wrapped, ok := rec.peers[peer]
+	if !ok {
+		wrapped = &recordedPeer{
+			Peer: peer,
+			rec:  rec,
+		}
+		rec.peers[peer] = wrapped
+	}
+	return wrapped
+}
+
+// record writes a record, reporting errors to OnError
+func (rec *Recorder) record(r Record) {
+	if err := rec.w.Write(r); err != nil && rec.OnError != nil {
+		rec.OnError(err)
+	}
+}
+
+// Service services the host and records the returned event
+func (rec *Recorder) Service(timeout uint32) enet.Event {
+	return rec.recordEvent(rec.Host.Service(timeout))
+}
+
+// ServiceBatch services the host and records the returned events
+func (rec *Recorder) ServiceBatch(max int) []enet.Event {
+	events := rec.Host.ServiceBatch(max)
+	for i, ev := range events {
+		events[i] = rec.recordEvent(ev)
+	}
+	return events
+}
+
+// recordEvent records a serviced event and wraps it so that packets sent to
+// its peer are recorded too
+func (rec *Recorder) recordEvent(ev enet.Event) enet.Event {
+	switch ev.GetType() {
+	case enet.EventNone:
+		return ev
+
+	case enet.EventConnect:
+		peer := ev.GetPeer()
+		addr := peer.GetAddress()
+		rec.connectIDs[peer] = peer.GetConnectID()
+		rec.record(Record{
+			Kind:    KindConnect,
+			PeerID:  peer.GetConnectID(),
+			Data:    ev.GetData(),
+			Address: addr.AddrPort().Addr().String(),
+			Port:    addr.GetPort(),
+		})
+
+	case enet.EventDisconnect:
+		peer := ev.GetPeer()
+		connectID, ok := rec.connectIDs[peer]
+		if !ok {
+			connectID = peer.GetConnectID()
+		}
+		delete(rec.connectIDs, peer)
+		rec.record(Record{
+			Kind:   KindDisconnect,
+			PeerID: connectID,
+			Data:   ev.GetData(),
+		})
+
+		// The event still returns the peer the application knows, a new
+		// connection in its slot gets a new one.
+		wrapped := rec.wrap(peer)
+		delete(rec.peers, peer)
+		return &recordedEvent{
+			Event: ev,
+			peer:  wrapped,
+		}
+
+	case enet.EventReceive:
+		packet := ev.GetPacket()
+		rec.record(Record{
+			Kind:      KindReceive,
+			PeerID:    ev.GetPeer().GetConnectID(),
+			ChannelID: ev.GetChannelID(),
+			Flags:     uint32(packet.GetFlags()),
+			Payload:   packet.GetData(),
+		})
+	}
+
+	return &recordedEvent{
+		Event: ev,
+		peer:  rec.wrap(ev.GetPeer()),
+	}
+}
+
+// Connect connects to a foreign host, recording packets sent to it
+func (rec *Recorder) Connect(addr enet.Address, channelCount int, data uint32) (enet.Peer, error) {
+	peer, err := rec.Host.Connect(addr, channelCount, data)
+	if err != nil {
+		return nil, err
+	}
+	return rec.wrap(peer), nil
+}
+
+// ConnectedPeers returns the connected peers, recording packets sent to
+// them
+func (rec *Recorder) ConnectedPeers() []enet.Peer {
+	peers := rec.Host.ConnectedPeers()
+	for i, peer := range peers {
+		peers[i] = rec.wrap(peer)
+	}
+	return peers
+}
+
+// Peers returns the peers selected by filter, or every peer that isn't
+// disconnected, recording packets sent to them
+func (rec *Recorder) Peers(filter func(enet.Peer) bool) []enet.Peer {
+	return rec.AppendPeers(nil, filter)
+}
+
+// AppendPeers appends the peers selected by filter, or every peer that
+// isn't disconnected, to dst, recording packets sent to them
+func (rec *Recorder) AppendPeers(dst []enet.Peer, filter func(enet.Peer) bool) []enet.Peer {
+	for peer := range rec.AllPeers(filter) {
+		dst = append(dst, peer)
+	}
+	return dst
+}
+
+// AllPeers returns an iterator over the peers selected by filter, or every
+// peer that isn't disconnected, recording packets sent to them. filter is
+// passed the recording peers.
+func (rec *Recorder) AllPeers(filter func(enet.Peer) bool) iter.Seq[enet.Peer] {
+	return func(yield func(enet.Peer) bool) {
+		for peer := range rec.Host.AllPeers(nil) {
+			wrapped := rec.wrap(peer)
+			if filter != nil && !filter(wrapped) {
+				continue
+			}
+			if !yield(wrapped) {
+				return
+			}
+		}
+	}
+}
+
+// PeerByAddress returns the peer at the given address, if any, recording
+// packets sent to it
+func (rec *Recorder) PeerByAddress(addr enet.Address) (enet.Peer, bool) {
+	peer, ok := rec.Host.PeerByAddress(addr)
+	if !ok {
+		return nil, false
+	}
+	return rec.wrap(peer), true
+}
+
+// SendBatch records and sends a batch of packets. Peers returned by the
+// recorder are unwrapped, so that the batch still takes the fast path of
+// the host and each packet is recorded once.
+func (rec *Recorder) SendBatch(batch []enet.Outgoing) error {
+	unwrapped := make([]enet.Outgoing, len(batch))
+	for i, out := range batch {
+		if peer, ok := out.Peer.(*recordedPeer); ok {
+			out.Peer = peer.Peer
+		}
+		rec.record(Record{
+			Kind:      KindSend,
+			PeerID:    out.Peer.GetConnectID(),
+			ChannelID: out.Channel,
+			Flags:     uint32(out.Flags),
+			Payload:   out.Data,
+		})
+		unwrapped[i] = out
+	}
+	return rec.Host.SendBatch(unwrapped)
+}
+
+// BroadcastBytes records and sends a byte array to all connected peers
+func (rec *Recorder) BroadcastBytes(data []byte, channel uint8, flags enet.PacketFlags) error {
+	packet, err := enet.NewPacket(data, flags)
+	if err != nil {
+		return err
+	}
+	return rec.BroadcastPacket(packet, channel)
+}
+
+// BroadcastPacket records and sends a packet to all connected peers
+func (rec *Recorder) BroadcastPacket(packet enet.Packet, channel uint8) error {
+	rec.record(Record{
+		Kind:      KindBroadcast,
+		ChannelID: channel,
+		Flags:     uint32(packet.GetFlags()),
+		Payload:   packet.GetData(),
+	})
+	return rec.Host.BroadcastPacket(packet, channel)
+}
+
+// BroadcastString records and sends a string to all connected peers
+func (rec *Recorder) BroadcastString(str string, channel uint8, flags enet.PacketFlags) error {
+	return rec.BroadcastBytes([]byte(str), channel, flags)
+}
+
+// recordedEvent is an event whose peer records the packets sent to it
+type recordedEvent struct {
+	enet.Event
+	peer *recordedPeer
+}
+
+func (event *recordedEvent) GetPeer() enet.Peer {
+	return event.peer
+}
+
+// recordedPeer is a peer that records the packets sent to it
+type recordedPeer struct {
+	enet.Peer
+	rec *Recorder
+}
+
+func (peer *recordedPeer) SendBytes(data []byte, channel uint8, flags enet.PacketFlags) error {
+	packet, err := enet.NewPacket(data, flags)
+	if err != nil {
+		return err
+	}
+	return peer.SendPacket(packet, channel)
+}
+
+func (peer *recordedPeer) SendString(str string, channel uint8, flags enet.PacketFlags) error {
+	return peer.SendBytes([]byte(str), channel, flags)
+}
+
+func (peer *recordedPeer) SendPacket(packet enet.Packet, channel uint8) error {
+	peer.rec.record(Record{
+		Kind:      KindSend,
+		PeerID:    peer.GetConnectID(),
+		ChannelID: channel,
+		Flags:     uint32(packet.GetFlags()),
+		Payload:   packet.GetData(),
+	})
+	return peer.Peer.SendPacket(packet, channel)
+}
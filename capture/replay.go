@@ -0,0 +1,182 @@
+package capture
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	enet "github.com/eikarna/gotops"
+)
+
+// Replayer feeds a capture into a server through a local client host. Every
+// peer that connected in the capture gets its own connection, and the packets
+// the server received from it are sent again in the order they were captured.
+type Replayer struct {
+	// Client is the host used to connect to the server. It must have room for
+	// as many peers as are connected at the same time in the capture.
+	Client enet.Host
+
+	// Server is the address of the server to replay the capture against
+	Server enet.Address
+
+	// ChannelCount is the number of channels to connect with, defaults to 1
+	ChannelCount int
+
+	// Speed scales the time between records; 1 replays in real time, 2 twice
+	// as fast. When 0, records are replayed as fast as possible.
+	Speed float64
+
+	// Reliable forces every packet to be sent reliably, so that the server
+	// receives exactly what was captured even on a lossy link.
+	Reliable bool
+
+	// ConnectTimeout is how long to wait for a connection to be established,
+	// defaults to 5 seconds
+	ConnectTimeout time.Duration
+
+	// OnEvent is called for every event the client receives while replaying.
+	// Packets are destroyed after OnEvent returns.
+	OnEvent func(ev enet.Event)
+}
+
+// ErrConnectTimeout is returned when the server didn't accept a connection in time
+var ErrConnectTimeout = errors.New("capture: timed out connecting to server")
+
+// Replay replays all records read from r until io.EOF, then disconnects any
+// peers that were still connected at the end of the capture.
+func (rp *Replayer) Replay(ctx context.Context, r *Reader) error {
+	peers := make(map[uint32]enet.Peer)
+	defer func() {
+		for _, peer := range peers {
+			peer.DisconnectNow(0)
+		}
+	}()
+
+	start := time.Now()
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		if rp.Speed > 0 {
+			at := start.Add(time.Duration(float64(rec.Time) / rp.Speed))
+			if err := rp.serviceUntil(ctx, at); err != nil {
+				return err
+			}
+		} else if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		switch rec.Kind {
+		case KindConnect:
+			peer, err := rp.connect(ctx, rec.Data)
+			if err != nil {
+				return fmt.Errorf("replaying connect of peer %d: %w", rec.PeerID, err)
+			}
+			peers[rec.PeerID] = peer
+
+		case KindReceive:
+			// Peers that connected before the capture started are skipped.
+			peer, ok := peers[rec.PeerID]
+			if !ok {
+				continue
+			}
+			flags := enet.PacketFlags(rec.Flags)
+			if rp.Reliable {
+				flags = enet.PacketFlagReliable
+			}
+			if err := peer.SendBytes(rec.Payload, rec.ChannelID, flags); err != nil {
+				return fmt.Errorf("replaying packet of peer %d: %w", rec.PeerID, err)
+			}
+
+		case KindDisconnect:
+			peer, ok := peers[rec.PeerID]
+			if !ok {
+				continue
+			}
+			peer.Disconnect(rec.Data)
+			delete(peers, rec.PeerID)
+		}
+
+		rp.service(0)
+	}
+
+	// Give the remaining packets a chance to be delivered.
+	return rp.serviceUntil(ctx, time.Now().Add(100*time.Millisecond))
+}
+
+// connect connects a new peer to the server and waits for it to be established
+func (rp *Replayer) connect(ctx context.Context, data uint32) (enet.Peer, error) {
+	channelCount := rp.ChannelCount
+	if channelCount <= 0 {
+		channelCount = 1
+	}
+	timeout := rp.ConnectTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	peer, err := rp.Client.Connect(rp.Server, channelCount, data)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		// Peers are reset by the time their disconnect event is returned, so
+		// match on the peer as well as on the connect ID.
+		ev := rp.service(10)
+		if ev == nil || (ev.GetPeer() != peer && ev.GetPeer().GetConnectID() != peer.GetConnectID()) {
+			continue
+		}
+		switch ev.GetType() {
+		case enet.EventConnect:
+			return peer, nil
+		case enet.EventDisconnect:
+			return nil, errors.New("capture: server refused connection")
+		}
+	}
+
+	peer.DisconnectNow(0)
+	return nil, ErrConnectTimeout
+}
+
+// serviceUntil services the client host until the given time
+func (rp *Replayer) serviceUntil(ctx context.Context, t time.Time) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		left := time.Until(t)
+		if left <= 0 {
+			return nil
+		}
+		if left > 10*time.Millisecond {
+			left = 10 * time.Millisecond
+		}
+		rp.service(uint32(left / time.Millisecond))
+	}
+}
+
+// service services the client host once, returning the event if there was one
+func (rp *Replayer) service(timeout uint32) enet.Event {
+	ev := rp.Client.Service(timeout)
+	if ev.GetType() == enet.EventNone {
+		return nil
+	}
+	if rp.OnEvent != nil {
+		rp.OnEvent(ev)
+	}
+	if ev.GetType() == enet.EventReceive {
+		ev.GetPacket().Destroy()
+	}
+	return ev
+}
@@ -0,0 +1,322 @@
+// Package client is a headless Growtopia client for load testing and
+// scripted tests of game servers. A Bot logs in, enters worlds, moves and
+// chats like the real client, answering pings and following redirects while
+// it waits on the server.
+//
+// A Bot is driven from a single goroutine: its methods service its own host,
+// and nothing happens between calls. Bots are independent, so running many
+// of them takes one goroutine each.
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"time"
+
+	enet "github.com/eikarna/gotops"
+	"github.com/eikarna/gotops/gamepacket"
+	"github.com/eikarna/gotops/world"
+)
+
+// Defaults of the login information
+const (
+	DefaultProtocol    = 209
+	DefaultGameVersion = 4.61
+)
+
+// DefaultTimeout is the time a Bot waits for the server by default
+const DefaultTimeout = 10 * time.Second
+
+// Errors returned by Bot methods
+var (
+	ErrTimeout      = errors.New("client: timed out waiting for the server")
+	ErrLoginFailed  = errors.New("client: login failed")
+	ErrJoinFailed   = errors.New("client: failed to enter world")
+	ErrNotInWorld   = errors.New("client: not in a world")
+	ErrDisconnected = errors.New("client: disconnected")
+)
+
+// Config is the identity of a bot and how it talks to the server
+type Config struct {
+	// Name is the name requested by a guest. TankIDName and TankIDPass log
+	// in with a GrowID instead.
+	Name       string
+	TankIDName string
+	TankIDPass string
+
+	// Protocol and GameVersion are sent in the login information. The
+	// defaults are DefaultProtocol and DefaultGameVersion.
+	Protocol    int
+	GameVersion float64
+
+	// Fields are added to the login information, replacing the generated
+	// fields of the same key
+	Fields gamepacket.Text
+
+	// NewPacket makes the host use the new packet header, which only the C
+	// backend implements
+	NewPacket bool
+
+	// Timeout is how long to wait for the server at each step, such as
+	// logging in or entering a world. The default is DefaultTimeout.
+	Timeout time.Duration
+}
+
+// Bot is a logged in client
+type Bot struct {
+	config Config
+
+	host enet.Host
+	peer enet.Peer
+
+	// state of the handshake, info being the login information sent on
+	// the hello
+	info     gamepacket.Text
+	loggedIn bool
+	lastLog  string
+	failure  string
+	redirect *redirection
+	closed   error
+
+	// state of the world, lastConsole being the last console message, which
+	// tells why entering a world failed
+	joining     bool
+	joinErr     error
+	spawned     bool
+	world       *world.World
+	netID       int32
+	x, y        float32
+	lastConsole string
+
+	pings int
+
+	// OnText is called for every text packet received once logged in
+	OnText func(typ gamepacket.MessageType, text gamepacket.Text)
+
+	// OnCall is called for every function call received, after the bot has
+	// handled it
+	OnCall func(call gamepacket.VariantList)
+
+	// OnTank is called for every tank packet received other than function
+	// calls, after the bot has handled it
+	OnTank func(tank *gamepacket.TankPacket)
+}
+
+// redirection is a pending OnSendToServer
+type redirection struct {
+	host   string
+	port   uint16
+	fields gamepacket.Text
+}
+
+// Dial connects to the server at addr and logs in. If the server redirects
+// the bot, it follows.
+func Dial(ctx context.Context, addr enet.Address, config Config) (*Bot, error) {
+	if config.Protocol == 0 {
+		config.Protocol = DefaultProtocol
+	}
+	if config.GameVersion == 0 {
+		config.GameVersion = DefaultGameVersion
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+
+	b := &Bot{config: config}
+	if err := b.login(ctx, addr, nil); err != nil {
+		b.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// login connects to addr with a new host and runs the login handshake,
+// adding extra to the login information
+func (b *Bot) login(ctx context.Context, addr enet.Address, extra gamepacket.Text) error {
+	if b.host != nil {
+		b.peer.DisconnectNow(0)
+		b.host.Destroy()
+	}
+	b.host, b.peer = nil, nil
+	b.loggedIn, b.failure, b.redirect, b.closed = false, "", nil, nil
+	b.leaveWorld()
+
+	addressType := enet.ENET_ADDRESS_TYPE_IPV4
+	if !addr.AddrPort().Addr().Unmap().Is4() {
+		addressType = enet.ENET_ADDRESS_TYPE_IPV6
+	}
+	host, err := enet.NewHost(addressType, nil, 1, 2, 0, 0)
+	if err != nil {
+		return err
+	}
+	host.UsingReceivedEvents(true)
+	host.UsingNewPacket(b.config.NewPacket)
+	b.host = host
+
+	b.peer, err = host.Connect(addr, 2, 0)
+	if err != nil {
+		return err
+	}
+	b.info = b.loginInfo()
+	for _, field := range extra {
+		b.info.Set(field.Key, field.Value)
+	}
+	err = b.until(ctx, func() bool { return b.loggedIn || b.failure != "" })
+	if err != nil {
+		return err
+	}
+	if b.failure != "" {
+		// The server disconnects us once its message is delivered.
+		// Disconnecting at the same time would leave both sides waiting
+		// for an acknowledgement.
+		b.until(ctx, func() bool { return b.closed != nil })
+		return fmt.Errorf("%w: %s", ErrLoginFailed, b.failure)
+	}
+	return b.send(gamepacket.MessageGameMessage, "action|enter_game\n")
+}
+
+// loginInfo returns the text packet the bot logs in with
+func (b *Bot) loginInfo() gamepacket.Text {
+	// Device identifiers are derived from the name, so that a bot looks
+	// like the same device every time.
+	h := fnv.New64a()
+	h.Write([]byte(b.config.Name + b.config.TankIDName))
+	id := h.Sum64()
+
+	text := gamepacket.Text{
+		{Key: "tankIDName", Value: b.config.TankIDName},
+		{Key: "tankIDPass", Value: b.config.TankIDPass},
+		{Key: "requestedName", Value: b.config.Name},
+		{Key: "f", Value: "1"},
+		{Key: "protocol", Value: strconv.Itoa(b.config.Protocol)},
+		{Key: "game_version", Value: strconv.FormatFloat(b.config.GameVersion, 'f', -1, 64)},
+		{Key: "lmode", Value: "0"},
+		{Key: "cbits", Value: "0"},
+		{Key: "player_age", Value: "20"},
+		{Key: "GDPR", Value: "1"},
+		{Key: "hash2", Value: strconv.Itoa(int(int32(id >> 32)))},
+		{Key: "meta", Value: "localhost"},
+		{Key: "fhash", Value: "-716928004"},
+		{Key: "rid", Value: fmt.Sprintf("%016X%016X", id, ^id)},
+		{Key: "platformID", Value: "0,1,1"},
+		{Key: "deviceVersion", Value: "0"},
+		{Key: "country", Value: "us"},
+		{Key: "hash", Value: strconv.Itoa(int(int32(id)))},
+		{Key: "mac", Value: fmt.Sprintf("02:%02x:%02x:%02x:%02x:%02x", byte(id>>8), byte(id>>16), byte(id>>24), byte(id>>32), byte(id>>40))},
+		{Key: "wk", Value: "NONE0"},
+	}
+	for _, field := range b.config.Fields {
+		text.Set(field.Key, field.Value)
+	}
+	return text
+}
+
+// Close disconnects from the server and frees the host
+func (b *Bot) Close() error {
+	if b.host == nil {
+		return nil
+	}
+	if b.closed == nil && b.peer != nil {
+		b.peer.Disconnect(0)
+		deadline := time.Now().Add(time.Second)
+		for b.closed == nil && b.peer.State() != enet.Disconnected && time.Now().Before(deadline) {
+			b.service(10)
+		}
+	}
+	b.host.Destroy()
+	b.host, b.peer = nil, nil
+	return nil
+}
+
+// Peer returns the peer of the server the bot is connected to
+func (b *Bot) Peer() enet.Peer {
+	return b.peer
+}
+
+// Pings returns the number of pings the bot answered
+func (b *Bot) Pings() int {
+	return b.pings
+}
+
+// Wait services the connection for d, answering the server. It returns
+// early with an error if the bot is disconnected or ctx is done.
+func (b *Bot) Wait(ctx context.Context, d time.Duration) error {
+	deadline := time.Now().Add(d)
+	for {
+		if err := b.check(ctx); err != nil {
+			return err
+		}
+		left := time.Until(deadline)
+		if left <= 0 {
+			return nil
+		}
+		if left > 10*time.Millisecond {
+			left = 10 * time.Millisecond
+		}
+		b.service(uint32(left / time.Millisecond))
+	}
+}
+
+// until services the connection until done returns true, giving up after
+// the configured timeout
+func (b *Bot) until(ctx context.Context, done func() bool) error {
+	deadline := time.Now().Add(b.config.Timeout)
+	for !done() {
+		if err := b.check(ctx); err != nil {
+			return err
+		}
+		if !time.Now().Before(deadline) {
+			return ErrTimeout
+		}
+		b.service(10)
+	}
+	return nil
+}
+
+// check returns why the bot can't go on, following a pending redirect
+func (b *Bot) check(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if b.redirect != nil {
+		r := b.redirect
+		addr, err := enet.ResolveAddress(ctx, "udp", r.host, r.port)
+		if err != nil {
+			return fmt.Errorf("client: redirected to %s: %w", r.host, err)
+		}
+		if err := b.login(ctx, addr, r.fields); err != nil {
+			return fmt.Errorf("client: redirected to %s: %w", r.host, err)
+		}
+	}
+	return b.closed
+}
+
+// service services the host once and handles the event, if any
+func (b *Bot) service(timeout uint32) {
+	ev := b.host.Service(timeout)
+	switch ev.GetType() {
+	case enet.EventConnect:
+		// The hello follows.
+	case enet.EventDisconnect:
+		b.closed = fmt.Errorf("%w: %v", ErrDisconnected, ev.DisconnectReason())
+	case enet.EventReceive:
+		b.handle(ev.GetPacket().GetData())
+	}
+}
+
+// send sends a game message to the server
+func (b *Bot) send(typ gamepacket.MessageType, text string) error {
+	return b.peer.SendBytes(gamepacket.Encode(typ, []byte(text)), 0, enet.PacketFlagReliable)
+}
+
+// sendTank sends a tank packet to the server
+func (b *Bot) sendTank(tank *gamepacket.TankPacket) error {
+	data, err := gamepacket.EncodeTank(tank)
+	if err != nil {
+		return err
+	}
+	return b.peer.SendBytes(data, 0, enet.PacketFlagReliable)
+}
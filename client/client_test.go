@@ -0,0 +1,251 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	enet "github.com/eikarna/gotops"
+	"github.com/eikarna/gotops/client"
+	"github.com/eikarna/gotops/gamepacket"
+	"github.com/eikarna/gotops/login"
+	"github.com/eikarna/gotops/redirect"
+	"github.com/eikarna/gotops/world"
+)
+
+// server is a minimal game server: it logs players in, sends them START
+// when they join it, pings them and echoes their chat
+type server struct {
+	t     *testing.T
+	host  enet.Host
+	login *login.Server
+
+	// OnAuthenticated is called in the goroutine of the server
+	OnAuthenticated func(peer enet.Peer, info *login.LoginInfo)
+
+	mu     sync.Mutex
+	names  []string
+	chat   []string
+	pongs  int
+	states int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newServer(t *testing.T, auth login.Authenticator) *server {
+	t.Helper()
+	host, err := enet.NewHost(enet.ENET_ADDRESS_TYPE_IPV4, enet.NewAddress(enet.ENET_ADDRESS_TYPE_IPV4, "127.0.0.1", 0), 8, 2, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &server{
+		t:     t,
+		host:  host,
+		login: login.NewServer(auth, 0),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	srv.login.OnAuthenticated = func(peer enet.Peer, info *login.LoginInfo) {
+		srv.mu.Lock()
+		srv.names = append(srv.names, info.Name())
+		srv.mu.Unlock()
+		if srv.OnAuthenticated != nil {
+			srv.OnAuthenticated(peer, info)
+		}
+	}
+	t.Cleanup(func() {
+		close(srv.stop)
+		<-srv.done
+		host.Destroy()
+	})
+	return srv
+}
+
+func (srv *server) start() {
+	go func() {
+		defer close(srv.done)
+		for {
+			select {
+			case <-srv.stop:
+				return
+			default:
+			}
+			ev := srv.host.Service(5)
+			if srv.login.Handle(ev) || ev.GetType() != enet.EventReceive {
+				continue
+			}
+			srv.handle(ev.GetPeer(), ev.GetPacket().GetData())
+			ev.GetPacket().Destroy()
+		}
+	}()
+}
+
+func (srv *server) address() enet.Address {
+	return enet.NewAddressFromAddrPort(netip.AddrPortFrom(netip.MustParseAddr("127.0.0.1"), srv.host.GetAddress().AddrPort().Port()))
+}
+
+func (srv *server) handle(peer enet.Peer, data []byte) {
+	typ, payload, _ := gamepacket.Decode(data)
+	if typ == gamepacket.MessageGamePacket {
+		var tank gamepacket.TankPacket
+		tank.UnmarshalBinary(payload)
+		srv.mu.Lock()
+		switch tank.Type {
+		case gamepacket.TankPingReply:
+			srv.pongs++
+		case gamepacket.TankState:
+			srv.states++
+		}
+		srv.mu.Unlock()
+		return
+	}
+
+	text := gamepacket.ParseText(payload)
+	switch text.Value("action") {
+	case "join_request":
+		if text.Value("name") != "START" {
+			call(peer, "OnConsoleMessage", "That world is locked.")
+			call(peer, "OnFailedToEnterWorld", int32(1))
+			return
+		}
+		if err := world.SendWorld(peer, world.New("START", 4, 3)); err != nil {
+			srv.t.Error(err)
+		}
+		call(peer, "OnSpawn", "spawn|avatar\nnetID|3\nposXY|32|64\nname|bot\ntype|local\n")
+		tank, _ := gamepacket.EncodeTank(&gamepacket.TankPacket{Type: gamepacket.TankPingRequest, Value: 1234})
+		peer.SendBytes(tank, 0, enet.PacketFlagReliable)
+
+	case "input":
+		srv.mu.Lock()
+		srv.chat = append(srv.chat, text.Value("text"))
+		srv.mu.Unlock()
+		call(peer, "OnConsoleMessage", "echo "+text.Value("text"))
+	}
+}
+
+// call sends a function call to a peer
+func call(peer enet.Peer, args ...any) {
+	tank, _ := gamepacket.NewCall(-1, 0, args)
+	data, _ := gamepacket.EncodeTank(tank)
+	peer.SendBytes(data, 0, enet.PacketFlagReliable)
+}
+
+func TestBot(t *testing.T) {
+	srv := newServer(t, nil)
+	srv.start()
+	ctx := context.Background()
+
+	bot, err := client.Dial(ctx, srv.address(), client.Config{Name: "Seth", Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bot.Close()
+
+	if err := bot.Move(1, 2); !errors.Is(err, client.ErrNotInWorld) {
+		t.Errorf("moving outside a world returned %v", err)
+	}
+	if err := bot.Join(ctx, "LOCKED"); !errors.Is(err, client.ErrJoinFailed) || !strings.Contains(err.Error(), "locked") {
+		t.Errorf("joining a locked world returned %v", err)
+	}
+	if err := bot.Join(ctx, "START"); err != nil {
+		t.Fatal(err)
+	}
+	if w := bot.World(); w == nil || w.Name != "START" || w.Width != 4 {
+		t.Errorf("joined world %+v", w)
+	}
+	if x, y := bot.Position(); bot.NetID() != 3 || x != 32 || y != 64 {
+		t.Errorf("spawned as %d at %v, %v", bot.NetID(), x, y)
+	}
+
+	var echoes []string
+	bot.OnCall = func(call gamepacket.VariantList) {
+		if call[0] == "OnConsoleMessage" {
+			echoes = append(echoes, call[1].(string))
+		}
+	}
+	if err := bot.Move(48, 64); err != nil {
+		t.Fatal(err)
+	}
+	if err := bot.Say("hello"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100 && len(echoes) == 0; i++ {
+		if err := bot.Wait(ctx, 10*time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(echoes) != 1 || echoes[0] != "echo hello" {
+		t.Errorf("chat echoed as %q", echoes)
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if len(srv.names) != 1 || srv.names[0] != "Seth" || len(srv.chat) != 1 || srv.chat[0] != "hello" {
+		t.Errorf("server saw names %q and chat %q", srv.names, srv.chat)
+	}
+	if bot.Pings() != 1 || srv.pongs != 1 || srv.states != 1 {
+		t.Errorf("%d pings answered, server got %d replies and %d states", bot.Pings(), srv.pongs, srv.states)
+	}
+}
+
+func TestLoginFailed(t *testing.T) {
+	srv := newServer(t, login.AuthFunc(func(enet.Peer, *login.LoginInfo) error {
+		return errors.New("server full")
+	}))
+	srv.start()
+
+	_, err := client.Dial(context.Background(), srv.address(), client.Config{Name: "Seth", Timeout: 5 * time.Second})
+	if !errors.Is(err, client.ErrLoginFailed) || !strings.Contains(err.Error(), "server full") {
+		t.Errorf("dialing returned %v", err)
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	signer := redirect.NewSigner([]byte("secret"))
+	var accepted *redirect.Session
+	target := newServer(t, signer.Authenticator(nil, func(peer enet.Peer, sess *redirect.Session) {
+		accepted = sess
+	}))
+	target.start()
+
+	srv := newServer(t, nil)
+	srv.OnAuthenticated = func(peer enet.Peer, info *login.LoginInfo) {
+		port := target.host.GetAddress().AddrPort().Port()
+		if err := signer.Send(peer, "127.0.0.1", port, &redirect.Session{UserID: 7, Name: info.Name(), World: "START"}); err != nil {
+			t.Error(err)
+		}
+	}
+	srv.start()
+
+	ctx := context.Background()
+	bot, err := client.Dial(ctx, srv.address(), client.Config{Name: "Seth", Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bot.Close()
+	for i := 0; i < 100; i++ {
+		if err := bot.Wait(ctx, 10*time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+		target.mu.Lock()
+		n := len(target.names)
+		target.mu.Unlock()
+		if n > 0 {
+			break
+		}
+	}
+	target.mu.Lock()
+	defer target.mu.Unlock()
+	if fmt.Sprint(target.names) != "[Seth]" {
+		t.Fatalf("redirect target logged in %q", target.names)
+	}
+	if accepted == nil || accepted.UserID != 7 {
+		t.Errorf("accepted session %+v", accepted)
+	}
+}
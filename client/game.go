@@ -0,0 +1,237 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/eikarna/gotops/gamepacket"
+	"github.com/eikarna/gotops/world"
+)
+
+// Facing left is flag 0x10 of the state of a player
+const stateFacingLeft uint32 = 0x10
+
+// handle processes a game message from the server
+func (b *Bot) handle(data []byte) {
+	typ, payload, err := gamepacket.Decode(data)
+	if err != nil {
+		return
+	}
+
+	switch typ {
+	case gamepacket.MessageServerHello:
+		if !b.loggedIn {
+			b.send(gamepacket.MessageGenericText, b.info.String())
+		}
+
+	case gamepacket.MessageGenericText, gamepacket.MessageGameMessage:
+		text := gamepacket.ParseText(payload)
+		switch text.Value("action") {
+		case "log":
+			b.lastLog = text.Value("msg")
+		case "logon_fail":
+			b.failure = b.lastLog
+			if b.failure == "" {
+				b.failure = "logon_fail"
+			}
+		}
+		if b.loggedIn && b.OnText != nil {
+			b.OnText(typ, text)
+		}
+
+	case gamepacket.MessageGamePacket:
+		var tank gamepacket.TankPacket
+		if err := tank.UnmarshalBinary(payload); err != nil {
+			return
+		}
+		if tank.Type == gamepacket.TankCallFunction {
+			var call gamepacket.VariantList
+			if err := call.UnmarshalBinary(tank.ExtraData); err != nil || len(call) == 0 {
+				return
+			}
+			b.handleCall(call)
+			if b.OnCall != nil {
+				b.OnCall(call)
+			}
+			return
+		}
+		b.handleTank(&tank)
+		if b.OnTank != nil {
+			b.OnTank(&tank)
+		}
+	}
+}
+
+// handleCall processes a function call from the server
+func (b *Bot) handleCall(call gamepacket.VariantList) {
+	name, _ := call[0].(string)
+	switch name {
+	case "OnSuperMainStartAcceptLogonHrdxs47254722215a":
+		b.loggedIn = true
+
+	case "OnSpawn":
+		s, _ := arg[string](call, 1)
+		text := gamepacket.ParseText([]byte(s))
+		if text.Value("type") != "local" {
+			return
+		}
+		netID, _ := strconv.ParseInt(text.Value("netID"), 10, 32)
+		b.netID = int32(netID)
+		x, y, _ := strings.Cut(text.Value("posXY"), "|")
+		if v, err := strconv.ParseFloat(x, 32); err == nil {
+			b.x = float32(v)
+		}
+		if v, err := strconv.ParseFloat(y, 32); err == nil {
+			b.y = float32(v)
+		}
+		b.spawned = true
+
+	case "OnFailedToEnterWorld":
+		if b.joining {
+			b.joinErr = fmt.Errorf("%w: %s", ErrJoinFailed, b.lastConsole)
+		}
+
+	case "OnConsoleMessage":
+		b.lastConsole, _ = arg[string](call, 1)
+
+	case "OnSendToServer":
+		port, ok1 := arg[int32](call, 1)
+		token, ok2 := arg[int32](call, 2)
+		user, ok3 := arg[int32](call, 3)
+		target, ok4 := arg[string](call, 4)
+		if !ok1 || !ok2 || !ok3 || !ok4 {
+			return
+		}
+		parts := strings.SplitN(target, "|", 3)
+		for len(parts) < 3 {
+			parts = append(parts, "")
+		}
+		b.redirect = &redirection{
+			host: parts[0],
+			port: uint16(port),
+			fields: gamepacket.Text{
+				{Key: "lmode", Value: "1"},
+				{Key: "user", Value: strconv.Itoa(int(user))},
+				{Key: "token", Value: strconv.Itoa(int(token))},
+				{Key: "doorID", Value: parts[1]},
+				{Key: "UUIDToken", Value: parts[2]},
+			},
+		}
+	}
+}
+
+// arg returns argument i of a call if it has type T
+func arg[T any](call gamepacket.VariantList, i int) (T, bool) {
+	var zero T
+	if i >= len(call) {
+		return zero, false
+	}
+	v, ok := call[i].(T)
+	return v, ok
+}
+
+// handleTank processes a tank packet from the server, other than a function
+// call
+func (b *Bot) handleTank(tank *gamepacket.TankPacket) {
+	switch tank.Type {
+	case gamepacket.TankSendMapData:
+		w := &world.World{}
+		if err := w.UnmarshalBinary(tank.ExtraData); err != nil {
+			if b.joining {
+				b.joinErr = fmt.Errorf("%w: %v", ErrJoinFailed, err)
+			}
+			return
+		}
+		b.world = w
+
+	case gamepacket.TankPingRequest:
+		b.pings++
+		b.sendTank(&gamepacket.TankPacket{
+			Type:  gamepacket.TankPingReply,
+			NetID: b.netID,
+			Value: tank.Value,
+		})
+	}
+}
+
+// Join enters a world and waits until the bot has spawned in it
+func (b *Bot) Join(ctx context.Context, name string) error {
+	b.leaveWorld()
+	b.joining = true
+	defer func() { b.joining = false }()
+
+	if err := b.send(gamepacket.MessageGameMessage, "action|join_request\nname|"+name+"\ninvitedWorld|0"); err != nil {
+		return err
+	}
+	err := b.until(ctx, func() bool { return b.joinErr != nil || b.world != nil && b.spawned })
+	if err == nil {
+		err = b.joinErr
+	}
+	if err != nil {
+		b.leaveWorld()
+	}
+	return err
+}
+
+// Leave exits the current world
+func (b *Bot) Leave() error {
+	if b.world == nil {
+		return ErrNotInWorld
+	}
+	b.leaveWorld()
+	return b.send(gamepacket.MessageGameMessage, "action|quit_to_exit")
+}
+
+// leaveWorld forgets the state of the current world
+func (b *Bot) leaveWorld() {
+	b.world, b.spawned, b.joinErr, b.netID = nil, false, nil, 0
+}
+
+// World returns the world the bot is in, or nil
+func (b *Bot) World() *world.World {
+	return b.world
+}
+
+// NetID returns the net ID of the bot in the current world
+func (b *Bot) NetID() int32 {
+	return b.netID
+}
+
+// Position returns the position of the bot in the current world
+func (b *Bot) Position() (x, y float32) {
+	return b.x, b.y
+}
+
+// Move moves the bot to x, y in the current world
+func (b *Bot) Move(x, y float32) error {
+	if b.world == nil {
+		return ErrNotInWorld
+	}
+	var flags uint32
+	if x < b.x {
+		flags |= stateFacingLeft
+	}
+	tank := &gamepacket.TankPacket{
+		Type:   gamepacket.TankState,
+		NetID:  b.netID,
+		Flags:  flags,
+		X:      x,
+		Y:      y,
+		XSpeed: x - b.x,
+		YSpeed: y - b.y,
+		PunchX: -1,
+		PunchY: -1,
+	}
+	b.x, b.y = x, y
+	return b.sendTank(tank)
+}
+
+// Say sends a chat message in the current world
+func (b *Bot) Say(text string) error {
+	if b.world == nil {
+		return ErrNotInWorld
+	}
+	return b.send(gamepacket.MessageGenericText, "action|input\n|text|"+text)
+}
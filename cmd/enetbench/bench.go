@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	enet "github.com/eikarna/gotops"
+)
+
+// headerSize is the size of the header of every benchmark packet: the case
+// it belongs to and the time it was sent
+const headerSize = 12
+
+// benchCase is a packet size and flags to measure
+type benchCase struct {
+	size  int
+	flags enet.PacketFlags
+}
+
+// flagNames names the flags cases can be run with
+var flagNames = map[string]enet.PacketFlags{
+	"reliable":    enet.PacketFlagReliable,
+	"unreliable":  0,
+	"unsequenced": enet.PacketFlagUnsequenced,
+}
+
+// flagName returns the name of the flags of the case
+func (c benchCase) flagName() string {
+	for name, flags := range flagNames {
+		if flags == c.flags {
+			return name
+		}
+	}
+	return fmt.Sprintf("flags(%d)", c.flags)
+}
+
+// result is the outcome of a case
+type result struct {
+	benchCase
+	elapsed   time.Duration
+	sent      int
+	latencies []time.Duration
+}
+
+// echo services host until stop is closed, sending every packet received
+// back on the channel it came from
+func echo(host enet.Host, stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		ev := host.Service(1)
+		if ev.GetType() != enet.EventReceive {
+			continue
+		}
+		packet := ev.GetPacket()
+		ev.GetPeer().SendBytes(packet.GetData(), ev.GetChannelID(), packet.GetFlags())
+		packet.Destroy()
+	}
+}
+
+// bencher sends packets to an echo server and times their return
+type bencher struct {
+	host enet.Host
+	peer enet.Peer
+
+	// window is the number of packets in flight
+	window int
+
+	// epoch is the time packets carry as an offset from
+	epoch time.Time
+}
+
+// connect connects to the echo server at addr
+func connect(host enet.Host, addr enet.Address, window int) (*bencher, error) {
+	peer, err := host.Connect(addr, 1, 0)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		switch host.Service(10).GetType() {
+		case enet.EventConnect:
+			return &bencher{host: host, peer: peer, window: window, epoch: time.Now()}, nil
+		case enet.EventDisconnect:
+			return nil, errors.New("connection refused")
+		}
+	}
+	peer.DisconnectNow(0)
+	return nil, errors.New("timed out connecting")
+}
+
+// run measures a case for d. Packets of other cases still in flight are
+// ignored.
+func (bn *bencher) run(id uint32, c benchCase, d time.Duration) (*result, error) {
+	size := c.size
+	if size < headerSize {
+		size = headerSize
+	}
+	data := make([]byte, size)
+	binary.LittleEndian.PutUint32(data, id)
+	res := &result{benchCase: c}
+
+	inFlight := 0
+	lastReceive := time.Now()
+	start := time.Now()
+	end := start.Add(d)
+	for {
+		now := time.Now()
+		if now.After(end) {
+			break
+		}
+		for inFlight < bn.window {
+			binary.LittleEndian.PutUint64(data[4:], uint64(time.Since(bn.epoch)))
+			if err := bn.peer.SendBytes(data, 0, c.flags); err != nil {
+				return nil, err
+			}
+			res.sent++
+			inFlight++
+		}
+		// Waiting rather than polling leaves the CPU to the socket
+		// readers; Service returns as soon as a packet is back.
+		if bn.receive(id, res, 1) {
+			inFlight--
+			lastReceive = now
+		} else if now.Sub(lastReceive) > 100*time.Millisecond {
+			// Unreliable packets were lost; stop waiting for them.
+			inFlight = 0
+			lastReceive = now
+		}
+	}
+	res.elapsed = time.Since(start)
+
+	// Packets still in flight are counted as received if they come back
+	// shortly, so that a short run doesn't look lossy.
+	drainEnd := time.Now().Add(200 * time.Millisecond)
+	for time.Now().Before(drainEnd) {
+		bn.receive(id, res, 1)
+	}
+	return res, nil
+}
+
+// receive services the host once and records the latency of the packet of
+// case id received, if any
+func (bn *bencher) receive(id uint32, res *result, timeout uint32) bool {
+	ev := bn.host.Service(timeout)
+	if ev.GetType() != enet.EventReceive {
+		return false
+	}
+	packet := ev.GetPacket()
+	defer packet.Destroy()
+	data := packet.GetData()
+	if len(data) < headerSize || binary.LittleEndian.Uint32(data) != id {
+		return false
+	}
+	sent := time.Duration(binary.LittleEndian.Uint64(data[4:]))
+	res.latencies = append(res.latencies, time.Since(bn.epoch)-sent)
+	return true
+}
+
+// writeHeader writes the header of the result table
+func writeHeader(w io.Writer) {
+	fmt.Fprintf(w, "%6s %-11s %9s %9s %6s %10s %9s %9s %9s %9s\n",
+		"size", "flags", "sent", "received", "loss", "packets/s", "MB/s", "p50", "p99", "max")
+}
+
+// write writes the result as a row of the table
+func (res *result) write(w io.Writer) {
+	sorted := append([]time.Duration(nil), res.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	received := len(sorted)
+	var loss, rate float64
+	if res.sent > 0 {
+		loss = 100 * float64(res.sent-received) / float64(res.sent)
+	}
+	if res.elapsed > 0 {
+		rate = float64(received) / res.elapsed.Seconds()
+	}
+	fmt.Fprintf(w, "%6d %-11s %9d %9d %5.1f%% %10.0f %9.2f %9s %9s %9s\n",
+		res.size, res.flagName(), res.sent, received, loss, rate, rate*float64(res.size)/1e6,
+		round(percentile(sorted, 0.5)), round(percentile(sorted, 0.99)), round(percentile(sorted, 1)))
+}
+
+// round rounds a latency for display
+func round(d time.Duration) time.Duration {
+	return d.Round(time.Microsecond)
+}
+
+// percentile returns the p-th percentile of sorted latencies, 0 if there
+// are none
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(p*float64(len(sorted))+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	enet "github.com/eikarna/gotops"
+)
+
+func TestParseCases(t *testing.T) {
+	cases, err := parseCases("64, 1024", "reliable,unsequenced")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) != 4 || cases[1] != (benchCase{1024, enet.PacketFlagReliable}) || cases[2].flagName() != "unsequenced" {
+		t.Errorf("parsed %+v", cases)
+	}
+	if _, err := parseCases("64", "fast"); err == nil {
+		t.Error("unknown flags accepted")
+	}
+	if _, err := parseCases("0", "reliable"); err == nil {
+		t.Error("empty packets accepted")
+	}
+}
+
+func TestRun(t *testing.T) {
+	server, err := enet.NewHost(enet.ENET_ADDRESS_TYPE_IPV4, enet.NewAddress(enet.ENET_ADDRESS_TYPE_IPV4, "127.0.0.1", 0), 1, 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		echo(server, stop)
+		close(done)
+	}()
+	defer func() {
+		close(stop)
+		<-done
+		server.Destroy()
+	}()
+
+	client, err := enet.NewHost(enet.ENET_ADDRESS_TYPE_IPV4, nil, 1, 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Destroy()
+	addr := enet.NewAddressFromAddrPort(netip.AddrPortFrom(netip.MustParseAddr("127.0.0.1"), server.GetAddress().AddrPort().Port()))
+	bn, err := connect(client, addr, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := bn.run(1, benchCase{size: 4, flags: enet.PacketFlagReliable}, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.sent == 0 || len(res.latencies) != res.sent {
+		t.Errorf("%d reliable packets sent, %d came back", res.sent, len(res.latencies))
+	}
+	for _, l := range res.latencies {
+		if l <= 0 || l > time.Second {
+			t.Fatalf("latency %v", l)
+		}
+	}
+
+	var out strings.Builder
+	res.write(&out)
+	if !strings.Contains(out.String(), " reliable ") || !strings.Contains(out.String(), "0.0%") {
+		t.Errorf("result row %q", out.String())
+	}
+}
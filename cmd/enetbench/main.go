@@ -0,0 +1,134 @@
+// Command enetbench measures the packets per second and round trip latency
+// of enet hosts across packet sizes and flags, to make performance
+// regressions visible.
+//
+// Usage:
+//
+//	enetbench [-sizes 64,1024] [-flags reliable,unreliable] [-duration 2s]
+//	enetbench -listen 17100
+//	enetbench -connect 10.0.0.2:17100
+//
+// Without -listen or -connect, an echo server runs in the same process on
+// loopback. -listen runs only the echo server, for -connect to measure
+// against from another machine.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/netip"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	enet "github.com/eikarna/gotops"
+)
+
+func main() {
+	sizes := flag.String("sizes", "64,256,1024,4096", "comma separated packet sizes in bytes")
+	flags := flag.String("flags", "reliable,unreliable,unsequenced", "comma separated packet flags")
+	duration := flag.Duration("duration", 2*time.Second, "duration of each measurement")
+	window := flag.Int("window", 64, "packets in flight")
+	listen := flag.Uint("listen", 0, "only run an echo server on this port")
+	remote := flag.String("connect", "", "measure against the echo server at this address")
+	flag.Parse()
+
+	if *listen != 0 {
+		host, err := enet.NewHost(enet.ENET_ADDRESS_TYPE_ANY, enet.NewListenAddress(enet.ENET_ADDRESS_TYPE_ANY, uint16(*listen)), 32, 1, 0, 0)
+		if err != nil {
+			fatal(err)
+		}
+		defer host.Destroy()
+		stop := make(chan struct{})
+		go func() {
+			interrupt := make(chan os.Signal, 1)
+			signal.Notify(interrupt, os.Interrupt)
+			<-interrupt
+			close(stop)
+		}()
+		fmt.Printf("echoing on port %d\n", *listen)
+		echo(host, stop)
+		return
+	}
+
+	cases, err := parseCases(*sizes, *flags)
+	if err != nil {
+		fatal(err)
+	}
+
+	var addr enet.Address
+	if *remote != "" {
+		addrPort, err := netip.ParseAddrPort(*remote)
+		if err != nil {
+			fatal(err)
+		}
+		addr = enet.NewAddressFromAddrPort(addrPort)
+	} else {
+		server, err := enet.NewHost(enet.ENET_ADDRESS_TYPE_IPV4, enet.NewAddress(enet.ENET_ADDRESS_TYPE_IPV4, "127.0.0.1", 0), 1, 1, 0, 0)
+		if err != nil {
+			fatal(err)
+		}
+		stop, done := make(chan struct{}), make(chan struct{})
+		go func() {
+			echo(server, stop)
+			close(done)
+		}()
+		defer func() {
+			close(stop)
+			<-done
+			server.Destroy()
+		}()
+		addr = enet.NewAddressFromAddrPort(netip.AddrPortFrom(netip.MustParseAddr("127.0.0.1"), server.GetAddress().AddrPort().Port()))
+	}
+
+	addressType := enet.ENET_ADDRESS_TYPE_IPV4
+	if !addr.AddrPort().Addr().Unmap().Is4() {
+		addressType = enet.ENET_ADDRESS_TYPE_IPV6
+	}
+	client, err := enet.NewHost(addressType, nil, 1, 1, 0, 0)
+	if err != nil {
+		fatal(err)
+	}
+	defer client.Destroy()
+	bn, err := connect(client, addr, *window)
+	if err != nil {
+		fatal(err)
+	}
+	defer bn.peer.DisconnectNow(0)
+
+	writeHeader(os.Stdout)
+	for i, c := range cases {
+		res, err := bn.run(uint32(i), c, *duration)
+		if err != nil {
+			fatal(err)
+		}
+		res.write(os.Stdout)
+	}
+}
+
+// parseCases returns every combination of the comma separated sizes and
+// flags
+func parseCases(sizes, flags string) ([]benchCase, error) {
+	var ret []benchCase
+	for _, f := range strings.Split(flags, ",") {
+		value, ok := flagNames[strings.TrimSpace(f)]
+		if !ok {
+			return nil, fmt.Errorf("unknown flags %q", f)
+		}
+		for _, s := range strings.Split(sizes, ",") {
+			size, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil || size <= 0 {
+				return nil, fmt.Errorf("invalid size %q", s)
+			}
+			ret = append(ret, benchCase{size: size, flags: value})
+		}
+	}
+	return ret, nil
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "enetbench: %s\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	enet "github.com/eikarna/gotops"
+	"github.com/eikarna/gotops/capture"
+	"github.com/eikarna/gotops/gamepacket"
+)
+
+// dissection is the decoded form of a single packet
+type dissection struct {
+	Index   int         `json:"index"`
+	Record  *recordInfo `json:"record,omitempty"`
+	Length  int         `json:"length"`
+	Message string      `json:"message,omitempty"`
+	Type    uint32      `json:"type"`
+	Text    []field     `json:"text,omitempty"`
+	Tank    *tankInfo   `json:"tank,omitempty"`
+	Call    []variant   `json:"call,omitempty"`
+	Raw     string      `json:"raw,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+type recordInfo struct {
+	Kind      string  `json:"kind"`
+	Time      float64 `json:"time"`
+	PeerID    uint32  `json:"peer"`
+	ChannelID uint8   `json:"channel"`
+	Flags     string  `json:"flags,omitempty"`
+	Data      uint32  `json:"data,omitempty"`
+	Address   string  `json:"address,omitempty"`
+}
+
+type field struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type tankInfo struct {
+	Type          uint8     `json:"type"`
+	Name          string    `json:"name"`
+	ObjectType    uint8     `json:"objectType"`
+	JumpCount     uint8     `json:"jumpCount"`
+	AnimationType uint8     `json:"animationType"`
+	NetID         int32     `json:"netID"`
+	TargetNetID   int32     `json:"targetNetID"`
+	Flags         uint32    `json:"flags"`
+	FloatVar      jsonFloat `json:"floatVar"`
+	Value         int32     `json:"value"`
+	X             jsonFloat `json:"x"`
+	Y             jsonFloat `json:"y"`
+	XSpeed        jsonFloat `json:"xSpeed"`
+	YSpeed        jsonFloat `json:"ySpeed"`
+	Rotation      jsonFloat `json:"rotation"`
+	PunchX        int32     `json:"punchX"`
+	PunchY        int32     `json:"punchY"`
+	ExtraData     string    `json:"extraData,omitempty"`
+}
+
+type variant struct {
+	Index int    `json:"index"`
+	Type  string `json:"type"`
+	Value any    `json:"value"`
+}
+
+// jsonFloat is a float32 that marshals to JSON even if it isn't finite, as
+// "NaN", "+Inf" or "-Inf"
+type jsonFloat float32
+
+func (f jsonFloat) MarshalJSON() ([]byte, error) {
+	switch v := float64(f); {
+	case math.IsNaN(v):
+		return []byte(`"NaN"`), nil
+	case math.IsInf(v, 1):
+		return []byte(`"+Inf"`), nil
+	case math.IsInf(v, -1):
+		return []byte(`"-Inf"`), nil
+	}
+	return json.Marshal(float32(f))
+}
+
+// MarshalJSON marshals the variant with the floats of its value as
+// jsonFloat, so that values that aren't finite don't fail
+func (v variant) MarshalJSON() ([]byte, error) {
+	type plain variant
+	p := plain(v)
+	switch value := v.Value.(type) {
+	case float32:
+		p.Value = jsonFloat(value)
+	case gamepacket.Vec2:
+		p.Value = struct{ X, Y jsonFloat }{jsonFloat(value.X), jsonFloat(value.Y)}
+	case gamepacket.Vec3:
+		p.Value = struct{ X, Y, Z jsonFloat }{jsonFloat(value.X), jsonFloat(value.Y), jsonFloat(value.Z)}
+	case gamepacket.Rect:
+		p.Value = struct{ X, Y, W, H jsonFloat }{jsonFloat(value.X), jsonFloat(value.Y), jsonFloat(value.W), jsonFloat(value.H)}
+	}
+	return json.Marshal(p)
+}
+
+// dissect decodes a game message
+func dissect(index int, data []byte) *dissection {
+	ret := &dissection{
+		Index:  index,
+		Length: len(data),
+	}
+
+	typ, payload, err := gamepacket.Decode(data)
+	if err != nil {
+		ret.Error = err.Error()
+		ret.Raw = hex.EncodeToString(data)
+		return ret
+	}
+	ret.Type = uint32(typ)
+	ret.Message = typ.String()
+
+	switch typ {
+	case gamepacket.MessageGenericText, gamepacket.MessageGameMessage, gamepacket.MessageTrack,
+		gamepacket.MessageError, gamepacket.MessageClientLogResponse:
+		for _, f := range gamepacket.ParseText(payload) {
+			ret.Text = append(ret.Text, field{Key: f.Key, Value: f.Value})
+		}
+
+	case gamepacket.MessageGamePacket:
+		var tank gamepacket.TankPacket
+		if err := tank.UnmarshalBinary(payload); err != nil {
+			ret.Error = err.Error()
+			ret.Raw = hex.EncodeToString(payload)
+			return ret
+		}
+		ret.Tank = &tankInfo{
+			Type:          uint8(tank.Type),
+			Name:          tank.Type.String(),
+			ObjectType:    tank.ObjectType,
+			JumpCount:     tank.JumpCount,
+			AnimationType: tank.AnimationType,
+			NetID:         tank.NetID,
+			TargetNetID:   tank.TargetNetID,
+			Flags:         tank.Flags,
+			FloatVar:      jsonFloat(tank.FloatVar),
+			Value:         tank.Value,
+			X:             jsonFloat(tank.X),
+			Y:             jsonFloat(tank.Y),
+			XSpeed:        jsonFloat(tank.XSpeed),
+			YSpeed:        jsonFloat(tank.YSpeed),
+			Rotation:      jsonFloat(tank.Rotation),
+			PunchX:        tank.PunchX,
+			PunchY:        tank.PunchY,
+			ExtraData:     hex.EncodeToString(tank.ExtraData),
+		}
+		if tank.Type == gamepacket.TankCallFunction {
+			var list gamepacket.VariantList
+			if err := list.UnmarshalBinary(tank.ExtraData); err != nil {
+				ret.Error = err.Error()
+				return ret
+			}
+			for i, value := range list {
+				ret.Call = append(ret.Call, variant{Index: i, Type: variantTypeName(value), Value: value})
+			}
+		}
+
+	default:
+		if len(payload) > 0 {
+			ret.Raw = hex.EncodeToString(payload)
+		}
+	}
+	return ret
+}
+
+// newRecordInfo describes a captured record
+func newRecordInfo(rec capture.Record) *recordInfo {
+	ret := &recordInfo{
+		Kind:      rec.Kind.String(),
+		Time:      rec.Time.Seconds(),
+		PeerID:    rec.PeerID,
+		ChannelID: rec.ChannelID,
+		Flags:     flagNames(enet.PacketFlags(rec.Flags)),
+		Data:      rec.Data,
+	}
+	if rec.Address != "" {
+		ret.Address = fmt.Sprintf("%s:%d", rec.Address, rec.Port)
+	}
+	return ret
+}
+
+// flagNames returns the names of the set packet flags
+func flagNames(flags enet.PacketFlags) string {
+	var names []string
+	if flags&enet.PacketFlagReliable != 0 {
+		names = append(names, "reliable")
+	}
+	if flags&enet.PacketFlagUnsequenced != 0 {
+		names = append(names, "unsequenced")
+	}
+	if flags&enet.PacketFlagUnreliableFragment != 0 {
+		names = append(names, "unreliable-fragment")
+	}
+	return strings.Join(names, ",")
+}
+
+// variantTypeName returns the name of a variant list value's type
+func variantTypeName(value any) string {
+	switch value.(type) {
+	case float32:
+		return "float"
+	case string:
+		return "string"
+	case gamepacket.Vec2:
+		return "vec2"
+	case gamepacket.Vec3:
+		return "vec3"
+	case uint32:
+		return "uint32"
+	case gamepacket.Rect:
+		return "rect"
+	case int32:
+		return "int32"
+	default:
+		return "unknown"
+	}
+}
+
+// writeText writes the dissection as annotated text
+func (d *dissection) writeText(w io.Writer) {
+	fmt.Fprintf(w, "#%d", d.Index)
+	if rec := d.Record; rec != nil {
+		fmt.Fprintf(w, " %s peer=%d channel=%d time=%.3fs", rec.Kind, rec.PeerID, rec.ChannelID, rec.Time)
+		if rec.Flags != "" {
+			fmt.Fprintf(w, " flags=%s", rec.Flags)
+		}
+		if rec.Address != "" {
+			fmt.Fprintf(w, " address=%s", rec.Address)
+		}
+		if rec.Kind == capture.KindConnect.String() || rec.Kind == capture.KindDisconnect.String() {
+			fmt.Fprintf(w, " data=%d\n", rec.Data)
+			return
+		}
+	}
+	fmt.Fprintf(w, " length=%d\n", d.Length)
+
+	if d.Message != "" {
+		fmt.Fprintf(w, "  message: %s (%d)\n", d.Message, d.Type)
+	}
+	for _, f := range d.Text {
+		fmt.Fprintf(w, "    %s|%s\n", f.Key, f.Value)
+	}
+	if tank := d.Tank; tank != nil {
+		fmt.Fprintf(w, "  tank: %s (%d)\n", tank.Name, tank.Type)
+		fmt.Fprintf(w, "    objectType=%d jumpCount=%d animationType=%d netID=%d targetNetID=%d flags=%#x\n",
+			tank.ObjectType, tank.JumpCount, tank.AnimationType, tank.NetID, tank.TargetNetID, tank.Flags)
+		fmt.Fprintf(w, "    floatVar=%g value=%d pos=(%g, %g) speed=(%g, %g) rotation=%g punch=(%d, %d)\n",
+			tank.FloatVar, tank.Value, tank.X, tank.Y, tank.XSpeed, tank.YSpeed, tank.Rotation, tank.PunchX, tank.PunchY)
+		if tank.ExtraData != "" && d.Call == nil {
+			fmt.Fprintf(w, "    extra data: %s\n", tank.ExtraData)
+		}
+	}
+	for _, v := range d.Call {
+		if s, ok := v.Value.(string); ok {
+			fmt.Fprintf(w, "    [%d] %s %q\n", v.Index, v.Type, s)
+		} else {
+			fmt.Fprintf(w, "    [%d] %s %v\n", v.Index, v.Type, v.Value)
+		}
+	}
+	if d.Raw != "" {
+		fmt.Fprintf(w, "  raw: %s\n", d.Raw)
+	}
+	if d.Error != "" {
+		fmt.Fprintf(w, "  error: %s\n", d.Error)
+	}
+}
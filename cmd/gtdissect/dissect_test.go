@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/eikarna/gotops/gamepacket"
+)
+
+func TestParseHex(t *testing.T) {
+	packets, err := parseHex("0x02 0x00 0x00 0x00\n61 62\n\n\n04000000\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packets) != 2 || !bytes.Equal(packets[0], []byte{2, 0, 0, 0, 'a', 'b'}) || !bytes.Equal(packets[1], []byte{4, 0, 0, 0}) {
+		t.Fatalf("unexpected packets %v", packets)
+	}
+
+	if _, err := parseHex("zz"); err == nil {
+		t.Fatal("expected invalid hex to fail")
+	}
+}
+
+func TestDissect(t *testing.T) {
+	call, _ := gamepacket.NewCall(-1, 0, gamepacket.VariantList{"OnConsoleMessage", "hello"})
+	data, _ := gamepacket.EncodeTank(call)
+
+	d := dissect(0, data)
+	if d.Error != "" {
+		t.Fatal(d.Error)
+	}
+	if d.Tank == nil || d.Tank.Name != "call function" || len(d.Call) != 2 || d.Call[0].Value != "OnConsoleMessage" {
+		t.Fatalf("unexpected dissection %+v", d)
+	}
+
+	var out strings.Builder
+	d.writeText(&out)
+	if !strings.Contains(out.String(), `[1] string "hello"`) {
+		t.Fatalf("expected variant in text output, got:\n%s", out.String())
+	}
+
+	if d := dissect(1, []byte{1, 2}); d.Error == "" || d.Raw != "0102" {
+		t.Fatalf("expected short message to be reported raw, got %+v", d)
+	}
+}
+
+// nonFiniteCall returns a call function message with floats that aren't
+// finite in its tank packet and variant list
+func nonFiniteCall() []byte {
+	nan, inf := float32(math.NaN()), float32(math.Inf(1))
+	call, _ := gamepacket.NewCall(-1, 0, gamepacket.VariantList{"OnSetPos", nan, -inf, gamepacket.Vec2{X: inf, Y: 1}})
+	call.X, call.Y, call.Rotation = nan, inf, -inf
+	data, _ := gamepacket.EncodeTank(call)
+	return data
+}
+
+func TestDissectJSONNonFinite(t *testing.T) {
+	b, err := json.Marshal(dissect(0, nonFiniteCall()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"x":"NaN"`, `"y":"+Inf"`, `"rotation":"-Inf"`, `"xSpeed":0`,
+		`"type":"float","value":"NaN"`, `"type":"float","value":"-Inf"`,
+		`"type":"vec2","value":{"X":"+Inf","Y":1}`,
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("%s missing from %s", want, b)
+		}
+	}
+}
+
+func FuzzDissect(f *testing.F) {
+	call, _ := gamepacket.NewCall(-1, 0, gamepacket.VariantList{"OnConsoleMessage", "hello", float32(1), uint32(2)})
+	data, _ := gamepacket.EncodeTank(call)
+	f.Add(data)
+	f.Add(gamepacket.Encode(gamepacket.MessageGenericText, []byte("action|log\nmsg|hi")))
+	f.Add(nonFiniteCall())
+	f.Fuzz(func(t *testing.T, data []byte) {
+		d := dissect(0, data)
+		var out strings.Builder
+		d.writeText(&out)
+		if _, err := json.Marshal(d); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
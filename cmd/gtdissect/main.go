@@ -0,0 +1,152 @@
+// Command gtdissect decodes Growtopia game messages from a capture file or a
+// hex dump and prints them as annotated text or JSON.
+//
+// Usage:
+//
+//	gtdissect [-json] [-kind receive,send] session.gtcap
+//	gtdissect [-json] dump.txt
+//	echo 0200000061637469... | gtdissect
+//
+// Hex dumps may contain whitespace and 0x prefixes; blank lines separate
+// packets. In JSON, floats that aren't finite are written as the strings
+// "NaN", "+Inf" and "-Inf".
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/eikarna/gotops/capture"
+)
+
+func main() {
+	asJSON := flag.Bool("json", false, "print packets as JSON, one object per line")
+	kinds := flag.String("kind", "", "comma separated record kinds to print from a capture (default all)")
+	peer := flag.Uint("peer", 0, "only print records of this peer from a capture")
+	flag.Parse()
+
+	var input io.Reader = os.Stdin
+	if flag.NArg() > 0 {
+		f, err := os.Open(flag.Arg(0))
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		input = f
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	emit := func(d *dissection) {
+		if *asJSON {
+			b, err := json.Marshal(d)
+			if err != nil {
+				fatal(err)
+			}
+			out.Write(append(b, '\n'))
+			return
+		}
+		d.writeText(out)
+	}
+
+	data, err := io.ReadAll(input)
+	if err != nil {
+		fatal(err)
+	}
+
+	r, err := capture.NewReader(bytes.NewReader(data))
+	if err == capture.ErrBadMagic {
+		packets, err := parseHex(string(data))
+		if err != nil {
+			fatal(err)
+		}
+		for i, packet := range packets {
+			emit(dissect(i, packet))
+		}
+		return
+	} else if err != nil {
+		fatal(err)
+	}
+
+	wanted := make(map[string]bool)
+	for _, kind := range strings.Split(*kinds, ",") {
+		if kind = strings.TrimSpace(kind); kind != "" {
+			wanted[kind] = true
+		}
+	}
+
+	for i := 0; ; i++ {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			out.Flush()
+			fatal(err)
+		}
+		if len(wanted) > 0 && !wanted[rec.Kind.String()] {
+			continue
+		}
+		if *peer != 0 && uint(rec.PeerID) != *peer {
+			continue
+		}
+
+		var d *dissection
+		switch rec.Kind {
+		case capture.KindReceive, capture.KindSend, capture.KindBroadcast:
+			d = dissect(i, rec.Payload)
+		default:
+			d = &dissection{Index: i}
+		}
+		d.Record = newRecordInfo(rec)
+		emit(d)
+	}
+}
+
+// parseHex parses a hex dump into packets separated by blank lines
+func parseHex(dump string) ([][]byte, error) {
+	var packets [][]byte
+	var current strings.Builder
+
+	flush := func() error {
+		if current.Len() == 0 {
+			return nil
+		}
+		packet, err := hex.DecodeString(current.String())
+		if err != nil {
+			return fmt.Errorf("invalid hex dump: %w", err)
+		}
+		packets = append(packets, packet)
+		current.Reset()
+		return nil
+	}
+
+	for _, line := range strings.Split(dump, "\n") {
+		if strings.TrimSpace(line) == "" {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		for _, word := range strings.Fields(line) {
+			word = strings.TrimPrefix(strings.TrimPrefix(word, "0x"), "0X")
+			current.WriteString(strings.TrimSuffix(word, ","))
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return packets, nil
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "gtdissect: %s\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,189 @@
+// Command gtload runs many headless bots against a game server and reports
+// how long logging in and entering a world took and why bots failed.
+//
+// Usage:
+//
+//	gtload [-bots 100] [-rate 20] [-duration 1m] [-world START] 127.0.0.1:17091
+//
+// Each bot logs in as a guest named after -prefix, enters -world, then walks
+// around and chats until the run is over. Progress is printed every
+// -report; a summary follows at the end or on interrupt.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"time"
+
+	enet "github.com/eikarna/gotops"
+	"github.com/eikarna/gotops/client"
+)
+
+// options of a run
+type options struct {
+	server    enet.Address
+	world     string
+	prefix    string
+	move      time.Duration
+	chat      time.Duration
+	timeout   time.Duration
+	newPacket bool
+}
+
+func main() {
+	bots := flag.Int("bots", 100, "number of bots")
+	rate := flag.Float64("rate", 20, "bots started per second")
+	duration := flag.Duration("duration", time.Minute, "length of the run")
+	report := flag.Duration("report", 5*time.Second, "interval between progress lines")
+	var opts options
+	flag.StringVar(&opts.world, "world", "START", "world the bots enter")
+	flag.StringVar(&opts.prefix, "prefix", "bot", "name prefix of the bots")
+	flag.DurationVar(&opts.move, "move", 500*time.Millisecond, "interval between moves of a bot")
+	flag.DurationVar(&opts.chat, "chat", 10*time.Second, "interval between chat messages of a bot, 0 to stay silent")
+	flag.DurationVar(&opts.timeout, "timeout", client.DefaultTimeout, "time to wait for the server at each step")
+	flag.BoolVar(&opts.newPacket, "newpacket", false, "use the new packet header")
+	flag.Parse()
+	if flag.NArg() != 1 || *bots <= 0 || *rate <= 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	ctx, cancelRun := context.WithTimeout(ctx, *duration)
+	defer cancelRun()
+
+	var err error
+	if opts.server, err = resolve(ctx, flag.Arg(0)); err != nil {
+		fatal(err)
+	}
+
+	st := newStats()
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(*report)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				st.writeProgress(os.Stdout)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	interval := time.Duration(float64(time.Second) / *rate)
+spawn:
+	for i := 0; i < *bots; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			run(ctx, fmt.Sprintf("%s%d", opts.prefix, i), &opts, st)
+		}(i)
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			break spawn
+		}
+	}
+	wg.Wait()
+	close(done)
+	st.write(os.Stdout)
+}
+
+// run drives a single bot until ctx is done
+func run(ctx context.Context, name string, opts *options, st *stats) {
+	start := time.Now()
+	bot, err := client.Dial(ctx, opts.server, client.Config{Name: name, NewPacket: opts.newPacket, Timeout: opts.timeout})
+	if ctx.Err() != nil {
+		// Bots still logging in when the run ends aren't failures.
+		if err == nil {
+			bot.Close()
+		}
+		return
+	}
+	st.record("login", time.Since(start), err)
+	if err != nil {
+		return
+	}
+	defer func() { st.addPings(bot.Pings()) }()
+	defer bot.Close()
+
+	start = time.Now()
+	err = bot.Join(ctx, opts.world)
+	if ctx.Err() != nil {
+		return
+	}
+	st.record("join", time.Since(start), err)
+	if err != nil {
+		return
+	}
+	st.online(1)
+	defer st.online(-1)
+
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+	w := bot.World()
+	width, height := float32(w.Width)*32, float32(w.Height)*32
+	lastChat := time.Now()
+	for {
+		if err := bot.Wait(ctx, opts.move); err != nil {
+			if ctx.Err() == nil {
+				st.record("play", 0, err)
+			}
+			return
+		}
+
+		x, y := bot.Position()
+		x = clamp(x+float32(random.Intn(65)-32), 0, width-32)
+		y = clamp(y+float32(random.Intn(65)-32), 0, height-32)
+		if err := bot.Move(x, y); err != nil {
+			st.record("play", 0, err)
+			return
+		}
+		if opts.chat > 0 && time.Since(lastChat) >= opts.chat {
+			lastChat = time.Now()
+			if err := bot.Say("hello from " + name); err != nil {
+				st.record("play", 0, err)
+				return
+			}
+		}
+	}
+}
+
+// clamp limits v to [lo, hi]
+func clamp(v, lo, hi float32) float32 {
+	if v > hi {
+		v = hi
+	}
+	if v < lo {
+		v = lo
+	}
+	return v
+}
+
+// resolve resolves a host:port address
+func resolve(ctx context.Context, s string) (enet.Address, error) {
+	host, portText, err := net.SplitHostPort(s)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portText, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q", portText)
+	}
+	return enet.ResolveAddress(ctx, "udp", host, uint16(port))
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "gtload: %s\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// stats collects the outcome of every step of every bot
+type stats struct {
+	mu      sync.Mutex
+	started time.Time
+	steps   map[string]*step
+	order   []string
+	current int
+	pings   int
+}
+
+// step is the outcome of one step, such as logging in, across bots
+type step struct {
+	latencies []time.Duration
+	failures  map[string]int
+}
+
+func newStats() *stats {
+	return &stats{
+		started: time.Now(),
+		steps:   make(map[string]*step),
+	}
+}
+
+// record records a step that took d, failing if err isn't nil
+func (st *stats) record(name string, d time.Duration, err error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	s := st.steps[name]
+	if s == nil {
+		s = &step{failures: make(map[string]int)}
+		st.steps[name] = s
+		st.order = append(st.order, name)
+	}
+	if err != nil {
+		s.failures[err.Error()]++
+		return
+	}
+	s.latencies = append(s.latencies, d)
+}
+
+// online counts bots entering (1) or leaving (-1) the world
+func (st *stats) online(delta int) {
+	st.mu.Lock()
+	st.current += delta
+	st.mu.Unlock()
+}
+
+// addPings counts pings answered by a bot
+func (st *stats) addPings(n int) {
+	st.mu.Lock()
+	st.pings += n
+	st.mu.Unlock()
+}
+
+// writeProgress writes a line with the bots online and the steps so far
+func (st *stats) writeProgress(w io.Writer) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	fmt.Fprintf(w, "%6s  online %d", time.Since(st.started).Round(time.Second), st.current)
+	for _, name := range st.order {
+		s := st.steps[name]
+		fmt.Fprintf(w, "  %s %d/%d", name, len(s.latencies), len(s.latencies)+s.count())
+	}
+	fmt.Fprintln(w)
+}
+
+// write writes the summary of the run
+func (st *stats) write(w io.Writer) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	fmt.Fprintf(w, "\n%-6s %6s %6s %8s %8s %8s %8s\n", "step", "ok", "failed", "p50", "p90", "p99", "max")
+	for _, name := range st.order {
+		s := st.steps[name]
+		sorted := append([]time.Duration(nil), s.latencies...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		fmt.Fprintf(w, "%-6s %6d %6d", name, len(sorted), s.count())
+		if name == "play" {
+			// Playing has no latency, only failures.
+			fmt.Fprintln(w)
+			continue
+		}
+		for _, p := range []float64{0.5, 0.9, 0.99, 1} {
+			fmt.Fprintf(w, " %8s", percentile(sorted, p).Round(100*time.Microsecond))
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "pings answered: %d\n", st.pings)
+
+	for _, name := range st.order {
+		s := st.steps[name]
+		errs := make([]string, 0, len(s.failures))
+		for err := range s.failures {
+			errs = append(errs, err)
+		}
+		sort.Slice(errs, func(i, j int) bool { return s.failures[errs[i]] > s.failures[errs[j]] })
+		for _, err := range errs {
+			fmt.Fprintf(w, "%s failed %d times: %s\n", name, s.failures[err], err)
+		}
+	}
+}
+
+// count returns the number of failures of the step
+func (s *step) count() int {
+	n := 0
+	for _, c := range s.failures {
+		n += c
+	}
+	return n
+}
+
+// percentile returns the p-th percentile of sorted latencies, 0 if there
+// are none
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(p*float64(len(sorted))+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	var sorted []time.Duration
+	for i := 1; i <= 100; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Millisecond)
+	}
+	for p, want := range map[float64]time.Duration{0.5: 50 * time.Millisecond, 0.99: 99 * time.Millisecond, 1: 100 * time.Millisecond, 0: time.Millisecond} {
+		if got := percentile(sorted, p); got != want {
+			t.Errorf("percentile %v is %v, want %v", p, got, want)
+		}
+	}
+	if got := percentile(nil, 0.5); got != 0 {
+		t.Errorf("percentile of nothing is %v", got)
+	}
+}
+
+func TestStats(t *testing.T) {
+	st := newStats()
+	st.record("login", 10*time.Millisecond, nil)
+	st.record("login", 30*time.Millisecond, nil)
+	st.record("login", 0, errors.New("server full"))
+	st.record("join", 5*time.Millisecond, nil)
+	st.record("play", 0, errors.New("disconnected"))
+	st.record("play", 0, errors.New("disconnected"))
+	st.addPings(3)
+
+	var out strings.Builder
+	st.write(&out)
+	for _, want := range []string{
+		"login       2      1     10ms     30ms     30ms     30ms",
+		"join        1      0      5ms",
+		"pings answered: 3",
+		"login failed 1 times: server full",
+		"play failed 2 times: disconnected",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("summary doesn't contain %q:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	st.online(2)
+	st.writeProgress(&out)
+	if !strings.Contains(out.String(), "online 2  login 2/3  join 1/1  play 0/2") {
+		t.Errorf("progress line %q", out.String())
+	}
+}
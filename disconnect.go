@@ -0,0 +1,86 @@
+package enet
+
+import (
+	"fmt"
+	"sync"
+)
+
+// DisconnectReason is a code telling why a peer was disconnected, sent as
+// the data of Peer.Disconnect and its variants and returned by
+// Event.DisconnectReason
+type DisconnectReason uint32
+
+// Standard disconnect reasons. Applications register their own codes with
+// RegisterDisconnectReason, from DisconnectUser up.
+const (
+	// DisconnectNone means no reason was given
+	DisconnectNone DisconnectReason = iota
+
+	// DisconnectKicked means the peer was kicked by the server
+	DisconnectKicked
+
+	// DisconnectBanned means the peer is banned
+	DisconnectBanned
+
+	// DisconnectTimeout means the peer stopped responding
+	DisconnectTimeout
+
+	// DisconnectServerShutdown means the server is shutting down
+	DisconnectServerShutdown
+
+	// DisconnectVersionMismatch means the peer runs an unsupported version
+	DisconnectVersionMismatch
+
+	// DisconnectFlood means the peer sent too much data
+	DisconnectFlood
+
+	// DisconnectUser is the first code left to applications
+	DisconnectUser DisconnectReason = 0x100
+)
+
+var (
+	disconnectReasonsMu sync.RWMutex
+	disconnectReasons   = map[DisconnectReason]string{
+		DisconnectNone:            "no reason",
+		DisconnectKicked:          "kicked",
+		DisconnectBanned:          "banned",
+		DisconnectTimeout:         "timed out",
+		DisconnectServerShutdown:  "server shutdown",
+		DisconnectVersionMismatch: "version mismatch",
+		DisconnectFlood:           "flooding",
+	}
+)
+
+// RegisterDisconnectReason gives a description to a disconnect reason. It
+// panics if the reason already has one.
+func RegisterDisconnectReason(reason DisconnectReason, text string) {
+	disconnectReasonsMu.Lock()
+	defer disconnectReasonsMu.Unlock()
+	if old, ok := disconnectReasons[reason]; ok {
+		panic(fmt.Sprintf("enet: disconnect reason %d already registered as %q", uint32(reason), old))
+	}
+	disconnectReasons[reason] = text
+}
+
+// String returns the description of the reason
+func (reason DisconnectReason) String() string {
+	disconnectReasonsMu.RLock()
+	text, ok := disconnectReasons[reason]
+	disconnectReasonsMu.RUnlock()
+	if ok {
+		return text
+	}
+	return fmt.Sprintf("disconnect(%d)", uint32(reason))
+}
+
+// disconnectReason returns the reason of a disconnect event, in the way of
+// Event.DisconnectReason
+func disconnectReason(typ EventType, data uint32, timedOut bool) DisconnectReason {
+	if typ != EventDisconnect {
+		return DisconnectNone
+	}
+	if timedOut {
+		return DisconnectTimeout
+	}
+	return DisconnectReason(data)
+}
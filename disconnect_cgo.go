@@ -0,0 +1,150 @@
+//go:build cgo && !purego
+
+package enet
+
+/*
+#include <string.h>
+#include <enet/enet.h>
+
+extern void goRemoteDisconnect(ENetPeer *peer);
+
+// gotops_new_packet_header_size is the size of the prefix of datagrams in
+// the new packet header modes
+#define GOTOPS_NEW_PACKET_HEADER_SIZE 6
+
+static enet_uint16 gotops_uint16(const enet_uint8 *b) {
+	return (enet_uint16) ((b[0] << 8) | b[1]);
+}
+
+// gotops_scan_disconnects reports the peer a received datagram comes from
+// if the datagram holds a disconnect command, before enet handles it. enet
+// returns disconnections the remote peer sent like timeouts, so this is
+// how the two are told apart. Only datagrams enet would accept count: the
+// header is checked like enet_protocol_handle_incoming_commands does, so
+// that a spoofed or stale disconnect can't pass a timeout off as one.
+static void gotops_scan_disconnects(ENetHost *host) {
+	enet_uint8 buffer[ENET_PROTOCOL_MAXIMUM_MTU];
+	const enet_uint8 *data = host->receivedData;
+	size_t length = host->receivedDataLength;
+
+	if (host->usingNewPacket || host->usingNewPacketForServer) {
+		if (length < GOTOPS_NEW_PACKET_HEADER_SIZE) {
+			return;
+		}
+		data += GOTOPS_NEW_PACKET_HEADER_SIZE;
+		length -= GOTOPS_NEW_PACKET_HEADER_SIZE;
+	}
+	if (length < 2 || length > sizeof(buffer)) {
+		return;
+	}
+
+	enet_uint16 peerID = gotops_uint16(data);
+	enet_uint8 sessionID = (peerID & ENET_PROTOCOL_HEADER_SESSION_MASK) >> ENET_PROTOCOL_HEADER_SESSION_SHIFT;
+	enet_uint16 flags = peerID & ENET_PROTOCOL_HEADER_FLAG_MASK;
+	peerID &= ~(ENET_PROTOCOL_HEADER_FLAG_MASK | ENET_PROTOCOL_HEADER_SESSION_MASK);
+	size_t headerSize = (flags & ENET_PROTOCOL_HEADER_FLAG_SENT_TIME) ? 4 : 2;
+	if (host->checksum != NULL) {
+		headerSize += 4;
+	}
+	if (peerID >= host->peerCount || length < headerSize) {
+		return;
+	}
+
+	ENetPeer *peer = &host->peers[peerID];
+	if (peer->state == ENET_PEER_STATE_DISCONNECTED || peer->state == ENET_PEER_STATE_ZOMBIE ||
+	    peer->state == ENET_PEER_STATE_ACKNOWLEDGING_DISCONNECT ||
+	    peer->address.port != host->receivedAddress.port ||
+	    memcmp(&peer->address.host, &host->receivedAddress.host, sizeof(peer->address.host)) != 0 ||
+	    (peer->outgoingPeerID < ENET_PROTOCOL_MAXIMUM_PEER_ID && sessionID != peer->incomingSessionID)) {
+		return;
+	}
+
+	// Work on a copy, the checksum is computed with the connect ID in
+	// place of the checksum field and enet still has to read the datagram.
+	memcpy(buffer, data, headerSize);
+	if (flags & ENET_PROTOCOL_HEADER_FLAG_COMPRESSED) {
+		if (host->compressor.context == NULL || host->compressor.decompress == NULL) {
+			return;
+		}
+		size_t size = host->compressor.decompress(host->compressor.context, data + headerSize, length - headerSize,
+		                                          buffer + headerSize, sizeof(buffer) - headerSize);
+		if (size == 0) {
+			return;
+		}
+		length = headerSize + size;
+	} else {
+		memcpy(buffer + headerSize, data + headerSize, length - headerSize);
+	}
+
+	if (host->checksum != NULL) {
+		enet_uint8 *field = buffer + headerSize - sizeof(enet_uint32);
+		enet_uint32 desired;
+		memcpy(&desired, field, sizeof(desired));
+		memcpy(field, &peer->connectID, sizeof(peer->connectID));
+		ENetBuffer whole;
+		whole.data = buffer;
+		whole.dataLength = length;
+		if (host->checksum(&whole, 1) != desired) {
+			return;
+		}
+	}
+
+	const enet_uint8 *command = buffer + headerSize;
+	const enet_uint8 *end = buffer + length;
+
+	while (end - command >= 4) {
+		enet_uint8 number = command[0] & ENET_PROTOCOL_COMMAND_MASK;
+		if (number >= ENET_PROTOCOL_COMMAND_COUNT) {
+			return;
+		}
+		size_t size = enet_protocol_command_size(command[0]);
+		if (size == 0 || (size_t) (end - command) < size) {
+			return;
+		}
+		switch (number) {
+		case ENET_PROTOCOL_COMMAND_DISCONNECT:
+			goRemoteDisconnect(peer);
+			return;
+		case ENET_PROTOCOL_COMMAND_SEND_RELIABLE:
+			size += gotops_uint16(command + 4);
+			break;
+		case ENET_PROTOCOL_COMMAND_SEND_UNRELIABLE:
+		case ENET_PROTOCOL_COMMAND_SEND_UNSEQUENCED:
+		case ENET_PROTOCOL_COMMAND_SEND_FRAGMENT:
+		case ENET_PROTOCOL_COMMAND_SEND_UNRELIABLE_FRAGMENT:
+			size += gotops_uint16(command + 6);
+			break;
+		}
+		if ((size_t) (end - command) < size) {
+			return;
+		}
+		command += size;
+	}
+}
+
+static int gotops_intercept(ENetHost *host, ENetEvent *event) {
+	gotops_scan_disconnects(host);
+	return 0;
+}
+
+// gotops_watch_disconnects sets the intercept callback of a host. gotops
+// owns the callback: hosts don't expose their ENetHost, so nothing else can
+// have set one to chain to.
+static void gotops_watch_disconnects(ENetHost *host) {
+	host->intercept = gotops_intercept;
+}
+*/
+import "C"
+
+// watchDisconnects sets the intercept callback of a host, which reports
+// the peers that sent a disconnect command
+func watchDisconnects(cHost *C.ENetHost) {
+	C.gotops_watch_disconnects(cHost)
+}
+
+// forgetDisconnect drops what is known about the disconnection of a peer,
+// once its slot may be reused by another connection
+func forgetDisconnect(cPeer *C.ENetPeer) {
+	localDisconnects.Delete(cPeer)
+	remoteDisconnects.Delete(cPeer)
+}
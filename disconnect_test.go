@@ -0,0 +1,144 @@
+package enet_test
+
+import (
+	"testing"
+	"time"
+
+	enet "github.com/eikarna/gotops"
+)
+
+const disconnectWorldClosed = enet.DisconnectUser
+
+func init() {
+	enet.RegisterDisconnectReason(disconnectWorldClosed, "world closed")
+}
+
+func TestDisconnectReasonString(t *testing.T) {
+	if got := enet.DisconnectBanned.String(); got != "banned" {
+		t.Errorf("DisconnectBanned is %q", got)
+	}
+	if got := (enet.DisconnectUser + 1).String(); got != "disconnect(257)" {
+		t.Errorf("unregistered reason is %q", got)
+	}
+	if got := disconnectWorldClosed.String(); got != "world closed" {
+		t.Errorf("registered reason is %q", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a reason twice didn't panic")
+		}
+	}()
+	enet.RegisterDisconnectReason(enet.DisconnectKicked, "kicked again")
+}
+
+// waitDisconnect services host until a disconnect event, servicing other
+// alongside
+func waitDisconnect(t *testing.T, host, other enet.Host) enet.Event {
+	t.Helper()
+	for start := time.Now(); time.Since(start) < 5*time.Second; {
+		if other != nil {
+			other.Service(0)
+		}
+		if ev := host.Service(1); ev.GetType() == enet.EventDisconnect {
+			return ev
+		}
+	}
+	t.Fatal("no disconnect event")
+	return nil
+}
+
+func TestDisconnectReason(t *testing.T) {
+	port := getFreePort()
+	server, err := enet.NewHost(enet.ENET_ADDRESS_TYPE_IPV4, enet.NewListenAddress(enet.ENET_ADDRESS_TYPE_IPV4, port), 4, 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Destroy()
+
+	connect := func() (enet.Host, enet.Peer) {
+		client, err := enet.NewHost(enet.ENET_ADDRESS_TYPE_IPV4, nil, 1, 1, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.Connect(enet.NewAddress(enet.ENET_ADDRESS_TYPE_IPV4, "127.0.0.1", port), 1, 0); err != nil {
+			t.Fatal(err)
+		}
+		for start := time.Now(); time.Since(start) < 2*time.Second; {
+			client.Service(0)
+			if ev := server.Service(1); ev.GetType() == enet.EventConnect {
+				return client, ev.GetPeer()
+			}
+		}
+		t.Fatal("client didn't connect")
+		return nil, nil
+	}
+
+	// A remote disconnect carries its reason.
+	client, _ := connect()
+	client.ConnectedPeers()[0].Disconnect(uint32(enet.DisconnectVersionMismatch))
+	ev := waitDisconnect(t, server, client)
+	client.Destroy()
+	if ev.TimedOut() || ev.DisconnectReason() != enet.DisconnectVersionMismatch {
+		t.Errorf("remote disconnect: timed out %v, reason %v", ev.TimedOut(), ev.DisconnectReason())
+	}
+
+	// So does one without data.
+	client, _ = connect()
+	client.ConnectedPeers()[0].Disconnect(0)
+	ev = waitDisconnect(t, server, client)
+	client.Destroy()
+	if ev.TimedOut() || ev.DisconnectReason() != enet.DisconnectNone {
+		t.Errorf("remote disconnect without data: timed out %v, reason %v", ev.TimedOut(), ev.DisconnectReason())
+	}
+
+	// A peer that stops responding times out.
+	client, peer := connect()
+	client.Destroy()
+	peer.PeerTimeout(1, 200, 500)
+	peer.SendString("anyone there?", 0, enet.PacketFlagReliable)
+	ev = waitDisconnect(t, server, nil)
+	if !ev.TimedOut() || ev.DisconnectReason() != enet.DisconnectTimeout {
+		t.Errorf("timeout: timed out %v, reason %v", ev.TimedOut(), ev.DisconnectReason())
+	}
+}
+
+func TestDisconnectConnectingTimeout(t *testing.T) {
+	port := getFreePort()
+	server, err := enet.NewHost(enet.ENET_ADDRESS_TYPE_IPV4, enet.NewListenAddress(enet.ENET_ADDRESS_TYPE_IPV4, port), 1, 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := enet.NewHost(enet.ENET_ADDRESS_TYPE_IPV4, nil, 1, 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Destroy()
+
+	// Disconnecting a peer still connecting resets it without an event, so
+	// the connection reusing it must not count as disconnected by this side.
+	peer, err := client.Connect(enet.NewAddress(enet.ENET_ADDRESS_TYPE_IPV4, "127.0.0.1", getFreePort()), 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer.Disconnect(0)
+	peer, err = client.Connect(enet.NewAddress(enet.ENET_ADDRESS_TYPE_IPV4, "127.0.0.1", port), 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for start := time.Now(); peer.State() != enet.Connected; {
+		if time.Since(start) > 2*time.Second {
+			t.Fatal("client didn't connect")
+		}
+		server.Service(0)
+		client.Service(1)
+	}
+
+	server.Destroy()
+	peer.PeerTimeout(1, 200, 500)
+	peer.SendString("anyone there?", 0, enet.PacketFlagReliable)
+	ev := waitDisconnect(t, client, nil)
+	if !ev.TimedOut() || ev.DisconnectReason() != enet.DisconnectTimeout {
+		t.Errorf("timed out %v, reason %v", ev.TimedOut(), ev.DisconnectReason())
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !cgo || purego
+
+package enet
+
+// Initialize enet
+func Initialize() {
+}
+
+// Deinitialize enet
+func Deinitialize() {
+}
+
+// LinkedVersion returns the version of the enet protocol implemented by the
+// pure-Go backend. Returns MAJOR.MINOR.PATCH as a string.
+func LinkedVersion() string {
+	return "1.3.18"
+}
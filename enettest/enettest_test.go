@@ -0,0 +1,234 @@
+package enettest_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	enet "github.com/eikarna/gotops"
+	"github.com/eikarna/gotops/enettest"
+)
+
+// run advances the network clock by a millisecond at a time, servicing
+// every host in order, until handle returns true or steps run out. Events
+// are passed to handle along with the name of the host returning them.
+func run(network *enettest.Network, steps int, handle func(name string, ev enet.Event) bool, hosts ...enet.Host) bool {
+	for i := 0; i < steps; i++ {
+		network.Advance(time.Millisecond)
+		for _, host := range hosts {
+			name := network.Name(host.GetAddress())
+			for {
+				ev := host.Service(0)
+				if ev.GetType() == enet.EventNone {
+					break
+				}
+				done := handle(name, ev)
+				if ev.GetType() == enet.EventReceive {
+					ev.GetPacket().Destroy()
+				}
+				if done {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// newPair creates a server and a client connected to it
+func newPair(t *testing.T, network *enettest.Network) (server, client enet.Host, peer enet.Peer) {
+	t.Helper()
+
+	server, err := network.NewHost("server", 8, 2, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err = network.NewHost("", 1, 2, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Destroy()
+		server.Destroy()
+	})
+
+	peer, err = client.Connect(network.Address("server"), 2, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connected := 0
+	ok := run(network, 10000, func(name string, ev enet.Event) bool {
+		if ev.GetType() == enet.EventConnect {
+			connected++
+		}
+		return connected == 2
+	}, server, client)
+	if !ok {
+		t.Fatal("client didn't connect")
+	}
+	return server, client, peer
+}
+
+func TestConnect(t *testing.T) {
+	network := enettest.NewNetwork(1)
+	server, client, peer := newPair(t, network)
+
+	if peer.State() != enet.Connected {
+		t.Errorf("client peer state %d, want %d", peer.State(), enet.Connected)
+	}
+	peers := server.ConnectedPeers()
+	if len(peers) != 1 {
+		t.Fatalf("server has %d connected peers, want 1", len(peers))
+	}
+	if got, want := network.Name(peers[0].GetAddress()), network.Name(client.GetAddress()); got != want {
+		t.Errorf("server peer address %q, want %q", got, want)
+	}
+	if got := network.Name(server.GetAddress()); got != "server" {
+		t.Errorf("server address %q, want %q", got, "server")
+	}
+}
+
+func TestUnknownHost(t *testing.T) {
+	network := enettest.NewNetwork(1)
+	client, err := network.NewHost("", 1, 2, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Destroy()
+
+	if _, err := client.Connect(network.Address("nowhere"), 2, 0); !errors.Is(err, enettest.ErrUnknownHost) {
+		t.Errorf("Connect returned %v, want %v", err, enettest.ErrUnknownHost)
+	}
+	if _, err := network.NewHost(network.Name(client.GetAddress()), 1, 2, 0, 0); !errors.Is(err, enettest.ErrNameInUse) {
+		t.Errorf("NewHost returned %v, want %v", err, enettest.ErrNameInUse)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	network := enettest.NewNetwork(1)
+	server, client, peer := newPair(t, network)
+	network.SetLink(network.Name(client.GetAddress()), "server", enettest.Link{Latency: 50 * time.Millisecond})
+
+	sent := network.Now()
+	peer.SendString("hello", 0, enet.PacketFlagReliable)
+
+	var received time.Time
+	run(network, 1000, func(name string, ev enet.Event) bool {
+		if ev.GetType() != enet.EventReceive {
+			return false
+		}
+		received = network.Now()
+		return true
+	}, server, client)
+	if received.IsZero() {
+		t.Fatal("packet wasn't received")
+	}
+	if delay := received.Sub(sent); delay < 50*time.Millisecond || delay > 52*time.Millisecond {
+		t.Errorf("packet received after %v, want 50ms", delay)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	network := enettest.NewNetwork(1)
+	server, client, peer := newPair(t, network)
+
+	var batch []enet.Outgoing
+	for i := 0; i < 5; i++ {
+		batch = append(batch, enet.Outgoing{Peer: peer, Data: []byte{byte(i)}, Flags: enet.PacketFlagReliable})
+	}
+	if err := client.SendBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+	client.Service(0)
+	network.Advance(time.Millisecond)
+
+	var received []byte
+	for _, max := range []int{2, 2, 2} {
+		events := server.ServiceBatch(max)
+		if len(events) > max {
+			t.Fatalf("%d events serviced with a maximum of %d", len(events), max)
+		}
+		for _, ev := range events {
+			received = append(received, ev.GetPacket().GetData()...)
+			ev.GetPacket().Destroy()
+		}
+	}
+	if !reflect.DeepEqual(received, []byte{0, 1, 2, 3, 4}) {
+		t.Errorf("received %v", received)
+	}
+	if events := server.ServiceBatch(2); events != nil {
+		t.Errorf("%d events left", len(events))
+	}
+}
+
+// exchange sends numbered reliable packets through a lossy, reordering link
+// and returns every event of the server
+func exchange(t *testing.T, seed int64, count int) []string {
+	network := enettest.NewNetwork(seed)
+	network.SetDefaultLink(enettest.Link{
+		Latency: 20 * time.Millisecond,
+		Jitter:  10 * time.Millisecond,
+		Loss:    0.2,
+		Reorder: 0.2,
+	})
+	server, client, peer := newPair(t, network)
+
+	for i := 0; i < count; i++ {
+		peer.SendString(fmt.Sprint(i), 0, enet.PacketFlagReliable)
+	}
+
+	var log []string
+	received := 0
+	ok := run(network, 60000, func(name string, ev enet.Event) bool {
+		if name != "server" {
+			return false
+		}
+		log = append(log, fmt.Sprintf("%v %d %s", network.Now().Sub(time.Unix(0, 0)), ev.GetType(), ev.GetPacket().GetData()))
+		if ev.GetType() == enet.EventReceive {
+			if got, want := string(ev.GetPacket().GetData()), fmt.Sprint(received); got != want {
+				t.Errorf("received %q, want %q", got, want)
+			}
+			received++
+		}
+		return received == count
+	}, server, client)
+	if !ok {
+		t.Fatalf("received %d of %d packets", received, count)
+	}
+	if network.Dropped() == 0 {
+		t.Error("no datagram was dropped")
+	}
+	return log
+}
+
+func TestLossAndReordering(t *testing.T) {
+	first := exchange(t, 7, 50)
+	second := exchange(t, 7, 50)
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("runs with the same seed differ:\n%v\n%v", first, second)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	network := enettest.NewNetwork(1)
+	server, client, _ := newPair(t, network)
+	network.SetLink("server", network.Name(client.GetAddress()), enettest.Link{Loss: 1})
+	network.SetLink(network.Name(client.GetAddress()), "server", enettest.Link{Loss: 1})
+
+	start := network.Now()
+	ok := run(network, 60000, func(name string, ev enet.Event) bool {
+		return ev.GetType() == enet.EventDisconnect
+	}, server)
+	if !ok {
+		t.Fatal("server peer didn't time out")
+	}
+	if elapsed := network.Now().Sub(start); elapsed < 5*time.Second || elapsed > 30*time.Second {
+		t.Errorf("server peer timed out after %v", elapsed)
+	}
+	if peers := server.ConnectedPeers(); len(peers) != 0 {
+		t.Errorf("server has %d connected peers after timeout", len(peers))
+	}
+}
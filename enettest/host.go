@@ -0,0 +1,520 @@
+package enettest
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"math"
+	"net"
+	"net/netip"
+	"strconv"
+	"time"
+
+	enet "github.com/eikarna/gotops"
+	"github.com/eikarna/gotops/internal/leak"
+	"github.com/eikarna/gotops/internal/protocol"
+)
+
+// NewHost creates a host attached to the network, like enet.NewHost. Other
+// hosts connect to it by name. An empty name picks a unique one, which is
+// enough for clients.
+func (n *Network) NewHost(name string, peerCount, channelLimit uint64, incomingBandwidth, outgoingBandwidth uint32) (enet.Host, error) {
+	c, err := n.attach(name)
+	if err != nil {
+		return nil, err
+	}
+
+	n.mu.Lock()
+	seed := n.random.Int63()
+	n.mu.Unlock()
+
+	ph, err := protocol.NewHost(c, protocol.Config{
+		PeerCount:         int(peerCount),
+		ChannelLimit:      int(channelLimit),
+		IncomingBandwidth: incomingBandwidth,
+		OutgoingBandwidth: outgoingBandwidth,
+		Clock:             n.Now,
+		Seed:              seed,
+	})
+	if err != nil {
+		c.Close()
+		return nil, errors.New("unable to create host")
+	}
+
+	return &host{
+		network: n,
+		conn:    c,
+		host:    ph,
+	}, nil
+}
+
+// Address returns the address of the host with the given name, to pass to
+// Host.Connect
+func (n *Network) Address(name string) enet.Address {
+	return &address{
+		network: n,
+		name:    name,
+		port:    Port,
+	}
+}
+
+// Name returns the name of the host an address refers to, such as the
+// address of a peer, to pass to SetLink or NewHost
+func (n *Network) Name(addr enet.Address) string {
+	if addr, ok := addr.(*address); ok {
+		return addr.name
+	}
+	return n.name(addr.AddrPort())
+}
+
+// host is a host attached to a Network
+type host struct {
+	network *Network
+	conn    *conn
+	host    *protocol.Host
+
+	usingReceivedEvents bool
+}
+
+// GetAddress returns the address of the host, its name on the network
+func (h *host) GetAddress() enet.Address {
+	return &address{
+		network: h.network,
+		name:    h.conn.name,
+		port:    Port,
+	}
+}
+
+// ConnectedPeers returns a list of connected peers
+func (h *host) ConnectedPeers() []enet.Peer {
+	peers := h.Peers(enet.InState(enet.Connected))
+	if peers == nil {
+		peers = make([]enet.Peer, 0)
+	}
+	return peers
+}
+
+// Peers returns the peers selected by filter, or every peer that isn't
+// disconnected
+func (h *host) Peers(filter func(enet.Peer) bool) []enet.Peer {
+	return h.AppendPeers(nil, filter)
+}
+
+// AppendPeers appends the peers selected by filter, or every peer that
+// isn't disconnected, to dst
+func (h *host) AppendPeers(dst []enet.Peer, filter func(enet.Peer) bool) []enet.Peer {
+	for p := range h.AllPeers(filter) {
+		dst = append(dst, p)
+	}
+	return dst
+}
+
+// AllPeers returns an iterator over the peers selected by filter, or every
+// peer that isn't disconnected
+func (h *host) AllPeers(filter func(enet.Peer) bool) iter.Seq[enet.Peer] {
+	return func(yield func(enet.Peer) bool) {
+		peers := h.host.Peers()
+		for i := range peers {
+			if peers[i].State() == protocol.StateDisconnected {
+				continue
+			}
+			p := peer{network: h.network, peer: &peers[i]}
+			if filter != nil && !filter(p) {
+				continue
+			}
+			if !yield(p) {
+				return
+			}
+		}
+	}
+}
+
+// PeerCount returns the number of connected peers
+func (h *host) PeerCount() int {
+	count := 0
+	peers := h.host.Peers()
+	for i := range peers {
+		if peers[i].State() == protocol.StateConnected {
+			count++
+		}
+	}
+	return count
+}
+
+// PeerByAddress returns the peer at the given address, if any
+func (h *host) PeerByAddress(addr enet.Address) (enet.Peer, bool) {
+	for p := range h.AllPeers(nil) {
+		if enet.AddressEqual(p.GetAddress(), addr) {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// SocketFD returns enet.ErrNoSocket, as hosts attached to a network have
+// no socket
+func (h *host) SocketFD() (uintptr, error) {
+	return 0, enet.ErrNoSocket
+}
+
+// Destroy detaches the host from the network
+func (h *host) Destroy() {
+	h.host.Close()
+}
+
+// UsingNewPacketForServer sets the host to use the new packet header (for
+// servers)
+func (h *host) UsingNewPacketForServer(state bool) {
+	h.host.NewPacketHeaderForServer = state
+}
+
+// UsingNewPacket sets the host to use the new packet header
+func (h *host) UsingNewPacket(state bool) {
+	h.host.NewPacketHeader = state
+}
+
+// UsingReceivedEvents sets the host to return events owning their payload
+func (h *host) UsingReceivedEvents(state bool) {
+	h.usingReceivedEvents = state
+}
+
+// Service sends queued commands and handles the datagrams that have arrived
+// by the current time of the network clock. It never waits: the timeout is
+// only used to bound how far the host looks ahead, as time doesn't pass
+// until Network.Advance is called.
+func (h *host) Service(timeout uint32) enet.Event {
+	ev, err := h.host.Service(time.Duration(timeout) * time.Millisecond)
+	if err != nil {
+		return &event{}
+	}
+	return h.wrap(ev)
+}
+
+// ServiceBatch services the host once like Service with a zero timeout and
+// returns up to max events
+func (h *host) ServiceBatch(max int) []enet.Event {
+	if max <= 0 {
+		return nil
+	}
+	ev := h.Service(0)
+	if ev.GetType() == enet.EventNone {
+		return nil
+	}
+	ret := []enet.Event{ev}
+	for len(ret) < max {
+		ev, ok := h.host.CheckEvents()
+		if !ok {
+			break
+		}
+		ret = append(ret, h.wrap(ev))
+	}
+	return ret
+}
+
+// wrap returns a serviced event the way the host was set to return events
+func (h *host) wrap(ev protocol.Event) enet.Event {
+	if ev.Type == protocol.EventReceive {
+		leak.Track(leak.KindReceivedPacket, ev.Packet)
+	}
+	if h.usingReceivedEvents {
+		return enet.NewReceivedEvent(&event{network: h.network, event: ev})
+	}
+	return &event{
+		network: h.network,
+		event:   ev,
+	}
+}
+
+// SendBatch sends every packet of batch to its peer
+func (h *host) SendBatch(batch []enet.Outgoing) error {
+	for _, out := range batch {
+		if err := out.Peer.SendBytes(out.Data, out.Channel, out.Flags&^enet.PacketFlagNoAllocate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Connect to a host on the network by name
+func (h *host) Connect(addr enet.Address, channelCount int, data uint32) (enet.Peer, error) {
+	name := h.network.Name(addr)
+	to, ok := h.network.lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownHost, name)
+	}
+
+	p, err := h.host.Connect(to, channelCount, data)
+	if errors.Is(err, protocol.ErrNewPacketHeader) {
+		return nil, enet.ErrNewPacketUnsupported
+	} else if err != nil {
+		return nil, errors.New("couldn't connect to foreign peer")
+	}
+
+	return peer{
+		network: h.network,
+		peer:    p,
+	}, nil
+}
+
+// CompressWithRangeCoder fails, as the protocol implementation doesn't
+// support compression
+func (h *host) CompressWithRangeCoder() error {
+	return errors.New("couldn't set the packet compressor to default range coder because enettest doesn't support compression")
+}
+
+// EnableChecksum enables crc32 checksums for the host
+func (h *host) EnableChecksum() {
+	h.host.Checksum = true
+}
+
+// BroadcastBytes sends a byte array to all connected peers
+func (h *host) BroadcastBytes(data []byte, channel uint8, flags enet.PacketFlags) error {
+	return h.BroadcastPacket(NewPacket(data, flags), channel)
+}
+
+// BroadcastPacket sends a packet to all connected peers
+func (h *host) BroadcastPacket(p enet.Packet, channel uint8) error {
+	h.host.Broadcast(channel, toPacket(p))
+	return nil
+}
+
+// BroadcastString sends a string to all connected peers
+func (h *host) BroadcastString(str string, channel uint8, flags enet.PacketFlags) error {
+	return h.BroadcastPacket(NewPacket([]byte(str), flags), channel)
+}
+
+// peer is a peer of a host attached to a Network
+type peer struct {
+	network *Network
+	peer    *protocol.Peer
+}
+
+// State returns the state of the peer
+func (p peer) State() enet.EnetPeerState {
+	return enet.EnetPeerState(p.peer.State())
+}
+
+// GetConnectID returns the connect ID of the peer
+func (p peer) GetConnectID() uint32 {
+	return p.peer.ConnectID()
+}
+
+// GetAddress returns the address of the peer, the name of its host
+func (p peer) GetAddress() enet.Address {
+	return &address{
+		network: p.network,
+		name:    p.network.name(p.peer.Address()),
+		port:    p.peer.Address().Port(),
+	}
+}
+
+// Disconnect the peer from the host
+func (p peer) Disconnect(data uint32) {
+	p.peer.Disconnect(data)
+}
+
+// DisconnectNow disconnects the peer from the host immediately
+func (p peer) DisconnectNow(data uint32) {
+	p.peer.DisconnectNow(data)
+}
+
+// DisconnectLater disconnects the peer once its queued packets are sent
+func (p peer) DisconnectLater(data uint32) {
+	p.peer.DisconnectLater(data)
+}
+
+// PeerTimeout sets the timeout parameters of the peer
+func (p peer) PeerTimeout(timeoutLimit, timeoutMin, timeoutMax uint32) {
+	p.peer.Timeout(timeoutLimit, timeoutMin, timeoutMax)
+}
+
+// SendBytes sends a byte slice to the peer
+func (p peer) SendBytes(data []byte, channel uint8, flags enet.PacketFlags) error {
+	return p.SendPacket(NewPacket(data, flags), channel)
+}
+
+// SendString sends a string to the peer
+func (p peer) SendString(str string, channel uint8, flags enet.PacketFlags) error {
+	return p.SendPacket(NewPacket([]byte(str), flags), channel)
+}
+
+// SendPacket sends a packet to the peer. Like enet, the packet belongs to
+// the peer afterwards and packets that can't be queued are dropped.
+func (p peer) SendPacket(pk enet.Packet, channel uint8) error {
+	p.peer.Send(channel, toPacket(pk))
+	return nil
+}
+
+// SetData sets an arbitrary value against the peer
+func (p peer) SetData(data []byte) {
+	if len(data) > math.MaxUint32 {
+		panic(fmt.Sprintf("maximum peer data length is uint32 (%d)", math.MaxUint32))
+	}
+
+	if data == nil {
+		leak.Release(p.peer)
+		p.peer.Data = nil
+		return
+	}
+	leak.Track(leak.KindPeerData, p.peer)
+	p.peer.Data = append([]byte{}, data...)
+}
+
+// GetData returns the value set against the peer, nil if none is set
+func (p peer) GetData() []byte {
+	if p.peer.Data == nil {
+		return nil
+	}
+	return append([]byte{}, p.peer.Data...)
+}
+
+// packet is a packet sent or received on a Network
+type packet struct {
+	packet *protocol.Packet
+}
+
+// NewPacket creates a packet to send to peers on a Network. Packets created
+// with enet.NewPacket may be sent as well, they are copied and destroyed.
+func NewPacket(data []byte, flags enet.PacketFlags) enet.Packet {
+	p := protocol.NewPacket(data, uint32(flags)&^uint32(enet.PacketFlagNoAllocate))
+	leak.Track(leak.KindPacket, p)
+	return packet{
+		packet: p,
+	}
+}
+
+// toPacket returns the protocol packet to send for p
+func toPacket(p enet.Packet) *protocol.Packet {
+	if p, ok := p.(packet); ok {
+		leak.Release(p.packet)
+		return p.packet
+	}
+	ret := protocol.NewPacket(p.GetData(), uint32(p.GetFlags())&^uint32(enet.PacketFlagNoAllocate))
+	p.Destroy()
+	return ret
+}
+
+// Destroy releases the packet
+func (p packet) Destroy() {
+	leak.Release(p.packet)
+	p.packet.Destroy()
+}
+
+// GetData returns a copy of the data of the packet
+func (p packet) GetData() []byte {
+	return append([]byte(nil), p.packet.Data...)
+}
+
+// GetFlags returns the flags of the packet
+func (p packet) GetFlags() enet.PacketFlags {
+	return enet.PacketFlags(p.packet.Flags)
+}
+
+// event is an event returned by a host attached to a Network
+type event struct {
+	network *Network
+	event   protocol.Event
+}
+
+func (ev *event) GetType() enet.EventType {
+	return enet.EventType(ev.event.Type)
+}
+
+func (ev *event) GetPeer() enet.Peer {
+	return peer{
+		network: ev.network,
+		peer:    ev.event.Peer,
+	}
+}
+
+func (ev *event) GetChannelID() uint8 {
+	return ev.event.ChannelID
+}
+
+func (ev *event) GetData() uint32 {
+	return ev.event.Data
+}
+
+func (ev *event) GetPacket() enet.Packet {
+	return packet{
+		packet: ev.event.Packet,
+	}
+}
+
+func (ev *event) DisconnectReason() enet.DisconnectReason {
+	switch {
+	case ev.event.Type != protocol.EventDisconnect:
+		return enet.DisconnectNone
+	case ev.event.Timeout:
+		return enet.DisconnectTimeout
+	}
+	return enet.DisconnectReason(ev.event.Data)
+}
+
+func (ev *event) TimedOut() bool {
+	return ev.event.Type == protocol.EventDisconnect && ev.event.Timeout
+}
+
+// address is the address of a host on a Network, which is its name
+type address struct {
+	network *Network
+	name    string
+	port    uint16
+}
+
+// BuildAny clears the name of the address
+func (addr *address) BuildAny(addressType enet.ENetAddressType) {
+	addr.name = ""
+}
+
+// SetHost sets the name of the host the address refers to
+func (addr *address) SetHost(addressType enet.ENetAddressType, name string) {
+	addr.name = name
+}
+
+// SetPort sets the port of the address
+func (addr *address) SetPort(port uint16) {
+	addr.port = port
+}
+
+// String returns the name and port of the address as name:port, like
+// HostPort. Network.Name returns the name alone.
+func (addr *address) String() string {
+	return addr.HostPort()
+}
+
+// GetPort returns the port of the address
+func (addr *address) GetPort() uint16 {
+	return addr.port
+}
+
+// AddrPort returns the virtual address of the host the address refers to,
+// or an invalid address if there is no such host
+func (addr *address) AddrPort() netip.AddrPort {
+	if addr.network != nil {
+		if addrPort, ok := addr.network.lookup(addr.name); ok {
+			return netip.AddrPortFrom(addrPort.Addr(), addr.port)
+		}
+	}
+	if ip, err := netip.ParseAddr(addr.name); err == nil {
+		return netip.AddrPortFrom(ip.Unmap(), addr.port)
+	}
+	return netip.AddrPortFrom(netip.Addr{}, addr.port)
+}
+
+// HostPort returns the name and port of the address as name:port
+func (addr *address) HostPort() string {
+	return net.JoinHostPort(addr.name, strconv.Itoa(int(addr.port)))
+}
+
+// NetAddr returns the virtual address of the host the address refers to
+func (addr *address) NetAddr() *net.UDPAddr {
+	return net.UDPAddrFromAddrPort(addr.AddrPort())
+}
+
+// Network returns the network of the address, "udp"
+func (addr *address) Network() string {
+	return "udp"
+}
+
+var _ net.Addr = (*address)(nil)
@@ -0,0 +1,34 @@
+package enettest
+
+import (
+	"testing"
+
+	"github.com/eikarna/gotops/internal/leak"
+)
+
+// LeakTracking tells whether packets and peer data are tracked, which
+// takes building with the enetleak tag:
+//
+//	go test -tags enetleak ./...
+const LeakTracking = leak.Enabled
+
+// AssertNoLeaks fails the test if packets created or received from now on
+// weren't destroyed or sent, or peer data set from now on wasn't cleared
+// with SetData(nil), by the time the test and its cleanups end. Each leak
+// is reported with the stack of its allocation. Hosts of enet and of
+// Network are both tracked.
+//
+// Without the enetleak tag it does nothing. Allocations of parallel tests
+// can't be told apart, so only use it in tests that don't run in parallel.
+func AssertNoLeaks(t testing.TB) {
+	t.Helper()
+	if !leak.Enabled {
+		return
+	}
+	mark := leak.Mark()
+	t.Cleanup(func() {
+		for _, l := range leak.Outstanding(mark) {
+			t.Errorf("%s leaked, allocated at:\n%s", l.Kind, l.Stack)
+		}
+	})
+}
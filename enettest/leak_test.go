@@ -0,0 +1,121 @@
+//go:build enetleak
+
+package enettest_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	enet "github.com/eikarna/gotops"
+	"github.com/eikarna/gotops/enettest"
+)
+
+// leakRecorder records the errors reported by AssertNoLeaks instead of
+// failing the test
+type leakRecorder struct {
+	testing.TB
+	errors   []string
+	cleanups []func()
+}
+
+func (r *leakRecorder) Helper() {}
+
+func (r *leakRecorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *leakRecorder) Cleanup(f func()) {
+	r.cleanups = append(r.cleanups, f)
+}
+
+// finish runs the cleanups, as at the end of a test
+func (r *leakRecorder) finish() {
+	for i := len(r.cleanups) - 1; i >= 0; i-- {
+		r.cleanups[i]()
+	}
+}
+
+// receive services server until it receives a packet
+func receive(t *testing.T, network *enettest.Network, server, client enet.Host) enet.Packet {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		network.Advance(time.Millisecond)
+		client.Service(0)
+		if ev := server.Service(0); ev.GetType() == enet.EventReceive {
+			return ev.GetPacket()
+		}
+	}
+	t.Fatal("nothing received")
+	return nil
+}
+
+func TestAssertNoLeaks(t *testing.T) {
+	network := enettest.NewNetwork(1)
+	server, client, peer := newPair(t, network)
+	serverPeer := server.ConnectedPeers()[0]
+
+	r := &leakRecorder{TB: t}
+	enettest.AssertNoLeaks(r)
+	enet.NewPacket([]byte("never sent"), 0)
+	peer.SendString("hello", 0, enet.PacketFlagReliable)
+	received := receive(t, network, server, client)
+	serverPeer.SetData([]byte("player"))
+	r.finish()
+
+	kinds := map[string]bool{}
+	for _, e := range r.errors {
+		kinds[e[:strings.Index(e, " leaked")]] = true
+		if !strings.Contains(e, "TestAssertNoLeaks") {
+			t.Errorf("leak report doesn't point at the test:\n%s", e)
+		}
+	}
+	for _, kind := range []string{"packet", "received packet", "peer data"} {
+		if !kinds[kind] {
+			t.Errorf("%s leak not reported, got %q", kind, r.errors)
+		}
+	}
+
+	// Released allocations aren't reported.
+	r = &leakRecorder{TB: t}
+	enettest.AssertNoLeaks(r)
+	received.Destroy()
+	serverPeer.SetData(nil)
+	packet, _ := enet.NewPacket([]byte("destroyed"), 0)
+	packet.Destroy()
+	peer.SendString("hello again", 0, enet.PacketFlagReliable)
+	receive(t, network, server, client).Destroy()
+	r.finish()
+	if len(r.errors) != 0 {
+		t.Errorf("released allocations reported: %q", r.errors)
+	}
+}
+
+func TestPeerDataTracked(t *testing.T) {
+	network := enettest.NewNetwork(1)
+	server, _, _ := newPair(t, network)
+	serverPeer := server.ConnectedPeers()[0]
+
+	// Replaced and cleared data isn't reported.
+	r := &leakRecorder{TB: t}
+	enettest.AssertNoLeaks(r)
+	serverPeer.SetData([]byte{1, 2, 3})
+	serverPeer.SetData([]byte{4, 5, 6})
+	serverPeer.SetData(nil)
+	r.finish()
+	if len(r.errors) != 0 {
+		t.Errorf("cleared peer data reported: %q", r.errors)
+	}
+
+	// Data left set is reported once.
+	r = &leakRecorder{TB: t}
+	enettest.AssertNoLeaks(r)
+	serverPeer.SetData([]byte{1, 2, 3})
+	serverPeer.SetData([]byte{4, 5, 6})
+	r.finish()
+	serverPeer.SetData(nil)
+	if len(r.errors) != 1 || !strings.HasPrefix(r.errors[0], "peer data leaked") {
+		t.Errorf("peer data left set reported as %q", r.errors)
+	}
+}
@@ -0,0 +1,306 @@
+// Package enettest provides an in-memory network of ENet hosts for tests.
+//
+// Hosts created on a Network implement enet.Host and speak the real ENet
+// protocol, but exchange datagrams in memory and read the time from the
+// network's manual clock. Nothing happens unless the test services a host or
+// advances the clock, so protocol logic can be tested deterministically:
+//
+//	network := enettest.NewNetwork(1)
+//	server, _ := network.NewHost("server", 32, 2, 0, 0)
+//	client, _ := network.NewHost("", 1, 2, 0, 0)
+//	client.Connect(network.Address("server"), 2, 0)
+//
+//	for i := 0; i < 100; i++ {
+//		network.Advance(time.Millisecond)
+//		client.Service(0)
+//		if ev := server.Service(0); ev.GetType() == enet.EventConnect {
+//			break
+//		}
+//	}
+//
+// Latency, jitter, loss and reordering can be injected per link with
+// Network.SetLink.
+package enettest
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/eikarna/gotops/internal/protocol"
+)
+
+// ErrUnknownHost is returned when connecting to a name no host listens on
+var ErrUnknownHost = errors.New("enettest: unknown host")
+
+// ErrNameInUse is returned when creating a host with a name that is taken
+var ErrNameInUse = errors.New("enettest: host name already in use")
+
+// Port is the port every virtual host appears to be bound to
+const Port = 17091
+
+// Link describes the conditions datagrams experience from one host to
+// another. The zero value delivers every datagram instantly and in order.
+type Link struct {
+	// Latency is the one-way delay of every datagram
+	Latency time.Duration
+
+	// Jitter adds a random delay between 0 and Jitter to every datagram.
+	// Datagrams are delivered in order of arrival, so jitter reorders them.
+	Jitter time.Duration
+
+	// Loss is the probability, between 0 and 1, of dropping a datagram
+	Loss float64
+
+	// Reorder is the probability, between 0 and 1, of holding a datagram
+	// back for an extra Latency+Jitter+1ms so that the datagrams sent after
+	// it overtake it
+	Reorder float64
+}
+
+// Network is an in-memory network of hosts sharing a manual clock. It is
+// safe for concurrent use, but like enet hosts, each host must only be
+// serviced by one goroutine at a time.
+type Network struct {
+	mu          sync.Mutex
+	now         time.Time
+	random      *rand.Rand
+	defaultLink Link
+	links       map[[2]string]Link
+	conns       map[string]*conn
+	addrs       map[netip.AddrPort]*conn
+	hosts       int
+	dropped     int
+}
+
+// NewNetwork creates an empty network. The seed makes loss, jitter and
+// reordering reproducible.
+func NewNetwork(seed int64) *Network {
+	return &Network{
+		now:    time.Unix(0, 0),
+		random: rand.New(rand.NewSource(seed)),
+		links:  make(map[[2]string]Link),
+		conns:  make(map[string]*conn),
+		addrs:  make(map[netip.AddrPort]*conn),
+	}
+}
+
+// Now returns the current time of the network clock
+func (n *Network) Now() time.Time {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.now
+}
+
+// Advance moves the network clock forward. Datagrams whose delay has
+// elapsed become available to the hosts they were sent to.
+func (n *Network) Advance(d time.Duration) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.now = n.now.Add(d)
+}
+
+// SetDefaultLink sets the conditions of every link without its own
+// conditions set with SetLink
+func (n *Network) SetDefaultLink(link Link) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.defaultLink = link
+}
+
+// SetLink sets the conditions of datagrams sent from one host to another.
+// Links are one-way, set both directions to degrade a connection entirely.
+func (n *Network) SetLink(from, to string, link Link) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.links[[2]string{from, to}] = link
+}
+
+// ResetLink removes the conditions set with SetLink, making the link use
+// the default conditions again
+func (n *Network) ResetLink(from, to string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.links, [2]string{from, to})
+}
+
+// Dropped returns the number of datagrams dropped because of loss or
+// because no host was listening on their destination
+func (n *Network) Dropped() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.dropped
+}
+
+// Pending returns the number of datagrams in flight, delivered or not
+func (n *Network) Pending() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	count := 0
+	for _, c := range n.conns {
+		count += len(c.inbox)
+	}
+	return count
+}
+
+// link returns the conditions from one host to another. n.mu must be held.
+func (n *Network) link(from, to string) Link {
+	if link, ok := n.links[[2]string{from, to}]; ok {
+		return link
+	}
+	return n.defaultLink
+}
+
+// attach creates the socket of a new host. An empty name picks a unique
+// one.
+func (n *Network) attach(name string) (*conn, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.hosts++
+	if name == "" {
+		name = fmt.Sprintf("host-%d", n.hosts)
+	}
+	if _, ok := n.conns[name]; ok {
+		return nil, ErrNameInUse
+	}
+	if n.hosts >= 1<<24 {
+		return nil, errors.New("enettest: too many hosts")
+	}
+
+	// Give every host a distinct address in 10.0.0.0/8, the protocol
+	// implementation identifies peers by address.
+	ip := netip.AddrFrom4([4]byte{10, byte(n.hosts >> 16), byte(n.hosts >> 8), byte(n.hosts)})
+	c := &conn{
+		network: n,
+		name:    name,
+		addr:    netip.AddrPortFrom(ip, Port),
+	}
+	n.conns[name] = c
+	n.addrs[c.addr] = c
+	return c, nil
+}
+
+// lookup returns the address of the host with the given name
+func (n *Network) lookup(name string) (netip.AddrPort, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	c, ok := n.conns[name]
+	if !ok {
+		return netip.AddrPort{}, false
+	}
+	return c.addr, true
+}
+
+// name returns the name of the host with the given address
+func (n *Network) name(addr netip.AddrPort) string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if c, ok := n.addrs[addr]; ok {
+		return c.name
+	}
+	return addr.String()
+}
+
+// datagram is a datagram in flight
+type datagram struct {
+	data []byte
+	from netip.AddrPort
+	at   time.Time
+}
+
+// conn is the socket of a virtual host
+type conn struct {
+	network *Network
+	name    string
+	addr    netip.AddrPort
+	inbox   []datagram
+	closed  bool
+}
+
+// ReadFrom returns the next datagram that has arrived by the current time
+// of the network clock. It never waits as time only passes with Advance.
+func (c *conn) ReadFrom(b []byte, timeout time.Duration) (int, netip.AddrPort, error) {
+	n := c.network
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if c.closed {
+		return 0, netip.AddrPort{}, protocol.ErrClosed
+	}
+	if len(c.inbox) == 0 || c.inbox[0].at.After(n.now) {
+		return 0, netip.AddrPort{}, protocol.ErrTimeout
+	}
+	d := c.inbox[0]
+	c.inbox[0] = datagram{}
+	c.inbox = c.inbox[1:]
+	return copy(b, d.data), d.from, nil
+}
+
+// WriteTo sends a datagram through the link to addr. Like UDP, datagrams to
+// addresses nobody listens on are silently dropped.
+func (c *conn) WriteTo(b []byte, addr netip.AddrPort) error {
+	n := c.network
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if c.closed {
+		return protocol.ErrClosed
+	}
+	to, ok := n.addrs[addr]
+	if !ok {
+		n.dropped++
+		return nil
+	}
+	link := n.link(c.name, to.name)
+	if link.Loss > 0 && n.random.Float64() < link.Loss {
+		n.dropped++
+		return nil
+	}
+
+	delay := link.Latency
+	if link.Jitter > 0 {
+		delay += time.Duration(n.random.Int63n(int64(link.Jitter) + 1))
+	}
+	if link.Reorder > 0 && n.random.Float64() < link.Reorder {
+		delay += link.Latency + link.Jitter + time.Millisecond
+	}
+
+	d := datagram{
+		data: append([]byte(nil), b...),
+		from: c.addr,
+		at:   n.now.Add(delay),
+	}
+	i := sort.Search(len(to.inbox), func(i int) bool {
+		return to.inbox[i].at.After(d.at)
+	})
+	to.inbox = append(to.inbox, datagram{})
+	copy(to.inbox[i+1:], to.inbox[i:])
+	to.inbox[i] = d
+	return nil
+}
+
+// LocalAddr returns the virtual address of the host
+func (c *conn) LocalAddr() netip.AddrPort {
+	return c.addr
+}
+
+// Close detaches the host from the network, freeing its name
+func (c *conn) Close() error {
+	n := c.network
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if c.closed {
+		return protocol.ErrClosed
+	}
+	c.closed = true
+	c.inbox = nil
+	delete(n.conns, c.name)
+	delete(n.addrs, c.addr)
+	return nil
+}
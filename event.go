@@ -1,8 +1,5 @@
 package enet
 
-// #include <enet/enet.h>
-import "C"
-
 // EventType is a type of event
 type EventType int
 
@@ -35,32 +32,14 @@ type Event interface {
 	GetChannelID() uint8
 	GetData() uint32
 	GetPacket() Packet
-}
-
-type enetEvent struct {
-	cEvent C.struct__ENetEvent
-}
-
-func (event *enetEvent) GetType() EventType {
-	return (EventType)(event.cEvent._type)
-}
 
-func (event *enetEvent) GetPeer() Peer {
-	return enetPeer{
-		cPeer: event.cEvent.peer,
-	}
-}
-
-func (event *enetEvent) GetChannelID() uint8 {
-	return (uint8)(event.cEvent.channelID)
-}
-
-func (event *enetEvent) GetData() uint32 {
-	return (uint32)(event.cEvent.data)
-}
+	// DisconnectReason returns the reason of a disconnect event: the data
+	// sent by the remote peer, or DisconnectTimeout if TimedOut is true. It
+	// returns DisconnectNone for other events.
+	DisconnectReason() DisconnectReason
 
-func (event *enetEvent) GetPacket() Packet {
-	return enetPacket{
-		cPacket: event.cEvent.packet,
-	}
+	// TimedOut tells whether a disconnect event was generated locally
+	// because the peer stopped responding, or a connection attempt went
+	// unanswered, rather than sent by the remote peer
+	TimedOut() bool
 }
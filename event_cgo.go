@@ -0,0 +1,64 @@
+//go:build cgo && !purego
+
+package enet
+
+// #include <enet/enet.h>
+import "C"
+
+type enetEvent struct {
+	cEvent   C.struct__ENetEvent
+	timedOut bool
+}
+
+// classify marks disconnect events as timeouts, unless this side
+// disconnected the peer or the peer sent a disconnect command
+func (event *enetEvent) classify() {
+	switch event.cEvent._type {
+	case C.ENET_EVENT_TYPE_CONNECT:
+		forgetDisconnect(event.cEvent.peer)
+	case C.ENET_EVENT_TYPE_DISCONNECT:
+		_, local := localDisconnects.LoadAndDelete(event.cEvent.peer)
+		_, remote := remoteDisconnects.LoadAndDelete(event.cEvent.peer)
+		event.timedOut = !local && !remote
+	}
+}
+
+// goRemoteDisconnect is called by the intercept callback of hosts when a
+// peer sent a disconnect command
+//
+//export goRemoteDisconnect
+func goRemoteDisconnect(cPeer *C.ENetPeer) {
+	remoteDisconnects.Store(cPeer, struct{}{})
+}
+
+func (event *enetEvent) GetType() EventType {
+	return (EventType)(event.cEvent._type)
+}
+
+func (event *enetEvent) GetPeer() Peer {
+	return enetPeer{
+		cPeer: event.cEvent.peer,
+	}
+}
+
+func (event *enetEvent) GetChannelID() uint8 {
+	return (uint8)(event.cEvent.channelID)
+}
+
+func (event *enetEvent) GetData() uint32 {
+	return (uint32)(event.cEvent.data)
+}
+
+func (event *enetEvent) GetPacket() Packet {
+	return enetPacket{
+		cPacket: event.cEvent.packet,
+	}
+}
+
+func (event *enetEvent) DisconnectReason() DisconnectReason {
+	return disconnectReason(event.GetType(), event.GetData(), event.timedOut)
+}
+
+func (event *enetEvent) TimedOut() bool {
+	return event.timedOut
+}
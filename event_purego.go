@@ -0,0 +1,41 @@
+//go:build !cgo || purego
+
+package enet
+
+import "github.com/eikarna/gotops/internal/protocol"
+
+type enetEvent struct {
+	event protocol.Event
+}
+
+func (event *enetEvent) GetType() EventType {
+	return (EventType)(event.event.Type)
+}
+
+func (event *enetEvent) GetPeer() Peer {
+	return enetPeer{
+		peer: event.event.Peer,
+	}
+}
+
+func (event *enetEvent) GetChannelID() uint8 {
+	return event.event.ChannelID
+}
+
+func (event *enetEvent) GetData() uint32 {
+	return event.event.Data
+}
+
+func (event *enetEvent) GetPacket() Packet {
+	return enetPacket{
+		packet: event.event.Packet,
+	}
+}
+
+func (event *enetEvent) DisconnectReason() DisconnectReason {
+	return disconnectReason(event.GetType(), event.event.Data, event.event.Timeout)
+}
+
+func (event *enetEvent) TimedOut() bool {
+	return event.event.Type == protocol.EventDisconnect && event.event.Timeout
+}
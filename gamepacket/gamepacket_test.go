@@ -0,0 +1,242 @@
+package gamepacket_test
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/eikarna/gotops/gamepacket"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	data := gamepacket.Encode(gamepacket.MessageGenericText, []byte("action|log"))
+	expected := []byte{2, 0, 0, 0, 'a', 'c', 't', 'i', 'o', 'n', '|', 'l', 'o', 'g', 0}
+	if !bytes.Equal(data, expected) {
+		t.Fatalf("expected %v, got %v", expected, data)
+	}
+
+	typ, payload, err := gamepacket.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ != gamepacket.MessageGenericText || string(payload) != "action|log\x00" {
+		t.Fatalf("unexpected decode result %v %q", typ, payload)
+	}
+
+	if _, _, err := gamepacket.Decode([]byte{1, 2, 3}); err != gamepacket.ErrShortMessage {
+		t.Fatalf("expected ErrShortMessage, got %v", err)
+	}
+}
+
+func TestParseText(t *testing.T) {
+	text := gamepacket.ParseText([]byte("action|input\n|text|hello|world\n\nrequestedName|\x00garbage"))
+	expected := gamepacket.Text{
+		{Key: "action", Value: "input"},
+		{Key: "text", Value: "hello|world"},
+		{Key: "requestedName", Value: ""},
+	}
+	if !reflect.DeepEqual(text, expected) {
+		t.Fatalf("expected %v, got %v", expected, text)
+	}
+
+	text.Set("action", "log")
+	text.Set("msg", "hi")
+	if actual := text.String(); actual != "action|log\ntext|hello|world\nrequestedName|\nmsg|hi" {
+		t.Fatalf("unexpected text %q", actual)
+	}
+	if _, ok := text.Get("missing"); ok {
+		t.Fatal("expected missing key to not be found")
+	}
+}
+
+func TestTankPacket(t *testing.T) {
+	tank := gamepacket.TankPacket{
+		Type:        gamepacket.TankState,
+		NetID:       5,
+		TargetNetID: -1,
+		Flags:       gamepacket.TankFlagExtended,
+		Value:       18,
+		X:           32.5,
+		Y:           64,
+		PunchX:      -1,
+		PunchY:      3,
+		ExtraData:   []byte{1, 2, 3},
+	}
+
+	data, err := tank.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != gamepacket.TankHeaderSize+3 {
+		t.Fatalf("expected %d bytes, got %d", gamepacket.TankHeaderSize+3, len(data))
+	}
+
+	var decoded gamepacket.TankPacket
+	if err := decoded.UnmarshalBinary(append(data, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, tank) {
+		t.Fatalf("expected %+v, got %+v", tank, decoded)
+	}
+
+	if err := decoded.UnmarshalBinary(data[:len(data)-1]); err != gamepacket.ErrShortTank {
+		t.Fatalf("expected ErrShortTank for truncated extra data, got %v", err)
+	}
+}
+
+func TestVariantList(t *testing.T) {
+	list := gamepacket.VariantList{
+		"OnConsoleMessage",
+		float32(1.5),
+		gamepacket.Vec2{X: 1, Y: 2},
+		gamepacket.Vec3{X: 1, Y: 2, Z: 3},
+		uint32(7),
+		gamepacket.Rect{X: 1, Y: 2, W: 3, H: 4},
+		int32(-7),
+	}
+
+	call, err := gamepacket.NewCall(-1, 100, list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := gamepacket.EncodeTank(call)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	typ, payload, err := gamepacket.Decode(data)
+	if err != nil || typ != gamepacket.MessageGamePacket {
+		t.Fatalf("unexpected message %v: %v", typ, err)
+	}
+	var tank gamepacket.TankPacket
+	if err := tank.UnmarshalBinary(payload); err != nil {
+		t.Fatal(err)
+	}
+	if tank.Type != gamepacket.TankCallFunction || tank.NetID != -1 || tank.Value != 100 {
+		t.Fatalf("unexpected call header %+v", tank)
+	}
+
+	var decoded gamepacket.VariantList
+	if err := decoded.UnmarshalBinary(tank.ExtraData); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, list) {
+		t.Fatalf("expected %v, got %v", list, decoded)
+	}
+
+	if _, err := (gamepacket.VariantList{struct{}{}}).MarshalBinary(); err == nil {
+		t.Fatal("expected unsupported type to fail")
+	}
+	if err := decoded.UnmarshalBinary([]byte{1, 0, 2, 0xff, 0, 0, 0}); err != gamepacket.ErrBadVariant {
+		t.Fatalf("expected ErrBadVariant for oversized string, got %v", err)
+	}
+	if err := decoded.UnmarshalBinary([]byte{2, 0, 5, 1, 0, 0, 0, 0, 5, 2, 0, 0, 0}); err != gamepacket.ErrBadVariant {
+		t.Fatalf("expected ErrBadVariant for a repeated index, got %v", err)
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	f.Add(gamepacket.Encode(gamepacket.MessageGenericText, []byte("action|log")))
+	f.Add([]byte{1, 2})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		typ, payload, err := gamepacket.Decode(data)
+		if err != nil {
+			return
+		}
+		if !bytes.Equal(gamepacket.Encode(typ, payload)[:len(data)], data) {
+			t.Fatalf("decoding %x gave %v %x", data, typ, payload)
+		}
+	})
+}
+
+func FuzzParseText(f *testing.F) {
+	f.Add([]byte("action|input\n|text|hello|world\n\nrequestedName|\x00garbage"))
+	f.Add([]byte("\r\n|\n||"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		text := gamepacket.ParseText(data)
+		for _, field := range text {
+			if field.Key == "" && field.Value == "" {
+				continue
+			}
+			if strings.ContainsAny(field.Key, "|\n\x00") || strings.ContainsAny(field.Value, "\n\x00") {
+				t.Fatalf("field %q of %q", field, data)
+			}
+		}
+		// Parsing is stable once keys have no leading '|'.
+		if again := gamepacket.ParseText([]byte(text.String())); len(again) > len(text) {
+			t.Fatalf("%q parses to %d fields, then %d", data, len(text), len(again))
+		}
+	})
+}
+
+func FuzzTankPacket(f *testing.F) {
+	tank := &gamepacket.TankPacket{Type: gamepacket.TankState, NetID: 7, ExtraData: []byte{1, 2, 3}}
+	data, _ := tank.MarshalBinary()
+	f.Add(data)
+	f.Add(data[:gamepacket.TankHeaderSize])
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var tank gamepacket.TankPacket
+		if err := tank.UnmarshalBinary(data); err != nil {
+			return
+		}
+		if len(tank.ExtraData) > len(data)-gamepacket.TankHeaderSize {
+			t.Fatalf("%d bytes of extra data out of %d", len(tank.ExtraData), len(data))
+		}
+		encoded, err := tank.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var again gamepacket.TankPacket
+		if err := again.UnmarshalBinary(encoded); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(again.ExtraData, tank.ExtraData) || again.NetID != tank.NetID {
+			t.Fatalf("round trip of %x changed the packet", data)
+		}
+	})
+}
+
+func FuzzVariantList(f *testing.F) {
+	data, _ := gamepacket.VariantList{"OnConsoleMessage", "hi", float32(1), gamepacket.Vec2{1, 2}, gamepacket.Vec3{1, 2, 3}, uint32(4), gamepacket.Rect{1, 2, 3, 4}, int32(-1)}.MarshalBinary()
+	f.Add(data)
+	f.Add([]byte{2, 0, 2, 0xff, 0xff, 0xff, 0xff})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var list gamepacket.VariantList
+		if err := list.UnmarshalBinary(data); err != nil {
+			return
+		}
+		encoded, err := list.MarshalBinary()
+		if err != nil {
+			t.Fatalf("decoded list %v doesn't encode: %v", list, err)
+		}
+		var again gamepacket.VariantList
+		if err := again.UnmarshalBinary(encoded); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(variantBits(list), variantBits(again)) {
+			t.Fatalf("round trip changed %v to %v", list, again)
+		}
+	})
+}
+
+// variantBits replaces floats by their bits, so that NaNs compare equal
+func variantBits(list gamepacket.VariantList) []any {
+	ret := make([]any, len(list))
+	for i, v := range list {
+		switch v := v.(type) {
+		case float32:
+			ret[i] = math.Float32bits(v)
+		case gamepacket.Vec2:
+			ret[i] = [2]uint32{math.Float32bits(v.X), math.Float32bits(v.Y)}
+		case gamepacket.Vec3:
+			ret[i] = [3]uint32{math.Float32bits(v.X), math.Float32bits(v.Y), math.Float32bits(v.Z)}
+		case gamepacket.Rect:
+			ret[i] = [4]uint32{math.Float32bits(v.X), math.Float32bits(v.Y), math.Float32bits(v.W), math.Float32bits(v.H)}
+		default:
+			ret[i] = v
+		}
+	}
+	return ret
+}
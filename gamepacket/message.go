@@ -0,0 +1,84 @@
+// Package gamepacket encodes and decodes the Growtopia messages carried in
+// enet packets: the 4 byte message type, text packets, tank packets and
+// variant lists.
+package gamepacket
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// MessageType is the 4 byte type at the start of every game message
+type MessageType uint32
+
+const (
+	// MessageServerHello is sent by the server right after a peer has connected
+	MessageServerHello MessageType = iota + 1
+
+	// MessageGenericText carries a text packet, such as the login information
+	MessageGenericText
+
+	// MessageGameMessage carries a text packet with an action
+	MessageGameMessage
+
+	// MessageGamePacket carries a tank packet
+	MessageGamePacket
+
+	// MessageError is sent when something went wrong
+	MessageError
+
+	// MessageTrack carries tracking information as a text packet
+	MessageTrack
+
+	// MessageClientLogRequest asks the client for its log
+	MessageClientLogRequest
+
+	// MessageClientLogResponse carries the client log
+	MessageClientLogResponse
+)
+
+// String returns the name of the message type
+func (typ MessageType) String() string {
+	switch typ {
+	case MessageServerHello:
+		return "server hello"
+	case MessageGenericText:
+		return "generic text"
+	case MessageGameMessage:
+		return "game message"
+	case MessageGamePacket:
+		return "game packet"
+	case MessageError:
+		return "error"
+	case MessageTrack:
+		return "track"
+	case MessageClientLogRequest:
+		return "client log request"
+	case MessageClientLogResponse:
+		return "client log response"
+	default:
+		return fmt.Sprintf("message(%d)", uint32(typ))
+	}
+}
+
+// ErrShortMessage is returned when a message is too short to contain its type
+var ErrShortMessage = errors.New("gamepacket: message shorter than 4 bytes")
+
+// Encode builds a game message of the given type. The payload is followed by
+// a null terminator, as the client expects.
+func Encode(typ MessageType, payload []byte) []byte {
+	ret := make([]byte, 4+len(payload)+1)
+	binary.LittleEndian.PutUint32(ret[0:4], uint32(typ))
+	copy(ret[4:], payload)
+	return ret
+}
+
+// Decode splits a game message into its type and payload. The payload is a
+// view on data and still contains any null terminator.
+func Decode(data []byte) (MessageType, []byte, error) {
+	if len(data) < 4 {
+		return 0, nil, ErrShortMessage
+	}
+	return MessageType(binary.LittleEndian.Uint32(data[0:4])), data[4:], nil
+}
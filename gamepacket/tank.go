@@ -0,0 +1,196 @@
+package gamepacket
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// TankType is the type of a tank packet
+type TankType uint8
+
+// Tank packet types
+const (
+	TankState TankType = iota
+	TankCallFunction
+	TankUpdateStatus
+	TankTileChangeRequest
+	TankSendMapData
+	TankSendTileUpdateData
+	TankSendTileUpdateDataMultiple
+	TankTileActivateRequest
+	TankTileApplyDamage
+	TankSendInventoryState
+	TankItemActivateRequest
+	TankItemActivateObjectRequest
+	TankSendTileTreeState
+	TankModifyItemInventory
+	TankItemChangeObject
+	TankSendLock
+	TankSendItemDatabaseData
+	TankSendParticleEffect
+	TankSetIconState
+	TankItemEffect
+	TankSetCharacterState
+	TankPingReply
+	TankPingRequest
+	TankGotPunched
+	TankAppCheckResponse
+	TankAppIntegrityFail
+	TankDisconnect
+	TankBattleJoin
+	TankBattleEvent
+	TankUseDoor
+	TankSendParental
+	TankGoneFishin
+	TankSteam
+	TankPetBattle
+	TankNPC
+	TankSpecial
+	TankSendParticleEffectV2
+	TankActiveArrowToItem
+	TankSelectTileIndex
+	TankSendPlayerTributeData
+)
+
+var tankTypeNames = [...]string{
+	"state", "call function", "update status", "tile change request",
+	"send map data", "send tile update data", "send tile update data multiple",
+	"tile activate request", "tile apply damage", "send inventory state",
+	"item activate request", "item activate object request", "send tile tree state",
+	"modify item inventory", "item change object", "send lock",
+	"send item database data", "send particle effect", "set icon state",
+	"item effect", "set character state", "ping reply", "ping request",
+	"got punched", "app check response", "app integrity fail", "disconnect",
+	"battle join", "battle event", "use door", "send parental", "gone fishin",
+	"steam", "pet battle", "npc", "special", "send particle effect v2",
+	"active arrow to item", "select tile index", "send player tribute data",
+}
+
+// String returns the name of the tank packet type
+func (typ TankType) String() string {
+	if int(typ) < len(tankTypeNames) {
+		return tankTypeNames[typ]
+	}
+	return fmt.Sprintf("tank(%d)", uint8(typ))
+}
+
+// TankFlagExtended is set in TankPacket.Flags when the packet carries extra data
+const TankFlagExtended uint32 = 0x8
+
+// TankHeaderSize is the size of a tank packet without its extra data
+const TankHeaderSize = 56
+
+// ErrShortTank is returned when a tank packet is shorter than its header or
+// than the extra data size it declares
+var ErrShortTank = errors.New("gamepacket: tank packet too short")
+
+// TankPacket is the fixed size structure used for most game state updates.
+// Many fields have a different meaning depending on the type, the names used
+// here are the most common ones.
+type TankPacket struct {
+	Type          TankType
+	ObjectType    uint8
+	JumpCount     uint8
+	AnimationType uint8
+	NetID         int32
+	TargetNetID   int32
+	Flags         uint32
+	FloatVar      float32
+	Value         int32
+	X, Y          float32
+	XSpeed        float32
+	YSpeed        float32
+	Rotation      float32
+	PunchX        int32
+	PunchY        int32
+
+	// ExtraData follows the header. When it is not empty, TankFlagExtended
+	// is set on the encoded packet.
+	ExtraData []byte
+}
+
+// MarshalBinary encodes the tank packet, without the message type
+func (tank *TankPacket) MarshalBinary() ([]byte, error) {
+	return tank.AppendBinary(make([]byte, 0, TankHeaderSize+len(tank.ExtraData)))
+}
+
+// AppendBinary appends the encoded tank packet to b
+func (tank *TankPacket) AppendBinary(b []byte) ([]byte, error) {
+	if uint64(len(tank.ExtraData)) > math.MaxUint32 {
+		return nil, errors.New("gamepacket: tank packet extra data too large")
+	}
+
+	flags := tank.Flags
+	if len(tank.ExtraData) > 0 {
+		flags |= TankFlagExtended
+	}
+
+	le := binary.LittleEndian
+	b = append(b, byte(tank.Type), tank.ObjectType, tank.JumpCount, tank.AnimationType)
+	b = le.AppendUint32(b, uint32(tank.NetID))
+	b = le.AppendUint32(b, uint32(tank.TargetNetID))
+	b = le.AppendUint32(b, flags)
+	b = le.AppendUint32(b, math.Float32bits(tank.FloatVar))
+	b = le.AppendUint32(b, uint32(tank.Value))
+	b = le.AppendUint32(b, math.Float32bits(tank.X))
+	b = le.AppendUint32(b, math.Float32bits(tank.Y))
+	b = le.AppendUint32(b, math.Float32bits(tank.XSpeed))
+	b = le.AppendUint32(b, math.Float32bits(tank.YSpeed))
+	b = le.AppendUint32(b, math.Float32bits(tank.Rotation))
+	b = le.AppendUint32(b, uint32(tank.PunchX))
+	b = le.AppendUint32(b, uint32(tank.PunchY))
+	b = le.AppendUint32(b, uint32(len(tank.ExtraData)))
+	b = append(b, tank.ExtraData...)
+	return b, nil
+}
+
+// UnmarshalBinary decodes a tank packet, without the message type. Bytes
+// following the extra data, such as a null terminator, are ignored. The
+// extra data is copied out of data.
+func (tank *TankPacket) UnmarshalBinary(data []byte) error {
+	if len(data) < TankHeaderSize {
+		return ErrShortTank
+	}
+
+	le := binary.LittleEndian
+	size := le.Uint32(data[52:56])
+	if uint64(size) > uint64(len(data)-TankHeaderSize) {
+		return ErrShortTank
+	}
+
+	*tank = TankPacket{
+		Type:          TankType(data[0]),
+		ObjectType:    data[1],
+		JumpCount:     data[2],
+		AnimationType: data[3],
+		NetID:         int32(le.Uint32(data[4:8])),
+		TargetNetID:   int32(le.Uint32(data[8:12])),
+		Flags:         le.Uint32(data[12:16]),
+		FloatVar:      math.Float32frombits(le.Uint32(data[16:20])),
+		Value:         int32(le.Uint32(data[20:24])),
+		X:             math.Float32frombits(le.Uint32(data[24:28])),
+		Y:             math.Float32frombits(le.Uint32(data[28:32])),
+		XSpeed:        math.Float32frombits(le.Uint32(data[32:36])),
+		YSpeed:        math.Float32frombits(le.Uint32(data[36:40])),
+		Rotation:      math.Float32frombits(le.Uint32(data[40:44])),
+		PunchX:        int32(le.Uint32(data[44:48])),
+		PunchY:        int32(le.Uint32(data[48:52])),
+	}
+	if size > 0 {
+		tank.ExtraData = append([]byte(nil), data[TankHeaderSize:TankHeaderSize+int(size)]...)
+	}
+	return nil
+}
+
+// EncodeTank builds a game message carrying the tank packet
+func EncodeTank(tank *TankPacket) ([]byte, error) {
+	b := make([]byte, 4, 4+TankHeaderSize+len(tank.ExtraData)+1)
+	binary.LittleEndian.PutUint32(b, uint32(MessageGamePacket))
+	b, err := tank.AppendBinary(b)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, 0), nil
+}
@@ -0,0 +1,78 @@
+package gamepacket
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Field is a single key|value line of a text packet
+type Field struct {
+	Key   string
+	Value string
+}
+
+// Text is a text packet made up of key|value lines, in the order they appear
+type Text []Field
+
+// ParseText parses a text packet. A trailing null terminator is ignored, as
+// are empty lines and a leading '|' on a line.
+func ParseText(data []byte) Text {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		data = data[:i]
+	}
+
+	var ret Text
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		line = strings.TrimPrefix(line, "|")
+		if line == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(line, "|")
+		ret = append(ret, Field{Key: key, Value: value})
+	}
+	return ret
+}
+
+// Get returns the value of the first field with the given key
+func (text Text) Get(key string) (string, bool) {
+	for _, field := range text {
+		if field.Key == key {
+			return field.Value, true
+		}
+	}
+	return "", false
+}
+
+// Value returns the value of the first field with the given key, or an empty
+// string if there is none
+func (text Text) Value(key string) string {
+	value, _ := text.Get(key)
+	return value
+}
+
+// Set replaces the value of the first field with the given key, or appends
+// a new field if there is none
+func (text *Text) Set(key, value string) {
+	for i := range *text {
+		if (*text)[i].Key == key {
+			(*text)[i].Value = value
+			return
+		}
+	}
+	*text = append(*text, Field{Key: key, Value: value})
+}
+
+// String returns the text packet as key|value lines
+func (text Text) String() string {
+	var builder strings.Builder
+	for i, field := range text {
+		if i > 0 {
+			builder.WriteByte('\n')
+		}
+		builder.WriteString(field.Key)
+		builder.WriteByte('|')
+		builder.WriteString(field.Value)
+	}
+	return builder.String()
+}
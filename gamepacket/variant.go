@@ -0,0 +1,190 @@
+package gamepacket
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// VariantType is the type tag of a value in a variant list
+type VariantType uint8
+
+// Variant types
+const (
+	VariantFloat  VariantType = 1
+	VariantString VariantType = 2
+	VariantVec2   VariantType = 3
+	VariantVec3   VariantType = 4
+	VariantUint32 VariantType = 5
+	VariantRect   VariantType = 8
+	VariantInt32  VariantType = 9
+)
+
+// Vec2 is a 2 component vector
+type Vec2 struct {
+	X, Y float32
+}
+
+// Vec3 is a 3 component vector
+type Vec3 struct {
+	X, Y, Z float32
+}
+
+// Rect is a rectangle
+type Rect struct {
+	X, Y, W, H float32
+}
+
+// VariantList is the list of arguments of a function call made with a
+// TankCallFunction packet, the first one being the function name. Values
+// must be one of float32, string, Vec2, Vec3, uint32, Rect or int32.
+type VariantList []any
+
+// ErrBadVariant is returned when decoding a malformed variant list
+var ErrBadVariant = errors.New("gamepacket: malformed variant list")
+
+// MarshalBinary encodes the variant list as the extra data of a tank packet
+func (list VariantList) MarshalBinary() ([]byte, error) {
+	if len(list) > math.MaxUint8 {
+		return nil, fmt.Errorf("gamepacket: variant list has %d values, max is %d", len(list), math.MaxUint8)
+	}
+
+	le := binary.LittleEndian
+	b := []byte{uint8(len(list))}
+	for i, value := range list {
+		b = append(b, uint8(i))
+		switch v := value.(type) {
+		case float32:
+			b = append(b, uint8(VariantFloat))
+			b = le.AppendUint32(b, math.Float32bits(v))
+		case string:
+			if uint64(len(v)) > math.MaxUint32 {
+				return nil, errors.New("gamepacket: variant string too long")
+			}
+			b = append(b, uint8(VariantString))
+			b = le.AppendUint32(b, uint32(len(v)))
+			b = append(b, v...)
+		case Vec2:
+			b = append(b, uint8(VariantVec2))
+			b = le.AppendUint32(b, math.Float32bits(v.X))
+			b = le.AppendUint32(b, math.Float32bits(v.Y))
+		case Vec3:
+			b = append(b, uint8(VariantVec3))
+			b = le.AppendUint32(b, math.Float32bits(v.X))
+			b = le.AppendUint32(b, math.Float32bits(v.Y))
+			b = le.AppendUint32(b, math.Float32bits(v.Z))
+		case uint32:
+			b = append(b, uint8(VariantUint32))
+			b = le.AppendUint32(b, v)
+		case Rect:
+			b = append(b, uint8(VariantRect))
+			b = le.AppendUint32(b, math.Float32bits(v.X))
+			b = le.AppendUint32(b, math.Float32bits(v.Y))
+			b = le.AppendUint32(b, math.Float32bits(v.W))
+			b = le.AppendUint32(b, math.Float32bits(v.H))
+		case int32:
+			b = append(b, uint8(VariantInt32))
+			b = le.AppendUint32(b, uint32(v))
+		case int:
+			if v < math.MinInt32 || v > math.MaxInt32 {
+				return nil, fmt.Errorf("gamepacket: variant %d out of int32 range", i)
+			}
+			b = append(b, uint8(VariantInt32))
+			b = le.AppendUint32(b, uint32(int32(v)))
+		default:
+			return nil, fmt.Errorf("gamepacket: variant %d has unsupported type %T", i, value)
+		}
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes a variant list from the extra data of a tank
+// packet. Values are stored at the index they were encoded with, which must
+// each be used once.
+func (list *VariantList) UnmarshalBinary(data []byte) error {
+	if len(data) < 1 {
+		return ErrBadVariant
+	}
+
+	le := binary.LittleEndian
+	count := int(data[0])
+	data = data[1:]
+	ret := make(VariantList, count)
+
+	for n := 0; n < count; n++ {
+		if len(data) < 2 {
+			return ErrBadVariant
+		}
+		index, typ := int(data[0]), VariantType(data[1])
+		data = data[2:]
+		if index >= count || ret[index] != nil {
+			return ErrBadVariant
+		}
+
+		var size int
+		switch typ {
+		case VariantFloat, VariantUint32, VariantInt32:
+			size = 4
+		case VariantVec2:
+			size = 8
+		case VariantVec3:
+			size = 12
+		case VariantRect:
+			size = 16
+		case VariantString:
+			if len(data) < 4 {
+				return ErrBadVariant
+			}
+			length := le.Uint32(data)
+			if uint64(length) > uint64(len(data)-4) {
+				return ErrBadVariant
+			}
+			ret[index] = string(data[4 : 4+length])
+			data = data[4+length:]
+			continue
+		default:
+			return fmt.Errorf("gamepacket: unknown variant type %d", typ)
+		}
+
+		if len(data) < size {
+			return ErrBadVariant
+		}
+		f := func(i int) float32 { return math.Float32frombits(le.Uint32(data[i*4:])) }
+		switch typ {
+		case VariantFloat:
+			ret[index] = f(0)
+		case VariantUint32:
+			ret[index] = le.Uint32(data)
+		case VariantInt32:
+			ret[index] = int32(le.Uint32(data))
+		case VariantVec2:
+			ret[index] = Vec2{f(0), f(1)}
+		case VariantVec3:
+			ret[index] = Vec3{f(0), f(1), f(2)}
+		case VariantRect:
+			ret[index] = Rect{f(0), f(1), f(2), f(3)}
+		}
+		data = data[size:]
+	}
+
+	*list = ret
+	return nil
+}
+
+// NewCall builds a TankCallFunction packet calling a function on the client.
+// netID selects the player the call applies to, -1 for none, and delay is in
+// milliseconds.
+func NewCall(netID int32, delay int32, list VariantList) (*TankPacket, error) {
+	data, err := list.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &TankPacket{
+		Type:      TankCallFunction,
+		NetID:     netID,
+		Value:     delay,
+		Flags:     TankFlagExtended,
+		ExtraData: data,
+	}, nil
+}
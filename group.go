@@ -0,0 +1,269 @@
+package enet
+
+// Multicast sends a single packet to several peers. The packet is shared by
+// the peers rather than copied, and is destroyed once sent to all of them,
+// or right away if none of them is connected.
+func Multicast(packet Packet, channel uint8, peers []Peer) error {
+	return multicast(packet, channel, peers)
+}
+
+// sendCopies sends the data of a packet to peers of another backend, such as
+// those of enettest, one copy each, and destroys the packet
+func sendCopies(packet Packet, channel uint8, peers []Peer) error {
+	data, flags := packet.GetData(), packet.GetFlags()
+	packet.Destroy()
+	for _, peer := range peers {
+		if err := peer.SendBytes(data, channel, flags&^PacketFlagNoAllocate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Except returns a filter selecting every peer but the given one, such as
+// to multicast what a player did to everyone else
+func Except(peer Peer) func(Peer) bool {
+	return func(p Peer) bool {
+		return p != peer
+	}
+}
+
+// Group is a set of peers, such as the players in a world, that packets may
+// be multicast to. Like hosts, groups aren't safe for concurrent use.
+//
+// A peer is a member for one connection. Hosts reuse peers for new
+// connections, so once a peer is reused it is dropped from the group, even
+// if the disconnect event of its previous connection never reached Handle.
+type Group struct {
+	members []member
+	index   map[Peer]int
+
+	// left is called with the stale members dropped from the group
+	left func(Peer)
+}
+
+// member is a peer in a group, with the connect ID of the connection it
+// joined with
+type member struct {
+	peer      Peer
+	connectID uint32
+}
+
+// stale tells whether the peer has been reused by another connection
+// since it joined. A peer that is merely disconnected isn't stale, so that
+// its group can still be found when its disconnect event is handled.
+func (m member) stale() bool {
+	switch m.peer.State() {
+	case Disconnected, Zombie:
+		return false
+	}
+	return m.peer.GetConnectID() != m.connectID
+}
+
+// NewGroup creates an empty group
+func NewGroup() *Group {
+	return &Group{index: make(map[Peer]int)}
+}
+
+// Add adds a peer to the group and tells whether it wasn't already in it
+func (g *Group) Add(peer Peer) bool {
+	if i, ok := g.index[peer]; ok {
+		if !g.members[i].stale() {
+			return false
+		}
+		g.removeAt(i)
+	}
+	g.index[peer] = len(g.members)
+	g.members = append(g.members, member{peer: peer, connectID: peer.GetConnectID()})
+	return true
+}
+
+// Remove removes a peer from the group and tells whether it was in it
+func (g *Group) Remove(peer Peer) bool {
+	i, ok := g.index[peer]
+	if !ok {
+		return false
+	}
+	g.removeAt(i)
+	return true
+}
+
+// removeAt removes the member at index i
+func (g *Group) removeAt(i int) {
+	peer := g.members[i].peer
+	last := len(g.members) - 1
+	g.members[i] = g.members[last]
+	g.index[g.members[i].peer] = i
+	g.members[last] = member{}
+	g.members = g.members[:last]
+	delete(g.index, peer)
+}
+
+// prune drops the stale members
+func (g *Group) prune() {
+	for i := 0; i < len(g.members); {
+		m := g.members[i]
+		if !m.stale() {
+			i++
+			continue
+		}
+		g.removeAt(i)
+		if g.left != nil {
+			g.left(m.peer)
+		}
+	}
+}
+
+// Contains tells whether a peer is in the group
+func (g *Group) Contains(peer Peer) bool {
+	i, ok := g.index[peer]
+	return ok && !g.members[i].stale()
+}
+
+// Len returns the number of peers in the group
+func (g *Group) Len() int {
+	g.prune()
+	return len(g.members)
+}
+
+// Peers returns the peers in the group
+func (g *Group) Peers() []Peer {
+	g.prune()
+	peers := make([]Peer, len(g.members))
+	for i, m := range g.members {
+		peers[i] = m.peer
+	}
+	return peers
+}
+
+// Handle removes peers from the group as their disconnect events are
+// returned by the host
+func (g *Group) Handle(ev Event) {
+	if ev.GetType() == EventDisconnect {
+		g.Remove(ev.GetPeer())
+	}
+}
+
+// Multicast sends a single packet to the peers of the group selected by
+// filter, or to all of them if filter is nil
+func (g *Group) Multicast(packet Packet, channel uint8, filter func(Peer) bool) error {
+	g.prune()
+	peers := make([]Peer, 0, len(g.members))
+	for _, m := range g.members {
+		if filter == nil || filter(m.peer) {
+			peers = append(peers, m.peer)
+		}
+	}
+	return multicast(packet, channel, peers)
+}
+
+// MulticastBytes sends data to the peers of the group selected by filter,
+// allocating a single packet
+func (g *Group) MulticastBytes(data []byte, channel uint8, flags PacketFlags, filter func(Peer) bool) error {
+	packet, err := NewPacket(data, flags)
+	if err != nil {
+		return err
+	}
+	return g.Multicast(packet, channel, filter)
+}
+
+// Rooms sorts peers into named groups, such as worlds. A peer is in at most
+// one room, and rooms are removed once empty. Like groups, rooms drop peers
+// reused by another connection.
+type Rooms struct {
+	rooms map[string]*Group
+	of    map[Peer]string
+}
+
+// NewRooms creates a set of rooms
+func NewRooms() *Rooms {
+	return &Rooms{
+		rooms: make(map[string]*Group),
+		of:    make(map[Peer]string),
+	}
+}
+
+// Join moves a peer to a room, leaving the room it was in, and returns the
+// room joined
+func (r *Rooms) Join(peer Peer, name string) *Group {
+	if current, ok := r.of[peer]; ok {
+		if room := r.rooms[current]; current == name && room != nil && room.Contains(peer) {
+			return room
+		}
+		r.Leave(peer)
+	}
+	room := r.rooms[name]
+	if room == nil {
+		room = NewGroup()
+		room.left = func(peer Peer) {
+			if r.of[peer] == name {
+				delete(r.of, peer)
+			}
+		}
+		r.rooms[name] = room
+	}
+	room.Add(peer)
+	r.of[peer] = name
+	return room
+}
+
+// Leave removes a peer from its room and returns the name of the room it
+// left, if any
+func (r *Rooms) Leave(peer Peer) (string, bool) {
+	name, ok := r.of[peer]
+	if !ok {
+		return "", false
+	}
+	delete(r.of, peer)
+	room := r.rooms[name]
+	if room == nil {
+		return "", false
+	}
+	live := room.Contains(peer)
+	room.Remove(peer)
+	if room.Len() == 0 {
+		delete(r.rooms, name)
+	}
+	if !live {
+		return "", false
+	}
+	return name, true
+}
+
+// Room returns the room with the given name, or nil if it is empty
+func (r *Rooms) Room(name string) *Group {
+	room := r.rooms[name]
+	if room != nil && room.Len() == 0 {
+		delete(r.rooms, name)
+		return nil
+	}
+	return room
+}
+
+// RoomOf returns the name of the room a peer is in
+func (r *Rooms) RoomOf(peer Peer) (string, bool) {
+	name, ok := r.of[peer]
+	if room := r.rooms[name]; ok && (room == nil || !room.Contains(peer)) {
+		r.Leave(peer)
+		return "", false
+	}
+	return name, ok
+}
+
+// Len returns the number of rooms with peers in them
+func (r *Rooms) Len() int {
+	for name, room := range r.rooms {
+		if room.Len() == 0 {
+			delete(r.rooms, name)
+		}
+	}
+	return len(r.rooms)
+}
+
+// Handle removes peers from their room as their disconnect events are
+// returned by the host
+func (r *Rooms) Handle(ev Event) {
+	if ev.GetType() == EventDisconnect {
+		r.Leave(ev.GetPeer())
+	}
+}
@@ -0,0 +1,207 @@
+package enet_test
+
+import (
+	"testing"
+	"time"
+
+	enet "github.com/eikarna/gotops"
+	"github.com/eikarna/gotops/enettest"
+)
+
+// serviceAll services hosts until done returns true or limit elapses,
+// passing every event of the server to handle and counting the packets
+// received by each client
+func serviceAll(server enet.Host, clients []enet.Host, received []int, limit time.Duration, handle func(enet.Event), done func() bool) bool {
+	start := time.Now()
+	for time.Since(start) < limit {
+		if ev := server.Service(1); ev.GetType() != enet.EventNone {
+			handle(ev)
+			if ev.GetType() == enet.EventReceive {
+				ev.GetPacket().Destroy()
+			}
+		}
+		for i, client := range clients {
+			if ev := client.Service(0); ev.GetType() == enet.EventReceive {
+				received[i]++
+				ev.GetPacket().Destroy()
+			}
+		}
+		if done() {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRoomsMulticast(t *testing.T) {
+	port := getFreePort()
+	server, err := enet.NewHost(enet.ENET_ADDRESS_TYPE_IPV4, enet.NewListenAddress(enet.ENET_ADDRESS_TYPE_IPV4, port), 10, 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Destroy()
+
+	clients := make([]enet.Host, 3)
+	for i := range clients {
+		if clients[i], err = enet.NewHost(enet.ENET_ADDRESS_TYPE_IPV4, nil, 1, 1, 0, 0); err != nil {
+			t.Fatal(err)
+		}
+		defer clients[i].Destroy()
+		if _, err := clients[i].Connect(enet.NewAddress(enet.ENET_ADDRESS_TYPE_IPV4, "127.0.0.1", port), 1, uint32(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Clients 0 and 1 join START, client 2 joins OTHER.
+	rooms := enet.NewRooms()
+	var peers []enet.Peer
+	received := make([]int, len(clients))
+	handle := func(ev enet.Event) {
+		rooms.Handle(ev)
+		if ev.GetType() == enet.EventConnect {
+			peers = append(peers, ev.GetPeer())
+			if ev.GetData() == 2 {
+				rooms.Join(ev.GetPeer(), "OTHER")
+			} else {
+				rooms.Join(ev.GetPeer(), "START")
+			}
+		}
+	}
+	if !serviceAll(server, clients, received, 2*time.Second, handle, func() bool { return len(peers) == 3 }) {
+		t.Fatal("clients didn't connect")
+	}
+
+	start := rooms.Room("START")
+	if rooms.Len() != 2 || start.Len() != 2 {
+		t.Fatalf("%d rooms, %d peers in START", rooms.Len(), start.Len())
+	}
+	sender := start.Peers()[0]
+	if err := start.MulticastBytes([]byte("hello"), 0, enet.PacketFlagReliable, enet.Except(sender)); err != nil {
+		t.Fatal(err)
+	}
+	if err := start.MulticastBytes([]byte("all"), 0, enet.PacketFlagReliable, nil); err != nil {
+		t.Fatal(err)
+	}
+	serviceAll(server, clients, received, 300*time.Millisecond, handle, func() bool { return false })
+	if total := received[0] + received[1]; total != 3 || received[2] != 0 {
+		t.Errorf("received %v, want 3 packets in START and none in OTHER", received)
+	}
+
+	// Nobody selected: the packet is destroyed rather than leaked.
+	if err := start.MulticastBytes([]byte("nobody"), 0, enet.PacketFlagReliable, func(enet.Peer) bool { return false }); err != nil {
+		t.Fatal(err)
+	}
+
+	rooms.Join(sender, "OTHER")
+	if start.Len() != 1 || rooms.Room("OTHER").Len() != 2 {
+		t.Errorf("START has %d peers and OTHER %d after moving", start.Len(), rooms.Room("OTHER").Len())
+	}
+
+	for _, peer := range peers {
+		peer.Disconnect(0)
+	}
+	if !serviceAll(server, clients, received, 2*time.Second, handle, func() bool { return rooms.Len() == 0 }) {
+		t.Errorf("%d rooms left after every peer disconnected", rooms.Len())
+	}
+	if _, ok := rooms.RoomOf(sender); ok {
+		t.Error("disconnected peer is still in a room")
+	}
+}
+
+func TestGroupForeignPeers(t *testing.T) {
+	network := enettest.NewNetwork(1)
+	server, err := network.NewHost("server", 4, 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Destroy()
+	client, err := network.NewHost("", 1, 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Destroy()
+	if _, err := client.Connect(network.Address("server"), 1, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	group := enet.NewGroup()
+	for i := 0; i < 1000; i++ {
+		network.Advance(time.Millisecond)
+		if ev := server.Service(0); ev.GetType() == enet.EventConnect {
+			if !group.Add(ev.GetPeer()) || group.Add(ev.GetPeer()) {
+				t.Fatal("adding a peer twice succeeded")
+			}
+			packet, err := enet.NewPacket([]byte("hello"), enet.PacketFlagReliable)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := group.Multicast(packet, 0, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if ev := client.Service(0); ev.GetType() == enet.EventReceive {
+			if data := string(ev.GetPacket().GetData()); data != "hello" {
+				t.Errorf("received %q", data)
+			}
+			ev.GetPacket().Destroy()
+			return
+		}
+	}
+	t.Fatal("client didn't receive the multicast")
+}
+
+// TestRoomsReusedPeer checks that a peer reused by a new connection isn't
+// left in the room of the previous one when its disconnect event wasn't
+// passed to Handle
+func TestRoomsReusedPeer(t *testing.T) {
+	network := enettest.NewNetwork(1)
+	server, err := network.NewHost("server", 1, 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Destroy()
+
+	// connect connects a new client and returns the server side peer
+	connect := func() (enet.Host, enet.Peer) {
+		client, err := network.NewHost("", 1, 1, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.Connect(network.Address("server"), 1, 0); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 1000; i++ {
+			network.Advance(time.Millisecond)
+			client.Service(0)
+			if ev := server.Service(0); ev.GetType() == enet.EventConnect {
+				return client, ev.GetPeer()
+			}
+		}
+		t.Fatal("client didn't connect")
+		return nil, nil
+	}
+
+	rooms := enet.NewRooms()
+	first, peer := connect()
+	group := enet.NewGroup()
+	group.Add(peer)
+	rooms.Join(peer, "world")
+
+	peer.DisconnectNow(0)
+	first.Destroy()
+	if name, ok := rooms.RoomOf(peer); !ok || name != "world" {
+		t.Fatal("disconnected peer lost its room before its slot was reused")
+	}
+
+	second, reused := connect()
+	defer second.Destroy()
+	if group.Contains(reused) || group.Len() != 0 {
+		t.Error("reused peer is still in the group")
+	}
+	if _, ok := rooms.RoomOf(reused); ok || rooms.Len() != 0 {
+		t.Error("reused peer is still in the room of the previous connection")
+	}
+	if !group.Add(reused) || rooms.Join(reused, "world").Len() != 1 {
+		t.Error("reused peer couldn't join again")
+	}
+}
@@ -1,17 +1,33 @@
 package enet
 
-// #include <enet/enet.h>
-import "C"
 import (
 	"errors"
-	"unsafe"
+	"iter"
 )
 
+// ErrNewPacketUnsupported is returned by Connect on hosts of the pure-Go
+// backend and enettest set to a new packet header mode, which only the C
+// backend implements
+var ErrNewPacketUnsupported = errors.New("enet: the new packet header modes need the C backend")
+
 // Host for communicating with peers
 type Host interface {
 	Destroy()
 	Service(timeout uint32) Event
 
+	// ServiceBatch services the host once without waiting and returns up
+	// to max of the events that are ready, or nil if there are none. With
+	// the cgo backend this is a single call into C rather than one per
+	// event.
+	ServiceBatch(max int) []Event
+
+	// SendBatch queues every packet of batch on its peer. With the cgo
+	// backend the packets are created and queued in a single call into C,
+	// where Peer.SendBytes takes several per packet. Like SendBytes,
+	// packets that can't be queued, such as to a peer that isn't
+	// connected, are dropped.
+	SendBatch(batch []Outgoing) error
+
 	Connect(addr Address, channelCount int, data uint32) (Peer, error)
 
 	CompressWithRangeCoder() error
@@ -19,157 +35,78 @@ type Host interface {
 	BroadcastPacket(packet Packet, channel uint8) error
 	BroadcastString(str string, channel uint8, flags PacketFlags) error
 	EnableChecksum()
-	ConnectedPeers() []enetPeer
-	UsingNewPacketForServer(state bool)
-	UsingNewPacket(state bool)
-	GetAddress() Address
-}
+	ConnectedPeers() []Peer
 
-// enetHost is the host for communicating with peers
-type enetHost struct {
-	cHost *C.struct__ENetHost
-}
+	// Peers returns the peers selected by filter, or every peer that isn't
+	// disconnected if filter is nil, including those still connecting or
+	// disconnecting
+	Peers(filter func(Peer) bool) []Peer
 
-// GetAddress return the address of the host
-func (host *enetHost) GetAddress() Address {
-	return &enetAddress{
-		cAddr: host.cHost.address,
-	}
-}
+	// AppendPeers appends the peers Peers would return to dst and returns
+	// the extended slice. It allocates nothing once dst is large enough, so
+	// a buffer can be reused across calls.
+	AppendPeers(dst []Peer, filter func(Peer) bool) []Peer
 
-// ConnectedPeers return a list of connected peers
-func (host enetHost) ConnectedPeers() []enetPeer {
-	var connectedList = make([]enetPeer, 0)
-	for i := 0; i < int(host.cHost.peerCount); i++ {
-		currentPeer := (*C.ENetPeer)(unsafe.Pointer(uintptr(unsafe.Pointer(host.cHost.peers)) + uintptr(i)*C.sizeof_ENetPeer))
-		if currentPeer.state != C.ENET_PEER_STATE_CONNECTED {
-			continue
-		}
-		connectedList = append(connectedList, enetPeer{cPeer: currentPeer})
-	}
-	return connectedList
-}
+	// AllPeers returns an iterator over the peers selected by filter, for
+	// use with range. Unlike Peers it doesn't allocate a slice.
+	AllPeers(filter func(Peer) bool) iter.Seq[Peer]
 
-// Destroy the host
-func (host *enetHost) Destroy() {
-	C.enet_host_destroy(host.cHost)
-}
+	// PeerCount returns the number of connected peers
+	PeerCount() int
 
-// UsingNewPacketForServer set the host to use new packet (for server)
-func (host *enetHost) UsingNewPacketForServer(state bool) {
-	if state {
-		host.cHost.usingNewPacketForServer = 1
-	} else {
-		host.cHost.usingNewPacketForServer = 0
-	}
-}
+	// PeerByAddress returns the peer at the given address, if any
+	PeerByAddress(addr Address) (Peer, bool)
 
-// UsingNewPacket set the host to use new packet
-func (host *enetHost) UsingNewPacket(state bool) {
-	if state {
-		host.cHost.usingNewPacket = 1
-	} else {
-		host.cHost.usingNewPacket = 0
-	}
-}
+	// SocketFD returns the file descriptor of the socket of the host, for
+	// setting options this package doesn't offer. The socket is owned by
+	// the host and must not be closed.
+	SocketFD() (uintptr, error)
 
-// Service the host
-func (host *enetHost) Service(timeout uint32) Event {
-	ret := &enetEvent{}
-	C.enet_host_service(
-		host.cHost,
-		&ret.cEvent,
-		(C.enet_uint32)(timeout),
-	)
-	return ret
-}
-
-// Connect to a foreign host
-func (host *enetHost) Connect(addr Address, channelCount int, data uint32) (Peer, error) {
-	peer := C.enet_host_connect(
-		host.cHost,
-		&(addr.(*enetAddress)).cAddr,
-		(C.size_t)(channelCount),
-		(C.enet_uint32)(data),
-	)
-
-	if peer == nil {
-		return nil, errors.New("couldn't connect to foreign peer")
-	}
-
-	return enetPeer{
-		cPeer: peer,
-	}, nil
-}
-
-// CompressWithRangeCoder set the packet compressor to default range coder
-func (host *enetHost) CompressWithRangeCoder() error {
-	status := C.enet_host_compress_with_range_coder(host.cHost)
-
-	if status == -1 {
-		return errors.New("couldn't set the packet compressor to default range coder because context is nil")
-	} else if status != 0 {
-		return errors.New("couldn't set the packet compressor to default range coder for unknown reason")
-	}
+	// UsingNewPacketForServer and UsingNewPacket set the Growtopia new
+	// packet header modes of the fork. Only the C backend implements them:
+	// other hosts set to either mode send and receive nothing, and Connect
+	// fails with ErrNewPacketUnsupported.
+	UsingNewPacketForServer(state bool)
+	UsingNewPacket(state bool)
 
-	return nil
+	// UsingReceivedEvents makes Service return *ReceivedEvent values, which
+	// own a copy of their payload and need no Packet.Destroy
+	UsingReceivedEvents(state bool)
+	GetAddress() Address
 }
 
-// EnableChecksum enable checksum crc32 for host
-func (host *enetHost) EnableChecksum() {
-	host.cHost.checksum = C.ENetChecksumCallback(C.enet_crc32)
-	return
+// NewDualStackHost creates a host listening on the given port for both IPv4
+// and IPv6 peers. Peer addresses keep their family: IPv4 peers aren't
+// reported as IPv4-mapped IPv6 addresses.
+func NewDualStackHost(port uint16, peerCount, channelLimit uint64, incomingBandwidth, outgoingBandwidth uint32) (Host, error) {
+	return NewHost(ENET_ADDRESS_TYPE_ANY, NewListenAddress(ENET_ADDRESS_TYPE_ANY, port), peerCount, channelLimit, incomingBandwidth, outgoingBandwidth)
 }
 
-// NewHost create a host for communicating to peers
-func NewHost(addressType ENetAddressType, addr Address, peerCount, channelLimit uint64, incomingBandwidth, outgoingBandwidth uint32) (Host, error) {
-	var cAddr *C.struct__ENetAddress
-	if addr != nil {
-		cAddr = &(addr.(*enetAddress)).cAddr
-	}
-
-	host := C.enet_host_create(
-		C.ENetAddressType(addressType),
-		cAddr,
-		(C.size_t)(peerCount),
-		(C.size_t)(channelLimit),
-		(C.enet_uint32)(incomingBandwidth),
-		(C.enet_uint32)(outgoingBandwidth),
-	)
-
-	if host == nil {
-		return nil, errors.New("unable to create host")
+// InState returns a filter selecting the peers in one of the given states
+func InState(states ...EnetPeerState) func(Peer) bool {
+	return func(peer Peer) bool {
+		state := peer.State()
+		for _, s := range states {
+			if state == s {
+				return true
+			}
+		}
+		return false
 	}
-
-	return &enetHost{
-		cHost: host,
-	}, nil
 }
 
-// BroadcastBytes send a byte array to all connected peers
-func (host *enetHost) BroadcastBytes(data []byte, channel uint8, flags PacketFlags) error {
-	packet, err := NewPacket(data, flags)
-	if err != nil {
-		return err
+// findPeer returns the first peer of an iterator at the given address
+func findPeer(seq iter.Seq[Peer], addr Address) (Peer, bool) {
+	addrPort := addr.AddrPort()
+	for peer := range seq {
+		if peer.GetAddress().AddrPort() == addrPort {
+			return peer, true
+		}
 	}
-	return host.BroadcastPacket(packet, channel)
-}
-
-// BroadcastPacket send a packet to all connected peers
-func (host *enetHost) BroadcastPacket(packet Packet, channel uint8) error {
-	C.enet_host_broadcast(
-		host.cHost,
-		(C.enet_uint8)(channel),
-		packet.(enetPacket).cPacket,
-	)
-	return nil
+	return nil, false
 }
 
-// BroadcastString send a string to all connected peers
-func (host *enetHost) BroadcastString(str string, channel uint8, flags PacketFlags) error {
-	packet, err := NewPacket([]byte(str), flags)
-	if err != nil {
-		return err
-	}
-	return host.BroadcastPacket(packet, channel)
+// connected selects connected peers
+func connected(peer Peer) bool {
+	return peer.State() == Connected
 }
@@ -0,0 +1,298 @@
+//go:build cgo && !purego
+
+package enet
+
+// #include <enet/enet.h>
+import "C"
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"net/netip"
+	"unsafe"
+
+	"github.com/eikarna/gotops/internal/leak"
+)
+
+// enetHost is the host for communicating with peers
+type enetHost struct {
+	cHost *C.struct__ENetHost
+
+	usingReceivedEvents bool
+
+	batch batchBuffers
+}
+
+// GetAddress return the address of the host
+func (host *enetHost) GetAddress() Address {
+	return &enetAddress{
+		cAddr: host.cHost.address,
+	}
+}
+
+// ConnectedPeers return a list of connected peers
+func (host *enetHost) ConnectedPeers() []Peer {
+	return host.AppendPeers(make([]Peer, 0), connected)
+}
+
+// Peers returns the peers selected by filter, or every peer that isn't
+// disconnected
+func (host *enetHost) Peers(filter func(Peer) bool) []Peer {
+	return host.AppendPeers(nil, filter)
+}
+
+// AppendPeers appends the peers selected by filter, or every peer that
+// isn't disconnected, to dst
+func (host *enetHost) AppendPeers(dst []Peer, filter func(Peer) bool) []Peer {
+	peers := unsafe.Slice(host.cHost.peers, int(host.cHost.peerCount))
+	for i := range peers {
+		if peers[i].state == C.ENET_PEER_STATE_DISCONNECTED {
+			continue
+		}
+		peer := enetPeer{cPeer: &peers[i]}
+		if filter == nil || filter(peer) {
+			dst = append(dst, peer)
+		}
+	}
+	return dst
+}
+
+// AllPeers returns an iterator over the peers selected by filter, or every
+// peer that isn't disconnected
+func (host *enetHost) AllPeers(filter func(Peer) bool) iter.Seq[Peer] {
+	return func(yield func(Peer) bool) {
+		peers := unsafe.Slice(host.cHost.peers, int(host.cHost.peerCount))
+		for i := range peers {
+			if peers[i].state == C.ENET_PEER_STATE_DISCONNECTED {
+				continue
+			}
+			peer := enetPeer{cPeer: &peers[i]}
+			if filter != nil && !filter(peer) {
+				continue
+			}
+			if !yield(peer) {
+				return
+			}
+		}
+	}
+}
+
+// PeerCount returns the number of connected peers
+func (host *enetHost) PeerCount() int {
+	count := 0
+	peers := unsafe.Slice(host.cHost.peers, int(host.cHost.peerCount))
+	for i := range peers {
+		if peers[i].state == C.ENET_PEER_STATE_CONNECTED {
+			count++
+		}
+	}
+	return count
+}
+
+// PeerByAddress returns the peer at the given address, if any
+func (host *enetHost) PeerByAddress(addr Address) (Peer, bool) {
+	return findPeer(host.AllPeers(nil), addr)
+}
+
+// Destroy the host
+func (host *enetHost) Destroy() {
+	host.simulate(nil)
+	peers := unsafe.Slice(host.cHost.peers, int(host.cHost.peerCount))
+	for i := range peers {
+		forgetDisconnect(&peers[i])
+	}
+	C.enet_host_destroy(host.cHost)
+}
+
+// UsingNewPacketForServer set the host to use new packet (for server)
+func (host *enetHost) UsingNewPacketForServer(state bool) {
+	if state {
+		host.cHost.usingNewPacketForServer = 1
+	} else {
+		host.cHost.usingNewPacketForServer = 0
+	}
+}
+
+// UsingNewPacket set the host to use new packet
+func (host *enetHost) UsingNewPacket(state bool) {
+	if state {
+		host.cHost.usingNewPacket = 1
+	} else {
+		host.cHost.usingNewPacket = 0
+	}
+}
+
+// UsingReceivedEvents set the host to return events owning their payload
+func (host *enetHost) UsingReceivedEvents(state bool) {
+	host.usingReceivedEvents = state
+}
+
+// Service the host
+func (host *enetHost) Service(timeout uint32) Event {
+	ret := &enetEvent{}
+	C.enet_host_service(
+		host.cHost,
+		&ret.cEvent,
+		(C.enet_uint32)(timeout),
+	)
+	return host.wrap(ret)
+}
+
+// wrap classifies a serviced event and returns it the way the host was set
+// to return events
+func (host *enetHost) wrap(ev *enetEvent) Event {
+	ev.classify()
+	if ev.cEvent._type == C.ENET_EVENT_TYPE_RECEIVE {
+		leak.Track(leak.KindReceivedPacket, ev.cEvent.packet)
+	}
+	if host.usingReceivedEvents {
+		return NewReceivedEvent(ev)
+	}
+	return ev
+}
+
+// Connect to a foreign host
+func (host *enetHost) Connect(addr Address, channelCount int, data uint32) (Peer, error) {
+	peer := C.enet_host_connect(
+		host.cHost,
+		&(addr.(*enetAddress)).cAddr,
+		(C.size_t)(channelCount),
+		(C.enet_uint32)(data),
+	)
+
+	if peer == nil {
+		return nil, errors.New("couldn't connect to foreign peer")
+	}
+	forgetDisconnect(peer)
+
+	return enetPeer{
+		cPeer: peer,
+	}, nil
+}
+
+// CompressWithRangeCoder set the packet compressor to default range coder
+func (host *enetHost) CompressWithRangeCoder() error {
+	status := C.enet_host_compress_with_range_coder(host.cHost)
+
+	if status == -1 {
+		return errors.New("couldn't set the packet compressor to default range coder because context is nil")
+	} else if status != 0 {
+		return errors.New("couldn't set the packet compressor to default range coder for unknown reason")
+	}
+
+	return nil
+}
+
+// EnableChecksum enable checksum crc32 for host
+func (host *enetHost) EnableChecksum() {
+	host.cHost.checksum = C.ENetChecksumCallback(C.enet_crc32)
+	return
+}
+
+// NewHost create a host for communicating to peers. With
+// ENET_ADDRESS_TYPE_ANY and a listen address, the host accepts both IPv4 and
+// IPv6 peers.
+func NewHost(addressType ENetAddressType, addr Address, peerCount, channelLimit uint64, incomingBandwidth, outgoingBandwidth uint32) (Host, error) {
+	return NewHostFromConfig(HostConfig{
+		AddressType:       addressType,
+		Address:           addr,
+		PeerCount:         peerCount,
+		ChannelLimit:      channelLimit,
+		IncomingBandwidth: incomingBandwidth,
+		OutgoingBandwidth: outgoingBandwidth,
+	})
+}
+
+// NewHostFromConfig creates a host like NewHost and sets the options of its
+// sockets. Options enet doesn't offer are set on the sockets directly, and
+// with ReusePort the sockets are replaced by ones bound with the option.
+func NewHostFromConfig(config HostConfig) (Host, error) {
+	if err := config.Socket.validate(); err != nil {
+		return nil, err
+	}
+
+	var cAddr *C.struct__ENetAddress
+	var bind netip.AddrPort
+	if config.Address != nil {
+		addr := *config.Address.(*enetAddress)
+		bind = addr.AddrPort()
+		if config.Socket.ReusePort {
+			// Bind to any port first, as binding to a port in use by
+			// another host fails without SO_REUSEPORT.
+			addr.cAddr.port = 0
+		}
+		cAddr = &addr.cAddr
+	}
+
+	host := C.enet_host_create(
+		C.ENetAddressType(config.AddressType),
+		cAddr,
+		(C.size_t)(config.PeerCount),
+		(C.size_t)(config.ChannelLimit),
+		(C.enet_uint32)(config.IncomingBandwidth),
+		(C.enet_uint32)(config.OutgoingBandwidth),
+	)
+
+	if host == nil {
+		return nil, errors.New("unable to create host")
+	}
+	if !config.Socket.isZero() {
+		if err := setHostSocketOptions(host, bind, config.Socket); err != nil {
+			C.enet_host_destroy(host)
+			return nil, fmt.Errorf("unable to create host: %w", err)
+		}
+	}
+	watchDisconnects(host)
+
+	return &enetHost{
+		cHost:               host,
+		usingReceivedEvents: config.ReceivedEvents,
+	}, nil
+}
+
+// SocketFD returns the file descriptor of the socket of the host, for
+// setting options this package doesn't offer. Hosts accepting both IPv4
+// and IPv6 have a socket per family, and the IPv4 one is returned. The
+// socket is owned by the host and must not be closed.
+func (host *enetHost) SocketFD() (uintptr, error) {
+	if host.cHost.socket4 != C.ENET_SOCKET_NULL {
+		return uintptr(host.cHost.socket4), nil
+	}
+	if host.cHost.socket6 != C.ENET_SOCKET_NULL {
+		return uintptr(host.cHost.socket6), nil
+	}
+	return 0, ErrNoSocket
+}
+
+// BroadcastBytes send a byte array to all connected peers
+func (host *enetHost) BroadcastBytes(data []byte, channel uint8, flags PacketFlags) error {
+	packet, err := NewPacket(data, flags)
+	if err != nil {
+		return err
+	}
+	return host.BroadcastPacket(packet, channel)
+}
+
+// BroadcastPacket send a packet to all connected peers
+func (host *enetHost) BroadcastPacket(packet Packet, channel uint8) error {
+	p, err := nativePacket(packet)
+	if err != nil {
+		return err
+	}
+	leak.Release(p.cPacket)
+	C.enet_host_broadcast(
+		host.cHost,
+		(C.enet_uint8)(channel),
+		p.cPacket,
+	)
+	return nil
+}
+
+// BroadcastString send a string to all connected peers
+func (host *enetHost) BroadcastString(str string, channel uint8, flags PacketFlags) error {
+	packet, err := NewPacket([]byte(str), flags)
+	if err != nil {
+		return err
+	}
+	return host.BroadcastPacket(packet, channel)
+}
@@ -0,0 +1,319 @@
+//go:build !cgo || purego
+
+package enet
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"net"
+	"net/netip"
+	"syscall"
+	"time"
+
+	"github.com/eikarna/gotops/internal/leak"
+	"github.com/eikarna/gotops/internal/protocol"
+)
+
+// enetHost is the host for communicating with peers
+type enetHost struct {
+	host *protocol.Host
+	conn *simConn
+
+	usingReceivedEvents bool
+}
+
+// GetAddress return the address of the host
+func (host *enetHost) GetAddress() Address {
+	addr := host.host.Address()
+	return &enetAddress{
+		addr: addr.Addr(),
+		port: addr.Port(),
+	}
+}
+
+// ConnectedPeers return a list of connected peers
+func (host *enetHost) ConnectedPeers() []Peer {
+	return host.AppendPeers(make([]Peer, 0), connected)
+}
+
+// Peers returns the peers selected by filter, or every peer that isn't
+// disconnected
+func (host *enetHost) Peers(filter func(Peer) bool) []Peer {
+	return host.AppendPeers(nil, filter)
+}
+
+// AppendPeers appends the peers selected by filter, or every peer that
+// isn't disconnected, to dst
+func (host *enetHost) AppendPeers(dst []Peer, filter func(Peer) bool) []Peer {
+	peers := host.host.Peers()
+	for i := range peers {
+		if peers[i].State() == protocol.StateDisconnected {
+			continue
+		}
+		peer := enetPeer{peer: &peers[i]}
+		if filter == nil || filter(peer) {
+			dst = append(dst, peer)
+		}
+	}
+	return dst
+}
+
+// AllPeers returns an iterator over the peers selected by filter, or every
+// peer that isn't disconnected
+func (host *enetHost) AllPeers(filter func(Peer) bool) iter.Seq[Peer] {
+	return func(yield func(Peer) bool) {
+		peers := host.host.Peers()
+		for i := range peers {
+			if peers[i].State() == protocol.StateDisconnected {
+				continue
+			}
+			peer := enetPeer{peer: &peers[i]}
+			if filter != nil && !filter(peer) {
+				continue
+			}
+			if !yield(peer) {
+				return
+			}
+		}
+	}
+}
+
+// PeerCount returns the number of connected peers
+func (host *enetHost) PeerCount() int {
+	count := 0
+	peers := host.host.Peers()
+	for i := range peers {
+		if peers[i].State() == protocol.StateConnected {
+			count++
+		}
+	}
+	return count
+}
+
+// PeerByAddress returns the peer at the given address, if any
+func (host *enetHost) PeerByAddress(addr Address) (Peer, bool) {
+	return findPeer(host.AllPeers(nil), addr)
+}
+
+// Destroy the host. Its simulator, if any, is detached and may be attached
+// to another host.
+func (host *enetHost) Destroy() {
+	if sim := host.conn.sim.Swap(nil); sim != nil {
+		sim.detach()
+	}
+	host.host.Close()
+}
+
+// UsingNewPacketForServer set the host to use new packet (for server)
+func (host *enetHost) UsingNewPacketForServer(state bool) {
+	host.host.NewPacketHeaderForServer = state
+}
+
+// UsingNewPacket set the host to use new packet
+func (host *enetHost) UsingNewPacket(state bool) {
+	host.host.NewPacketHeader = state
+}
+
+// UsingReceivedEvents set the host to return events owning their payload
+func (host *enetHost) UsingReceivedEvents(state bool) {
+	host.usingReceivedEvents = state
+}
+
+// Service the host. If the socket failed, for example because the host was
+// destroyed, this waits for the timeout and returns no event.
+func (host *enetHost) Service(timeout uint32) Event {
+	ev, err := host.host.Service(time.Duration(timeout) * time.Millisecond)
+	if err != nil {
+		time.Sleep(time.Duration(timeout) * time.Millisecond)
+		return &enetEvent{}
+	}
+	return host.wrap(ev)
+}
+
+// ServiceBatch services the host once without waiting and returns up to
+// max events
+func (host *enetHost) ServiceBatch(max int) []Event {
+	if max <= 0 {
+		return nil
+	}
+	ev := host.Service(0)
+	if ev.GetType() == EventNone {
+		return nil
+	}
+	ret := []Event{ev}
+	for len(ret) < max {
+		ev, ok := host.host.CheckEvents()
+		if !ok {
+			break
+		}
+		ret = append(ret, host.wrap(ev))
+	}
+	return ret
+}
+
+// wrap returns a serviced event the way the host was set to return events
+func (host *enetHost) wrap(ev protocol.Event) Event {
+	if ev.Type == protocol.EventReceive {
+		leak.Track(leak.KindReceivedPacket, ev.Packet)
+	}
+	if host.usingReceivedEvents {
+		return NewReceivedEvent(&enetEvent{event: ev})
+	}
+	return &enetEvent{
+		event: ev,
+	}
+}
+
+// SendBatch queues every packet of batch. There is no C call to save, so
+// this is the same as sending them one at a time.
+func (host *enetHost) SendBatch(batch []Outgoing) error {
+	return sendEach(batch)
+}
+
+// Connect to a foreign host
+func (host *enetHost) Connect(addr Address, channelCount int, data uint32) (Peer, error) {
+	peer, err := host.host.Connect(addr.(*enetAddress).addrPort(), channelCount, data)
+	if errors.Is(err, protocol.ErrNewPacketHeader) {
+		return nil, ErrNewPacketUnsupported
+	} else if err != nil {
+		return nil, errors.New("couldn't connect to foreign peer")
+	}
+
+	return enetPeer{
+		peer: peer,
+	}, nil
+}
+
+// CompressWithRangeCoder set the packet compressor to default range coder
+func (host *enetHost) CompressWithRangeCoder() error {
+	return errors.New("couldn't set the packet compressor to default range coder because the pure-Go backend doesn't support compression")
+}
+
+// EnableChecksum enable checksum crc32 for host
+func (host *enetHost) EnableChecksum() {
+	host.host.Checksum = true
+}
+
+// NewHost create a host for communicating to peers. With
+// ENET_ADDRESS_TYPE_ANY and a listen address, the host accepts both IPv4 and
+// IPv6 peers.
+func NewHost(addressType ENetAddressType, addr Address, peerCount, channelLimit uint64, incomingBandwidth, outgoingBandwidth uint32) (Host, error) {
+	return NewHostFromConfig(HostConfig{
+		AddressType:       addressType,
+		Address:           addr,
+		PeerCount:         peerCount,
+		ChannelLimit:      channelLimit,
+		IncomingBandwidth: incomingBandwidth,
+		OutgoingBandwidth: outgoingBandwidth,
+	})
+}
+
+// NewHostFromConfig creates a host like NewHost, setting the options of its
+// socket before binding it
+func NewHostFromConfig(config HostConfig) (Host, error) {
+	if err := config.Socket.validate(); err != nil {
+		return nil, err
+	}
+
+	network := "udp"
+	switch config.AddressType {
+	case ENET_ADDRESS_TYPE_IPV4:
+		network = "udp4"
+	case ENET_ADDRESS_TYPE_IPV6:
+		network = "udp6"
+	}
+
+	var bind netip.AddrPort
+	if config.Address != nil {
+		bind = config.Address.(*enetAddress).addrPort()
+	}
+	if config.AddressType == ENET_ADDRESS_TYPE_ANY && (!bind.Addr().IsValid() || bind.Addr().IsUnspecified()) {
+		// Binding to no address in particular lets Go pick a dual-stack
+		// socket, or an IPv4 one where IPv6 isn't available.
+		bind = netip.AddrPortFrom(netip.Addr{}, bind.Port())
+	}
+
+	lc := &net.ListenConfig{}
+	if opts := config.Socket; !opts.isZero() {
+		lc.Control = func(network, address string, c syscall.RawConn) error {
+			var err error
+			if cerr := c.Control(func(fd uintptr) {
+				err = setSocketOptions(int(fd), network == "udp6", opts)
+			}); cerr != nil {
+				return cerr
+			}
+			return err
+		}
+	}
+	udp, err := protocol.ListenUDPConfig(lc, network, bind)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create host: %w", err)
+	}
+	conn := &simConn{Conn: udp}
+
+	host, err := protocol.NewHost(conn, protocol.Config{
+		PeerCount:         int(config.PeerCount),
+		ChannelLimit:      int(config.ChannelLimit),
+		IncomingBandwidth: config.IncomingBandwidth,
+		OutgoingBandwidth: config.OutgoingBandwidth,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, errors.New("unable to create host")
+	}
+
+	return &enetHost{
+		host:                host,
+		conn:                conn,
+		usingReceivedEvents: config.ReceivedEvents,
+	}, nil
+}
+
+// SocketFD returns the file descriptor of the socket of the host, for
+// setting options this package doesn't offer. The socket is owned by the
+// host and must not be closed.
+func (host *enetHost) SocketFD() (uintptr, error) {
+	udp, ok := host.conn.Conn.(*protocol.UDPConn)
+	if !ok {
+		return 0, ErrNoSocket
+	}
+	raw, err := udp.UDP().SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var ret uintptr
+	if err := raw.Control(func(fd uintptr) { ret = fd }); err != nil {
+		return 0, err
+	}
+	return ret, nil
+}
+
+// BroadcastBytes send a byte array to all connected peers
+func (host *enetHost) BroadcastBytes(data []byte, channel uint8, flags PacketFlags) error {
+	packet, err := NewPacket(data, flags)
+	if err != nil {
+		return err
+	}
+	return host.BroadcastPacket(packet, channel)
+}
+
+// BroadcastPacket send a packet to all connected peers
+func (host *enetHost) BroadcastPacket(packet Packet, channel uint8) error {
+	p, err := nativePacket(packet)
+	if err != nil {
+		return err
+	}
+	leak.Release(p.packet)
+	host.host.Broadcast(channel, p.packet)
+	return nil
+}
+
+// BroadcastString send a string to all connected peers
+func (host *enetHost) BroadcastString(str string, channel uint8, flags PacketFlags) error {
+	packet, err := NewPacket([]byte(str), flags)
+	if err != nil {
+		return err
+	}
+	return host.BroadcastPacket(packet, channel)
+}
@@ -0,0 +1,255 @@
+package enet_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	enet "github.com/eikarna/gotops"
+)
+
+func TestHostPeers(t *testing.T) {
+	port := getFreePort()
+	server, err := enet.NewHost(enet.ENET_ADDRESS_TYPE_IPV4, enet.NewListenAddress(enet.ENET_ADDRESS_TYPE_IPV4, port), 10, 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Destroy()
+	client, err := enet.NewHost(enet.ENET_ADDRESS_TYPE_IPV4, nil, 2, 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Destroy()
+
+	serverAddr := enet.NewAddress(enet.ENET_ADDRESS_TYPE_IPV4, "127.0.0.1", port)
+	if _, err := client.Connect(serverAddr, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// The peer is connecting: it is a peer, but not a connected one.
+	if peers := client.Peers(nil); len(peers) != 1 || peers[0].State() == enet.Connected {
+		t.Fatalf("connecting client has peers %v", peers)
+	}
+	if client.PeerCount() != 0 || len(client.ConnectedPeers()) != 0 {
+		t.Errorf("connecting client has %d connected peers", client.PeerCount())
+	}
+	if peers := client.Peers(enet.InState(enet.Connecting)); len(peers) != 1 {
+		t.Errorf("client has %d connecting peers, want 1", len(peers))
+	}
+
+	start := time.Now()
+	for client.PeerCount() == 0 || server.PeerCount() == 0 {
+		if time.Since(start) > 2*time.Second {
+			t.Fatal("client didn't connect")
+		}
+		client.Service(1)
+		server.Service(1)
+	}
+
+	peer, ok := client.PeerByAddress(serverAddr)
+	if !ok || peer.GetAddress().GetPort() != port {
+		t.Fatalf("PeerByAddress returned %v, %v", peer, ok)
+	}
+	if _, ok := client.PeerByAddress(enet.NewAddress(enet.ENET_ADDRESS_TYPE_IPV4, "127.0.0.1", port+1)); ok {
+		t.Error("PeerByAddress found a peer at another port")
+	}
+	if len(server.ConnectedPeers()) != 1 {
+		t.Errorf("server has %d connected peers", len(server.ConnectedPeers()))
+	}
+
+	visited := 0
+	for range server.AllPeers(nil) {
+		visited++
+		break
+	}
+	if visited != 1 {
+		t.Errorf("iteration visited %d peers after stopping", visited)
+	}
+
+	buffer := make([]enet.Peer, 0, 4)
+	if peers := server.AppendPeers(buffer, nil); len(peers) != 1 || &peers[0] != &buffer[:1][0] {
+		t.Errorf("AppendPeers returned %d peers, not in the buffer", len(peers))
+	}
+	if allocs := testing.AllocsPerRun(100, func() { server.AppendPeers(buffer[:0], nil) }); allocs != 0 {
+		t.Errorf("AppendPeers allocates %v times", allocs)
+	}
+
+	if allocs := testing.AllocsPerRun(100, func() { server.PeerCount() }); allocs != 0 {
+		t.Errorf("PeerCount allocates %v times", allocs)
+	}
+
+	peer.Disconnect(0)
+	if peers := client.Peers(enet.InState(enet.Disconnecting)); len(peers) != 1 {
+		t.Errorf("client has %d disconnecting peers, want 1", len(peers))
+	}
+}
+
+func TestDualStackHost(t *testing.T) {
+	port := getFreePort()
+	server, err := enet.NewDualStackHost(port, 4, 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Destroy()
+
+	for _, tc := range []struct {
+		addressType enet.ENetAddressType
+		host        string
+	}{
+		{enet.ENET_ADDRESS_TYPE_IPV4, "127.0.0.1"},
+		{enet.ENET_ADDRESS_TYPE_IPV6, "::1"},
+	} {
+		client, err := enet.NewHost(tc.addressType, nil, 1, 1, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Destroy()
+		if _, err := client.Connect(enet.NewAddress(tc.addressType, tc.host, port), 1, 0); err != nil {
+			t.Fatal(err)
+		}
+
+		var peer enet.Peer
+		start := time.Now()
+		for peer == nil && time.Since(start) < 2*time.Second {
+			client.Service(1)
+			if ev := server.Service(1); ev.GetType() == enet.EventConnect {
+				peer = ev.GetPeer()
+			}
+		}
+		if peer == nil {
+			t.Fatalf("%s client didn't connect", tc.host)
+		}
+		if got := peer.GetAddress().AddrPort().Addr().String(); got != tc.host {
+			t.Errorf("%s client has address %s", tc.host, got)
+		}
+	}
+	if server.PeerCount() != 2 {
+		t.Errorf("server has %d peers, want 2", server.PeerCount())
+	}
+}
+
+// connectLoopback creates a server on loopback and connects count clients to
+// it, each from its own host. The peers returned are the server side and
+// client side of each connection.
+func connectLoopback(tb testing.TB, count int) (server enet.Host, clients []enet.Host, serverPeers, clientPeers []enet.Peer) {
+	tb.Helper()
+	port := getFreePort()
+	server, err := enet.NewHost(enet.ENET_ADDRESS_TYPE_IPV4, enet.NewListenAddress(enet.ENET_ADDRESS_TYPE_IPV4, port), uint64(count), 1, 0, 0)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(server.Destroy)
+
+	for i := 0; i < count; i++ {
+		client, err := enet.NewHost(enet.ENET_ADDRESS_TYPE_IPV4, nil, 1, 1, 0, 0)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		tb.Cleanup(client.Destroy)
+		peer, err := client.Connect(enet.NewAddress(enet.ENET_ADDRESS_TYPE_IPV4, "127.0.0.1", port), 1, 0)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		clients = append(clients, client)
+		clientPeers = append(clientPeers, peer)
+	}
+
+	start := time.Now()
+	for server.PeerCount() < count {
+		if time.Since(start) > 5*time.Second {
+			tb.Fatalf("%d of %d clients connected", server.PeerCount(), count)
+		}
+		server.Service(1)
+		for _, client := range clients {
+			client.Service(0)
+		}
+	}
+	for _, client := range clients {
+		for client.PeerCount() == 0 {
+			client.Service(1)
+			server.Service(0)
+		}
+	}
+	return server, clients, server.ConnectedPeers(), clientPeers
+}
+
+// drain services host until it has no event left, destroying the packets
+// received, and returns how many were received
+func drain(host enet.Host) int {
+	n := 0
+	for {
+		ev := host.Service(0)
+		switch ev.GetType() {
+		case enet.EventNone:
+			return n
+		case enet.EventReceive:
+			ev.GetPacket().Destroy()
+			n++
+		}
+	}
+}
+
+// receiveAll services server and client until server received n packets
+func receiveAll(b *testing.B, server, client enet.Host, n int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for n > 0 {
+		if time.Now().After(deadline) {
+			b.Fatalf("%d packets not received", n)
+		}
+		client.Service(0)
+		ev := server.Service(1)
+		if ev.GetType() == enet.EventReceive {
+			ev.GetPacket().Destroy()
+			n--
+		}
+	}
+}
+
+func BenchmarkService(b *testing.B) {
+	for _, size := range []int{64, 1024, 8192} {
+		b.Run(fmt.Sprintf("%dB", size), func(b *testing.B) {
+			server, clients, _, peers := connectLoopback(b, 1)
+			data := make([]byte, size)
+			const batch = 64
+
+			b.ReportAllocs()
+			b.SetBytes(int64(size))
+			b.ResetTimer()
+			for sent := 0; sent < b.N; sent += batch {
+				n := batch
+				if b.N-sent < n {
+					n = b.N - sent
+				}
+				for i := 0; i < n; i++ {
+					peers[0].SendBytes(data, 0, enet.PacketFlagReliable)
+				}
+				receiveAll(b, server, clients[0], n)
+			}
+		})
+	}
+}
+
+func BenchmarkBroadcastPacket(b *testing.B) {
+	for _, count := range []int{1, 8, 32} {
+		b.Run(fmt.Sprintf("%dpeers", count), func(b *testing.B) {
+			server, clients, _, _ := connectLoopback(b, count)
+			data := make([]byte, 256)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				packet, err := enet.NewPacket(data, 0)
+				if err != nil {
+					b.Fatal(err)
+				}
+				server.BroadcastPacket(packet, 0)
+				if i%64 == 63 {
+					drain(server)
+					for _, client := range clients {
+						drain(client)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+// Package leak tracks packets and peer data that must be released by hand.
+// Tracking is only compiled in with the enetleak build tag; otherwise every
+// function does nothing and costs nothing.
+package leak
+
+// Kinds of tracked allocations
+const (
+	KindPacket         = "packet"
+	KindReceivedPacket = "received packet"
+	KindPeerData       = "peer data"
+)
+
+// Leak is an allocation that wasn't released
+type Leak struct {
+	Kind string
+
+	// Stack is where the allocation was made
+	Stack string
+
+	// Seq orders allocations, Mark returning the next one
+	Seq uint64
+}
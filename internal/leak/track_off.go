@@ -0,0 +1,22 @@
+//go:build !enetleak
+
+package leak
+
+// Enabled tells whether tracking is compiled in
+const Enabled = false
+
+// Track records an allocation identified by key
+func Track(kind string, key any) {}
+
+// Release forgets the allocation identified by key
+func Release(key any) {}
+
+// Mark returns the sequence number of the next allocation
+func Mark() uint64 {
+	return 0
+}
+
+// Outstanding returns the allocations made since mark that weren't released
+func Outstanding(mark uint64) []Leak {
+	return nil
+}
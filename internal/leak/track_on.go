@@ -0,0 +1,78 @@
+//go:build enetleak
+
+package leak
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Enabled tells whether tracking is compiled in
+const Enabled = true
+
+var (
+	mu          sync.Mutex
+	seq         uint64
+	allocations = make(map[any]Leak)
+)
+
+// Track records an allocation identified by key, along with the stack of
+// the caller
+func Track(kind string, key any) {
+	stack := callers()
+	mu.Lock()
+	defer mu.Unlock()
+	allocations[key] = Leak{Kind: kind, Stack: stack, Seq: seq}
+	seq++
+}
+
+// Release forgets the allocation identified by key
+func Release(key any) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(allocations, key)
+}
+
+// Mark returns the sequence number of the next allocation
+func Mark() uint64 {
+	mu.Lock()
+	defer mu.Unlock()
+	return seq
+}
+
+// Outstanding returns the allocations made since mark that weren't
+// released, oldest first
+func Outstanding(mark uint64) []Leak {
+	mu.Lock()
+	var ret []Leak
+	for _, l := range allocations {
+		if l.Seq >= mark {
+			ret = append(ret, l)
+		}
+	}
+	mu.Unlock()
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Seq < ret[j].Seq })
+	return ret
+}
+
+// callers formats the stack above Track and the function calling it
+func callers() string {
+	pc := make([]uintptr, 32)
+	n := runtime.Callers(3, pc)
+	frames := runtime.CallersFrames(pc[:n])
+	var b strings.Builder
+	for {
+		frame, more := frames.Next()
+		if strings.HasPrefix(frame.Function, "testing.") || strings.HasPrefix(frame.Function, "runtime.") {
+			break
+		}
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
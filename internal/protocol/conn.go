@@ -0,0 +1,191 @@
+package protocol
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrTimeout is returned by Conn.ReadFrom when no datagram arrived in time
+var ErrTimeout = errors.New("protocol: read timed out")
+
+// ErrClosed is returned when using a closed Conn
+var ErrClosed = net.ErrClosed
+
+// Conn is the datagram socket a Host sends and receives through
+type Conn interface {
+	// ReadFrom reads a single datagram into b, waiting up to timeout for one
+	// to arrive. It returns ErrTimeout if none did.
+	ReadFrom(b []byte, timeout time.Duration) (int, netip.AddrPort, error)
+
+	// WriteTo sends a single datagram to addr
+	WriteTo(b []byte, addr netip.AddrPort) error
+
+	// LocalAddr returns the address the socket is bound to
+	LocalAddr() netip.AddrPort
+
+	Close() error
+}
+
+// Clock returns the current time. Hosts read it once per Service iteration.
+type Clock func() time.Time
+
+// datagram is a datagram received by a UDPConn
+type datagram struct {
+	data []byte
+	addr netip.AddrPort
+}
+
+// UDPConn is a Conn over a UDP socket. Datagrams are read by a background
+// goroutine so that ReadFrom can wait with a timeout without deadlines.
+type UDPConn struct {
+	conn     *net.UDPConn
+	incoming chan datagram
+	closed   chan struct{}
+	once     sync.Once
+	pool     sync.Pool
+}
+
+// ListenUDP binds a UDP socket. network is "udp", "udp4" or "udp6". addr
+// may have an invalid address to bind to any address, and a zero port to
+// bind to any port.
+func ListenUDP(network string, addr netip.AddrPort) (*UDPConn, error) {
+	return ListenUDPConfig(&net.ListenConfig{}, network, addr)
+}
+
+// ListenUDPConfig binds a UDP socket like ListenUDP, with the given
+// configuration, such as to set socket options before binding
+func ListenUDPConfig(lc *net.ListenConfig, network string, addr netip.AddrPort) (*UDPConn, error) {
+	address := net.JoinHostPort("", strconv.Itoa(int(addr.Port())))
+	if addr.Addr().IsValid() {
+		address = addr.String()
+	}
+	conn, err := lc.ListenPacket(context.Background(), network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewUDPConn(conn.(*net.UDPConn)), nil
+}
+
+// NewUDPConn wraps an existing UDP socket
+func NewUDPConn(conn *net.UDPConn) *UDPConn {
+	ret := &UDPConn{
+		conn:     conn,
+		incoming: make(chan datagram, 256),
+		closed:   make(chan struct{}),
+	}
+	ret.pool.New = func() any {
+		// As large as the receive buffer of a Host, so that no datagram
+		// a Host could read is truncated here.
+		return make([]byte, MaximumMTU)
+	}
+	go ret.readLoop()
+	return ret
+}
+
+// UDP returns the underlying UDP socket
+func (conn *UDPConn) UDP() *net.UDPConn {
+	return conn.conn
+}
+
+// Bounds of the delay before reading again after a read error
+const (
+	minimumReadBackoff = time.Millisecond
+	maximumReadBackoff = time.Second
+)
+
+func (conn *UDPConn) readLoop() {
+	var backoff time.Duration
+	for {
+		b := conn.pool.Get().([]byte)
+		n, addr, err := conn.conn.ReadFromUDPAddrPort(b[:cap(b)])
+		if err != nil {
+			conn.pool.Put(b)
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			// Errors such as ICMP port unreachable are not fatal, but
+			// a socket failing every read mustn't spin, so wait longer
+			// after each error in a row.
+			if backoff *= 2; backoff == 0 {
+				backoff = minimumReadBackoff
+			} else if backoff > maximumReadBackoff {
+				backoff = maximumReadBackoff
+			}
+			timer := time.NewTimer(backoff)
+			select {
+			case <-conn.closed:
+				timer.Stop()
+				return
+			case <-timer.C:
+				continue
+			}
+		}
+		backoff = 0
+		select {
+		case conn.incoming <- datagram{data: b[:n], addr: addr}:
+		case <-conn.closed:
+			return
+		}
+	}
+}
+
+// ReadFrom implements Conn
+func (conn *UDPConn) ReadFrom(b []byte, timeout time.Duration) (int, netip.AddrPort, error) {
+	var d datagram
+	select {
+	case d = <-conn.incoming:
+	case <-conn.closed:
+		return 0, netip.AddrPort{}, ErrClosed
+	default:
+		if timeout <= 0 {
+			return 0, netip.AddrPort{}, ErrTimeout
+		}
+		timer := time.NewTimer(timeout)
+		select {
+		case d = <-conn.incoming:
+			timer.Stop()
+		case <-conn.closed:
+			timer.Stop()
+			return 0, netip.AddrPort{}, ErrClosed
+		case <-timer.C:
+			return 0, netip.AddrPort{}, ErrTimeout
+		}
+	}
+	n := copy(b, d.data)
+	conn.pool.Put(d.data[:cap(d.data)])
+	return n, unmap(d.addr), nil
+}
+
+// WriteTo implements Conn
+func (conn *UDPConn) WriteTo(b []byte, addr netip.AddrPort) error {
+	_, err := conn.conn.WriteToUDPAddrPort(b, addr)
+	return err
+}
+
+// LocalAddr implements Conn
+func (conn *UDPConn) LocalAddr() netip.AddrPort {
+	return unmap(conn.conn.LocalAddr().(*net.UDPAddr).AddrPort())
+}
+
+// Close implements Conn
+func (conn *UDPConn) Close() error {
+	var err error
+	conn.once.Do(func() {
+		close(conn.closed)
+		err = conn.conn.Close()
+	})
+	return err
+}
+
+// unmap turns IPv4-mapped IPv6 addresses into plain IPv4 addresses
+func unmap(addr netip.AddrPort) netip.AddrPort {
+	if addr.Addr().Is4In6() {
+		return netip.AddrPortFrom(addr.Addr().Unmap(), addr.Port())
+	}
+	return addr
+}
@@ -0,0 +1,996 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"math/rand"
+	"net/netip"
+	"time"
+)
+
+// Errors returned by a Host
+var (
+	ErrNoFreePeer      = errors.New("protocol: no available peers")
+	ErrChannelCount    = errors.New("protocol: invalid channel count")
+	ErrNewPacketHeader = errors.New("protocol: new packet header modes are not supported")
+)
+
+// Config configures a new Host
+type Config struct {
+	// PeerCount is the maximum number of peers
+	PeerCount int
+
+	// ChannelLimit is the maximum number of channels per peer, 0 for the maximum
+	ChannelLimit int
+
+	// IncomingBandwidth and OutgoingBandwidth are in bytes per second, 0 for
+	// unlimited. They are announced to peers and used to size windows.
+	IncomingBandwidth uint32
+	OutgoingBandwidth uint32
+
+	// Clock returns the current time, defaults to time.Now
+	Clock Clock
+
+	// Seed seeds the connect IDs the host picks, defaults to the time the
+	// host is created
+	Seed int64
+}
+
+// Host is an ENet host communicating with peers through a Conn
+type Host struct {
+	conn  Conn
+	clock Clock
+	start time.Time
+
+	peers             []Peer
+	channelLimit      int
+	incomingBandwidth uint32
+	outgoingBandwidth uint32
+	serviceTime       uint32
+	events            []Event
+	random            *rand.Rand
+	receiveBuffer     []byte
+	sendBuffer        []byte
+
+	// MTU is the maximum transmission unit used for new peers
+	MTU int
+
+	// MaximumPacketSize is the largest packet that may be sent or received
+	MaximumPacketSize int
+
+	// MaximumWaitingData is the most memory a peer may hold in packets
+	// being reassembled from fragments. Fragments starting new packets
+	// beyond it are dropped.
+	MaximumWaitingData int
+
+	// Checksum enables CRC32 checksums, like setting enet_crc32 as checksum
+	// callback. Both sides must agree on it.
+	Checksum bool
+
+	// NewPacketHeader and NewPacketHeaderForServer select the Growtopia
+	// "new packet" header modes, like usingNewPacket and
+	// usingNewPacketForServer of the fork. Their layouts aren't implemented:
+	// while either is set, Connect and Service fail with ErrNewPacketHeader
+	// and nothing is sent or received.
+	NewPacketHeader          bool
+	NewPacketHeaderForServer bool
+
+	// Intercept is called for every datagram received. If it returns true,
+	// the datagram is considered handled and not processed any further.
+	Intercept func(data []byte, addr netip.AddrPort) bool
+
+	// TotalSentData, TotalSentPackets, TotalReceivedData and
+	// TotalReceivedPackets count datagrams and bytes on the wire
+	TotalSentData        uint32
+	TotalSentPackets     uint32
+	TotalReceivedData    uint32
+	TotalReceivedPackets uint32
+}
+
+// NewHost creates a host that communicates through conn
+func NewHost(conn Conn, config Config) (*Host, error) {
+	if config.PeerCount <= 0 || config.PeerCount > MaximumPeerID {
+		return nil, errors.New("protocol: invalid peer count")
+	}
+	channelLimit := config.ChannelLimit
+	if channelLimit <= 0 || channelLimit > MaximumChannelCount {
+		channelLimit = MaximumChannelCount
+	}
+	clock := config.Clock
+	if clock == nil {
+		clock = time.Now
+	}
+
+	start := clock()
+	seed := config.Seed
+	if seed == 0 {
+		seed = start.UnixNano()
+	}
+	host := &Host{
+		conn:              conn,
+		clock:             clock,
+		start:             start,
+		peers:             make([]Peer, config.PeerCount),
+		channelLimit:      channelLimit,
+		incomingBandwidth: config.IncomingBandwidth,
+		outgoingBandwidth: config.OutgoingBandwidth,
+		random:            rand.New(rand.NewSource(seed)),
+		receiveBuffer:     make([]byte, MaximumMTU),
+		MTU:               HostDefaultMTU,
+		MaximumPacketSize: HostDefaultMaximumPacketSize,
+
+		MaximumWaitingData: HostDefaultMaximumWaitingData,
+	}
+	for i := range host.peers {
+		peer := &host.peers[i]
+		peer.host = host
+		peer.incomingPeerID = uint16(i)
+		peer.Reset()
+	}
+	return host, nil
+}
+
+// Conn returns the socket of the host
+func (host *Host) Conn() Conn {
+	return host.conn
+}
+
+// Address returns the address the host is bound to
+func (host *Host) Address() netip.AddrPort {
+	return host.conn.LocalAddr()
+}
+
+// Peers returns all peer slots of the host, whatever their state
+func (host *Host) Peers() []Peer {
+	return host.peers
+}
+
+// ChannelLimit returns the maximum number of channels per peer
+func (host *Host) ChannelLimit() int {
+	return host.channelLimit
+}
+
+// Close closes the socket of the host, resetting all peers
+func (host *Host) Close() error {
+	for i := range host.peers {
+		host.peers[i].resetQueues()
+	}
+	for _, ev := range host.events {
+		if ev.Packet != nil {
+			ev.Packet.Destroy()
+		}
+	}
+	host.events = nil
+	return host.conn.Close()
+}
+
+// now updates and returns the service time
+func (host *Host) now() uint32 {
+	host.serviceTime = uint32(host.clock().Sub(host.start) / time.Millisecond)
+	// enet reserves 0 to mean "not set" for several timestamps.
+	if host.serviceTime == 0 {
+		host.serviceTime = 1
+	}
+	return host.serviceTime
+}
+
+// queueEvent queues an event to be returned by Service
+func (host *Host) queueEvent(ev Event) {
+	host.events = append(host.events, ev)
+}
+
+// Connect starts connecting to a foreign host. A connect event is generated
+// once the connection is established.
+func (host *Host) Connect(addr netip.AddrPort, channelCount int, data uint32) (*Peer, error) {
+	if host.newPacketHeader() {
+		return nil, ErrNewPacketHeader
+	}
+	if channelCount < MinimumChannelCount {
+		channelCount = MinimumChannelCount
+	} else if channelCount > MaximumChannelCount {
+		channelCount = MaximumChannelCount
+	}
+
+	var peer *Peer
+	for i := range host.peers {
+		if host.peers[i].state == StateDisconnected {
+			peer = &host.peers[i]
+			break
+		}
+	}
+	if peer == nil {
+		return nil, ErrNoFreePeer
+	}
+
+	peer.setupChannels(channelCount)
+	peer.state = StateConnecting
+	peer.address = addr
+	peer.connectID = host.random.Uint32()
+	peer.mtu = uint32(host.MTU)
+	peer.windowSize = host.windowSize(host.outgoingBandwidth)
+
+	peer.queueOutgoing(command{
+		command:                    commandConnect | commandFlagAcknowledge,
+		channelID:                  0xFF,
+		outgoingPeerID:             peer.incomingPeerID,
+		incomingSessionID:          peer.incomingSessionID,
+		outgoingSessionID:          peer.outgoingSessionID,
+		mtu:                        peer.mtu,
+		windowSize:                 peer.windowSize,
+		channelCount:               uint32(channelCount),
+		incomingBandwidth:          host.incomingBandwidth,
+		outgoingBandwidth:          host.outgoingBandwidth,
+		packetThrottleInterval:     peerPacketThrottleInterval,
+		packetThrottleAcceleration: peerPacketThrottleAcceleration,
+		packetThrottleDeceleration: peerPacketThrottleDeceleration,
+		connectID:                  peer.connectID,
+		data:                       data,
+	}, nil, 0, 0)
+	return peer, nil
+}
+
+// windowSize returns the reliable window size for a bandwidth
+func (host *Host) windowSize(bandwidth uint32) uint32 {
+	if bandwidth == 0 {
+		return MaximumWindowSize
+	}
+	size := (bandwidth / peerWindowSizeScale) * MinimumWindowSize
+	if size < MinimumWindowSize {
+		size = MinimumWindowSize
+	} else if size > MaximumWindowSize {
+		size = MaximumWindowSize
+	}
+	return size
+}
+
+// Broadcast queues a packet to be sent to all connected peers
+func (host *Host) Broadcast(channelID uint8, packet *Packet) {
+	for i := range host.peers {
+		if host.peers[i].state == StateConnected {
+			host.peers[i].Send(channelID, packet)
+		}
+	}
+	if packet.refs.Load() == 0 {
+		packet.Destroy()
+	}
+}
+
+// Flush sends all queued commands without waiting for events
+func (host *Host) Flush() {
+	if host.newPacketHeader() {
+		return
+	}
+	host.now()
+	host.sendOutgoing(false)
+}
+
+// Service sends queued commands, receives datagrams and returns the next
+// event, waiting up to timeout for one. It returns an event of type
+// EventNone if nothing happened.
+func (host *Host) Service(timeout time.Duration) (Event, error) {
+	if host.newPacketHeader() {
+		return Event{}, ErrNewPacketHeader
+	}
+	if ev, ok := host.dispatch(); ok {
+		return ev, nil
+	}
+
+	deadline := host.clock().Add(timeout)
+	for {
+		host.now()
+		if err := host.sendOutgoing(true); err != nil {
+			return Event{}, err
+		}
+		if ev, ok := host.dispatch(); ok {
+			return ev, nil
+		}
+
+		received, err := host.receiveIncoming(0)
+		if err != nil {
+			return Event{}, err
+		}
+		if received {
+			host.sendOutgoing(false)
+		}
+		if ev, ok := host.dispatch(); ok {
+			return ev, nil
+		}
+
+		before := host.clock()
+		wait := deadline.Sub(before)
+		if wait <= 0 {
+			return Event{}, nil
+		}
+		// Wake up in time to resend and ping.
+		if wait > 10*time.Millisecond {
+			wait = 10 * time.Millisecond
+		}
+		received, err = host.receiveIncoming(wait)
+		if err != nil {
+			return Event{}, err
+		}
+		if !received && !host.clock().After(before) {
+			// Time doesn't pass on its own, as with a manual clock.
+			return Event{}, nil
+		}
+	}
+}
+
+// CheckEvents returns a queued event without sending or receiving anything
+func (host *Host) CheckEvents() (Event, bool) {
+	return host.dispatch()
+}
+
+// dispatch pops the next event, updating the peer state as enet does
+func (host *Host) dispatch() (Event, bool) {
+	if len(host.events) == 0 {
+		return Event{}, false
+	}
+	ev := host.events[0]
+	host.events[0] = Event{}
+	host.events = host.events[1:]
+
+	if ev.Type == EventDisconnect {
+		ev.Peer.Reset()
+	}
+	return ev, true
+}
+
+// notifyConnect generates the connect event of a peer. Like enet, the peer
+// is connected right away so that commands following in the same datagram
+// are accepted.
+func (host *Host) notifyConnect(peer *Peer) {
+	peer.state = StateConnected
+	host.queueEvent(Event{
+		Type: EventConnect,
+		Peer: peer,
+		Data: peer.eventData,
+	})
+}
+
+// notifyDisconnect generates the disconnect event of a peer, or resets it
+// if the application never saw it connect
+func (host *Host) notifyDisconnect(peer *Peer) {
+	host.queueDisconnect(peer, false)
+}
+
+// notifyTimeout generates the disconnect event of a peer that timed out.
+// Like enet, it carries no data.
+func (host *Host) notifyTimeout(peer *Peer) {
+	peer.eventData = 0
+	host.queueDisconnect(peer, true)
+}
+
+// queueDisconnect implements notifyDisconnect and notifyTimeout
+func (host *Host) queueDisconnect(peer *Peer, timeout bool) {
+	if peer.state != StateConnecting && peer.state < StateConnectionSucceeded {
+		peer.Reset()
+		return
+	}
+	peer.resetQueues()
+	peer.state = StateZombie
+	host.queueEvent(Event{
+		Type:    EventDisconnect,
+		Peer:    peer,
+		Data:    peer.eventData,
+		Timeout: timeout,
+	})
+}
+
+// receiveIncoming reads and handles datagrams, waiting up to wait for the
+// first one. It reports whether anything was received.
+func (host *Host) receiveIncoming(wait time.Duration) (bool, error) {
+	received := false
+	for i := 0; i < 256; i++ {
+		n, addr, err := host.conn.ReadFrom(host.receiveBuffer, wait)
+		if err == ErrTimeout {
+			return received, nil
+		} else if err != nil {
+			return received, err
+		}
+		wait = 0
+		received = true
+
+		host.TotalReceivedData += uint32(n)
+		host.TotalReceivedPackets++
+		data := host.receiveBuffer[:n]
+		if host.Intercept != nil && host.Intercept(data, addr) {
+			continue
+		}
+		host.now()
+		host.handleDatagram(data, addr)
+	}
+	return received, nil
+}
+
+// newPacketHeader reports whether either new packet header mode is set
+func (host *Host) newPacketHeader() bool {
+	return host.NewPacketHeader || host.NewPacketHeaderForServer
+}
+
+// handleDatagram handles all commands of a received datagram
+func (host *Host) handleDatagram(data []byte, addr netip.AddrPort) {
+	if len(data) < 2 {
+		return
+	}
+
+	be := binary.BigEndian
+	peerID := be.Uint16(data)
+	sessionID := uint8((peerID & headerSessionMask) >> headerSessionShift)
+	flags := peerID & headerFlagMask
+	peerID &^= headerFlagMask | headerSessionMask
+
+	headerSize := 2
+	if flags&headerFlagSentTime != 0 {
+		headerSize = 4
+	}
+	if host.Checksum {
+		headerSize += 4
+	}
+	if len(data) < headerSize {
+		return
+	}
+	var sentTime uint16
+	if flags&headerFlagSentTime != 0 {
+		sentTime = be.Uint16(data[2:])
+	}
+
+	var peer *Peer
+	if peerID != MaximumPeerID {
+		if int(peerID) >= len(host.peers) {
+			return
+		}
+		peer = &host.peers[peerID]
+		if peer.state == StateDisconnected || peer.state == StateZombie ||
+			peer.address != addr ||
+			(peer.outgoingPeerID < MaximumPeerID && sessionID != peer.incomingSessionID) {
+			return
+		}
+	}
+
+	if flags&headerFlagCompressed != 0 {
+		// Range coder compression is not supported, see the package
+		// documentation.
+		return
+	}
+
+	if host.Checksum {
+		offset := headerSize - 4
+		desired := be.Uint32(data[offset:])
+		var connectID uint32
+		if peer != nil {
+			connectID = peer.connectID
+		}
+		binary.LittleEndian.PutUint32(data[offset:], connectID)
+		if crc32.ChecksumIEEE(data) != desired {
+			return
+		}
+	}
+
+	data = data[headerSize:]
+	var cmd command
+	for len(data) > 0 {
+		size, ok := decodeCommand(data, &cmd)
+		if !ok {
+			return
+		}
+		data = data[size:]
+
+		var payload []byte
+		switch cmd.number() {
+		case commandSendReliable, commandSendUnreliable, commandSendUnsequenced,
+			commandSendFragment, commandSendUnreliableFragment:
+			if int(cmd.dataLength) > len(data) {
+				return
+			}
+			payload = data[:cmd.dataLength]
+			data = data[cmd.dataLength:]
+		}
+
+		if peer == nil && cmd.number() != commandConnect {
+			return
+		}
+
+		switch cmd.number() {
+		case commandAcknowledge:
+			if !host.handleAcknowledge(peer, &cmd) {
+				return
+			}
+		case commandConnect:
+			if peer != nil {
+				return
+			}
+			peer = host.handleConnect(addr, &cmd)
+			if peer == nil {
+				return
+			}
+		case commandVerifyConnect:
+			if !host.handleVerifyConnect(peer, &cmd) {
+				return
+			}
+		case commandDisconnect:
+			host.handleDisconnect(peer, &cmd)
+		case commandPing:
+			if peer.state != StateConnected && peer.state != StateDisconnectLater {
+				return
+			}
+		case commandSendReliable, commandSendUnreliable, commandSendUnsequenced,
+			commandSendFragment, commandSendUnreliableFragment:
+			if !peer.handleSend(&cmd, payload) {
+				return
+			}
+		case commandBandwidthLimit:
+			if peer.state != StateConnected && peer.state != StateDisconnectLater {
+				return
+			}
+			peer.incomingBandwidth = cmd.incomingBandwidth
+			peer.outgoingBandwidth = cmd.outgoingBandwidth
+		case commandThrottleConfigure:
+			if peer.state != StateConnected && peer.state != StateDisconnectLater {
+				return
+			}
+			peer.packetThrottleInterval = cmd.packetThrottleInterval
+			peer.packetThrottleAcceleration = cmd.packetThrottleAcceleration
+			peer.packetThrottleDeceleration = cmd.packetThrottleDeceleration
+		}
+
+		if peer != nil && cmd.command&commandFlagAcknowledge != 0 {
+			if flags&headerFlagSentTime == 0 {
+				return
+			}
+			switch peer.state {
+			case StateDisconnecting, StateAcknowledgingConnect, StateDisconnected, StateZombie:
+			case StateAcknowledgingDisconnect:
+				if cmd.number() == commandDisconnect {
+					peer.queueAcknowledgement(&cmd, sentTime)
+				}
+			default:
+				peer.queueAcknowledgement(&cmd, sentTime)
+			}
+		}
+	}
+}
+
+// handleConnect sets up a peer for an incoming connection
+func (host *Host) handleConnect(addr netip.AddrPort, cmd *command) *Peer {
+	channelCount := int(cmd.channelCount)
+	if channelCount < MinimumChannelCount || channelCount > MaximumChannelCount {
+		return nil
+	}
+
+	var peer *Peer
+	for i := range host.peers {
+		current := &host.peers[i]
+		if current.state == StateDisconnected {
+			if peer == nil {
+				peer = current
+			}
+		} else if current.state != StateConnecting && current.address == addr && current.connectID == cmd.connectID {
+			// A retransmitted connect of a connection already set up.
+			return nil
+		}
+	}
+	if peer == nil {
+		return nil
+	}
+
+	if channelCount > host.channelLimit {
+		channelCount = host.channelLimit
+	}
+	peer.setupChannels(channelCount)
+	peer.state = StateAcknowledgingConnect
+	peer.connectID = cmd.connectID
+	peer.address = addr
+	peer.outgoingPeerID = cmd.outgoingPeerID
+	peer.incomingBandwidth = cmd.incomingBandwidth
+	peer.outgoingBandwidth = cmd.outgoingBandwidth
+	peer.packetThrottleInterval = cmd.packetThrottleInterval
+	peer.packetThrottleAcceleration = cmd.packetThrottleAcceleration
+	peer.packetThrottleDeceleration = cmd.packetThrottleDeceleration
+	peer.eventData = cmd.data
+
+	const sessionMask = headerSessionMask >> headerSessionShift
+	incomingSessionID := cmd.incomingSessionID
+	if incomingSessionID == 0xFF {
+		incomingSessionID = peer.outgoingSessionID
+	}
+	incomingSessionID = (incomingSessionID + 1) & sessionMask
+	if incomingSessionID == peer.outgoingSessionID {
+		incomingSessionID = (incomingSessionID + 1) & sessionMask
+	}
+	peer.outgoingSessionID = incomingSessionID
+
+	outgoingSessionID := cmd.outgoingSessionID
+	if outgoingSessionID == 0xFF {
+		outgoingSessionID = peer.incomingSessionID
+	}
+	outgoingSessionID = (outgoingSessionID + 1) & sessionMask
+	if outgoingSessionID == peer.incomingSessionID {
+		outgoingSessionID = (outgoingSessionID + 1) & sessionMask
+	}
+	peer.incomingSessionID = outgoingSessionID
+
+	mtu := cmd.mtu
+	if mtu < MinimumMTU {
+		mtu = MinimumMTU
+	} else if mtu > MaximumMTU {
+		mtu = MaximumMTU
+	}
+	if mtu < peer.mtu {
+		peer.mtu = mtu
+	}
+
+	windowSize := host.windowSize(host.incomingBandwidth)
+	if windowSize > cmd.windowSize {
+		windowSize = cmd.windowSize
+	}
+	if windowSize < MinimumWindowSize {
+		windowSize = MinimumWindowSize
+	} else if windowSize > MaximumWindowSize {
+		windowSize = MaximumWindowSize
+	}
+	peer.windowSize = windowSize
+
+	peer.queueOutgoing(command{
+		command:                    commandVerifyConnect | commandFlagAcknowledge,
+		channelID:                  0xFF,
+		outgoingPeerID:             peer.incomingPeerID,
+		incomingSessionID:          incomingSessionID,
+		outgoingSessionID:          outgoingSessionID,
+		mtu:                        peer.mtu,
+		windowSize:                 windowSize,
+		channelCount:               uint32(channelCount),
+		incomingBandwidth:          host.incomingBandwidth,
+		outgoingBandwidth:          host.outgoingBandwidth,
+		packetThrottleInterval:     peer.packetThrottleInterval,
+		packetThrottleAcceleration: peer.packetThrottleAcceleration,
+		packetThrottleDeceleration: peer.packetThrottleDeceleration,
+		connectID:                  peer.connectID,
+	}, nil, 0, 0)
+	return peer
+}
+
+// handleVerifyConnect completes an outgoing connection
+func (host *Host) handleVerifyConnect(peer *Peer, cmd *command) bool {
+	if peer.state != StateConnecting {
+		return true
+	}
+
+	channelCount := int(cmd.channelCount)
+	if channelCount < MinimumChannelCount || channelCount > MaximumChannelCount ||
+		cmd.packetThrottleInterval != peerPacketThrottleInterval ||
+		cmd.packetThrottleAcceleration != peerPacketThrottleAcceleration ||
+		cmd.packetThrottleDeceleration != peerPacketThrottleDeceleration ||
+		cmd.connectID != peer.connectID {
+		peer.eventData = 0
+		host.notifyDisconnect(peer)
+		return false
+	}
+
+	host.removeSentReliable(peer, 1, 0xFF)
+
+	if channelCount < len(peer.channels) {
+		peer.channels = peer.channels[:channelCount]
+	}
+	peer.outgoingPeerID = cmd.outgoingPeerID
+	peer.incomingSessionID = cmd.incomingSessionID
+	peer.outgoingSessionID = cmd.outgoingSessionID
+
+	mtu := cmd.mtu
+	if mtu < MinimumMTU {
+		mtu = MinimumMTU
+	} else if mtu > MaximumMTU {
+		mtu = MaximumMTU
+	}
+	if mtu < peer.mtu {
+		peer.mtu = mtu
+	}
+	windowSize := cmd.windowSize
+	if windowSize < MinimumWindowSize {
+		windowSize = MinimumWindowSize
+	} else if windowSize > MaximumWindowSize {
+		windowSize = MaximumWindowSize
+	}
+	if windowSize < peer.windowSize {
+		peer.windowSize = windowSize
+	}
+	peer.incomingBandwidth = cmd.incomingBandwidth
+	peer.outgoingBandwidth = cmd.outgoingBandwidth
+
+	host.notifyConnect(peer)
+	return true
+}
+
+// handleDisconnect handles a disconnect requested by the remote host
+func (host *Host) handleDisconnect(peer *Peer, cmd *command) {
+	switch peer.state {
+	case StateDisconnected, StateZombie, StateAcknowledgingDisconnect:
+		return
+	}
+
+	peer.resetQueues()
+	switch {
+	case peer.state == StateConnectionSucceeded || peer.state == StateDisconnecting || peer.state == StateConnecting:
+		peer.eventData = cmd.data
+		host.notifyDisconnect(peer)
+	case peer.state != StateConnected && peer.state != StateDisconnectLater:
+		peer.Reset()
+	case cmd.command&commandFlagAcknowledge != 0:
+		peer.state = StateAcknowledgingDisconnect
+		peer.eventData = cmd.data
+	default:
+		peer.eventData = cmd.data
+		host.notifyDisconnect(peer)
+	}
+}
+
+// handleAcknowledge handles the acknowledgement of a reliable command
+func (host *Host) handleAcknowledge(peer *Peer, cmd *command) bool {
+	if peer.state == StateDisconnected || peer.state == StateZombie {
+		return true
+	}
+
+	receivedSentTime := host.serviceTime&0xFFFF0000 | uint32(cmd.receivedSentTime)
+	if receivedSentTime&0x8000 > host.serviceTime&0x8000 {
+		receivedSentTime -= 0x10000
+	}
+	if timeLess(host.serviceTime, receivedSentTime) {
+		return true
+	}
+
+	roundTripTime := timeDifference(host.serviceTime, receivedSentTime)
+	if roundTripTime < 1 {
+		roundTripTime = 1
+	}
+	if peer.lastReceiveTime > 0 {
+		peer.roundTripTimeVariance -= peer.roundTripTimeVariance / 4
+		if roundTripTime >= peer.roundTripTime {
+			diff := roundTripTime - peer.roundTripTime
+			peer.roundTripTimeVariance += diff / 4
+			peer.roundTripTime += diff / 8
+		} else {
+			diff := peer.roundTripTime - roundTripTime
+			peer.roundTripTimeVariance += diff / 4
+			peer.roundTripTime -= diff / 8
+		}
+	} else {
+		peer.roundTripTime = roundTripTime
+		peer.roundTripTimeVariance = (roundTripTime + 1) / 2
+	}
+	peer.lastReceiveTime = host.serviceTime
+	peer.earliestTimeout = 0
+
+	commandNumber := host.removeSentReliable(peer, cmd.receivedReliableSequenceNumber, cmd.channelID)
+
+	switch peer.state {
+	case StateAcknowledgingConnect:
+		if commandNumber != commandVerifyConnect {
+			return false
+		}
+		host.notifyConnect(peer)
+	case StateDisconnecting:
+		if commandNumber != commandDisconnect {
+			return false
+		}
+		host.notifyDisconnect(peer)
+	case StateDisconnectLater:
+		if len(peer.outgoing) == 0 && len(peer.sentReliable) == 0 {
+			peer.Disconnect(peer.eventData)
+		}
+	}
+	return true
+}
+
+// removeSentReliable removes an acknowledged command, returning its number
+func (host *Host) removeSentReliable(peer *Peer, sequenceNumber uint16, channelID uint8) int {
+	for i, oc := range peer.sentReliable {
+		if oc.cmd.reliableSequenceNumber == sequenceNumber && oc.cmd.channelID == channelID {
+			peer.sentReliable = append(peer.sentReliable[:i], peer.sentReliable[i+1:]...)
+			return host.releaseCommand(peer, oc)
+		}
+	}
+	// The command may have been acknowledged before it was resent.
+	for i, oc := range peer.outgoing {
+		if oc.sendAttempts > 0 && oc.cmd.command&commandFlagAcknowledge != 0 &&
+			oc.cmd.reliableSequenceNumber == sequenceNumber && oc.cmd.channelID == channelID {
+			peer.outgoing = append(peer.outgoing[:i], peer.outgoing[i+1:]...)
+			return host.releaseCommand(peer, oc)
+		}
+	}
+	return commandNone
+}
+
+// releaseCommand drops an acknowledged command and its packet reference
+func (host *Host) releaseCommand(peer *Peer, oc *outgoingCommand) int {
+	if oc.packet != nil {
+		peer.reliableDataInTransit -= uint32(oc.fragmentLength)
+		oc.packet.unref()
+	}
+	return oc.cmd.number()
+}
+
+// checkTimeouts resends reliable commands that weren't acknowledged in time.
+// It returns true if the peer timed out and was disconnected.
+func (host *Host) checkTimeouts(peer *Peer) bool {
+	var resend []*outgoingCommand
+	kept := peer.sentReliable[:0]
+	for _, oc := range peer.sentReliable {
+		if timeDifference(host.serviceTime, oc.sentTime) < oc.roundTripTimeout {
+			kept = append(kept, oc)
+			continue
+		}
+		if peer.earliestTimeout == 0 || timeLess(oc.sentTime, peer.earliestTimeout) {
+			peer.earliestTimeout = oc.sentTime
+		}
+		if peer.earliestTimeout != 0 &&
+			(timeDifference(host.serviceTime, peer.earliestTimeout) >= peer.timeoutMaximum ||
+				(uint32(1)<<(oc.sendAttempts-1) >= peer.timeoutLimit &&
+					timeDifference(host.serviceTime, peer.earliestTimeout) >= peer.timeoutMinimum)) {
+			host.notifyTimeout(peer)
+			return true
+		}
+		if oc.packet != nil {
+			peer.reliableDataInTransit -= uint32(oc.fragmentLength)
+		}
+		peer.PacketsLost++
+		oc.roundTripTimeout *= 2
+		resend = append(resend, oc)
+	}
+	peer.sentReliable = kept
+	if len(resend) > 0 {
+		peer.outgoing = append(resend, peer.outgoing...)
+	}
+	return false
+}
+
+// sendOutgoing sends the queued acknowledgements and commands of every peer
+func (host *Host) sendOutgoing(checkTimeouts bool) error {
+	for i := range host.peers {
+		peer := &host.peers[i]
+		if peer.state == StateDisconnected || peer.state == StateZombie {
+			continue
+		}
+
+		if checkTimeouts && len(peer.sentReliable) > 0 && host.checkTimeouts(peer) {
+			continue
+		}
+
+		if peer.state == StateConnected && len(peer.sentReliable) == 0 && len(peer.outgoing) == 0 &&
+			timeDifference(host.serviceTime, peer.lastReceiveTime) >= peer.pingInterval {
+			peer.Ping()
+		}
+
+		for {
+			sent, err := host.sendDatagram(peer)
+			if err != nil {
+				return err
+			}
+			if !sent {
+				break
+			}
+		}
+
+		if peer.state == StateDisconnectLater && len(peer.outgoing) == 0 && len(peer.sentReliable) == 0 {
+			peer.Disconnect(peer.eventData)
+			host.sendDatagram(peer)
+		}
+	}
+	return nil
+}
+
+// sendDatagram sends a single datagram of queued commands to a peer. It
+// reports whether anything was sent.
+func (host *Host) sendDatagram(peer *Peer) (bool, error) {
+	headerSize := 4
+	if host.Checksum {
+		headerSize += 4
+	}
+
+	b := host.sendBuffer[:0]
+	b = append(b, make([]byte, headerSize)...)
+	limit := int(peer.mtu)
+	commands := 0
+	sentTime := false
+	zombie := false
+
+	// Acknowledgements go first.
+	for len(peer.acknowledgements) > 0 && commands < MaximumPacketCommands {
+		ack := peer.acknowledgements[0]
+		cmd := command{
+			command:                        commandAcknowledge,
+			channelID:                      ack.channelID,
+			reliableSequenceNumber:         ack.reliableSequenceNumber,
+			receivedReliableSequenceNumber: ack.reliableSequenceNumber,
+			receivedSentTime:               ack.sentTime,
+		}
+		if len(b)+cmd.size() > limit {
+			break
+		}
+		b = cmd.appendTo(b)
+		commands++
+		peer.acknowledgements = peer.acknowledgements[1:]
+		if ack.command&commandMask == commandDisconnect {
+			zombie = true
+		}
+	}
+
+	kept := peer.outgoing[:0]
+	for i, oc := range peer.outgoing {
+		size := oc.cmd.size() + int(oc.fragmentLength)
+		reliable := oc.cmd.command&commandFlagAcknowledge != 0
+		full := commands >= MaximumPacketCommands || len(b)+size > limit
+		windowFull := reliable && oc.packet != nil && len(peer.sentReliable) > 0 &&
+			peer.reliableDataInTransit+uint32(oc.fragmentLength) > peer.windowSize
+		if full || windowFull {
+			kept = append(kept, peer.outgoing[i:]...)
+			break
+		}
+
+		b = oc.cmd.appendTo(b)
+		if oc.packet != nil {
+			b = append(b, oc.packet.Data[oc.fragmentOffset:oc.fragmentOffset+uint32(oc.fragmentLength)]...)
+		}
+		commands++
+
+		if reliable {
+			sentTime = true
+			oc.sendAttempts++
+			if oc.roundTripTimeout == 0 {
+				oc.roundTripTimeout = peer.roundTripTime + 4*peer.roundTripTimeVariance
+			}
+			oc.sentTime = host.serviceTime
+			if oc.packet != nil {
+				peer.reliableDataInTransit += uint32(oc.fragmentLength)
+			}
+			peer.PacketsSent++
+			peer.sentReliable = append(peer.sentReliable, oc)
+		} else if oc.packet != nil {
+			oc.packet.unref()
+		}
+	}
+	for i := len(kept); i < len(peer.outgoing); i++ {
+		peer.outgoing[i] = nil
+	}
+	peer.outgoing = kept
+	host.sendBuffer = b[:0]
+
+	if commands == 0 {
+		return false, nil
+	}
+
+	// Write the header now that we know whether it carries a sent time.
+	if !sentTime {
+		copy(b[2:], b[4:])
+		b = b[:len(b)-2]
+		headerSize -= 2
+	}
+	peerID := peer.outgoingPeerID
+	if peerID < MaximumPeerID {
+		peerID |= uint16(peer.outgoingSessionID) << headerSessionShift
+	}
+	if sentTime {
+		peerID |= headerFlagSentTime
+		binary.BigEndian.PutUint16(b[2:], uint16(host.serviceTime))
+	}
+	binary.BigEndian.PutUint16(b, peerID)
+	if host.Checksum {
+		offset := headerSize - 4
+		var connectID uint32
+		if peer.outgoingPeerID < MaximumPeerID {
+			connectID = peer.connectID
+		}
+		binary.LittleEndian.PutUint32(b[offset:], connectID)
+		binary.BigEndian.PutUint32(b[offset:], crc32.ChecksumIEEE(b))
+	}
+
+	peer.lastSendTime = host.serviceTime
+	host.TotalSentData += uint32(len(b))
+	host.TotalSentPackets++
+	err := host.conn.WriteTo(b, peer.address)
+
+	if zombie {
+		host.notifyDisconnect(peer)
+	}
+	return true, err
+}
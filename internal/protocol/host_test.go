@@ -0,0 +1,453 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/bits"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestPair creates a server and a client host over UDP loopback and
+// connects them, returning the peers on both sides.
+func newTestPair(t *testing.T, configure func(host *Host)) (server, client *Host, serverPeer, clientPeer *Peer) {
+	t.Helper()
+
+	serverConn, err := ListenUDP("udp4", netip.MustParseAddrPort("127.0.0.1:0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientConn, err := ListenUDP("udp4", netip.MustParseAddrPort("127.0.0.1:0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, _ = NewHost(serverConn, Config{PeerCount: 4, ChannelLimit: 2})
+	client, _ = NewHost(clientConn, Config{PeerCount: 1})
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+	if configure != nil {
+		configure(server)
+		configure(client)
+	}
+
+	clientPeer, err = client.Connect(server.Address(), 2, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connected := 0
+	deadline := time.Now().Add(5 * time.Second)
+	for connected < 2 && time.Now().Before(deadline) {
+		if ev, _ := client.Service(time.Millisecond); ev.Type == EventConnect {
+			connected++
+		}
+		if ev, _ := server.Service(time.Millisecond); ev.Type == EventConnect {
+			if ev.Data != 42 {
+				t.Fatalf("expected connect data 42, got %d", ev.Data)
+			}
+			serverPeer = ev.Peer
+			connected++
+		}
+	}
+	if connected < 2 {
+		t.Fatal("timed out connecting")
+	}
+	return server, client, serverPeer, clientPeer
+}
+
+// receive services both hosts until the server receives a packet
+func receive(t *testing.T, server, client *Host) Event {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		client.Service(time.Millisecond)
+		if ev, _ := server.Service(time.Millisecond); ev.Type != EventNone {
+			return ev
+		}
+	}
+	t.Fatal("timed out waiting for an event")
+	return Event{}
+}
+
+func TestHostSendReceive(t *testing.T) {
+	for name, configure := range map[string]func(host *Host){
+		"plain":    nil,
+		"checksum": func(host *Host) { host.Checksum = true },
+	} {
+		t.Run(name, func(t *testing.T) {
+			server, client, serverPeer, clientPeer := newTestPair(t, configure)
+
+			if clientPeer.ChannelCount() != 2 || serverPeer.ChannelCount() != 2 {
+				t.Fatalf("expected 2 channels, got %d and %d", clientPeer.ChannelCount(), serverPeer.ChannelCount())
+			}
+
+			large := bytes.Repeat([]byte("0123456789"), 1000)
+			packets := []struct {
+				channel uint8
+				data    []byte
+				flags   uint32
+			}{
+				{0, []byte("reliable"), PacketFlagReliable},
+				{1, []byte("unsequenced"), PacketFlagUnsequenced},
+				{1, large, PacketFlagReliable},
+				{0, large, PacketFlagUnreliableFragment},
+				{0, []byte("unreliable"), 0},
+			}
+			for _, p := range packets {
+				if err := clientPeer.Send(p.channel, NewPacket(p.data, p.flags)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, p := range packets {
+				ev := receive(t, server, client)
+				if ev.Type != EventReceive || ev.Peer != serverPeer || ev.ChannelID != p.channel {
+					t.Fatalf("unexpected event %+v", ev)
+				}
+				if !bytes.Equal(ev.Packet.Data, p.data) {
+					t.Fatalf("expected %d bytes, got %d", len(p.data), len(ev.Packet.Data))
+				}
+			}
+
+			clientPeer.Disconnect(7)
+			ev := receive(t, server, client)
+			if ev.Type != EventDisconnect || ev.Data != 7 {
+				t.Fatalf("expected disconnect event with data 7, got %+v", ev)
+			}
+			if serverPeer.State() != StateDisconnected {
+				t.Fatalf("expected peer to be reset after disconnect, got state %d", serverPeer.State())
+			}
+		})
+	}
+}
+
+func TestHostTimeout(t *testing.T) {
+	server, client, serverPeer, clientPeer := newTestPair(t, nil)
+	serverPeer.Timeout(1, 100, 200)
+
+	// The client stops servicing, so the server's pings go unanswered.
+	_ = clientPeer
+	client.Close()
+
+	ev := Event{}
+	deadline := time.Now().Add(5 * time.Second)
+	for ev.Type == EventNone && time.Now().Before(deadline) {
+		ev, _ = server.Service(10 * time.Millisecond)
+	}
+	if ev.Type != EventDisconnect || ev.Peer != serverPeer {
+		t.Fatalf("expected disconnect event on timeout, got %+v", ev)
+	}
+}
+
+func TestPacketReferences(t *testing.T) {
+	_, _, serverPeer, _ := newTestPair(t, nil)
+
+	freed := false
+	packet := NewPacket([]byte("broadcast"), PacketFlagReliable)
+	packet.OnFree = func(*Packet) { freed = true }
+	serverPeer.host.Broadcast(0, packet)
+	packet.Destroy()
+	if freed {
+		t.Fatal("packet freed while queued")
+	}
+	serverPeer.DisconnectNow(0)
+	if !freed {
+		t.Fatal("expected packet to be freed once no peer references it")
+	}
+}
+
+// pipeConn is an in-memory Conn delivering datagrams to its other end and
+// recording those it sent
+type pipeConn struct {
+	addr  netip.AddrPort
+	other *pipeConn
+	queue [][]byte
+	sent  [][]byte
+}
+
+func (c *pipeConn) ReadFrom(b []byte, timeout time.Duration) (int, netip.AddrPort, error) {
+	if len(c.queue) == 0 {
+		return 0, netip.AddrPort{}, ErrTimeout
+	}
+	d := c.queue[0]
+	c.queue = c.queue[1:]
+	return copy(b, d), c.other.addr, nil
+}
+
+func (c *pipeConn) WriteTo(b []byte, addr netip.AddrPort) error {
+	d := append([]byte(nil), b...)
+	c.sent = append(c.sent, d)
+	c.other.queue = append(c.other.queue, d)
+	return nil
+}
+
+func (c *pipeConn) LocalAddr() netip.AddrPort { return c.addr }
+func (c *pipeConn) Close() error              { return nil }
+
+// newPipePair connects a server and a client over a pipeConn. Seeds and
+// clocks are fixed so that datagrams are the same every time.
+func newPipePair(tb testing.TB) (server, client *Host, clientConn *pipeConn) {
+	tb.Helper()
+	serverConn := &pipeConn{addr: netip.MustParseAddrPort("10.0.0.1:17091")}
+	clientConn = &pipeConn{addr: netip.MustParseAddrPort("10.0.0.2:50000"), other: serverConn}
+	serverConn.other = clientConn
+
+	clock := func() Clock {
+		now := time.Unix(0, 0)
+		return func() time.Time {
+			now = now.Add(time.Millisecond)
+			return now
+		}
+	}
+	server, _ = NewHost(serverConn, Config{PeerCount: 2, ChannelLimit: 2, Clock: clock(), Seed: 1})
+	client, _ = NewHost(clientConn, Config{PeerCount: 1, Clock: clock(), Seed: 2})
+	if _, err := client.Connect(serverConn.addr, 2, 0); err != nil {
+		tb.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		client.Service(0)
+		server.Service(0)
+	}
+	if server.peers[0].state != StateConnected {
+		tb.Fatalf("server peer is in state %d", server.peers[0].state)
+	}
+	return server, client, clientConn
+}
+
+// FuzzHandleDatagram feeds datagrams to a host with a connected peer. No
+// datagram may panic, or make a peer hold more than MaximumWaitingData in
+// incomplete fragments.
+func FuzzHandleDatagram(f *testing.F) {
+	server, client, clientConn := newPipePair(f)
+	clientConn.sent = nil
+	peer := &client.peers[0]
+	peer.Send(0, NewPacket([]byte("reliable"), PacketFlagReliable))
+	peer.Send(1, NewPacket([]byte("unreliable"), 0))
+	peer.Send(1, NewPacket([]byte("unsequenced"), PacketFlagUnsequenced))
+	peer.Send(0, NewPacket(bytes.Repeat([]byte("fragment"), 400), PacketFlagReliable))
+	peer.Disconnect(7)
+	for i := 0; i < 4; i++ {
+		client.Flush()
+	}
+	for _, d := range clientConn.sent {
+		f.Add(d)
+	}
+	f.Add(fragmentDatagram(&server.peers[0], 1, 2000))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		server, _, clientConn := newPipePair(t)
+		server.MaximumWaitingData = 64 * 1024
+		server.handleDatagram(data, clientConn.addr)
+		server.handleDatagram(data, netip.MustParseAddrPort("10.0.0.3:50000"))
+		for i := 0; i < 4; i++ {
+			server.Service(0)
+		}
+		for i := range server.peers {
+			if n := server.peers[i].fragmentData; n < 0 || n > server.MaximumWaitingData {
+				t.Fatalf("peer %d holds %d bytes of fragments", i, n)
+			}
+		}
+	})
+}
+
+// fragmentDatagram builds a datagram carrying the first of two fragments of
+// a reliable packet, as sent to peer
+func fragmentDatagram(peer *Peer, sequenceNumber uint16, totalLength uint32) []byte {
+	payload := make([]byte, totalLength/2)
+	b := binary.BigEndian.AppendUint16(nil, peer.incomingPeerID|uint16(peer.incomingSessionID)<<headerSessionShift)
+	cmd := command{
+		command:                commandSendFragment,
+		reliableSequenceNumber: sequenceNumber,
+		sequenceNumber:         sequenceNumber,
+		dataLength:             uint16(len(payload)),
+		fragmentCount:          2,
+		totalLength:            totalLength,
+	}
+	return append(cmd.appendTo(b), payload...)
+}
+
+func TestMaximumWaitingData(t *testing.T) {
+	server, _, clientConn := newPipePair(t)
+	server.MaximumWaitingData = 5000
+	peer := &server.peers[0]
+
+	// None of the packets completes, the third one doesn't fit.
+	for i := uint16(1); i <= 3; i++ {
+		server.handleDatagram(fragmentDatagram(peer, i, 2000), clientConn.addr)
+	}
+	if n := peer.fragmentData; n != 4000 {
+		t.Fatalf("peer holds %d bytes of fragments, want 4000", n)
+	}
+
+	peer.Reset()
+	if n := peer.fragmentData; n != 0 {
+		t.Fatalf("peer holds %d bytes of fragments after a reset", n)
+	}
+}
+
+// commandDatagram builds a datagram carrying a single send command with
+// payload, as sent to peer
+func commandDatagram(peer *Peer, cmd command, payload string) []byte {
+	b := binary.BigEndian.AppendUint16(nil, peer.incomingPeerID|uint16(peer.incomingSessionID)<<headerSessionShift)
+	cmd.dataLength = uint16(len(payload))
+	return append(cmd.appendTo(b), payload...)
+}
+
+func TestUnreliableOrdering(t *testing.T) {
+	server, _, clientConn := newPipePair(t)
+	peer := &server.peers[0]
+	reliable := func(sequenceNumber uint16, payload string) []byte {
+		return commandDatagram(peer, command{
+			command:                commandSendReliable | commandFlagAcknowledge,
+			reliableSequenceNumber: sequenceNumber,
+		}, payload)
+	}
+	unreliable := func(reliableSequenceNumber, sequenceNumber uint16, payload string) []byte {
+		return commandDatagram(peer, command{
+			command:                commandSendUnreliable,
+			reliableSequenceNumber: reliableSequenceNumber,
+			sequenceNumber:         sequenceNumber,
+		}, payload)
+	}
+
+	// Unreliable packets wait for the reliable packets sent before them.
+	for _, d := range [][]byte{
+		unreliable(1, 2, "u1b"),
+		unreliable(1, 1, "u1a"),
+		reliable(2, "r2"),
+		unreliable(2, 1, "u2"),
+		unreliable(1, 1, "u1a again"),
+		unreliable(0, 1, "u0"),
+		reliable(1, "r1"),
+		unreliable(1, 1, "stale"),
+	} {
+		server.handleDatagram(d, clientConn.addr)
+	}
+	var got []string
+	for {
+		ev, ok := server.CheckEvents()
+		if !ok {
+			break
+		}
+		if ev.Type == EventReceive {
+			got = append(got, string(ev.Packet.Data))
+		}
+	}
+	want := []string{"u0", "r1", "u1a", "u1b", "r2", "u2"}
+	if len(got) != len(want) {
+		t.Fatalf("received %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("received %q, want %q", got, want)
+		}
+	}
+	if n := len(peer.channels[0].held); n != 0 {
+		t.Errorf("%d packets still held", n)
+	}
+}
+
+// goldenHex decodes hex split into fields by spaces
+func goldenHex(tb testing.TB, s string) []byte {
+	tb.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return b
+}
+
+// TestGoldenDatagrams pins the datagrams of a handshake and of sends, laid
+// out by hand after the structures of enet's protocol.h
+func TestGoldenDatagrams(t *testing.T) {
+	_, client, clientConn := newPipePair(t)
+	peer := &client.peers[0]
+	connectID := fmt.Sprintf("%08x", bits.ReverseBytes32(peer.connectID))
+
+	handshake := []struct {
+		name string
+		got  []byte
+		want string
+	}{{
+		// peer ID 0xFFF with the sent time flag, sent time
+		// connect with the acknowledge flag, channel 0xFF, reliable sequence number 1
+		// outgoing peer ID, incoming and outgoing session IDs, MTU, window size, channel count
+		// incoming and outgoing bandwidth, throttle interval, acceleration and deceleration
+		// connect ID in host order, data
+		"connect", clientConn.sent[0],
+		"8fff 0002" +
+			"82 ff 0001" +
+			"0000 ff ff 00000570 00010000 00000002" +
+			"00000000 00000000 00001388 00000002 00000002" +
+			connectID + "00000000",
+	}, {
+		// peer ID 0 and session 0 with the sent time flag, sent time
+		// verify connect, without an acknowledgement of the connect
+		"verify connect", clientConn.other.sent[0],
+		"8000 0003" +
+			"83 ff 0001" +
+			"0000 00 00 00000570 00010000 00000002" +
+			"00000000 00000000 00001388 00000002 00000002" +
+			connectID,
+	}, {
+		// peer ID 0 without a sent time
+		// acknowledge, channel 0xFF, reliable sequence number 1, received
+		// reliable sequence number and sent time
+		"acknowledge", clientConn.sent[1],
+		"0000" +
+			"01 ff 0001 0001 0003",
+	}}
+	for _, g := range handshake {
+		if want := goldenHex(t, g.want); !bytes.Equal(g.got, want) {
+			t.Errorf("%s datagram is %x, want %x", g.name, g.got, want)
+		}
+	}
+
+	clientConn.sent = nil
+	peer.Send(1, NewPacket([]byte("hi"), PacketFlagReliable))
+	peer.Send(1, NewPacket([]byte("yo"), 0))
+	client.Flush()
+	// send reliable with the acknowledge flag, channel 1, reliable sequence
+	// number 1, data length, data
+	// send unreliable, channel 1, reliable sequence number 1, unreliable
+	// sequence number 1, data length, data
+	want := goldenHex(t, fmt.Sprintf("8000 %04x", uint16(client.serviceTime))+
+		"86 01 0001 0002 6869"+
+		"07 01 0001 0001 0002 796f")
+	if len(clientConn.sent) != 1 || !bytes.Equal(clientConn.sent[0], want) {
+		t.Errorf("send datagrams are %x, want %x", clientConn.sent, want)
+	}
+
+}
+
+// TestNewPacketHeaderUnsupported checks that hosts set to a new packet
+// header mode fail instead of sending datagrams in some other layout
+func TestNewPacketHeaderUnsupported(t *testing.T) {
+	for name, set := range map[string]func(host *Host){
+		"client": func(host *Host) { host.NewPacketHeader = true },
+		"server": func(host *Host) { host.NewPacketHeaderForServer = true },
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, client, clientConn := newPipePair(t)
+			set(client)
+			clientConn.sent = nil
+
+			client.peers[0].Send(0, NewPacket([]byte("hi"), PacketFlagReliable))
+			client.Flush()
+			if _, err := client.Service(0); err != ErrNewPacketHeader {
+				t.Errorf("Service returned %v, want ErrNewPacketHeader", err)
+			}
+			if _, err := client.Connect(netip.MustParseAddrPort("10.0.0.3:17091"), 2, 0); err != ErrNewPacketHeader {
+				t.Errorf("Connect returned %v, want ErrNewPacketHeader", err)
+			}
+			if len(clientConn.sent) != 0 {
+				t.Errorf("sent %x", clientConn.sent)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+//go:build cgo && !purego
+
+package protocol_test
+
+import (
+	"bytes"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	enet "github.com/eikarna/gotops"
+	"github.com/eikarna/gotops/internal/protocol"
+)
+
+// endpoint is one side of an interop test, a host of the C backend or a
+// protocol.Host
+type endpoint interface {
+	// connect starts connecting to the host listening at addr
+	connect(t *testing.T, addr netip.AddrPort)
+
+	// service services the host once, returning the type and data of the
+	// event, if any
+	service() (enet.EventType, []byte)
+
+	send(data []byte, flags uint32)
+	close()
+}
+
+type cEndpoint struct {
+	host enet.Host
+	peer enet.Peer
+}
+
+func newCEndpoint(t *testing.T, listen netip.AddrPort, configure func(enet.Host)) *cEndpoint {
+	var addr enet.Address
+	if listen.IsValid() {
+		addr = enet.NewAddressFromAddrPort(listen)
+	}
+	host, err := enet.NewHost(enet.ENET_ADDRESS_TYPE_IPV4, addr, 1, 2, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if configure != nil {
+		configure(host)
+	}
+	return &cEndpoint{host: host}
+}
+
+func (e *cEndpoint) connect(t *testing.T, addr netip.AddrPort) {
+	peer, err := e.host.Connect(enet.NewAddressFromAddrPort(addr), 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.peer = peer
+}
+
+func (e *cEndpoint) service() (enet.EventType, []byte) {
+	ev := e.host.Service(1)
+	switch ev.GetType() {
+	case enet.EventConnect:
+		e.peer = ev.GetPeer()
+	case enet.EventReceive:
+		data := append([]byte(nil), ev.GetPacket().GetData()...)
+		ev.GetPacket().Destroy()
+		return enet.EventReceive, data
+	}
+	return ev.GetType(), nil
+}
+
+func (e *cEndpoint) send(data []byte, flags uint32) {
+	e.peer.SendBytes(data, 0, enet.PacketFlags(flags))
+}
+
+func (e *cEndpoint) close() {
+	e.host.Destroy()
+}
+
+type goEndpoint struct {
+	host *protocol.Host
+	peer *protocol.Peer
+}
+
+func newGoEndpoint(t *testing.T, listen netip.AddrPort, configure func(*protocol.Host)) *goEndpoint {
+	if !listen.IsValid() {
+		listen = netip.MustParseAddrPort("127.0.0.1:0")
+	}
+	conn, err := protocol.ListenUDP("udp4", listen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, err := protocol.NewHost(conn, protocol.Config{PeerCount: 1, ChannelLimit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if configure != nil {
+		configure(host)
+	}
+	return &goEndpoint{host: host}
+}
+
+func (e *goEndpoint) connect(t *testing.T, addr netip.AddrPort) {
+	peer, err := e.host.Connect(addr, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.peer = peer
+}
+
+func (e *goEndpoint) service() (enet.EventType, []byte) {
+	ev, err := e.host.Service(time.Millisecond)
+	if err != nil {
+		return enet.EventNone, nil
+	}
+	switch ev.Type {
+	case protocol.EventConnect:
+		e.peer = ev.Peer
+		return enet.EventConnect, nil
+	case protocol.EventReceive:
+		return enet.EventReceive, ev.Packet.Data
+	case protocol.EventDisconnect:
+		return enet.EventDisconnect, nil
+	}
+	return enet.EventNone, nil
+}
+
+func (e *goEndpoint) send(data []byte, flags uint32) {
+	e.peer.Send(0, protocol.NewPacket(data, flags))
+}
+
+func (e *goEndpoint) close() {
+	e.host.Close()
+}
+
+// freePort returns a UDP port free on the loopback interface
+func freePort(t *testing.T) netip.AddrPort {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).AddrPort()
+}
+
+// TestInterop connects hosts of the C backend and protocol.Host both ways
+// and exchanges packets, for every framing both support
+func TestInterop(t *testing.T) {
+	checksumC := func(host enet.Host) { host.EnableChecksum() }
+	checksumGo := func(host *protocol.Host) { host.Checksum = true }
+
+	for _, tc := range []struct {
+		name     string
+		goServer bool
+		c        func(enet.Host)
+		g        func(*protocol.Host)
+	}{
+		{name: "C server"},
+		{name: "Go server", goServer: true},
+		{name: "C server checksum", c: checksumC, g: checksumGo},
+		{name: "Go server checksum", goServer: true, c: checksumC, g: checksumGo},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			addr := freePort(t)
+			var server, client endpoint
+			if tc.goServer {
+				server, client = newGoEndpoint(t, addr, tc.g), newCEndpoint(t, netip.AddrPort{}, tc.c)
+			} else {
+				server, client = newCEndpoint(t, addr, tc.c), newGoEndpoint(t, netip.AddrPort{}, tc.g)
+			}
+			defer server.close()
+			defer client.close()
+
+			client.connect(t, addr)
+			connected := 0
+			for deadline := time.Now().Add(5 * time.Second); connected < 2; {
+				if time.Now().After(deadline) {
+					t.Fatal("hosts didn't connect")
+				}
+				for _, e := range []endpoint{server, client} {
+					if typ, _ := e.service(); typ == enet.EventConnect {
+						connected++
+					}
+				}
+			}
+
+			large := bytes.Repeat([]byte("0123456789"), 1000)
+			packets := []struct {
+				data  []byte
+				flags uint32
+			}{
+				{[]byte("reliable"), protocol.PacketFlagReliable},
+				{large, protocol.PacketFlagReliable},
+				{[]byte("unreliable"), 0},
+			}
+			for _, pair := range [][2]endpoint{{client, server}, {server, client}} {
+				from, to := pair[0], pair[1]
+				for _, p := range packets {
+					from.send(p.data, p.flags)
+				}
+				for i, deadline := 0, time.Now().Add(5*time.Second); i < len(packets); {
+					if time.Now().After(deadline) {
+						t.Fatalf("%d of %d packets received", i, len(packets))
+					}
+					from.service()
+					typ, data := to.service()
+					if typ != enet.EventReceive {
+						continue
+					}
+					if !bytes.Equal(data, packets[i].data) {
+						t.Fatalf("packet %d has %d bytes, want %d", i, len(data), len(packets[i].data))
+					}
+					i++
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package protocol
+
+import "sync/atomic"
+
+// Packet is a reference counted packet that may be queued on several peers
+// at once, as with a broadcast.
+type Packet struct {
+	Data  []byte
+	Flags uint32
+
+	refs      atomic.Int32
+	destroyed atomic.Bool
+
+	// OnFree is called once the packet has been destroyed and isn't
+	// referenced by any peer anymore.
+	OnFree func(packet *Packet)
+}
+
+// NewPacket creates a packet. Unless PacketFlagNoAllocate is set, data is
+// copied.
+func NewPacket(data []byte, flags uint32) *Packet {
+	packet := &Packet{
+		Flags: flags,
+	}
+	if flags&PacketFlagNoAllocate != 0 {
+		packet.Data = data
+	} else {
+		packet.Data = append(make([]byte, 0, len(data)), data...)
+	}
+	return packet
+}
+
+// Destroy releases the packet. Queued sends keep it alive until they are done.
+func (packet *Packet) Destroy() {
+	if packet.destroyed.Swap(true) {
+		return
+	}
+	packet.maybeFree()
+}
+
+// Referenced tells whether queued sends still hold the packet
+func (packet *Packet) Referenced() bool {
+	return packet.refs.Load() != 0
+}
+
+// ref takes a reference on behalf of a queued command
+func (packet *Packet) ref() {
+	packet.refs.Add(1)
+}
+
+// unref drops a reference taken with ref. Packets that are not referenced
+// anymore after being sent are destroyed, like in enet.
+func (packet *Packet) unref() {
+	if packet.refs.Add(-1) == 0 {
+		packet.Flags |= PacketFlagSent
+		packet.destroyed.Store(true)
+		packet.maybeFree()
+	}
+}
+
+// maybeFree calls OnFree if the packet is destroyed and unreferenced
+func (packet *Packet) maybeFree() {
+	if packet.refs.Load() == 0 && packet.OnFree != nil {
+		onFree := packet.OnFree
+		packet.OnFree = nil
+		onFree(packet)
+	}
+}
+
+// EventType is the type of an Event
+type EventType int
+
+// Event types, matching ENetEventType
+const (
+	EventNone EventType = iota
+	EventConnect
+	EventDisconnect
+	EventReceive
+)
+
+// Event is returned by Host.Service
+type Event struct {
+	Type      EventType
+	Peer      *Peer
+	ChannelID uint8
+	Data      uint32
+	Packet    *Packet
+
+	// Timeout is set on disconnect events of peers that timed out
+	Timeout bool
+}
@@ -0,0 +1,715 @@
+package protocol
+
+import (
+	"errors"
+	"net/netip"
+)
+
+// PeerState is the connection state of a peer, matching ENetPeerState
+type PeerState int
+
+// Peer states
+const (
+	StateDisconnected PeerState = iota
+	StateConnecting
+	StateAcknowledgingConnect
+	StateConnectionPending
+	StateConnectionSucceeded
+	StateConnected
+	StateDisconnectLater
+	StateDisconnecting
+	StateAcknowledgingDisconnect
+	StateZombie
+)
+
+// Errors returned when sending to a peer
+var (
+	ErrNotConnected   = errors.New("protocol: peer is not connected")
+	ErrInvalidChannel = errors.New("protocol: invalid channel")
+	ErrPacketTooLarge = errors.New("protocol: packet too large")
+)
+
+// outgoingCommand is a command queued for sending, or awaiting acknowledgement
+type outgoingCommand struct {
+	cmd              command
+	packet           *Packet
+	fragmentOffset   uint32
+	fragmentLength   uint16
+	sentTime         uint32
+	roundTripTimeout uint32
+	sendAttempts     uint16
+}
+
+// acknowledgement is an acknowledgement queued for sending
+type acknowledgement struct {
+	command                byte
+	channelID              uint8
+	reliableSequenceNumber uint16
+	sentTime               uint16
+}
+
+// incomingPacket is a reliable packet waiting for the packets before it
+type incomingPacket struct {
+	packet *Packet
+	span   uint16
+}
+
+// fragmentBuffer reassembles a fragmented packet
+type fragmentBuffer struct {
+	reliableSequenceNumber uint16
+	data                   []byte
+	flags                  uint32
+	fragmentCount          uint32
+	remaining              uint32
+	received               []uint32
+}
+
+// channel holds the sequencing state of one channel of a peer
+type channel struct {
+	outgoingReliableSequenceNumber   uint16
+	outgoingUnreliableSequenceNumber uint16
+	incomingReliableSequenceNumber   uint16
+	incomingUnreliableSequenceNumber uint16
+
+	pending             map[uint16]incomingPacket
+	fragments           map[uint16]*fragmentBuffer
+	unreliableFragments map[uint32]*fragmentBuffer
+	held                []heldPacket
+}
+
+// heldPacket is an unreliable packet sent after a reliable packet that
+// hasn't been received yet. Like enet, it is held back until the reliable
+// packet is dispatched.
+type heldPacket struct {
+	reliableSequenceNumber uint16
+	sequenceNumber         uint16
+	packet                 *Packet
+}
+
+// maximumHeldPackets is the most unreliable packets a channel holds back.
+// Beyond it, new ones are dropped rather than growing forever.
+const maximumHeldPackets = 64
+
+// Peer is a remote host connected to a Host
+type Peer struct {
+	host *Host
+
+	incomingPeerID    uint16
+	outgoingPeerID    uint16
+	connectID         uint32
+	incomingSessionID uint8
+	outgoingSessionID uint8
+	address           netip.AddrPort
+	state             PeerState
+	channels          []channel
+
+	incomingBandwidth          uint32
+	outgoingBandwidth          uint32
+	packetThrottleInterval     uint32
+	packetThrottleAcceleration uint32
+	packetThrottleDeceleration uint32
+	mtu                        uint32
+	windowSize                 uint32
+	reliableDataInTransit      uint32
+	fragmentData               int
+
+	lastSendTime          uint32
+	lastReceiveTime       uint32
+	earliestTimeout       uint32
+	roundTripTime         uint32
+	roundTripTimeVariance uint32
+	timeoutLimit          uint32
+	timeoutMinimum        uint32
+	timeoutMaximum        uint32
+	pingInterval          uint32
+
+	outgoingReliableSequenceNumber uint16
+	outgoingUnsequencedGroup       uint16
+	incomingUnsequencedGroup       uint16
+	unsequencedWindow              [peerUnsequencedWindowSize / 32]uint32
+
+	acknowledgements []acknowledgement
+	sentReliable     []*outgoingCommand
+	outgoing         []*outgoingCommand
+
+	eventData uint32
+
+	// PacketsSent and PacketsLost count reliable packets, for statistics
+	PacketsSent uint32
+	PacketsLost uint32
+
+	// Data is arbitrary application data attached to the peer. It survives
+	// disconnects, like ENetPeer.data.
+	Data []byte
+}
+
+// Host returns the host the peer belongs to
+func (peer *Peer) Host() *Host {
+	return peer.host
+}
+
+// ID returns the index of the peer in its host
+func (peer *Peer) ID() uint16 {
+	return peer.incomingPeerID
+}
+
+// State returns the connection state of the peer
+func (peer *Peer) State() PeerState {
+	return peer.state
+}
+
+// Address returns the address of the remote host
+func (peer *Peer) Address() netip.AddrPort {
+	return peer.address
+}
+
+// ConnectID returns the ID of the current connection
+func (peer *Peer) ConnectID() uint32 {
+	return peer.connectID
+}
+
+// RoundTripTime returns the mean round trip time in milliseconds
+func (peer *Peer) RoundTripTime() uint32 {
+	return peer.roundTripTime
+}
+
+// ChannelCount returns the number of channels allocated for the peer
+func (peer *Peer) ChannelCount() int {
+	return len(peer.channels)
+}
+
+// Timeout sets the timeout parameters of the peer, see enet_peer_timeout.
+// Zero values select the defaults.
+func (peer *Peer) Timeout(limit, minimum, maximum uint32) {
+	if limit == 0 {
+		limit = peerTimeoutLimit
+	}
+	if minimum == 0 {
+		minimum = peerTimeoutMinimum
+	}
+	if maximum == 0 {
+		maximum = peerTimeoutMaximum
+	}
+	peer.timeoutLimit = limit
+	peer.timeoutMinimum = minimum
+	peer.timeoutMaximum = maximum
+}
+
+// Ping queues a ping, used to keep the connection alive and measure latency
+func (peer *Peer) Ping() {
+	if peer.state != StateConnected {
+		return
+	}
+	peer.queueOutgoing(command{
+		command:   commandPing | commandFlagAcknowledge,
+		channelID: 0xFF,
+	}, nil, 0, 0)
+}
+
+// Send queues a packet to be sent on the given channel
+func (peer *Peer) Send(channelID uint8, packet *Packet) error {
+	if peer.state != StateConnected {
+		return ErrNotConnected
+	}
+	if int(channelID) >= len(peer.channels) {
+		return ErrInvalidChannel
+	}
+	if len(packet.Data) > peer.host.MaximumPacketSize {
+		return ErrPacketTooLarge
+	}
+
+	ch := &peer.channels[channelID]
+	fragmentLength := peer.fragmentLength()
+	length := uint32(len(packet.Data))
+
+	if length > fragmentLength {
+		fragmentCount := (length + fragmentLength - 1) / fragmentLength
+		if fragmentCount > MaximumFragmentCount {
+			return ErrPacketTooLarge
+		}
+
+		var commandNumber byte
+		var startSequenceNumber uint16
+		if packet.Flags&(PacketFlagReliable|PacketFlagUnreliableFragment) == PacketFlagUnreliableFragment &&
+			ch.outgoingUnreliableSequenceNumber < 0xFFFF {
+			commandNumber = commandSendUnreliableFragment
+			startSequenceNumber = ch.outgoingUnreliableSequenceNumber + 1
+		} else {
+			commandNumber = commandSendFragment | commandFlagAcknowledge
+			startSequenceNumber = ch.outgoingReliableSequenceNumber + 1
+		}
+
+		for number, offset := uint32(0), uint32(0); offset < length; number, offset = number+1, offset+fragmentLength {
+			size := fragmentLength
+			if length-offset < size {
+				size = length - offset
+			}
+			peer.queueOutgoing(command{
+				command:        commandNumber,
+				channelID:      channelID,
+				sequenceNumber: startSequenceNumber,
+				dataLength:     uint16(size),
+				fragmentCount:  fragmentCount,
+				fragmentNumber: number,
+				totalLength:    length,
+				fragmentOffset: offset,
+			}, packet, offset, uint16(size))
+		}
+		return nil
+	}
+
+	cmd := command{
+		channelID:  channelID,
+		dataLength: uint16(length),
+	}
+	switch {
+	case packet.Flags&(PacketFlagReliable|PacketFlagUnsequenced) == PacketFlagUnsequenced:
+		cmd.command = commandSendUnsequenced | commandFlagUnsequenced
+	case packet.Flags&PacketFlagReliable != 0 || ch.outgoingUnreliableSequenceNumber >= 0xFFFF:
+		cmd.command = commandSendReliable | commandFlagAcknowledge
+	default:
+		cmd.command = commandSendUnreliable
+	}
+	peer.queueOutgoing(cmd, packet, 0, uint16(length))
+	return nil
+}
+
+// fragmentLength returns the largest payload that fits in a single datagram
+func (peer *Peer) fragmentLength() uint32 {
+	overhead := uint32(4 + commandSizes[commandSendFragment])
+	if peer.host.Checksum {
+		overhead += 4
+	}
+	return peer.mtu - overhead
+}
+
+// queueOutgoing assigns sequence numbers to a command and queues it
+func (peer *Peer) queueOutgoing(cmd command, packet *Packet, offset uint32, length uint16) {
+	oc := &outgoingCommand{
+		cmd:            cmd,
+		packet:         packet,
+		fragmentOffset: offset,
+		fragmentLength: length,
+	}
+
+	if cmd.channelID == 0xFF {
+		peer.outgoingReliableSequenceNumber++
+		oc.cmd.reliableSequenceNumber = peer.outgoingReliableSequenceNumber
+	} else {
+		ch := &peer.channels[cmd.channelID]
+		switch {
+		case cmd.command&commandFlagAcknowledge != 0:
+			ch.outgoingReliableSequenceNumber++
+			ch.outgoingUnreliableSequenceNumber = 0
+			oc.cmd.reliableSequenceNumber = ch.outgoingReliableSequenceNumber
+		case cmd.command&commandFlagUnsequenced != 0:
+			peer.outgoingUnsequencedGroup++
+			oc.cmd.reliableSequenceNumber = 0
+			oc.cmd.sequenceNumber = peer.outgoingUnsequencedGroup
+		default:
+			if offset == 0 {
+				ch.outgoingUnreliableSequenceNumber++
+			}
+			oc.cmd.reliableSequenceNumber = ch.outgoingReliableSequenceNumber
+			if cmd.number() == commandSendUnreliable {
+				oc.cmd.sequenceNumber = ch.outgoingUnreliableSequenceNumber
+			}
+		}
+	}
+
+	if packet != nil {
+		packet.ref()
+	}
+	peer.outgoing = append(peer.outgoing, oc)
+}
+
+// queueAcknowledgement queues an acknowledgement of a received command
+func (peer *Peer) queueAcknowledgement(cmd *command, sentTime uint16) {
+	peer.acknowledgements = append(peer.acknowledgements, acknowledgement{
+		command:                cmd.command,
+		channelID:              cmd.channelID,
+		reliableSequenceNumber: cmd.reliableSequenceNumber,
+		sentTime:               sentTime,
+	})
+}
+
+// Disconnect requests a disconnection. A disconnect event is generated once
+// the remote host acknowledged it.
+func (peer *Peer) Disconnect(data uint32) {
+	switch peer.state {
+	case StateDisconnecting, StateDisconnected, StateAcknowledgingDisconnect, StateZombie:
+		return
+	}
+
+	peer.resetQueues()
+	cmd := command{
+		channelID: 0xFF,
+		data:      data,
+	}
+	connected := peer.state == StateConnected || peer.state == StateDisconnectLater
+	if connected {
+		cmd.command = commandDisconnect | commandFlagAcknowledge
+	} else {
+		cmd.command = commandDisconnect | commandFlagUnsequenced
+	}
+	peer.queueOutgoing(cmd, nil, 0, 0)
+
+	if connected {
+		peer.state = StateDisconnecting
+	} else {
+		peer.host.Flush()
+		peer.Reset()
+	}
+}
+
+// DisconnectNow disconnects immediately, without waiting for an
+// acknowledgement. No disconnect event is generated.
+func (peer *Peer) DisconnectNow(data uint32) {
+	if peer.state == StateDisconnected {
+		return
+	}
+	if peer.state != StateZombie && peer.state != StateDisconnecting {
+		peer.resetQueues()
+		peer.queueOutgoing(command{
+			command:   commandDisconnect | commandFlagUnsequenced,
+			channelID: 0xFF,
+			data:      data,
+		}, nil, 0, 0)
+		peer.host.Flush()
+	}
+	peer.Reset()
+}
+
+// DisconnectLater disconnects once all queued packets have been sent
+func (peer *Peer) DisconnectLater(data uint32) {
+	if (peer.state == StateConnected || peer.state == StateDisconnectLater) &&
+		(len(peer.outgoing) > 0 || len(peer.sentReliable) > 0) {
+		peer.state = StateDisconnectLater
+		peer.eventData = data
+		return
+	}
+	peer.Disconnect(data)
+}
+
+// Reset forcefully disconnects the peer without notifying the remote host
+func (peer *Peer) Reset() {
+	peer.resetQueues()
+	peer.outgoingPeerID = MaximumPeerID
+	peer.connectID = 0
+	peer.state = StateDisconnected
+	peer.incomingSessionID = 0xFF
+	peer.outgoingSessionID = 0xFF
+	peer.channels = nil
+	peer.incomingBandwidth = 0
+	peer.outgoingBandwidth = 0
+	peer.mtu = uint32(peer.host.MTU)
+	peer.windowSize = MaximumWindowSize
+	peer.reliableDataInTransit = 0
+	peer.lastSendTime = 0
+	peer.lastReceiveTime = 0
+	peer.earliestTimeout = 0
+	peer.roundTripTime = peerDefaultRoundTripTime
+	peer.roundTripTimeVariance = 0
+	peer.timeoutLimit = peerTimeoutLimit
+	peer.timeoutMinimum = peerTimeoutMinimum
+	peer.timeoutMaximum = peerTimeoutMaximum
+	peer.pingInterval = peerPingInterval
+	peer.outgoingReliableSequenceNumber = 0
+	peer.outgoingUnsequencedGroup = 0
+	peer.incomingUnsequencedGroup = 0
+	peer.unsequencedWindow = [len(peer.unsequencedWindow)]uint32{}
+	peer.eventData = 0
+	peer.PacketsSent = 0
+	peer.PacketsLost = 0
+}
+
+// resetQueues drops every queued command and partially received packet
+func (peer *Peer) resetQueues() {
+	for _, oc := range peer.outgoing {
+		if oc.packet != nil {
+			oc.packet.unref()
+		}
+	}
+	for _, oc := range peer.sentReliable {
+		if oc.packet != nil {
+			oc.packet.unref()
+		}
+	}
+	peer.outgoing = nil
+	peer.sentReliable = nil
+	peer.acknowledgements = nil
+	for i := range peer.channels {
+		peer.channels[i].pending = nil
+		peer.channels[i].fragments = nil
+		peer.channels[i].unreliableFragments = nil
+		peer.channels[i].held = nil
+	}
+	peer.fragmentData = 0
+}
+
+// setupChannels allocates count channels
+func (peer *Peer) setupChannels(count int) {
+	peer.channels = make([]channel, count)
+}
+
+// inReliableWindow reports whether a reliable sequence number falls in the
+// window of sequence numbers the channel currently accepts
+func (ch *channel) inReliableWindow(sequenceNumber uint16) bool {
+	reliableWindow := sequenceNumber / peerReliableWindowSize
+	currentWindow := ch.incomingReliableSequenceNumber / peerReliableWindowSize
+	if sequenceNumber < ch.incomingReliableSequenceNumber {
+		reliableWindow += peerReliableWindows
+	}
+	return reliableWindow >= currentWindow && reliableWindow < currentWindow+peerReliableWindows-1
+}
+
+// receiveReliable handles a reliable packet, dispatching it and any packets
+// that were waiting on it
+func (peer *Peer) receiveReliable(channelID uint8, sequenceNumber uint16, packet *Packet, span uint16) {
+	ch := &peer.channels[channelID]
+	if ch.pending == nil {
+		ch.pending = make(map[uint16]incomingPacket)
+	}
+	ch.pending[sequenceNumber] = incomingPacket{packet: packet, span: span}
+
+	for {
+		next, ok := ch.pending[ch.incomingReliableSequenceNumber+1]
+		if !ok {
+			return
+		}
+		delete(ch.pending, ch.incomingReliableSequenceNumber+1)
+		ch.incomingReliableSequenceNumber += next.span
+		ch.incomingUnreliableSequenceNumber = 0
+		peer.host.queueEvent(Event{
+			Type:      EventReceive,
+			Peer:      peer,
+			ChannelID: channelID,
+			Packet:    next.packet,
+		})
+		peer.dispatchHeld(channelID)
+	}
+}
+
+// receiveUnreliable handles an unreliable packet sent after the reliable
+// packet with the given sequence number. It is dispatched if that packet
+// was, held back if it is still to come, and dropped if it is stale.
+func (peer *Peer) receiveUnreliable(channelID uint8, reliableSequenceNumber, sequenceNumber uint16, packet *Packet) {
+	ch := &peer.channels[channelID]
+	if reliableSequenceNumber == ch.incomingReliableSequenceNumber {
+		if int16(sequenceNumber-ch.incomingUnreliableSequenceNumber) <= 0 {
+			return
+		}
+		ch.incomingUnreliableSequenceNumber = sequenceNumber
+		peer.host.queueEvent(Event{
+			Type:      EventReceive,
+			Peer:      peer,
+			ChannelID: channelID,
+			Packet:    packet,
+		})
+		return
+	}
+	if !ch.inReliableWindow(reliableSequenceNumber) || len(ch.held) >= maximumHeldPackets {
+		return
+	}
+
+	// Keep held packets in the order they are to be dispatched in.
+	ahead := reliableSequenceNumber - ch.incomingReliableSequenceNumber
+	i := len(ch.held)
+	for i > 0 {
+		h := &ch.held[i-1]
+		if hAhead := h.reliableSequenceNumber - ch.incomingReliableSequenceNumber; hAhead < ahead ||
+			(hAhead == ahead && int16(h.sequenceNumber-sequenceNumber) < 0) {
+			break
+		} else if hAhead == ahead && h.sequenceNumber == sequenceNumber {
+			return
+		}
+		i--
+	}
+	ch.held = append(ch.held, heldPacket{})
+	copy(ch.held[i+1:], ch.held[i:])
+	ch.held[i] = heldPacket{
+		reliableSequenceNumber: reliableSequenceNumber,
+		sequenceNumber:         sequenceNumber,
+		packet:                 packet,
+	}
+}
+
+// dispatchHeld dispatches the held packets sent after the last reliable
+// packet dispatched, and drops those sent after earlier ones
+func (peer *Peer) dispatchHeld(channelID uint8) {
+	ch := &peer.channels[channelID]
+	n := 0
+	for _, h := range ch.held {
+		if h.reliableSequenceNumber != ch.incomingReliableSequenceNumber && ch.inReliableWindow(h.reliableSequenceNumber) {
+			break
+		}
+		n++
+		if h.reliableSequenceNumber != ch.incomingReliableSequenceNumber ||
+			int16(h.sequenceNumber-ch.incomingUnreliableSequenceNumber) <= 0 {
+			continue
+		}
+		ch.incomingUnreliableSequenceNumber = h.sequenceNumber
+		peer.host.queueEvent(Event{
+			Type:      EventReceive,
+			Peer:      peer,
+			ChannelID: channelID,
+			Packet:    h.packet,
+		})
+	}
+	for i := 0; i < n; i++ {
+		ch.held[i] = heldPacket{}
+	}
+	ch.held = ch.held[n:]
+}
+
+// handleSend handles the send commands
+func (peer *Peer) handleSend(cmd *command, payload []byte) bool {
+	if int(cmd.channelID) >= len(peer.channels) ||
+		(peer.state != StateConnected && peer.state != StateDisconnectLater) {
+		return false
+	}
+	ch := &peer.channels[cmd.channelID]
+
+	switch cmd.number() {
+	case commandSendReliable:
+		sequenceNumber := cmd.reliableSequenceNumber
+		if !ch.inReliableWindow(sequenceNumber) || sequenceNumber == ch.incomingReliableSequenceNumber {
+			return true
+		}
+		if _, ok := ch.pending[sequenceNumber]; ok {
+			return true
+		}
+		peer.receiveReliable(cmd.channelID, sequenceNumber, NewPacket(payload, PacketFlagReliable), 1)
+
+	case commandSendUnreliable:
+		peer.receiveUnreliable(cmd.channelID, cmd.reliableSequenceNumber, cmd.sequenceNumber, NewPacket(payload, 0))
+
+	case commandSendUnsequenced:
+		group := uint32(cmd.sequenceNumber)
+		index := group % peerUnsequencedWindowSize
+		if group < uint32(peer.incomingUnsequencedGroup) {
+			group += 0x10000
+		}
+		if group >= uint32(peer.incomingUnsequencedGroup)+peerFreeUnsequencedWindows*peerUnsequencedWindowSize {
+			return true
+		}
+		group &= 0xFFFF
+		if group-index != uint32(peer.incomingUnsequencedGroup) {
+			peer.incomingUnsequencedGroup = uint16(group - index)
+			peer.unsequencedWindow = [len(peer.unsequencedWindow)]uint32{}
+		} else if peer.unsequencedWindow[index/32]&(1<<(index%32)) != 0 {
+			return true
+		}
+		peer.unsequencedWindow[index/32] |= 1 << (index % 32)
+		peer.host.queueEvent(Event{
+			Type:      EventReceive,
+			Peer:      peer,
+			ChannelID: cmd.channelID,
+			Packet:    NewPacket(payload, PacketFlagUnsequenced),
+		})
+
+	case commandSendFragment, commandSendUnreliableFragment:
+		return peer.handleFragment(ch, cmd, payload)
+	}
+	return true
+}
+
+// handleFragment handles a fragment of a reliable or unreliable packet
+func (peer *Peer) handleFragment(ch *channel, cmd *command, payload []byte) bool {
+	reliable := cmd.number() == commandSendFragment
+	startSequenceNumber := cmd.sequenceNumber
+
+	if cmd.fragmentCount == 0 || cmd.fragmentCount > MaximumFragmentCount ||
+		cmd.fragmentNumber >= cmd.fragmentCount ||
+		cmd.totalLength > uint32(peer.host.MaximumPacketSize) ||
+		cmd.totalLength < cmd.fragmentCount ||
+		cmd.fragmentOffset >= cmd.totalLength ||
+		uint32(len(payload)) > cmd.totalLength-cmd.fragmentOffset {
+		return false
+	}
+
+	var buffers map[uint16]*fragmentBuffer
+	var key uint32
+	if reliable {
+		if !ch.inReliableWindow(startSequenceNumber) || startSequenceNumber == ch.incomingReliableSequenceNumber {
+			return true
+		}
+		if _, ok := ch.pending[startSequenceNumber]; ok {
+			return true
+		}
+		if ch.fragments == nil {
+			ch.fragments = make(map[uint16]*fragmentBuffer)
+		}
+		buffers = ch.fragments
+	} else {
+		if cmd.reliableSequenceNumber != ch.incomingReliableSequenceNumber && !ch.inReliableWindow(cmd.reliableSequenceNumber) {
+			return true
+		}
+		if cmd.reliableSequenceNumber == ch.incomingReliableSequenceNumber &&
+			int16(startSequenceNumber-ch.incomingUnreliableSequenceNumber) <= 0 {
+			return true
+		}
+		if ch.unreliableFragments == nil {
+			ch.unreliableFragments = make(map[uint32]*fragmentBuffer)
+		}
+		key = uint32(cmd.reliableSequenceNumber)<<16 | uint32(startSequenceNumber)
+	}
+
+	var buffer *fragmentBuffer
+	if reliable {
+		buffer = buffers[startSequenceNumber]
+	} else {
+		buffer = ch.unreliableFragments[key]
+	}
+	if buffer == nil {
+		if !reliable && len(ch.unreliableFragments) >= 64 {
+			// Drop incomplete unreliable packets rather than growing forever.
+			for k, b := range ch.unreliableFragments {
+				peer.fragmentData -= len(b.data)
+				delete(ch.unreliableFragments, k)
+			}
+		}
+		if peer.fragmentData+int(cmd.totalLength) > peer.host.MaximumWaitingData {
+			return false
+		}
+		peer.fragmentData += int(cmd.totalLength)
+		buffer = &fragmentBuffer{
+			reliableSequenceNumber: cmd.reliableSequenceNumber,
+			data:                   make([]byte, cmd.totalLength),
+			fragmentCount:          cmd.fragmentCount,
+			remaining:              cmd.fragmentCount,
+			received:               make([]uint32, (cmd.fragmentCount+31)/32),
+		}
+		if reliable {
+			buffer.flags = PacketFlagReliable
+			buffers[startSequenceNumber] = buffer
+		} else {
+			buffer.flags = PacketFlagUnreliableFragment
+			ch.unreliableFragments[key] = buffer
+		}
+	} else if buffer.fragmentCount != cmd.fragmentCount || uint32(len(buffer.data)) != cmd.totalLength {
+		return false
+	}
+
+	if buffer.received[cmd.fragmentNumber/32]&(1<<(cmd.fragmentNumber%32)) != 0 {
+		return true
+	}
+	buffer.received[cmd.fragmentNumber/32] |= 1 << (cmd.fragmentNumber % 32)
+	buffer.remaining--
+	copy(buffer.data[cmd.fragmentOffset:], payload)
+
+	if buffer.remaining > 0 {
+		return true
+	}
+
+	peer.fragmentData -= len(buffer.data)
+	packet := &Packet{Data: buffer.data, Flags: buffer.flags}
+	if reliable {
+		delete(buffers, startSequenceNumber)
+		peer.receiveReliable(cmd.channelID, startSequenceNumber, packet, uint16(buffer.fragmentCount))
+	} else {
+		delete(ch.unreliableFragments, key)
+		peer.receiveUnreliable(cmd.channelID, cmd.reliableSequenceNumber, startSequenceNumber, packet)
+	}
+	return true
+}
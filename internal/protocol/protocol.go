@@ -0,0 +1,287 @@
+// Package protocol is a pure-Go implementation of the ENet protocol, wire
+// compatible with the enet fork this module binds to. It backs the purego
+// build of the enet package and the in-memory transports used in tests.
+//
+// Range coder compression is not supported, compressed datagrams are
+// dropped. The Growtopia new packet header modes are not implemented either,
+// hosts set to use them fail instead of sending anything.
+package protocol
+
+import (
+	"encoding/binary"
+)
+
+// Protocol limits, matching enet/include/enet/protocol.h
+const (
+	MinimumMTU            = 576
+	MaximumMTU            = 4096
+	MaximumPacketCommands = 32
+	MinimumWindowSize     = 4096
+	MaximumWindowSize     = 65536
+	MinimumChannelCount   = 1
+	MaximumChannelCount   = 255
+	MaximumPeerID         = 0xFFF
+	MaximumFragmentCount  = 1024 * 1024
+)
+
+// Host and peer defaults, matching enet/include/enet/enet.h
+const (
+	HostDefaultMTU                = 1392
+	HostDefaultMaximumPacketSize  = 32 * 1024 * 1024
+	HostDefaultMaximumWaitingData = 32 * 1024 * 1024
+
+	peerDefaultRoundTripTime       = 500
+	peerDefaultPacketThrottle      = 32
+	peerPacketThrottleScale        = 32
+	peerPacketThrottleAcceleration = 2
+	peerPacketThrottleDeceleration = 2
+	peerPacketThrottleInterval     = 5000
+	peerWindowSizeScale            = 64 * 1024
+	peerTimeoutLimit               = 32
+	peerTimeoutMinimum             = 5000
+	peerTimeoutMaximum             = 30000
+	peerPingInterval               = 500
+	peerUnsequencedWindowSize      = 1024
+	peerFreeUnsequencedWindows     = 32
+	peerReliableWindows            = 16
+	peerReliableWindowSize         = 0x1000
+)
+
+// Protocol commands
+const (
+	commandNone = iota
+	commandAcknowledge
+	commandConnect
+	commandVerifyConnect
+	commandDisconnect
+	commandPing
+	commandSendReliable
+	commandSendUnreliable
+	commandSendFragment
+	commandSendUnsequenced
+	commandBandwidthLimit
+	commandThrottleConfigure
+	commandSendUnreliableFragment
+	commandCount
+
+	commandMask = 0x0F
+)
+
+// Command and header flags
+const (
+	commandFlagAcknowledge = 1 << 7
+	commandFlagUnsequenced = 1 << 6
+
+	headerFlagCompressed = 1 << 14
+	headerFlagSentTime   = 1 << 15
+	headerFlagMask       = headerFlagCompressed | headerFlagSentTime
+
+	headerSessionMask  = 3 << 12
+	headerSessionShift = 12
+)
+
+// Packet flags, matching ENetPacketFlag
+const (
+	PacketFlagReliable           uint32 = 1 << 0
+	PacketFlagUnsequenced        uint32 = 1 << 1
+	PacketFlagNoAllocate         uint32 = 1 << 2
+	PacketFlagUnreliableFragment uint32 = 1 << 3
+	PacketFlagSent               uint32 = 1 << 8
+)
+
+// commandSizes is the encoded size of each command, including its header
+var commandSizes = [commandCount]int{
+	commandNone:                   0,
+	commandAcknowledge:            8,
+	commandConnect:                48,
+	commandVerifyConnect:          44,
+	commandDisconnect:             8,
+	commandPing:                   4,
+	commandSendReliable:           6,
+	commandSendUnreliable:         8,
+	commandSendFragment:           24,
+	commandSendUnsequenced:        8,
+	commandBandwidthLimit:         12,
+	commandThrottleConfigure:      16,
+	commandSendUnreliableFragment: 24,
+}
+
+// command is a decoded protocol command. Only the fields relevant to the
+// command type are used.
+type command struct {
+	command                byte
+	channelID              uint8
+	reliableSequenceNumber uint16
+
+	// acknowledge
+	receivedReliableSequenceNumber uint16
+	receivedSentTime               uint16
+
+	// connect, verify connect
+	outgoingPeerID             uint16
+	incomingSessionID          uint8
+	outgoingSessionID          uint8
+	mtu                        uint32
+	windowSize                 uint32
+	channelCount               uint32
+	incomingBandwidth          uint32
+	outgoingBandwidth          uint32
+	packetThrottleInterval     uint32
+	packetThrottleAcceleration uint32
+	packetThrottleDeceleration uint32
+	connectID                  uint32
+
+	// connect, disconnect
+	data uint32
+
+	// send unreliable, send unsequenced, send fragment
+	sequenceNumber uint16
+	dataLength     uint16
+
+	// send fragment
+	fragmentCount  uint32
+	fragmentNumber uint32
+	totalLength    uint32
+	fragmentOffset uint32
+}
+
+// number returns the command number without its flags
+func (cmd *command) number() int {
+	return int(cmd.command & commandMask)
+}
+
+// size returns the encoded size of the command, without its payload
+func (cmd *command) size() int {
+	return commandSizes[cmd.number()]
+}
+
+// appendTo encodes the command to b
+func (cmd *command) appendTo(b []byte) []byte {
+	be := binary.BigEndian
+	b = append(b, cmd.command, cmd.channelID)
+	b = be.AppendUint16(b, cmd.reliableSequenceNumber)
+
+	switch cmd.number() {
+	case commandAcknowledge:
+		b = be.AppendUint16(b, cmd.receivedReliableSequenceNumber)
+		b = be.AppendUint16(b, cmd.receivedSentTime)
+	case commandConnect, commandVerifyConnect:
+		b = be.AppendUint16(b, cmd.outgoingPeerID)
+		b = append(b, cmd.incomingSessionID, cmd.outgoingSessionID)
+		b = be.AppendUint32(b, cmd.mtu)
+		b = be.AppendUint32(b, cmd.windowSize)
+		b = be.AppendUint32(b, cmd.channelCount)
+		b = be.AppendUint32(b, cmd.incomingBandwidth)
+		b = be.AppendUint32(b, cmd.outgoingBandwidth)
+		b = be.AppendUint32(b, cmd.packetThrottleInterval)
+		b = be.AppendUint32(b, cmd.packetThrottleAcceleration)
+		b = be.AppendUint32(b, cmd.packetThrottleDeceleration)
+		// enet never converts the connect ID, so it travels in host order.
+		b = binary.LittleEndian.AppendUint32(b, cmd.connectID)
+		if cmd.number() == commandConnect {
+			b = be.AppendUint32(b, cmd.data)
+		}
+	case commandDisconnect:
+		b = be.AppendUint32(b, cmd.data)
+	case commandSendReliable:
+		b = be.AppendUint16(b, cmd.dataLength)
+	case commandSendUnreliable, commandSendUnsequenced:
+		b = be.AppendUint16(b, cmd.sequenceNumber)
+		b = be.AppendUint16(b, cmd.dataLength)
+	case commandSendFragment, commandSendUnreliableFragment:
+		b = be.AppendUint16(b, cmd.sequenceNumber)
+		b = be.AppendUint16(b, cmd.dataLength)
+		b = be.AppendUint32(b, cmd.fragmentCount)
+		b = be.AppendUint32(b, cmd.fragmentNumber)
+		b = be.AppendUint32(b, cmd.totalLength)
+		b = be.AppendUint32(b, cmd.fragmentOffset)
+	case commandBandwidthLimit:
+		b = be.AppendUint32(b, cmd.incomingBandwidth)
+		b = be.AppendUint32(b, cmd.outgoingBandwidth)
+	case commandThrottleConfigure:
+		b = be.AppendUint32(b, cmd.packetThrottleInterval)
+		b = be.AppendUint32(b, cmd.packetThrottleAcceleration)
+		b = be.AppendUint32(b, cmd.packetThrottleDeceleration)
+	}
+	return b
+}
+
+// decodeCommand decodes the command at the start of b, returning the number
+// of bytes used by the command itself. It returns false if b is too short or
+// the command is unknown.
+func decodeCommand(b []byte, cmd *command) (int, bool) {
+	if len(b) < 4 {
+		return 0, false
+	}
+	*cmd = command{
+		command:                b[0],
+		channelID:              b[1],
+		reliableSequenceNumber: binary.BigEndian.Uint16(b[2:4]),
+	}
+	number := cmd.number()
+	if number == commandNone || number >= commandCount {
+		return 0, false
+	}
+	size := commandSizes[number]
+	if len(b) < size {
+		return 0, false
+	}
+
+	be := binary.BigEndian
+	switch number {
+	case commandAcknowledge:
+		cmd.receivedReliableSequenceNumber = be.Uint16(b[4:])
+		cmd.receivedSentTime = be.Uint16(b[6:])
+	case commandConnect, commandVerifyConnect:
+		cmd.outgoingPeerID = be.Uint16(b[4:])
+		cmd.incomingSessionID = b[6]
+		cmd.outgoingSessionID = b[7]
+		cmd.mtu = be.Uint32(b[8:])
+		cmd.windowSize = be.Uint32(b[12:])
+		cmd.channelCount = be.Uint32(b[16:])
+		cmd.incomingBandwidth = be.Uint32(b[20:])
+		cmd.outgoingBandwidth = be.Uint32(b[24:])
+		cmd.packetThrottleInterval = be.Uint32(b[28:])
+		cmd.packetThrottleAcceleration = be.Uint32(b[32:])
+		cmd.packetThrottleDeceleration = be.Uint32(b[36:])
+		cmd.connectID = binary.LittleEndian.Uint32(b[40:])
+		if number == commandConnect {
+			cmd.data = be.Uint32(b[44:])
+		}
+	case commandDisconnect:
+		cmd.data = be.Uint32(b[4:])
+	case commandSendReliable:
+		cmd.dataLength = be.Uint16(b[4:])
+	case commandSendUnreliable, commandSendUnsequenced:
+		cmd.sequenceNumber = be.Uint16(b[4:])
+		cmd.dataLength = be.Uint16(b[6:])
+	case commandSendFragment, commandSendUnreliableFragment:
+		cmd.sequenceNumber = be.Uint16(b[4:])
+		cmd.dataLength = be.Uint16(b[6:])
+		cmd.fragmentCount = be.Uint32(b[8:])
+		cmd.fragmentNumber = be.Uint32(b[12:])
+		cmd.totalLength = be.Uint32(b[16:])
+		cmd.fragmentOffset = be.Uint32(b[20:])
+	case commandBandwidthLimit:
+		cmd.incomingBandwidth = be.Uint32(b[4:])
+		cmd.outgoingBandwidth = be.Uint32(b[8:])
+	case commandThrottleConfigure:
+		cmd.packetThrottleInterval = be.Uint32(b[4:])
+		cmd.packetThrottleAcceleration = be.Uint32(b[8:])
+		cmd.packetThrottleDeceleration = be.Uint32(b[12:])
+	}
+	return size, true
+}
+
+// timeLess reports whether a is before b, allowing for wrap around
+func timeLess(a, b uint32) bool {
+	return a-b >= 86400000
+}
+
+// timeDifference returns the absolute difference between a and b
+func timeDifference(a, b uint32) uint32 {
+	if a-b >= 86400000 {
+		return b - a
+	}
+	return a - b
+}
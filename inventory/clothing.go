@@ -0,0 +1,92 @@
+package inventory
+
+import (
+	"fmt"
+
+	"github.com/eikarna/gotops/gamepacket"
+	"github.com/eikarna/gotops/items"
+)
+
+// ClothingSlot is where a piece of clothing is worn, the ClothingType of
+// its item
+type ClothingSlot uint8
+
+// Clothing slots
+const (
+	SlotHair ClothingSlot = iota
+	SlotShirt
+	SlotPants
+	SlotFeet
+	SlotFace
+	SlotHand
+	SlotBack
+	SlotMask
+	SlotNecklace
+	SlotAncestral
+	ClothingSlots
+)
+
+// actionClothes is the action type of items that can be worn
+const actionClothes = 20
+
+// DefaultSkinColor is the skin color of new players
+const DefaultSkinColor uint32 = 0x8295c3ff
+
+// Clothing is what a player wears
+type Clothing struct {
+	Slots     [ClothingSlots]uint16
+	SkinColor uint32
+}
+
+// remove unwears an item
+func (c *Clothing) remove(id uint16) bool {
+	removed := false
+	for i := range c.Slots {
+		if c.Slots[i] == id {
+			c.Slots[i] = 0
+			removed = true
+		}
+	}
+	return removed
+}
+
+// Call builds the OnSetClothing call telling clients what the player with
+// the given net ID wears
+func (c *Clothing) Call(netID int32) (*gamepacket.TankPacket, error) {
+	s := c.Slots
+	return gamepacket.NewCall(netID, 0, gamepacket.VariantList{
+		"OnSetClothing",
+		gamepacket.Vec3{X: float32(s[SlotHair]), Y: float32(s[SlotShirt]), Z: float32(s[SlotPants])},
+		gamepacket.Vec3{X: float32(s[SlotFeet]), Y: float32(s[SlotFace]), Z: float32(s[SlotHand])},
+		gamepacket.Vec3{X: float32(s[SlotBack]), Y: float32(s[SlotMask]), Z: float32(s[SlotNecklace])},
+		c.SkinColor,
+		gamepacket.Vec3{X: float32(s[SlotAncestral])},
+	})
+}
+
+// Equip wears an owned piece of clothing, replacing what was worn in its
+// slot, and returns the OnSetClothing call for the player with the given
+// net ID
+func (inv *Inventory) Equip(db *items.ItemDB, id uint16, netID int32) (*gamepacket.TankPacket, error) {
+	it, err := item(db, id)
+	if err != nil {
+		return nil, err
+	}
+	if it.ActionType != actionClothes || ClothingSlot(it.ClothingType) >= ClothingSlots {
+		return nil, fmt.Errorf("%w: %d", ErrNotClothing, id)
+	}
+	if inv.Count(id) == 0 {
+		return nil, fmt.Errorf("%w: item %d", ErrNotOwned, id)
+	}
+	inv.Clothing.Slots[it.ClothingType] = id
+	return inv.Clothing.Call(netID)
+}
+
+// Unequip stops wearing an item and returns the OnSetClothing call for the
+// player with the given net ID
+func (inv *Inventory) Unequip(id uint16, netID int32) (*gamepacket.TankPacket, error) {
+	if !inv.Clothing.remove(id) {
+		return nil, fmt.Errorf("%w: item %d isn't worn", ErrNotOwned, id)
+	}
+	return inv.Clothing.Call(netID)
+}
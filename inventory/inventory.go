@@ -0,0 +1,232 @@
+// Package inventory models player inventories, encodes the inventory
+// packet and emits the tank packets updating it, validating every change
+// against the item database.
+package inventory
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/eikarna/gotops/gamepacket"
+	"github.com/eikarna/gotops/items"
+)
+
+// Errors returned when changing or validating an inventory
+var (
+	ErrUnknownItem = errors.New("inventory: unknown item")
+	ErrNotOwned    = errors.New("inventory: item not owned")
+	ErrFull        = errors.New("inventory: no free slot")
+	ErrTooMany     = errors.New("inventory: more items than a slot holds")
+	ErrNotClothing = errors.New("inventory: item can't be worn")
+	ErrTruncated   = errors.New("inventory: inventory data is truncated")
+)
+
+// Items every player has without owning them
+const (
+	Fist   = 18
+	Wrench = 32
+)
+
+// DefaultMaxAmount is the number of items a slot holds when the item
+// database doesn't say
+const DefaultMaxAmount = 200
+
+// inventoryVersion is the version byte at the start of the inventory data
+const inventoryVersion = 1
+
+// Slot is a stack of items in an inventory
+type Slot struct {
+	ID    uint16
+	Count uint8
+	Flags uint8
+}
+
+// Inventory is the inventory of a player
+type Inventory struct {
+	// Size is the number of slots of the backpack
+	Size  uint32
+	Slots []Slot
+
+	// Clothing is what the player wears
+	Clothing Clothing
+}
+
+// New creates an empty inventory with the given number of slots
+func New(size uint32) *Inventory {
+	return &Inventory{
+		Size:     size,
+		Clothing: Clothing{SkinColor: DefaultSkinColor},
+	}
+}
+
+// Count returns how many of an item the inventory holds
+func (inv *Inventory) Count(id uint16) int {
+	if slot := inv.slot(id); slot != nil {
+		return int(slot.Count)
+	}
+	return 0
+}
+
+// slot returns the slot holding an item, or nil
+func (inv *Inventory) slot(id uint16) *Slot {
+	for i := range inv.Slots {
+		if inv.Slots[i].ID == id {
+			return &inv.Slots[i]
+		}
+	}
+	return nil
+}
+
+// item returns an item of the database
+func item(db *items.ItemDB, id uint16) (*items.Item, error) {
+	it, ok := db.Get(int32(id))
+	if !ok {
+		return nil, fmt.Errorf("%w %d", ErrUnknownItem, id)
+	}
+	return it, nil
+}
+
+// Add adds items to the inventory and returns the packet telling the
+// client. The item must exist and fit in its slot.
+func (inv *Inventory) Add(db *items.ItemDB, id uint16, count uint8) (*gamepacket.TankPacket, error) {
+	it, err := item(db, id)
+	if err != nil {
+		return nil, err
+	}
+	max := int(it.MaxAmount)
+	if max == 0 {
+		max = DefaultMaxAmount
+	}
+
+	owned := inv.Count(id)
+	if owned+int(count) > max {
+		return nil, fmt.Errorf("%w: %d + %d of item %d, max is %d", ErrTooMany, owned, count, id, max)
+	}
+	slot := inv.slot(id)
+	if slot == nil {
+		if uint32(len(inv.Slots)) >= inv.Size {
+			return nil, ErrFull
+		}
+		inv.Slots = append(inv.Slots, Slot{ID: id})
+		slot = &inv.Slots[len(inv.Slots)-1]
+	}
+	slot.Count += count
+	return ModifyPacket(id, 0, count), nil
+}
+
+// Remove removes items from the inventory and returns the packet telling
+// the client. Emptied slots are freed and unworn.
+func (inv *Inventory) Remove(id uint16, count uint8) (*gamepacket.TankPacket, error) {
+	for i := range inv.Slots {
+		slot := &inv.Slots[i]
+		if slot.ID != id || slot.Count < count {
+			continue
+		}
+		slot.Count -= count
+		if slot.Count == 0 {
+			inv.Slots = append(inv.Slots[:i], inv.Slots[i+1:]...)
+			inv.Clothing.remove(id)
+		}
+		return ModifyPacket(id, count, 0), nil
+	}
+	return nil, fmt.Errorf("%w: %d of item %d", ErrNotOwned, count, id)
+}
+
+// ModifyPacket builds the TankModifyItemInventory packet telling the
+// client an item count changed. The number of items removed is carried in
+// JumpCount and the number added in AnimationType.
+func ModifyPacket(id uint16, removed, added uint8) *gamepacket.TankPacket {
+	return &gamepacket.TankPacket{
+		Type:          gamepacket.TankModifyItemInventory,
+		JumpCount:     removed,
+		AnimationType: added,
+		Value:         int32(id),
+	}
+}
+
+// MarshalBinary encodes the inventory as the extra data of a
+// TankSendInventoryState packet
+func (inv *Inventory) MarshalBinary() ([]byte, error) {
+	if len(inv.Slots) > 0xffff {
+		return nil, fmt.Errorf("inventory: %d slots, max is %d", len(inv.Slots), 0xffff)
+	}
+
+	le := binary.LittleEndian
+	b := make([]byte, 0, 7+len(inv.Slots)*4)
+	b = append(b, inventoryVersion)
+	b = le.AppendUint32(b, inv.Size)
+	b = le.AppendUint16(b, uint16(len(inv.Slots)))
+	for _, slot := range inv.Slots {
+		b = le.AppendUint16(b, slot.ID)
+		b = append(b, slot.Count, slot.Flags)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes the extra data of a TankSendInventoryState
+// packet. Clothing isn't part of it and is left untouched. The slot count
+// is checked against the length of data before the slots are allocated.
+func (inv *Inventory) UnmarshalBinary(data []byte) error {
+	if len(data) < 7 {
+		return ErrTruncated
+	}
+	le := binary.LittleEndian
+	size := le.Uint32(data[1:5])
+	count := int(le.Uint16(data[5:7]))
+	data = data[7:]
+	if len(data) < count*4 {
+		return ErrTruncated
+	}
+
+	slots := make([]Slot, count)
+	for i := range slots {
+		slots[i] = Slot{
+			ID:    le.Uint16(data[i*4:]),
+			Count: data[i*4+2],
+			Flags: data[i*4+3],
+		}
+	}
+	inv.Size = size
+	inv.Slots = slots
+	return nil
+}
+
+// NewPacket builds the TankSendInventoryState packet sending the whole
+// inventory to the client
+func NewPacket(inv *Inventory) (*gamepacket.TankPacket, error) {
+	data, err := inv.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &gamepacket.TankPacket{
+		Type:      gamepacket.TankSendInventoryState,
+		Flags:     gamepacket.TankFlagExtended,
+		ExtraData: data,
+	}, nil
+}
+
+// Validate checks that a tank packet received from the client only uses
+// items the player owns, to detect clients faking their inventory. Packets
+// that don't involve inventory items are valid.
+func (inv *Inventory) Validate(db *items.ItemDB, tank *gamepacket.TankPacket) error {
+	switch tank.Type {
+	case gamepacket.TankTileChangeRequest, gamepacket.TankItemActivateRequest:
+		id := tank.Value
+		if id == Fist || id == Wrench {
+			return nil
+		}
+		if id < 0 || id > 0xffff {
+			return fmt.Errorf("%w %d", ErrUnknownItem, id)
+		}
+		if _, err := item(db, uint16(id)); err != nil {
+			return err
+		}
+		if inv.Count(uint16(id)) == 0 {
+			return fmt.Errorf("%w: item %d", ErrNotOwned, id)
+		}
+	case gamepacket.TankModifyItemInventory, gamepacket.TankSendInventoryState:
+		return fmt.Errorf("inventory: clients may not send %v packets", tank.Type)
+	}
+	return nil
+}
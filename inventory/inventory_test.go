@@ -0,0 +1,189 @@
+package inventory_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/eikarna/gotops/gamepacket"
+	"github.com/eikarna/gotops/inventory"
+	"github.com/eikarna/gotops/items"
+)
+
+func testDB(t *testing.T) *items.ItemDB {
+	t.Helper()
+
+	list := make([]items.Item, 50)
+	for i := range list {
+		list[i].ID = int32(i)
+	}
+	list[2].Name = "Dirt"
+	list[2].MaxAmount = 200
+	list[48].Name = "Red Shirt"
+	list[48].ActionType = 20
+	list[48].ClothingType = uint8(inventory.SlotShirt)
+	list[48].MaxAmount = 1
+	db, err := items.NewItemDB(items.MaxVersion, list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestAddRemove(t *testing.T) {
+	db := testDB(t)
+	inv := inventory.New(2)
+
+	tank, err := inv.Add(db, 2, 150)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &gamepacket.TankPacket{Type: gamepacket.TankModifyItemInventory, AnimationType: 150, Value: 2}
+	if !reflect.DeepEqual(tank, want) {
+		t.Errorf("Add packet %+v, want %+v", tank, want)
+	}
+	if _, err := inv.Add(db, 2, 51); !errors.Is(err, inventory.ErrTooMany) {
+		t.Errorf("adding past the maximum returned %v", err)
+	}
+	if _, err := inv.Add(db, 60, 1); !errors.Is(err, inventory.ErrUnknownItem) {
+		t.Errorf("adding an unknown item returned %v", err)
+	}
+	if _, err := inv.Add(db, 48, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := inv.Add(db, 3, 1); !errors.Is(err, inventory.ErrFull) {
+		t.Errorf("adding to a full inventory returned %v", err)
+	}
+
+	tank, err = inv.Remove(2, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tank.JumpCount != 50 || tank.AnimationType != 0 || inv.Count(2) != 100 {
+		t.Errorf("Remove packet %+v, count %d", tank, inv.Count(2))
+	}
+	if _, err := inv.Remove(2, 101); !errors.Is(err, inventory.ErrNotOwned) {
+		t.Errorf("removing more than owned returned %v", err)
+	}
+	if _, err := inv.Remove(2, 100); err != nil || len(inv.Slots) != 1 {
+		t.Errorf("removing everything returned %v, slots %v", err, inv.Slots)
+	}
+}
+
+func TestEquip(t *testing.T) {
+	db := testDB(t)
+	inv := inventory.New(16)
+	if _, err := inv.Equip(db, 48, 1); !errors.Is(err, inventory.ErrNotOwned) {
+		t.Errorf("equipping an item not owned returned %v", err)
+	}
+	inv.Add(db, 2, 1)
+	if _, err := inv.Equip(db, 2, 1); !errors.Is(err, inventory.ErrNotClothing) {
+		t.Errorf("equipping dirt returned %v", err)
+	}
+
+	inv.Add(db, 48, 1)
+	tank, err := inv.Equip(db, 48, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list gamepacket.VariantList
+	if err := list.UnmarshalBinary(tank.ExtraData); err != nil {
+		t.Fatal(err)
+	}
+	if tank.NetID != 7 || list[0] != "OnSetClothing" || list[1] != (gamepacket.Vec3{Y: 48}) {
+		t.Errorf("Equip call %v for net ID %d", list, tank.NetID)
+	}
+
+	// Losing the item takes it off.
+	inv.Remove(48, 1)
+	if inv.Clothing.Slots[inventory.SlotShirt] != 0 {
+		t.Error("removed shirt is still worn")
+	}
+	if _, err := inv.Unequip(48, 7); !errors.Is(err, inventory.ErrNotOwned) {
+		t.Errorf("unequipping an item not worn returned %v", err)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	inv := inventory.New(16)
+	inv.Slots = []inventory.Slot{{ID: inventory.Fist, Count: 1}, {ID: 2, Count: 200, Flags: 1}}
+	tank, err := inventory.NewPacket(inv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tank.Type != gamepacket.TankSendInventoryState {
+		t.Errorf("packet type %v", tank.Type)
+	}
+
+	var decoded inventory.Inventory
+	if err := decoded.UnmarshalBinary(tank.ExtraData); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Size != inv.Size || !reflect.DeepEqual(decoded.Slots, inv.Slots) {
+		t.Errorf("decoded %+v, want %+v", decoded, inv)
+	}
+	for i := 0; i < len(tank.ExtraData); i++ {
+		if err := decoded.UnmarshalBinary(tank.ExtraData[:i]); err == nil {
+			t.Errorf("decoding %d bytes succeeded", i)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	db := testDB(t)
+	inv := inventory.New(16)
+	inv.Add(db, 2, 10)
+
+	valid := []*gamepacket.TankPacket{
+		{Type: gamepacket.TankTileChangeRequest, Value: inventory.Fist},
+		{Type: gamepacket.TankTileChangeRequest, Value: 2},
+		{Type: gamepacket.TankItemActivateRequest, Value: 2},
+		{Type: gamepacket.TankState},
+	}
+	for _, tank := range valid {
+		if err := inv.Validate(db, tank); err != nil {
+			t.Errorf("%v with item %d: %v", tank.Type, tank.Value, err)
+		}
+	}
+
+	invalid := []*gamepacket.TankPacket{
+		{Type: gamepacket.TankTileChangeRequest, Value: 48},
+		{Type: gamepacket.TankItemActivateRequest, Value: 1000},
+		{Type: gamepacket.TankItemActivateRequest, Value: -1},
+		{Type: gamepacket.TankModifyItemInventory, Value: 2},
+	}
+	for _, tank := range invalid {
+		if err := inv.Validate(db, tank); err == nil {
+			t.Errorf("%v with item %d is valid", tank.Type, tank.Value)
+		}
+	}
+}
+
+func FuzzUnmarshalBinary(f *testing.F) {
+	inv := inventory.New(16)
+	inv.Slots = []inventory.Slot{{ID: inventory.Fist, Count: 1}, {ID: 2, Count: 200, Flags: 1}}
+	data, _ := inv.MarshalBinary()
+	f.Add(data)
+	f.Add([]byte{1, 0, 0, 0, 0, 0xff, 0xff})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var decoded inventory.Inventory
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			return
+		}
+		if len(decoded.Slots)*4 > len(data) {
+			t.Fatalf("%d slots decoded from %d bytes", len(decoded.Slots), len(data))
+		}
+		encoded, err := decoded.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var again inventory.Inventory
+		if err := again.UnmarshalBinary(encoded); err != nil {
+			t.Fatal(err)
+		}
+		if again.Size != decoded.Size || len(again.Slots) != len(decoded.Slots) ||
+			len(decoded.Slots) > 0 && !reflect.DeepEqual(again.Slots, decoded.Slots) {
+			t.Fatalf("round trip changed %+v to %+v", decoded, again)
+		}
+	})
+}
@@ -0,0 +1,296 @@
+package items
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// nameKey is the key the item names are XOR ciphered with
+const nameKey = "PBG892FXX982ABC*"
+
+// minItemSize is the size of an item of version 1 with empty strings, used
+// to bound the number of items announced in the header
+const minItemSize = 163
+
+// decoder reads little endian values, remembering the first error
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil || len(d.data) < n {
+		d.err = ErrTruncated
+		return make([]byte, n)
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) uint8() uint8 {
+	return d.next(1)[0]
+}
+
+func (d *decoder) uint16() uint16 {
+	return binary.LittleEndian.Uint16(d.next(2))
+}
+
+func (d *decoder) uint32() uint32 {
+	return binary.LittleEndian.Uint32(d.next(4))
+}
+
+func (d *decoder) string() string {
+	return string(d.next(int(d.uint16())))
+}
+
+// encoder appends little endian values
+type encoder struct {
+	b   []byte
+	err error
+}
+
+func (e *encoder) uint8(v uint8) {
+	e.b = append(e.b, v)
+}
+
+func (e *encoder) uint16(v uint16) {
+	e.b = binary.LittleEndian.AppendUint16(e.b, v)
+}
+
+func (e *encoder) uint32(v uint32) {
+	e.b = binary.LittleEndian.AppendUint32(e.b, v)
+}
+
+func (e *encoder) string(s string) {
+	if len(s) > math.MaxUint16 && e.err == nil {
+		e.err = fmt.Errorf("items: string of %d bytes is too long", len(s))
+	}
+	e.uint16(uint16(len(s)))
+	e.b = append(e.b, s...)
+}
+
+// cipherName ciphers or deciphers the name of the item with the given ID.
+// The ID is taken as unsigned, so that a negative one can't index out of the
+// key.
+func cipherName(name []byte, id int32) []byte {
+	ret := make([]byte, len(name))
+	for i := range name {
+		ret[i] = name[i] ^ nameKey[(uint64(i)+uint64(uint32(id)))%uint64(len(nameKey))]
+	}
+	return ret
+}
+
+// Parse parses items.dat. It never allocates for more items than the file
+// has room for.
+func Parse(data []byte) (*ItemDB, error) {
+	d := &decoder{data: data}
+	version := d.uint16()
+	count := int32(d.uint32())
+	if d.err != nil {
+		return nil, d.err
+	}
+	if version > MaxVersion {
+		return nil, ErrUnsupportedVersion
+	}
+	if count < 0 || int(count) > len(d.data)/minItemSize {
+		return nil, fmt.Errorf("items: items.dat announces %d items in %d bytes", count, len(data))
+	}
+
+	items := make([]Item, count)
+	for i := range items {
+		items[i].decode(d, version)
+		if d.err != nil {
+			return nil, fmt.Errorf("items: item %d: %w", i, d.err)
+		}
+	}
+	if len(d.data) != 0 {
+		return nil, fmt.Errorf("items: %d trailing bytes after the last item", len(d.data))
+	}
+	return NewItemDB(version, items)
+}
+
+// MarshalBinary serializes the database as items.dat. A parsed database is
+// serialized exactly as it was read.
+func (db *ItemDB) MarshalBinary() ([]byte, error) {
+	if db.Version > MaxVersion {
+		return nil, ErrUnsupportedVersion
+	}
+	e := &encoder{}
+	e.uint16(db.Version)
+	e.uint32(uint32(len(db.Items)))
+	for i := range db.Items {
+		db.Items[i].encode(e, db.Version)
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.b, nil
+}
+
+// decode reads an item of the given version
+func (item *Item) decode(d *decoder, version uint16) {
+	item.ID = int32(d.uint32())
+	item.Flags = Flags(d.uint8())
+	item.Category = d.uint8()
+	item.ActionType = d.uint8()
+	item.HitSoundType = d.uint8()
+	if version >= 3 {
+		item.Name = string(cipherName(d.next(int(d.uint16())), item.ID))
+	} else {
+		item.Name = d.string()
+	}
+	item.Texture = d.string()
+	item.TextureHash = int32(d.uint32())
+	item.Kind = d.uint8()
+	item.Val1 = int32(d.uint32())
+	item.TextureX = d.uint8()
+	item.TextureY = d.uint8()
+	item.SpreadType = d.uint8()
+	item.StripeyWallpaper = d.uint8()
+	item.CollisionType = d.uint8()
+	item.RawBreakHits = d.uint8()
+	item.DropChance = int32(d.uint32())
+	item.ClothingType = d.uint8()
+	item.Rarity = int16(d.uint16())
+	item.MaxAmount = d.uint8()
+	item.ExtraFile = d.string()
+	item.ExtraFileHash = int32(d.uint32())
+	item.AudioVolume = int32(d.uint32())
+	item.PetName = d.string()
+	item.PetPrefix = d.string()
+	item.PetSuffix = d.string()
+	item.PetAbility = d.string()
+	item.SeedBase = d.uint8()
+	item.SeedOverlay = d.uint8()
+	item.TreeBase = d.uint8()
+	item.TreeLeaves = d.uint8()
+	item.SeedColor = d.uint32()
+	item.SeedOverlayColor = d.uint32()
+	item.Ingredient = int32(d.uint32())
+	item.GrowTime = int32(d.uint32())
+	item.Val2 = int16(d.uint16())
+	item.IsRayman = int16(d.uint16())
+	item.ExtraOptions = d.string()
+	item.Texture2 = d.string()
+	item.ExtraOptions2 = d.string()
+	copy(item.Reserved[:], d.next(len(item.Reserved)))
+	if version >= 11 {
+		item.PunchOptions = d.string()
+	}
+	if version >= 12 {
+		copy(item.V12[:], d.next(len(item.V12)))
+	}
+	if version >= 13 {
+		item.V13 = int32(d.uint32())
+	}
+	if version >= 14 {
+		item.V14 = int32(d.uint32())
+	}
+	if version >= 15 {
+		copy(item.V15[:], d.next(len(item.V15)))
+		item.V15String = d.string()
+	}
+	if version >= 16 {
+		item.V16String = d.string()
+	}
+	if version >= 17 {
+		item.V17 = int32(d.uint32())
+	}
+	if version >= 18 {
+		item.V18 = int32(d.uint32())
+	}
+	if version >= 19 {
+		copy(item.V19[:], d.next(len(item.V19)))
+	}
+	if version >= 21 {
+		item.V21 = int16(d.uint16())
+	}
+	if version >= 22 {
+		item.Description = d.string()
+	}
+}
+
+// encode appends an item of the given version
+func (item *Item) encode(e *encoder, version uint16) {
+	e.uint32(uint32(item.ID))
+	e.uint8(uint8(item.Flags))
+	e.uint8(item.Category)
+	e.uint8(item.ActionType)
+	e.uint8(item.HitSoundType)
+	if version >= 3 {
+		e.string(string(cipherName([]byte(item.Name), item.ID)))
+	} else {
+		e.string(item.Name)
+	}
+	e.string(item.Texture)
+	e.uint32(uint32(item.TextureHash))
+	e.uint8(item.Kind)
+	e.uint32(uint32(item.Val1))
+	e.uint8(item.TextureX)
+	e.uint8(item.TextureY)
+	e.uint8(item.SpreadType)
+	e.uint8(item.StripeyWallpaper)
+	e.uint8(item.CollisionType)
+	e.uint8(item.RawBreakHits)
+	e.uint32(uint32(item.DropChance))
+	e.uint8(item.ClothingType)
+	e.uint16(uint16(item.Rarity))
+	e.uint8(item.MaxAmount)
+	e.string(item.ExtraFile)
+	e.uint32(uint32(item.ExtraFileHash))
+	e.uint32(uint32(item.AudioVolume))
+	e.string(item.PetName)
+	e.string(item.PetPrefix)
+	e.string(item.PetSuffix)
+	e.string(item.PetAbility)
+	e.uint8(item.SeedBase)
+	e.uint8(item.SeedOverlay)
+	e.uint8(item.TreeBase)
+	e.uint8(item.TreeLeaves)
+	e.uint32(item.SeedColor)
+	e.uint32(item.SeedOverlayColor)
+	e.uint32(uint32(item.Ingredient))
+	e.uint32(uint32(item.GrowTime))
+	e.uint16(uint16(item.Val2))
+	e.uint16(uint16(item.IsRayman))
+	e.string(item.ExtraOptions)
+	e.string(item.Texture2)
+	e.string(item.ExtraOptions2)
+	e.b = append(e.b, item.Reserved[:]...)
+	if version >= 11 {
+		e.string(item.PunchOptions)
+	}
+	if version >= 12 {
+		e.b = append(e.b, item.V12[:]...)
+	}
+	if version >= 13 {
+		e.uint32(uint32(item.V13))
+	}
+	if version >= 14 {
+		e.uint32(uint32(item.V14))
+	}
+	if version >= 15 {
+		e.b = append(e.b, item.V15[:]...)
+		e.string(item.V15String)
+	}
+	if version >= 16 {
+		e.string(item.V16String)
+	}
+	if version >= 17 {
+		e.uint32(uint32(item.V17))
+	}
+	if version >= 18 {
+		e.uint32(uint32(item.V18))
+	}
+	if version >= 19 {
+		e.b = append(e.b, item.V19[:]...)
+	}
+	if version >= 21 {
+		e.uint16(uint16(item.V21))
+	}
+	if version >= 22 {
+		e.string(item.Description)
+	}
+}
@@ -0,0 +1,198 @@
+// Package items parses items.dat, the item database Growtopia clients
+// download from the server, and indexes it by item ID and name.
+package items
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Flags are the editable type flags of an item
+type Flags uint8
+
+// Item flags
+const (
+	FlagFlippable Flags = 1 << iota
+	FlagEditable
+	FlagSeedless
+	FlagPermanent
+	FlagDropless
+	FlagNoSelf
+	FlagNoShadow
+	FlagWorldLocked
+)
+
+// Item is an entry of items.dat. Fields whose meaning is unknown are kept
+// as they are so that the file re-serializes byte for byte.
+type Item struct {
+	ID           int32
+	Flags        Flags
+	Category     uint8
+	ActionType   uint8
+	HitSoundType uint8
+
+	// Name is stored XOR ciphered since version 3
+	Name        string
+	Texture     string
+	TextureHash int32
+	Kind        uint8
+	Val1        int32
+	TextureX    uint8
+	TextureY    uint8
+	SpreadType  uint8
+
+	StripeyWallpaper uint8
+	CollisionType    uint8
+
+	// RawBreakHits is the number of hits to break the item times 6, see
+	// BreakHits
+	RawBreakHits uint8
+
+	DropChance    int32
+	ClothingType  uint8
+	Rarity        int16
+	MaxAmount     uint8
+	ExtraFile     string
+	ExtraFileHash int32
+	AudioVolume   int32
+
+	PetName    string
+	PetPrefix  string
+	PetSuffix  string
+	PetAbility string
+
+	SeedBase         uint8
+	SeedOverlay      uint8
+	TreeBase         uint8
+	TreeLeaves       uint8
+	SeedColor        uint32
+	SeedOverlayColor uint32
+	Ingredient       int32
+	GrowTime         int32
+	Val2             int16
+	IsRayman         int16
+	ExtraOptions     string
+	Texture2         string
+	ExtraOptions2    string
+	Reserved         [80]byte
+
+	// PunchOptions is present since version 11
+	PunchOptions string
+
+	// V12 to V21 are the unknown fields added by the version they're named
+	// after
+	V12       [13]byte
+	V13       int32
+	V14       int32
+	V15       [25]byte
+	V15String string
+	V16String string
+	V17       int32
+	V18       int32
+	V19       [9]byte
+	V21       int16
+
+	// Description is present since version 22
+	Description string
+}
+
+// BreakHits returns the number of hits it takes to break the item
+func (item *Item) BreakHits() int {
+	return int(item.RawBreakHits) / 6
+}
+
+// MaxVersion is the newest items.dat version that can be parsed
+const MaxVersion = 22
+
+// Errors returned when parsing items.dat
+var (
+	ErrTruncated          = errors.New("items: items.dat is truncated")
+	ErrUnsupportedVersion = fmt.Errorf("items: unsupported items.dat version, max is %d", MaxVersion)
+)
+
+// ItemDB is an item database indexed by ID and name
+type ItemDB struct {
+	// Version is the items.dat version
+	Version uint16
+
+	// Items holds every item, the index of an item being its ID
+	Items []Item
+
+	names map[string]int32
+}
+
+// NewItemDB creates a database from items whose IDs must match their
+// index
+func NewItemDB(version uint16, items []Item) (*ItemDB, error) {
+	if version > MaxVersion {
+		return nil, ErrUnsupportedVersion
+	}
+	db := &ItemDB{
+		Version: version,
+		Items:   items,
+		names:   make(map[string]int32, len(items)),
+	}
+	for i := range items {
+		if items[i].ID != int32(i) {
+			return nil, fmt.Errorf("items: item %d has ID %d", i, items[i].ID)
+		}
+		name := strings.ToLower(items[i].Name)
+		if _, ok := db.names[name]; !ok {
+			db.names[name] = items[i].ID
+		}
+	}
+	return db, nil
+}
+
+// Load parses the items.dat file at path
+func Load(path string) (*ItemDB, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Len returns the number of items
+func (db *ItemDB) Len() int {
+	return len(db.Items)
+}
+
+// Get returns the item with the given ID
+func (db *ItemDB) Get(id int32) (*Item, bool) {
+	if id < 0 || int(id) >= len(db.Items) {
+		return nil, false
+	}
+	return &db.Items[id], true
+}
+
+// ByName returns the first item with the given name, ignoring case
+func (db *ItemDB) ByName(name string) (*Item, bool) {
+	id, ok := db.names[strings.ToLower(name)]
+	if !ok {
+		return nil, false
+	}
+	return &db.Items[id], true
+}
+
+// Hash returns the hash of the serialized database, which clients compare
+// with the one of their cached items.dat in the login response
+func (db *ItemDB) Hash() uint32 {
+	data, err := db.MarshalBinary()
+	if err != nil {
+		return 0
+	}
+	return FileHash(data)
+}
+
+// FileHash returns the hash the client computes over a file, such as
+// items.dat
+func FileHash(data []byte) uint32 {
+	acc := uint32(0x55555555)
+	for _, b := range data {
+		acc = (acc >> 27) + (acc << 5) + uint32(b)
+	}
+	return acc
+}
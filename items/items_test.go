@@ -0,0 +1,177 @@
+package items_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/eikarna/gotops/items"
+)
+
+func testItems() []items.Item {
+	ret := []items.Item{
+		{ID: 0, Name: "Blank", Texture: "tiles_page1.rttex"},
+		{ID: 1, Name: "Blank Seed", Texture: "tiles_page1.rttex", Kind: 1},
+		{
+			ID:           2,
+			Flags:        items.FlagFlippable | items.FlagEditable,
+			Category:     1,
+			Name:         "Dirt",
+			Texture:      "tiles_page1.rttex",
+			TextureHash:  -12345,
+			TextureX:     2,
+			RawBreakHits: 18,
+			Rarity:       1,
+			MaxAmount:    200,
+			SeedColor:    0xff8a5a2b,
+			GrowTime:     31,
+			PunchOptions: "punch",
+			V13:          7,
+			V15String:    "v15",
+			V16String:    "v16",
+			V21:          -1,
+			Description:  "Just dirt.",
+		},
+	}
+	ret[2].Reserved[79] = 0xaa
+	ret[2].V12[0] = 1
+	ret[2].V19[8] = 2
+	return ret
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, version := range []uint16{2, 3, 10, 11, 15, 19, items.MaxVersion} {
+		db, err := items.NewItemDB(version, testItems())
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := db.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parsed, err := items.Parse(data)
+		if err != nil {
+			t.Fatalf("version %d: %v", version, err)
+		}
+		again, err := parsed.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, again) {
+			t.Errorf("version %d: re-serialized items.dat differs", version)
+		}
+		if version == items.MaxVersion && !reflect.DeepEqual(parsed.Items, testItems()) {
+			t.Errorf("version %d: parsed %+v, want %+v", version, parsed.Items, testItems())
+		}
+	}
+}
+
+func TestNameCipher(t *testing.T) {
+	db, err := items.NewItemDB(3, testItems())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := db.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// "Blank" XOR "PBG89", the key starting at offset 0 for item 0.
+	cipher := []byte{'B' ^ 'P', 'l' ^ 'B', 'a' ^ 'G', 'n' ^ '8', 'k' ^ '9'}
+	if !bytes.Equal(data[16:21], cipher) {
+		t.Errorf("ciphered name %x, want %x", data[16:21], cipher)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	db, err := items.NewItemDB(items.MaxVersion, testItems())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Len() != 3 {
+		t.Errorf("Len %d, want 3", db.Len())
+	}
+	if item, ok := db.Get(2); !ok || item.Name != "Dirt" || item.BreakHits() != 3 {
+		t.Errorf("Get(2) = %+v, %t", item, ok)
+	}
+	if _, ok := db.Get(3); ok {
+		t.Error("Get(3) found an item")
+	}
+	if item, ok := db.ByName("blank seed"); !ok || item.ID != 1 {
+		t.Errorf("ByName = %+v, %t", item, ok)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	db, err := items.NewItemDB(items.MaxVersion, testItems())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := db.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := items.Parse(data[:len(data)-1]); !errors.Is(err, items.ErrTruncated) {
+		t.Errorf("truncated: got %v, want %v", err, items.ErrTruncated)
+	}
+	if _, err := items.Parse(append(data, 0)); err == nil {
+		t.Error("trailing data: expected an error")
+	}
+	if _, err := items.Parse([]byte{items.MaxVersion + 1, 0, 0, 0, 0, 0}); !errors.Is(err, items.ErrUnsupportedVersion) {
+		t.Errorf("version: got %v, want %v", err, items.ErrUnsupportedVersion)
+	}
+	if _, err := items.Parse([]byte{1, 0, 0xff, 0xff, 0xff, 0x7f}); err == nil {
+		t.Error("huge item count: expected an error")
+	}
+
+	bad := testItems()
+	bad[1].ID = 5
+	if _, err := items.NewItemDB(items.MaxVersion, bad); err == nil {
+		t.Error("mismatched ID: expected an error")
+	}
+}
+
+func TestFileHash(t *testing.T) {
+	if got := items.FileHash(nil); got != 0x55555555 {
+		t.Errorf("FileHash(nil) = %#x", got)
+	}
+	if got := items.FileHash([]byte("a")); got != 0xaaaaab0b {
+		t.Errorf("FileHash(a) = %#x", got)
+	}
+
+	db, err := items.NewItemDB(items.MaxVersion, testItems())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := db.MarshalBinary()
+	if db.Hash() != items.FileHash(data) {
+		t.Error("Hash doesn't match the hash of the serialized database")
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	for _, version := range []uint16{2, 11, items.MaxVersion} {
+		db, err := items.NewItemDB(version, testItems())
+		if err != nil {
+			f.Fatal(err)
+		}
+		data, _ := db.MarshalBinary()
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		db, err := items.Parse(data)
+		if err != nil {
+			return
+		}
+		again, err := db.MarshalBinary()
+		if err != nil {
+			t.Fatalf("parsed database doesn't serialize: %v", err)
+		}
+		if !bytes.Equal(data, again) {
+			t.Fatalf("%x re-serialized as %x", data, again)
+		}
+	})
+}
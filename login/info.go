@@ -0,0 +1,122 @@
+// Package login runs the Growtopia login handshake: it greets every new
+// peer, parses and validates the login information the client sends back,
+// authenticates the player and answers with the logon response.
+package login
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/eikarna/gotops/gamepacket"
+)
+
+// Errors returned when parsing login information
+var (
+	ErrMissingField = errors.New("login: missing field")
+	ErrInvalidField = errors.New("login: invalid field")
+)
+
+// LoginInfo is the login information sent by a client after the hello
+type LoginInfo struct {
+	// TankIDName and TankIDPass are the GrowID credentials. They are empty
+	// for guests.
+	TankIDName string
+	TankIDPass string
+
+	// RequestedName is the name a guest asks for
+	RequestedName string
+
+	Protocol    int
+	GameVersion float64
+
+	MAC        string
+	RID        string
+	Meta       string
+	Country    string
+	PlatformID string
+	Hash       int32
+	Hash2      int32
+
+	// Fields holds every field of the login packet, including those
+	// without a typed counterpart
+	Fields gamepacket.Text
+}
+
+// ParseLoginInfo parses the text packet a client sends to log in. The
+// protocol and game version are required, as is a GrowID or a requested
+// name. The game version must be a finite number. Bad or missing fields
+// are reported with ErrInvalidField or ErrMissingField.
+func ParseLoginInfo(text gamepacket.Text) (*LoginInfo, error) {
+	info := &LoginInfo{
+		TankIDName:    text.Value("tankIDName"),
+		TankIDPass:    text.Value("tankIDPass"),
+		RequestedName: text.Value("requestedName"),
+		MAC:           text.Value("mac"),
+		RID:           text.Value("rid"),
+		Meta:          text.Value("meta"),
+		Country:       text.Value("country"),
+		PlatformID:    text.Value("platformID"),
+		Fields:        text,
+	}
+	if info.TankIDName == "" && info.RequestedName == "" {
+		return nil, fmt.Errorf("%w tankIDName or requestedName", ErrMissingField)
+	}
+
+	protocol, err := field(text, "protocol", true)
+	if err != nil {
+		return nil, err
+	}
+	info.Protocol = int(protocol)
+
+	version, ok := text.Get("game_version")
+	if !ok {
+		return nil, fmt.Errorf("%w game_version", ErrMissingField)
+	}
+	// ParseFloat accepts NaN and infinities, which would compare false
+	// against any minimum version.
+	info.GameVersion, err = strconv.ParseFloat(version, 64)
+	if err != nil || math.IsNaN(info.GameVersion) || math.IsInf(info.GameVersion, 0) {
+		return nil, fmt.Errorf("%w game_version %q", ErrInvalidField, version)
+	}
+
+	for _, hash := range []struct {
+		key string
+		ptr *int32
+	}{{"hash", &info.Hash}, {"hash2", &info.Hash2}} {
+		if *hash.ptr, err = field(text, hash.key, false); err != nil {
+			return nil, err
+		}
+	}
+	return info, nil
+}
+
+// field parses an integer field. Missing optional fields are zero.
+func field(text gamepacket.Text, key string, required bool) (int32, error) {
+	s, ok := text.Get(key)
+	if !ok {
+		if required {
+			return 0, fmt.Errorf("%w %s", ErrMissingField, key)
+		}
+		return 0, nil
+	}
+	v, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%w %s %q", ErrInvalidField, key, s)
+	}
+	return int32(v), nil
+}
+
+// Guest tells whether the player logs in without a GrowID
+func (info *LoginInfo) Guest() bool {
+	return info.TankIDName == ""
+}
+
+// Name returns the GrowID of the player, or the requested name of guests
+func (info *LoginInfo) Name() string {
+	if info.Guest() {
+		return info.RequestedName
+	}
+	return info.TankIDName
+}
@@ -0,0 +1,229 @@
+package login_test
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	enet "github.com/eikarna/gotops"
+	"github.com/eikarna/gotops/enettest"
+	"github.com/eikarna/gotops/gamepacket"
+	"github.com/eikarna/gotops/login"
+)
+
+const loginText = "tankIDName|\ntankIDPass|\nrequestedName|Seth\nf|1\nprotocol|209\ngame_version|4.61\n" +
+	"lmode|0\ncbits|0\nplayer_age|20\nGDPR|1\nhash2|-1234\nmeta|localhost\nfhash|-716928004\n" +
+	"rid|0123456789ABCDEF0123456789ABCDEF\nplatformID|0,1,1\ndeviceVersion|0\ncountry|us\n" +
+	"hash|987654321\nmac|02:00:00:00:00:00\nwk|NONE0\nzf|-1576181843\n"
+
+func TestParseLoginInfo(t *testing.T) {
+	info, err := login.ParseLoginInfo(gamepacket.ParseText([]byte(loginText)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Guest() || info.Name() != "Seth" || info.Protocol != 209 || info.GameVersion != 4.61 ||
+		info.Hash != 987654321 || info.Hash2 != -1234 || info.Meta != "localhost" || info.Country != "us" {
+		t.Errorf("parsed %+v", info)
+	}
+	if info.Fields.Value("wk") != "NONE0" {
+		t.Error("untyped fields are missing")
+	}
+
+	for _, tc := range []struct {
+		text string
+		err  error
+	}{
+		{"protocol|209\ngame_version|4.61", login.ErrMissingField},
+		{"requestedName|a\ngame_version|4.61", login.ErrMissingField},
+		{"requestedName|a\nprotocol|209", login.ErrMissingField},
+		{"requestedName|a\nprotocol|x\ngame_version|4.61", login.ErrInvalidField},
+		{"requestedName|a\nprotocol|209\ngame_version|new", login.ErrInvalidField},
+		{"requestedName|a\nprotocol|209\ngame_version|NaN", login.ErrInvalidField},
+		{"requestedName|a\nprotocol|209\ngame_version|+Inf", login.ErrInvalidField},
+		{"requestedName|a\nprotocol|209\ngame_version|-inf", login.ErrInvalidField},
+		{"requestedName|a\nprotocol|209\ngame_version|4.61\nhash|99999999999", login.ErrInvalidField},
+	} {
+		if _, err := login.ParseLoginInfo(gamepacket.ParseText([]byte(tc.text))); !errors.Is(err, tc.err) {
+			t.Errorf("parsing %q returned %v, want %v", tc.text, err, tc.err)
+		}
+	}
+}
+
+// result is what a client saw during the handshake
+type result struct {
+	hello        bool
+	messages     []string
+	calls        []gamepacket.VariantList
+	disconnected bool
+}
+
+// handshake connects a client to a host handled by srv, sends text once the
+// hello is received and returns what the client saw until it was accepted
+// or disconnected
+func handshake(t *testing.T, network *enettest.Network, srv *login.Server, text string) *result {
+	t.Helper()
+
+	server, err := network.NewHost("server", 4, 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Destroy()
+	client, err := network.NewHost("", 1, 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Destroy()
+	peer, err := client.Connect(network.Address("server"), 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := &result{}
+	for i := 0; i < 5000 && !res.disconnected && len(res.calls) == 0; i++ {
+		network.Advance(time.Millisecond)
+		srv.Tick()
+		for ev := server.Service(0); ev.GetType() != enet.EventNone; ev = server.Service(0) {
+			if !srv.Handle(ev) && ev.GetType() == enet.EventReceive {
+				t.Errorf("handshake didn't consume a packet")
+				ev.GetPacket().Destroy()
+			}
+		}
+
+		for ev := client.Service(0); ev.GetType() != enet.EventNone; ev = client.Service(0) {
+			switch ev.GetType() {
+			case enet.EventDisconnect:
+				res.disconnected = true
+				continue
+			case enet.EventConnect:
+				continue
+			}
+			data := append([]byte(nil), ev.GetPacket().GetData()...)
+			ev.GetPacket().Destroy()
+
+			typ, payload, err := gamepacket.Decode(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch typ {
+			case gamepacket.MessageServerHello:
+				res.hello = true
+				if text != "" {
+					enet.SendPacket(peer, int32(gamepacket.MessageGenericText), text)
+				}
+			case gamepacket.MessageGameMessage:
+				res.messages = append(res.messages, strings.TrimRight(string(payload), "\x00"))
+			case gamepacket.MessageGamePacket:
+				var tank gamepacket.TankPacket
+				if err := tank.UnmarshalBinary(payload); err != nil {
+					t.Fatal(err)
+				}
+				var list gamepacket.VariantList
+				if err := list.UnmarshalBinary(tank.ExtraData); err != nil {
+					t.Fatal(err)
+				}
+				res.calls = append(res.calls, list)
+			default:
+				t.Fatalf("received %v", typ)
+			}
+		}
+	}
+	return res
+}
+
+func TestHandshake(t *testing.T) {
+	network := enettest.NewNetwork(1)
+	srv := login.NewServer(login.AuthFunc(func(peer enet.Peer, info *login.LoginInfo) error {
+		if info.Name() != "Seth" {
+			return errors.New("wrong name")
+		}
+		return nil
+	}), 0xdeadbeef)
+	srv.Now = network.Now
+
+	var authenticated []*login.LoginInfo
+	srv.OnAuthenticated = func(peer enet.Peer, info *login.LoginInfo) {
+		if !srv.Authenticated(peer) || srv.Info(peer) != info {
+			t.Error("peer isn't authenticated in OnAuthenticated")
+		}
+		authenticated = append(authenticated, info)
+	}
+
+	res := handshake(t, network, srv, loginText)
+	if !res.hello || res.disconnected || len(res.messages) != 0 {
+		t.Fatalf("handshake result %+v", res)
+	}
+	if len(authenticated) != 1 || authenticated[0].RequestedName != "Seth" {
+		t.Errorf("authenticated %v", authenticated)
+	}
+	call := res.calls[0]
+	if len(call) != 6 || call[0] != "OnSuperMainStartAcceptLogonHrdxs47254722215a" || call[1] != uint32(0xdeadbeef) {
+		t.Errorf("logon response %v", call)
+	}
+}
+
+// TestZeroServer checks that a Server literal needs no NewServer
+func TestZeroServer(t *testing.T) {
+	srv := &login.Server{}
+	srv.Tick()
+	res := handshake(t, enettest.NewNetwork(1), srv, loginText)
+	if !res.hello || res.disconnected || len(res.calls) != 1 {
+		t.Fatalf("handshake result %+v", res)
+	}
+}
+
+func TestReject(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		setup func(*login.Server)
+		text  string
+		msg   string
+	}{
+		{"version", func(srv *login.Server) { srv.MinVersion = 4.62 }, loginText, "UPDATE REQUIRED"},
+		{"protocol", func(srv *login.Server) { srv.MinProtocol = 210 }, loginText, "UPDATE REQUIRED"},
+		{"invalid", func(*login.Server) {}, "requestedName|Seth", "Invalid login"},
+		{"auth", func(srv *login.Server) {
+			srv.Auth = login.AuthFunc(func(enet.Peer, *login.LoginInfo) error {
+				return errors.New("`4Banned.``")
+			})
+		}, loginText, "Banned"},
+		{"timeout", func(srv *login.Server) { srv.Timeout = time.Second }, "", "timed out"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			network := enettest.NewNetwork(1)
+			srv := login.NewServer(nil, 0)
+			srv.Now = network.Now
+			srv.OnAuthenticated = func(enet.Peer, *login.LoginInfo) {
+				t.Error("rejected player authenticated")
+			}
+			tc.setup(srv)
+
+			res := handshake(t, network, srv, tc.text)
+			if !res.disconnected || len(res.calls) != 0 {
+				t.Fatalf("handshake result %+v", res)
+			}
+			if len(res.messages) != 2 || !strings.Contains(res.messages[0], tc.msg) || res.messages[1] != "action|logon_fail" {
+				t.Errorf("received %q", res.messages)
+			}
+		})
+	}
+}
+
+func FuzzParseLoginInfo(f *testing.F) {
+	f.Add([]byte(loginText))
+	f.Add([]byte("protocol|-1\ngame_version|NaN\nhash|99999999999\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		info, err := login.ParseLoginInfo(gamepacket.ParseText(data))
+		if err != nil {
+			if !errors.Is(err, login.ErrMissingField) && !errors.Is(err, login.ErrInvalidField) {
+				t.Fatalf("unexpected error %v", err)
+			}
+			return
+		}
+		if math.IsNaN(info.GameVersion) || math.IsInf(info.GameVersion, 0) {
+			t.Fatalf("accepted game version %v", info.GameVersion)
+		}
+		info.Name()
+	})
+}
@@ -0,0 +1,253 @@
+package login
+
+import (
+	"fmt"
+	"time"
+
+	enet "github.com/eikarna/gotops"
+	"github.com/eikarna/gotops/gamepacket"
+)
+
+// Defaults of the logon response
+const (
+	DefaultCDNHost  = "ubistatic-a.akamaihd.net"
+	DefaultCDNPath  = "0098/654975/cache/"
+	DefaultSettings = "proto=209|choosemusic=audio/mp3/about_theme.mp3|active_holiday=0|clash_active=0|drop_lavacheck_faster=1|isPayingUser=0|usingStoreNavigation=1|enableInventoryTab=1|bigBackpack=1|"
+)
+
+// DefaultTimeout is the time a peer has to log in after the hello
+const DefaultTimeout = 30 * time.Second
+
+// Authenticator decides whether a player may log in. The error returned,
+// if any, is shown to the player.
+type Authenticator interface {
+	Authenticate(peer enet.Peer, info *LoginInfo) error
+}
+
+// AuthFunc adapts a function to an Authenticator
+type AuthFunc func(peer enet.Peer, info *LoginInfo) error
+
+// Authenticate calls f(peer, info)
+func (f AuthFunc) Authenticate(peer enet.Peer, info *LoginInfo) error {
+	return f(peer, info)
+}
+
+// state is the progress of a peer through the handshake
+type state int
+
+const (
+	// stateHello means the hello was sent and the login information is
+	// awaited
+	stateHello state = iota
+
+	// stateRejected means the login failed and the peer is being
+	// disconnected
+	stateRejected
+
+	// stateAuthenticated means the handshake is over
+	stateAuthenticated
+)
+
+// session is the handshake of a single peer
+type session struct {
+	state   state
+	started time.Time
+	info    *LoginInfo
+}
+
+// Server runs the login handshake of every peer of a host. Events returned
+// by the host are passed to Handle, which consumes those belonging to the
+// handshake. Once a player has logged in, OnAuthenticated is called and the
+// events of the peer are left to the application.
+//
+// The zero value lets everyone in with no CDN or settings; NewServer fills
+// in the defaults.
+type Server struct {
+	// Auth authenticates players. The default is to let everyone in.
+	Auth Authenticator
+
+	// MinProtocol and MinVersion are the oldest protocol and game version
+	// allowed to log in. Zero allows any.
+	MinProtocol int
+	MinVersion  float64
+
+	// ItemsHash is the hash of items.dat, as returned by items.ItemDB.Hash.
+	// Clients download the item database again when theirs doesn't match.
+	ItemsHash uint32
+
+	// CDNHost and CDNPath locate the cache clients download assets from
+	CDNHost string
+	CDNPath string
+
+	// Settings are the server settings sent in the logon response
+	Settings string
+
+	// Timeout is the time a peer has to log in, enforced by Tick
+	Timeout time.Duration
+
+	// Now returns the current time. The default is time.Now.
+	Now func() time.Time
+
+	// OnAuthenticated is called once for every player logged in, after the
+	// logon response has been sent
+	OnAuthenticated func(peer enet.Peer, info *LoginInfo)
+
+	sessions map[enet.Peer]*session
+}
+
+// NewServer creates a login server authenticating players with auth, which
+// may be nil to let everyone in
+func NewServer(auth Authenticator, itemsHash uint32) *Server {
+	return &Server{
+		Auth:      auth,
+		ItemsHash: itemsHash,
+		CDNHost:   DefaultCDNHost,
+		CDNPath:   DefaultCDNPath,
+		Settings:  DefaultSettings,
+		Timeout:   DefaultTimeout,
+		Now:       time.Now,
+		sessions:  make(map[enet.Peer]*session),
+	}
+}
+
+// Handle processes an event returned by the host and tells whether it
+// belonged to the handshake, in which case the application must ignore it.
+// Received packets consumed by the handshake are destroyed. The disconnect
+// event of an authenticated player isn't consumed.
+func (srv *Server) Handle(ev enet.Event) bool {
+	peer := ev.GetPeer()
+
+	switch ev.GetType() {
+	case enet.EventConnect:
+		if srv.sessions == nil {
+			srv.sessions = make(map[enet.Peer]*session)
+		}
+		srv.sessions[peer] = &session{state: stateHello, started: srv.now()}
+		if err := enet.SendPacket(peer, int32(gamepacket.MessageServerHello), ""); err != nil {
+			peer.DisconnectNow(0)
+			delete(srv.sessions, peer)
+		}
+		return true
+
+	case enet.EventReceive:
+		sess := srv.sessions[peer]
+		if sess == nil || sess.state == stateAuthenticated {
+			return false
+		}
+		packet := ev.GetPacket()
+		data := packet.GetData()
+		defer packet.Destroy()
+		if sess.state == stateHello {
+			srv.login(peer, sess, data)
+		}
+		return true
+
+	case enet.EventDisconnect:
+		sess := srv.sessions[peer]
+		if sess == nil {
+			return false
+		}
+		delete(srv.sessions, peer)
+		return sess.state != stateAuthenticated
+	}
+	return false
+}
+
+// login processes the login packet of a peer
+func (srv *Server) login(peer enet.Peer, sess *session, data []byte) {
+	typ, payload, err := gamepacket.Decode(data)
+	if err != nil || typ != gamepacket.MessageGenericText {
+		srv.reject(peer, sess, "`4Unexpected packet.`` Please update your client.")
+		return
+	}
+	info, err := ParseLoginInfo(gamepacket.ParseText(payload))
+	if err != nil {
+		srv.reject(peer, sess, "`4Invalid login.`` "+err.Error())
+		return
+	}
+	if info.Protocol < srv.MinProtocol || info.GameVersion < srv.MinVersion {
+		srv.reject(peer, sess, fmt.Sprintf("`4UPDATE REQUIRED!`` Version %.2f or newer is needed to play.", srv.MinVersion))
+		return
+	}
+	if srv.Auth != nil {
+		if err := srv.Auth.Authenticate(peer, info); err != nil {
+			srv.reject(peer, sess, err.Error())
+			return
+		}
+	}
+
+	tank, err := gamepacket.NewCall(-1, 0, gamepacket.VariantList{
+		"OnSuperMainStartAcceptLogonHrdxs47254722215a",
+		srv.ItemsHash,
+		srv.CDNHost,
+		srv.CDNPath,
+		"",
+		srv.Settings,
+	})
+	if err == nil {
+		err = sendTank(peer, tank)
+	}
+	if err != nil {
+		srv.reject(peer, sess, "`4Server error.`` Please try again later.")
+		return
+	}
+
+	sess.state = stateAuthenticated
+	sess.info = info
+	if srv.OnAuthenticated != nil {
+		srv.OnAuthenticated(peer, info)
+	}
+}
+
+// reject tells a peer why its login failed and disconnects it once the
+// message is delivered
+func (srv *Server) reject(peer enet.Peer, sess *session, msg string) {
+	sess.state = stateRejected
+	enet.SendPacket(peer, int32(gamepacket.MessageGameMessage), "action|log\nmsg|"+msg)
+	enet.SendPacket(peer, int32(gamepacket.MessageGameMessage), "action|logon_fail")
+	peer.DisconnectLater(0)
+}
+
+// sendTank sends a tank packet to a peer
+func sendTank(peer enet.Peer, tank *gamepacket.TankPacket) error {
+	data, err := gamepacket.EncodeTank(tank)
+	if err != nil {
+		return err
+	}
+	return peer.SendBytes(data, 0, enet.PacketFlagReliable)
+}
+
+// Tick disconnects the peers that didn't log in within Timeout
+func (srv *Server) Tick() {
+	if srv.Timeout <= 0 {
+		return
+	}
+	now := srv.now()
+	for peer, sess := range srv.sessions {
+		if sess.state == stateHello && now.Sub(sess.started) > srv.Timeout {
+			srv.reject(peer, sess, "`4Login timed out.``")
+		}
+	}
+}
+
+// now returns the current time from Now, or time.Now if it's unset
+func (srv *Server) now() time.Time {
+	if srv.Now == nil {
+		return time.Now()
+	}
+	return srv.Now()
+}
+
+// Authenticated tells whether a peer has logged in
+func (srv *Server) Authenticated(peer enet.Peer) bool {
+	sess := srv.sessions[peer]
+	return sess != nil && sess.state == stateAuthenticated
+}
+
+// Info returns the login information of a logged in peer, or nil
+func (srv *Server) Info(peer enet.Peer) *LoginInfo {
+	if sess := srv.sessions[peer]; sess != nil {
+		return sess.info
+	}
+	return nil
+}
@@ -1,11 +1,9 @@
 package enet
 
-// #include <enet/enet.h>
-import "C"
 import (
-	"encoding/binary"
 	"errors"
-	"unsafe"
+
+	"github.com/eikarna/gotops/gamepacket"
 )
 
 // PacketFlags are bit constants
@@ -14,22 +12,22 @@ type PacketFlags uint32
 const (
 	// PacketFlagReliable packets must be received by the target peer and resend attempts
 	// should be made until the packet is delivered
-	PacketFlagReliable PacketFlags = C.ENET_PACKET_FLAG_RELIABLE
+	PacketFlagReliable PacketFlags = 1 << 0
 
 	// PacketFlagUnsequenced packets will not be sequenced with other packets not supported
 	// for reliable packets
-	PacketFlagUnsequenced = C.ENET_PACKET_FLAG_UNSEQUENCED
+	PacketFlagUnsequenced = 1 << 1
 
 	// PacketFlagNoAllocate packets will not allocate data, and user must supply it instead
-	PacketFlagNoAllocate = C.ENET_PACKET_FLAG_NO_ALLOCATE
+	PacketFlagNoAllocate = 1 << 2
 
 	// PacketFlagUnreliableFragment packets will be fragmented using unreliable (instead of
 	// reliable) sends if it exceeds the MTU
-	PacketFlagUnreliableFragment = C.ENET_PACKET_FLAG_UNRELIABLE_FRAGMENT
+	PacketFlagUnreliableFragment = 1 << 3
 
 	// PacketFlagSent specifies whether the packet has been sent from all queues it has been
 	// entered into
-	PacketFlagSent = C.ENET_PACKET_FLAG_SENT
+	PacketFlagSent = 1 << 8
 )
 
 // Packet may be sent to or received from a peer
@@ -39,65 +37,37 @@ type Packet interface {
 	GetFlags() PacketFlags
 }
 
-// enetPacket is a wrapper around the C ENetPacket struct
-type enetPacket struct {
-	cPacket *C.struct__ENetPacket
-}
-
-// Destroy frees the memory associated with the packet
-func (packet enetPacket) Destroy() {
-	C.enet_packet_destroy(packet.cPacket)
-}
-
-// GetData returns the data associated with the packet
-func (packet enetPacket) GetData() []byte {
-	return C.GoBytes(
-		unsafe.Pointer(packet.cPacket.data),
-		(C.int)(packet.cPacket.dataLength),
-	)
-}
-
-// GetFlags returns the flags associated with the packet
-func (packet enetPacket) GetFlags() PacketFlags {
-	return (PacketFlags)(packet.cPacket.flags)
-}
-
-// NewPacket creates a new packet to send to peers
-func NewPacket(data []byte, flags PacketFlags) (Packet, error) {
-	buffer := C.CBytes(data)
-	packet := C.enet_packet_create(
-		buffer,
-		(C.size_t)(len(data)),
-		(C.enet_uint32)(flags),
-	)
-	C.free(buffer)
-
-	if packet == nil {
-		return nil, errors.New("unable to create packet")
+// nativePacket returns a packet of this backend to send. Packets of other
+// types, such as those of ReceivedEvent values being relayed, are copied
+// into a new packet and destroyed.
+func nativePacket(packet Packet) (enetPacket, error) {
+	if p, ok := packet.(enetPacket); ok {
+		return p, nil
 	}
-
-	return enetPacket{
-		cPacket: packet,
-	}, nil
+	data, flags := packet.GetData(), packet.GetFlags()
+	packet.Destroy()
+	p, err := NewPacket(data, flags&^(PacketFlagNoAllocate|PacketFlagSent))
+	if err != nil {
+		return enetPacket{}, err
+	}
+	return p.(enetPacket), nil
 }
 
-// GetMessageFromPacket returns the message from a packet
+// GetMessageFromPacket returns the message from a packet, after its 4 byte
+// message type, with its last byte replaced by a null terminator. Packets
+// holding no more than a message type give an empty string. The packet
+// isn't modified.
 func GetMessageFromPacket(packet Packet) string {
 	gamePacket := packet.GetData()
-	copy(gamePacket[len(gamePacket)-1:], []byte{0})
-	return string(gamePacket[4:])
+	if len(gamePacket) <= 4 {
+		return ""
+	}
+	return string(gamePacket[4:len(gamePacket)-1]) + "\x00"
 }
 
 // SendPacket sends a packet to a peer
 func SendPacket(peer Peer, gameMessageType int32, strData string) error {
-	packetSize := 5 + len(strData)
-	netPacket := make([]byte, packetSize)
-
-	binary.LittleEndian.PutUint32(netPacket[0:4], uint32(gameMessageType))
-	if strData != "" {
-		copy(netPacket[4:4+len(strData)], []byte(strData))
-	}
-	netPacket[4+len(strData)] = 0
+	netPacket := gamepacket.Encode(gamepacket.MessageType(gameMessageType), []byte(strData))
 	packet, err := NewPacket(netPacket, PacketFlagReliable)
 	if err != nil {
 		return errors.New("unable to create packet on SendPacket.")
@@ -108,13 +78,7 @@ func SendPacket(peer Peer, gameMessageType int32, strData string) error {
 
 // SendRawPacket sends a raw packet to a peer
 func SendRawPacket(peer Peer, gameMessageType int32, data []byte) error {
-	packetSize := 5 + len(data)
-	netPacket := make([]byte, packetSize)
-	binary.LittleEndian.PutUint32(netPacket[0:4], uint32(gameMessageType))
-	if data != nil {
-		copy(netPacket[4:4+len(data)], data)
-	}
-	netPacket[4+len(data)] = 0
+	netPacket := gamepacket.Encode(gamepacket.MessageType(gameMessageType), data)
 	packet, err := NewPacket(netPacket, PacketFlagReliable)
 	if err != nil {
 		return errors.New("unable to create packet on SendRawPacket.")
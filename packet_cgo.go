@@ -0,0 +1,88 @@
+//go:build cgo && !purego
+
+package enet
+
+// #include <enet/enet.h>
+import "C"
+import (
+	"errors"
+	"unsafe"
+
+	"github.com/eikarna/gotops/internal/leak"
+)
+
+// Ensure the packet flags shared with the pure-Go backend match enet.h.
+var (
+	_ = [1]struct{}{}[PacketFlagReliable-PacketFlags(C.ENET_PACKET_FLAG_RELIABLE)]
+	_ = [1]struct{}{}[PacketFlagUnsequenced-C.ENET_PACKET_FLAG_UNSEQUENCED]
+	_ = [1]struct{}{}[PacketFlagNoAllocate-C.ENET_PACKET_FLAG_NO_ALLOCATE]
+	_ = [1]struct{}{}[PacketFlagUnreliableFragment-C.ENET_PACKET_FLAG_UNRELIABLE_FRAGMENT]
+	_ = [1]struct{}{}[PacketFlagSent-C.ENET_PACKET_FLAG_SENT]
+)
+
+// enetPacket is a wrapper around the C ENetPacket struct
+type enetPacket struct {
+	cPacket *C.struct__ENetPacket
+}
+
+// Destroy frees the memory associated with the packet
+func (packet enetPacket) Destroy() {
+	leak.Release(packet.cPacket)
+	C.enet_packet_destroy(packet.cPacket)
+}
+
+// GetData returns the data associated with the packet
+func (packet enetPacket) GetData() []byte {
+	return C.GoBytes(
+		unsafe.Pointer(packet.cPacket.data),
+		(C.int)(packet.cPacket.dataLength),
+	)
+}
+
+// GetFlags returns the flags associated with the packet
+func (packet enetPacket) GetFlags() PacketFlags {
+	return (PacketFlags)(packet.cPacket.flags)
+}
+
+// NewPacket creates a new packet to send to peers
+func NewPacket(data []byte, flags PacketFlags) (Packet, error) {
+	buffer := C.CBytes(data)
+	packet := C.enet_packet_create(
+		buffer,
+		(C.size_t)(len(data)),
+		(C.enet_uint32)(flags),
+	)
+	C.free(buffer)
+
+	if packet == nil {
+		return nil, errors.New("unable to create packet")
+	}
+	leak.Track(leak.KindPacket, packet)
+
+	return enetPacket{
+		cPacket: packet,
+	}, nil
+}
+
+// multicast queues a single packet on several peers, destroying it if none
+// of them took it
+func multicast(packet Packet, channel uint8, peers []Peer) error {
+	p, ok := packet.(enetPacket)
+	if !ok {
+		return sendCopies(packet, channel, peers)
+	}
+	for _, peer := range peers {
+		if _, ok := peer.(enetPeer); !ok {
+			return sendCopies(packet, channel, peers)
+		}
+	}
+
+	leak.Release(p.cPacket)
+	for _, peer := range peers {
+		C.enet_peer_send(peer.(enetPeer).cPeer, (C.enet_uint8)(channel), p.cPacket)
+	}
+	if p.cPacket.referenceCount == 0 {
+		C.enet_packet_destroy(p.cPacket)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+//go:build !cgo || purego
+
+package enet
+
+import (
+	"errors"
+
+	"github.com/eikarna/gotops/internal/leak"
+	"github.com/eikarna/gotops/internal/protocol"
+)
+
+// enetPacket is a wrapper around a pure-Go packet
+type enetPacket struct {
+	packet *protocol.Packet
+}
+
+// Destroy frees the memory associated with the packet
+func (packet enetPacket) Destroy() {
+	leak.Release(packet.packet)
+	packet.packet.Destroy()
+}
+
+// GetData returns the data associated with the packet
+func (packet enetPacket) GetData() []byte {
+	return append([]byte(nil), packet.packet.Data...)
+}
+
+// GetFlags returns the flags associated with the packet
+func (packet enetPacket) GetFlags() PacketFlags {
+	return (PacketFlags)(packet.packet.Flags)
+}
+
+// NewPacket creates a new packet to send to peers. The data is always copied,
+// PacketFlagNoAllocate has no effect on the pure-Go backend.
+func NewPacket(data []byte, flags PacketFlags) (Packet, error) {
+	if len(data) > protocol.HostDefaultMaximumPacketSize {
+		return nil, errors.New("unable to create packet")
+	}
+
+	p := protocol.NewPacket(data, uint32(flags)&^uint32(PacketFlagNoAllocate))
+	leak.Track(leak.KindPacket, p)
+	return enetPacket{
+		packet: p,
+	}, nil
+}
+
+// multicast queues a single packet on several peers, destroying it if none
+// of them took it
+func multicast(packet Packet, channel uint8, peers []Peer) error {
+	p, ok := packet.(enetPacket)
+	if !ok {
+		return sendCopies(packet, channel, peers)
+	}
+	for _, peer := range peers {
+		if _, ok := peer.(enetPeer); !ok {
+			return sendCopies(packet, channel, peers)
+		}
+	}
+
+	leak.Release(p.packet)
+	for _, peer := range peers {
+		peer.(enetPeer).peer.Send(channel, p.packet)
+	}
+	if !p.packet.Referenced() {
+		p.packet.Destroy()
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package enet_test
+
+import (
+	"strings"
+	"testing"
+
+	enet "github.com/eikarna/gotops"
+)
+
+// bytesPacket is a packet over a byte slice
+type bytesPacket []byte
+
+func (p bytesPacket) Destroy()                   {}
+func (p bytesPacket) GetData() []byte            { return p }
+func (p bytesPacket) GetFlags() enet.PacketFlags { return 0 }
+
+func TestGetMessageFromPacket(t *testing.T) {
+	for data, want := range map[string]string{
+		"\x02\x00\x00\x00action|log\x00": "action|log\x00",
+		"\x02\x00\x00\x00action|log\n":   "action|log\x00",
+		"\x02\x00\x00\x00":               "",
+		"\x02":                           "",
+		"":                               "",
+	} {
+		packet := bytesPacket(data)
+		if got := enet.GetMessageFromPacket(packet); got != want {
+			t.Errorf("message of %q is %q, want %q", data, got, want)
+		}
+		if string(packet) != data {
+			t.Errorf("packet %q modified to %q", data, packet)
+		}
+	}
+}
+
+func FuzzGetMessageFromPacket(f *testing.F) {
+	f.Add([]byte("\x02\x00\x00\x00action|log\x00"))
+	f.Add([]byte{2})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		original := string(data)
+		message := enet.GetMessageFromPacket(bytesPacket(data))
+		if string(data) != original {
+			t.Fatal("packet modified")
+		}
+		if len(data) > 4 && (len(message) != len(data)-4 || !strings.HasSuffix(message, "\x00")) {
+			t.Fatalf("message of %q is %q", data, message)
+		}
+	})
+}
@@ -0,0 +1,202 @@
+//go:build cgo && !purego
+
+package enet
+
+// #include <enet/enet.h>
+import "C"
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+	"unsafe"
+
+	"github.com/eikarna/gotops/internal/leak"
+)
+
+// localDisconnects holds the C peers being disconnected by this side, and
+// remoteDisconnects the ones that sent a disconnect command. enet gives
+// timeouts no flag of their own, so a disconnect event is a timeout unless
+// the peer is in either.
+var localDisconnects, remoteDisconnects sync.Map
+
+// enetPeer is an implementation of the Peer interface
+type enetPeer struct {
+	cPeer *C.struct__ENetPeer
+}
+
+// NewPeer creates a new peer from a C peer
+func (peer enetPeer) State() EnetPeerState {
+	switch peer.cPeer.state {
+	case C.ENET_PEER_STATE_DISCONNECTED:
+		return Disconnected
+	case C.ENET_PEER_STATE_CONNECTING:
+		return Connecting
+	case C.ENET_PEER_STATE_ACKNOWLEDGING_CONNECT:
+		return AcknowledgingConnect
+	case C.ENET_PEER_STATE_CONNECTION_PENDING:
+		return ConnectionPending
+	case C.ENET_PEER_STATE_CONNECTION_SUCCEEDED:
+		return ConnectionSucceeded
+	case C.ENET_PEER_STATE_CONNECTED:
+		return Connected
+	case C.ENET_PEER_STATE_DISCONNECT_LATER:
+		return DisconnectLater
+	case C.ENET_PEER_STATE_DISCONNECTING:
+		return Disconnecting
+	case C.ENET_PEER_STATE_ACKNOWLEDGING_DISCONNECT:
+		return AcknowledgingDisconnect
+	case C.ENET_PEER_STATE_ZOMBIE:
+		return Zombie
+	default:
+		// Handle unexpected states
+		return Disconnected // or another appropriate default
+	}
+}
+
+// GetConnectID returns the connect ID of a peer
+func (peer enetPeer) GetConnectID() uint32 {
+	return uint32(peer.cPeer.connectID)
+}
+
+// GetAddress returns the address of a peer
+func (peer enetPeer) GetAddress() Address {
+	return &enetAddress{
+		cAddr: peer.cPeer.address,
+	}
+}
+
+// Disconnect a peer from a host
+func (peer enetPeer) Disconnect(data uint32) {
+	localDisconnects.Store(peer.cPeer, struct{}{})
+	C.enet_peer_disconnect(
+		peer.cPeer,
+		(C.enet_uint32)(data),
+	)
+	peer.forgetIfReset()
+}
+
+// DisconnectNow immediately disconnects a peer from a host
+func (peer enetPeer) DisconnectNow(data uint32) {
+	forgetDisconnect(peer.cPeer)
+	C.enet_peer_disconnect_now(
+		peer.cPeer,
+		(C.enet_uint32)(data),
+	)
+}
+
+// DisconnectLater schedules a peer for disconnection
+func (peer enetPeer) DisconnectLater(data uint32) {
+	localDisconnects.Store(peer.cPeer, struct{}{})
+	C.enet_peer_disconnect_later(
+		peer.cPeer,
+		(C.enet_uint32)(data),
+	)
+	peer.forgetIfReset()
+}
+
+// forgetIfReset forgets the disconnection of a peer enet reset right away
+// instead of disconnecting, such as one still connecting, as no event will
+// come for it
+func (peer enetPeer) forgetIfReset() {
+	if peer.cPeer.state == C.ENET_PEER_STATE_DISCONNECTED {
+		forgetDisconnect(peer.cPeer)
+	}
+}
+
+// PeerTimeout sets the timeout parameters for a peer
+func (peer enetPeer) PeerTimeout(timeoutLimit, timeoutMin, timeoutMax uint32) {
+	C.enet_peer_timeout(
+		peer.cPeer,
+		(C.enet_uint32)(timeoutLimit),
+		(C.enet_uint32)(timeoutMin),
+		(C.enet_uint32)(timeoutMax),
+	)
+}
+
+// SendBytes sends a byte slice to a peer
+func (peer enetPeer) SendBytes(data []byte, channel uint8, flags PacketFlags) error {
+	packet, err := NewPacket(data, flags)
+	if err != nil {
+		return err
+	}
+	return peer.SendPacket(packet, channel)
+}
+
+// SendString sends a string to a peer
+func (peer enetPeer) SendString(str string, channel uint8, flags PacketFlags) error {
+	packet, err := NewPacket([]byte(str), flags)
+	if err != nil {
+		return err
+	}
+	return peer.SendPacket(packet, channel)
+}
+
+// SendPacket sends a packet to a peer
+func (peer enetPeer) SendPacket(packet Packet, channel uint8) error {
+	p, err := nativePacket(packet)
+	if err != nil {
+		return err
+	}
+	leak.Release(p.cPacket)
+	C.enet_peer_send(
+		peer.cPeer,
+		(C.enet_uint8)(channel),
+		p.cPacket,
+	)
+	return nil
+}
+
+// SetData sets an arbitrary value against a peer. This is useful to attach some
+func (peer enetPeer) SetData(data []byte) {
+	if len(data) > math.MaxUint32 {
+		panic(fmt.Sprintf("maximum peer data length is uint32 (%d)", math.MaxUint32))
+	}
+
+	// Free any data that was previously stored against this peer.
+	existing := unsafe.Pointer(peer.cPeer.data)
+	if existing != nil {
+		C.free(existing)
+	}
+
+	// If nil, set this explicitly.
+	if data == nil {
+		leak.Release(peer.cPeer)
+		peer.cPeer.data = nil
+		return
+	}
+	leak.Track(leak.KindPeerData, peer.cPeer)
+
+	// First 4 bytes stores how many bytes we have. This is so we can C.GoBytes when
+	// retrieving which requires a byte length to read.
+	b := make([]byte, len(data)+4)
+	binary.LittleEndian.PutUint32(b, uint32(len(data)))
+	// Join this header + data in to a contiguous slice
+	copy(b[4:], data)
+	// And write it out to C memory, storing our pointer.
+	peer.cPeer.data = unsafe.Pointer(C.CBytes(b))
+}
+
+// GetData returns an application-specific value that's been set
+func (peer enetPeer) GetData() []byte {
+	ptr := unsafe.Pointer(peer.cPeer.data)
+
+	if ptr == nil {
+		return nil
+	}
+
+	// First 4 bytes are the bytes length.
+	header := []byte{
+		*(*byte)(unsafe.Add(ptr, 0)),
+		*(*byte)(unsafe.Add(ptr, 1)),
+		*(*byte)(unsafe.Add(ptr, 2)),
+		*(*byte)(unsafe.Add(ptr, 3)),
+	}
+
+	return []byte(C.GoBytes(
+		// Take from the start of the data.
+		unsafe.Add(ptr, 4),
+		// As many bytes as were indicated in the header.
+		C.int(binary.LittleEndian.Uint32(header)),
+	))
+}
@@ -0,0 +1,108 @@
+//go:build !cgo || purego
+
+package enet
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/eikarna/gotops/internal/leak"
+	"github.com/eikarna/gotops/internal/protocol"
+)
+
+// enetPeer is an implementation of the Peer interface
+type enetPeer struct {
+	peer *protocol.Peer
+}
+
+// State returns the state of a peer
+func (peer enetPeer) State() EnetPeerState {
+	return EnetPeerState(peer.peer.State())
+}
+
+// GetConnectID returns the connect ID of a peer
+func (peer enetPeer) GetConnectID() uint32 {
+	return peer.peer.ConnectID()
+}
+
+// GetAddress returns the address of a peer
+func (peer enetPeer) GetAddress() Address {
+	addr := peer.peer.Address()
+	return &enetAddress{
+		addr: addr.Addr(),
+		port: addr.Port(),
+	}
+}
+
+// Disconnect a peer from a host
+func (peer enetPeer) Disconnect(data uint32) {
+	peer.peer.Disconnect(data)
+}
+
+// DisconnectNow immediately disconnects a peer from a host
+func (peer enetPeer) DisconnectNow(data uint32) {
+	peer.peer.DisconnectNow(data)
+}
+
+// DisconnectLater schedules a peer for disconnection
+func (peer enetPeer) DisconnectLater(data uint32) {
+	peer.peer.DisconnectLater(data)
+}
+
+// PeerTimeout sets the timeout parameters for a peer
+func (peer enetPeer) PeerTimeout(timeoutLimit, timeoutMin, timeoutMax uint32) {
+	peer.peer.Timeout(timeoutLimit, timeoutMin, timeoutMax)
+}
+
+// SendBytes sends a byte slice to a peer
+func (peer enetPeer) SendBytes(data []byte, channel uint8, flags PacketFlags) error {
+	packet, err := NewPacket(data, flags)
+	if err != nil {
+		return err
+	}
+	return peer.SendPacket(packet, channel)
+}
+
+// SendString sends a string to a peer
+func (peer enetPeer) SendString(str string, channel uint8, flags PacketFlags) error {
+	packet, err := NewPacket([]byte(str), flags)
+	if err != nil {
+		return err
+	}
+	return peer.SendPacket(packet, channel)
+}
+
+// SendPacket sends a packet to a peer. Like the cgo backend, packets that
+// can't be queued, such as to a peer that isn't connected yet, are dropped.
+func (peer enetPeer) SendPacket(packet Packet, channel uint8) error {
+	p, err := nativePacket(packet)
+	if err != nil {
+		return err
+	}
+	leak.Release(p.packet)
+	peer.peer.Send(channel, p.packet)
+	return nil
+}
+
+// SetData sets an arbitrary value against a peer. This is useful to attach some
+func (peer enetPeer) SetData(data []byte) {
+	if len(data) > math.MaxUint32 {
+		panic(fmt.Sprintf("maximum peer data length is uint32 (%d)", math.MaxUint32))
+	}
+
+	if data == nil {
+		leak.Release(peer.peer)
+		peer.peer.Data = nil
+		return
+	}
+	leak.Track(leak.KindPeerData, peer.peer)
+	peer.peer.Data = append([]byte{}, data...)
+}
+
+// GetData returns an application-specific value that's been set
+func (peer enetPeer) GetData() []byte {
+	if peer.peer.Data == nil {
+		return nil
+	}
+	return append([]byte{}, peer.peer.Data...)
+}
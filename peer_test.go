@@ -213,3 +213,60 @@ func currentMemory(t testing.TB) int {
 
 	return kb
 }
+
+func BenchmarkSendBytes(b *testing.B) {
+	flags := []struct {
+		name  string
+		flags enet.PacketFlags
+	}{
+		{"reliable", enet.PacketFlagReliable},
+		{"unreliable", 0},
+		{"unsequenced", enet.PacketFlagUnsequenced},
+	}
+	for _, f := range flags {
+		for _, size := range []int{64, 1024, 8192} {
+			b.Run(fmt.Sprintf("%s/%dB", f.name, size), func(b *testing.B) {
+				server, clients, _, peers := connectLoopback(b, 1)
+				data := make([]byte, size)
+
+				b.ReportAllocs()
+				b.SetBytes(int64(size))
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if err := peers[0].SendBytes(data, 0, f.flags); err != nil {
+						b.Fatal(err)
+					}
+					// Flush regularly, so that queues stay short.
+					if i%64 == 63 {
+						drain(clients[0])
+						drain(server)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkGetData(b *testing.B) {
+	_, _, _, peers := connectLoopback(b, 1)
+	peers[0].SetData([]byte("player 1234"))
+	defer peers[0].SetData(nil)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		peers[0].GetData()
+	}
+}
+
+func BenchmarkSetData(b *testing.B) {
+	_, _, _, peers := connectLoopback(b, 1)
+	data := []byte("player 1234")
+	defer peers[0].SetData(nil)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		peers[0].SetData(data)
+	}
+}
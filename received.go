@@ -0,0 +1,93 @@
+package enet
+
+// ReceivedEvent is an event that owns its data. Its payload is a Go copy of
+// the received packet, which is destroyed as soon as the event is made, so
+// the event may be kept or handed to another goroutine without leaking. The
+// peer is still owned by the host and must only be used where the host is
+// serviced.
+//
+// Hosts return ReceivedEvent values from Service after
+// UsingReceivedEvents(true); NewReceivedEvent converts other events.
+type ReceivedEvent struct {
+	Type      EventType
+	Peer      Peer
+	ChannelID uint8
+	Data      uint32
+
+	// Payload and Flags are the data and flags of the received packet
+	Payload []byte
+	Flags   PacketFlags
+
+	// Timeout is set on disconnect events of peers that timed out
+	Timeout bool
+}
+
+// NewReceivedEvent copies an event, destroying its packet
+func NewReceivedEvent(ev Event) *ReceivedEvent {
+	ret := &ReceivedEvent{
+		Type: ev.GetType(),
+	}
+	if ret.Type == EventNone {
+		return ret
+	}
+	ret.Peer = ev.GetPeer()
+	ret.ChannelID = ev.GetChannelID()
+	ret.Data = ev.GetData()
+	ret.Timeout = ev.TimedOut()
+	if ret.Type == EventReceive {
+		packet := ev.GetPacket()
+		ret.Payload = packet.GetData()
+		ret.Flags = packet.GetFlags()
+		packet.Destroy()
+	}
+	return ret
+}
+
+func (ev *ReceivedEvent) GetType() EventType {
+	return ev.Type
+}
+
+func (ev *ReceivedEvent) GetPeer() Peer {
+	return ev.Peer
+}
+
+func (ev *ReceivedEvent) GetChannelID() uint8 {
+	return ev.ChannelID
+}
+
+func (ev *ReceivedEvent) GetData() uint32 {
+	return ev.Data
+}
+
+// GetPacket returns the payload as a packet. Destroying it does nothing and
+// its data isn't copied.
+func (ev *ReceivedEvent) GetPacket() Packet {
+	return ownedPacket{
+		data:  ev.Payload,
+		flags: ev.Flags,
+	}
+}
+
+func (ev *ReceivedEvent) DisconnectReason() DisconnectReason {
+	return disconnectReason(ev.Type, ev.Data, ev.Timeout)
+}
+
+func (ev *ReceivedEvent) TimedOut() bool {
+	return ev.Type == EventDisconnect && ev.Timeout
+}
+
+// ownedPacket is the packet of a ReceivedEvent
+type ownedPacket struct {
+	data  []byte
+	flags PacketFlags
+}
+
+func (packet ownedPacket) Destroy() {}
+
+func (packet ownedPacket) GetData() []byte {
+	return packet.data
+}
+
+func (packet ownedPacket) GetFlags() PacketFlags {
+	return packet.flags
+}
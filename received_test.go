@@ -0,0 +1,132 @@
+package enet_test
+
+import (
+	"testing"
+	"time"
+
+	enet "github.com/eikarna/gotops"
+)
+
+func TestReceivedEvents(t *testing.T) {
+	port := getFreePort()
+	server, err := enet.NewHostFromConfig(enet.HostConfig{
+		AddressType:    enet.ENET_ADDRESS_TYPE_IPV4,
+		Address:        enet.NewListenAddress(enet.ENET_ADDRESS_TYPE_IPV4, port),
+		PeerCount:      1,
+		ChannelLimit:   2,
+		ReceivedEvents: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Destroy()
+
+	client, err := enet.NewHost(enet.ENET_ADDRESS_TYPE_IPV4, nil, 1, 2, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Destroy()
+	peer, err := client.Connect(enet.NewAddress(enet.ENET_ADDRESS_TYPE_IPV4, "127.0.0.1", port), 2, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Events are handed to another goroutine, as a server with a worker
+	// pool would.
+	events := make(chan *enet.ReceivedEvent, 8)
+	done := make(chan struct{})
+	var got []*enet.ReceivedEvent
+	go func() {
+		defer close(done)
+		for ev := range events {
+			got = append(got, ev)
+		}
+	}()
+
+	sent, count := false, 0
+	for start := time.Now(); time.Since(start) < 2*time.Second && count < 2; {
+		if ev := client.Service(0); ev.GetType() == enet.EventConnect && !sent {
+			peer.SendString("hello", 1, enet.PacketFlagReliable)
+			sent = true
+		}
+		ev := server.Service(1)
+		if ev.GetType() == enet.EventNone {
+			continue
+		}
+		received, ok := ev.(*enet.ReceivedEvent)
+		if !ok {
+			t.Fatalf("Service returned %T", ev)
+		}
+		events <- received
+		count++
+	}
+	close(events)
+	<-done
+
+	if len(got) != 2 {
+		t.Fatalf("got %d events, want 2", len(got))
+	}
+	if got[0].Type != enet.EventConnect || got[0].Data != 7 {
+		t.Errorf("first event is %v with data %d", got[0].Type, got[0].Data)
+	}
+	ev := got[1]
+	if ev.Type != enet.EventReceive || string(ev.Payload) != "hello" || ev.ChannelID != 1 {
+		t.Fatalf("second event is %v %q on channel %d", ev.Type, ev.Payload, ev.ChannelID)
+	}
+	if ev.Flags&enet.PacketFlagReliable == 0 {
+		t.Errorf("flags are %#x", ev.Flags)
+	}
+	// Destroying the packet of a received event is harmless.
+	ev.GetPacket().Destroy()
+	if string(ev.GetPacket().GetData()) != "hello" {
+		t.Errorf("packet data is %q", ev.GetPacket().GetData())
+	}
+}
+
+func TestRelayReceivedEvent(t *testing.T) {
+	server, clients, serverPeers, clientPeers := connectLoopback(t, 2)
+	server.UsingReceivedEvents(true)
+	clientPeers[0].SendString("relay", 0, enet.PacketFlagReliable)
+
+	var ev enet.Event
+	for start := time.Now(); ev == nil; {
+		if time.Since(start) > 2*time.Second {
+			t.Fatal("packet not received")
+		}
+		clients[0].Service(0)
+		if e := server.Service(1); e.GetType() == enet.EventReceive {
+			ev = e
+		}
+	}
+	other := serverPeers[0]
+	if enet.AddressEqual(other.GetAddress(), ev.GetPeer().GetAddress()) {
+		other = serverPeers[1]
+	}
+	if err := other.SendPacket(ev.GetPacket(), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.BroadcastPacket(ev.GetPacket(), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// The other client gets both, the sender only the broadcast.
+	want := []int{1, 2}
+	for i, client := range clients {
+		received := 0
+		for start := time.Now(); received < want[i] && time.Since(start) < 2*time.Second; {
+			server.Service(0)
+			e := client.Service(1)
+			if e.GetType() != enet.EventReceive {
+				continue
+			}
+			if string(e.GetPacket().GetData()) != "relay" {
+				t.Errorf("client %d received %q", i, e.GetPacket().GetData())
+			}
+			e.GetPacket().Destroy()
+			received++
+		}
+		if received != want[i] {
+			t.Errorf("client %d received %d packets, want %d", i, received, want[i])
+		}
+	}
+}
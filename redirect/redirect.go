@@ -0,0 +1,306 @@
+// Package redirect moves players between game servers with OnSendToServer,
+// carrying their session in a signed, expiring token that the server they
+// reconnect to validates.
+package redirect
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	enet "github.com/eikarna/gotops"
+	"github.com/eikarna/gotops/gamepacket"
+	"github.com/eikarna/gotops/login"
+)
+
+// Errors returned when validating a token
+var (
+	ErrNoToken      = errors.New("redirect: login has no token")
+	ErrInvalidToken = errors.New("redirect: invalid token")
+	ErrExpiredToken = errors.New("redirect: token expired")
+	ErrTokenReused  = errors.New("redirect: token already used")
+)
+
+// DefaultTTL is the time a redirected player has to reconnect
+const DefaultTTL = 30 * time.Second
+
+// macSize is the size of the truncated signature of a token
+const macSize = 16
+
+// Session is what a player carries from one server to another
+type Session struct {
+	UserID int32
+	Name   string
+
+	// World and Door are where the player enters on the new server
+	World string
+	Door  string
+
+	// Token is the random number the client sends back, set by Issue
+	Token int32
+
+	// Expires is when the token stops being valid, set by Issue
+	Expires time.Time
+}
+
+// UsedStore remembers the tokens that were used, so that each one is
+// accepted once. Servers validating tokens of the same key, such as the
+// instances of a login server behind a load balancer, must share a store,
+// for example one backed by a database, or a token can be used once on each.
+type UsedStore interface {
+	// Use marks the token with the given ID as used until it expires. It
+	// returns false if the token was already used. now is the time of the
+	// signer, tokens that expired by then may be forgotten.
+	Use(id string, now, expires time.Time) (bool, error)
+}
+
+// MemoryStore is a UsedStore keeping used tokens in memory, which only
+// prevents reuse on the server it runs on
+type MemoryStore struct {
+	mu   sync.Mutex
+	used map[string]time.Time
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{used: make(map[string]time.Time)}
+}
+
+// Use marks a token as used, forgetting the expired ones
+func (m *MemoryStore) Use(id string, now, expires time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for used, until := range m.used {
+		if !now.Before(until) {
+			delete(m.used, used)
+		}
+	}
+	if _, ok := m.used[id]; ok {
+		return false, nil
+	}
+	m.used[id] = expires
+	return true, nil
+}
+
+// Signer issues and validates redirect tokens. Every server players are
+// redirected between must share its key.
+type Signer struct {
+	key []byte
+
+	// TTL is the time a token is valid for
+	TTL time.Duration
+
+	// Now returns the current time. The default is time.Now.
+	Now func() time.Time
+
+	// Used remembers the used tokens. The default is a MemoryStore, so
+	// servers sharing the key must set a shared store.
+	Used UsedStore
+}
+
+// NewSigner creates a signer with the given secret key
+func NewSigner(key []byte) *Signer {
+	return &Signer{
+		key:  append([]byte(nil), key...),
+		TTL:  DefaultTTL,
+		Now:  time.Now,
+		Used: NewMemoryStore(),
+	}
+}
+
+// sign returns the truncated signature of a payload
+func (s *Signer) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(payload)
+	return mac.Sum(nil)[:macSize]
+}
+
+// Issue fills in the token and expiry of a session and returns the signed
+// token carrying it
+func (s *Signer) Issue(sess *Session) (string, error) {
+	var nonce [4]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return "", err
+	}
+	sess.Token = int32(binary.LittleEndian.Uint32(nonce[:]) & math.MaxInt32)
+	sess.Expires = s.Now().Add(s.TTL)
+
+	var b []byte
+	b = binary.LittleEndian.AppendUint32(b, uint32(sess.UserID))
+	b = binary.LittleEndian.AppendUint32(b, uint32(sess.Token))
+	b = binary.LittleEndian.AppendUint64(b, uint64(sess.Expires.UnixMilli()))
+	for _, str := range []string{sess.Name, sess.World, sess.Door} {
+		if len(str) > math.MaxUint16 {
+			return "", fmt.Errorf("redirect: string of %d bytes is too long", len(str))
+		}
+		b = binary.LittleEndian.AppendUint16(b, uint16(len(str)))
+		b = append(b, str...)
+	}
+
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(b) + "." + enc.EncodeToString(s.sign(b)), nil
+}
+
+// Verify checks the signature and expiry of a token and returns the session
+// it carries. A token is only accepted once by the servers sharing the Used
+// store of the signer.
+func (s *Signer) Verify(token string) (*Session, error) {
+	sess, id, err := s.check(token)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.consume(id, sess.Expires); err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+// check checks the signature and expiry of a token and returns the session
+// it carries along with its ID. The session is only decoded once the
+// signature checks out.
+func (s *Signer) check(token string) (*Session, string, error) {
+	enc := base64.RawURLEncoding
+	payloadText, macText, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, "", ErrInvalidToken
+	}
+	payload, err := enc.DecodeString(payloadText)
+	if err != nil {
+		return nil, "", ErrInvalidToken
+	}
+	mac, err := enc.DecodeString(macText)
+	if err != nil || !hmac.Equal(mac, s.sign(payload)) {
+		return nil, "", ErrInvalidToken
+	}
+
+	sess, ok := decode(payload)
+	if !ok {
+		return nil, "", ErrInvalidToken
+	}
+	if !s.Now().Before(sess.Expires) {
+		return nil, "", ErrExpiredToken
+	}
+	// Tokens are identified by their decoded signature, as the decoder
+	// accepts several spellings of the same token.
+	return sess, hex.EncodeToString(mac), nil
+}
+
+// consume marks a token as used
+func (s *Signer) consume(id string, expires time.Time) error {
+	ok, err := s.Used.Use(id, s.Now(), expires)
+	if err != nil {
+		return fmt.Errorf("redirect: marking token as used: %w", err)
+	}
+	if !ok {
+		return ErrTokenReused
+	}
+	return nil
+}
+
+// decode decodes the payload of a token
+func decode(b []byte) (*Session, bool) {
+	if len(b) < 16 {
+		return nil, false
+	}
+	le := binary.LittleEndian
+	sess := &Session{
+		UserID:  int32(le.Uint32(b[0:4])),
+		Token:   int32(le.Uint32(b[4:8])),
+		Expires: time.UnixMilli(int64(le.Uint64(b[8:16]))),
+	}
+	b = b[16:]
+	for _, str := range []*string{&sess.Name, &sess.World, &sess.Door} {
+		if len(b) < 2 || len(b) < 2+int(le.Uint16(b)) {
+			return nil, false
+		}
+		n := int(le.Uint16(b))
+		*str = string(b[2 : 2+n])
+		b = b[2+n:]
+	}
+	return sess, len(b) == 0
+}
+
+// NewCall issues a token for the session and builds the OnSendToServer call
+// sending the player to the server at host:port
+func (s *Signer) NewCall(host string, port uint16, sess *Session) (*gamepacket.TankPacket, error) {
+	token, err := s.Issue(sess)
+	if err != nil {
+		return nil, err
+	}
+	return gamepacket.NewCall(-1, 0, gamepacket.VariantList{
+		"OnSendToServer",
+		int32(port),
+		sess.Token,
+		sess.UserID,
+		host + "|" + sess.Door + "|" + token,
+		int32(1),
+		sess.Name,
+	})
+}
+
+// Send redirects a peer to the server at host:port, carrying the session
+func (s *Signer) Send(peer enet.Peer, host string, port uint16, sess *Session) error {
+	tank, err := s.NewCall(host, port, sess)
+	if err != nil {
+		return err
+	}
+	data, err := gamepacket.EncodeTank(tank)
+	if err != nil {
+		return err
+	}
+	return peer.SendBytes(data, 0, enet.PacketFlagReliable)
+}
+
+// Validate checks the token a redirected client sends back when logging in
+// and returns the session it carries. The token, user and UUIDToken fields
+// must match what was issued, and the token is only accepted once.
+func (s *Signer) Validate(info *login.LoginInfo) (*Session, error) {
+	token, ok := info.Fields.Get("UUIDToken")
+	if !ok || token == "" {
+		return nil, ErrNoToken
+	}
+	sess, id, err := s.check(token)
+	if err != nil {
+		return nil, err
+	}
+	if info.Fields.Value("token") != strconv.Itoa(int(sess.Token)) ||
+		info.Fields.Value("user") != strconv.Itoa(int(sess.UserID)) {
+		return nil, fmt.Errorf("%w: token or user doesn't match", ErrInvalidToken)
+	}
+	if err := s.consume(id, sess.Expires); err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+// Authenticator returns a login.Authenticator accepting redirected players
+// with a valid token and passing the others to next, which may be nil to
+// reject them. Sessions are passed to accept, which may be nil.
+func (s *Signer) Authenticator(next login.Authenticator, accept func(peer enet.Peer, sess *Session)) login.Authenticator {
+	return login.AuthFunc(func(peer enet.Peer, info *login.LoginInfo) error {
+		sess, err := s.Validate(info)
+		switch {
+		case errors.Is(err, ErrNoToken):
+			if next == nil {
+				return errors.New("`4Please log in through the main server.``")
+			}
+			return next.Authenticate(peer, info)
+		case err != nil:
+			return errors.New("`4Your session expired.`` Please log in again.")
+		}
+		if accept != nil {
+			accept(peer, sess)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,214 @@
+package redirect_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	enet "github.com/eikarna/gotops"
+	"github.com/eikarna/gotops/gamepacket"
+	"github.com/eikarna/gotops/login"
+	"github.com/eikarna/gotops/redirect"
+)
+
+// clock is a manual clock for signers
+type clock struct{ now time.Time }
+
+func (c *clock) Now() time.Time { return c.now }
+
+func newSigner(key string) (*redirect.Signer, *clock) {
+	c := &clock{now: time.Unix(1700000000, 0)}
+	s := redirect.NewSigner([]byte(key))
+	s.Now = c.Now
+	return s, c
+}
+
+func TestToken(t *testing.T) {
+	s, c := newSigner("secret")
+	sess := &redirect.Session{UserID: 42, Name: "Seth", World: "START", Door: "EXIT"}
+	token, err := s.Issue(sess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(token, "|") {
+		t.Errorf("token %q contains a separator", token)
+	}
+
+	got, err := s.Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != *sess {
+		t.Errorf("verified %+v, want %+v", *got, *sess)
+	}
+	if _, err := s.Verify(token); !errors.Is(err, redirect.ErrTokenReused) {
+		t.Errorf("verifying twice returned %v", err)
+	}
+
+	other, _ := newSigner("other")
+	token, _ = s.Issue(sess)
+	if _, err := other.Verify(token); !errors.Is(err, redirect.ErrInvalidToken) {
+		t.Errorf("verifying with another key returned %v", err)
+	}
+	tampered := []byte(token)
+	tampered[3] ^= 1
+	if _, err := s.Verify(string(tampered)); !errors.Is(err, redirect.ErrInvalidToken) {
+		t.Errorf("verifying a tampered token returned %v", err)
+	}
+	for _, bad := range []string{"", ".", "abc", "abc.def"} {
+		if _, err := s.Verify(bad); !errors.Is(err, redirect.ErrInvalidToken) {
+			t.Errorf("verifying %q returned %v", bad, err)
+		}
+	}
+
+	c.now = c.now.Add(redirect.DefaultTTL)
+	if _, err := s.Verify(token); !errors.Is(err, redirect.ErrExpiredToken) {
+		t.Errorf("verifying an expired token returned %v", err)
+	}
+}
+
+// failingStore is a UsedStore that can't be reached
+type failingStore struct{}
+
+func (failingStore) Use(id string, now, expires time.Time) (bool, error) {
+	return false, errors.New("store unreachable")
+}
+
+func TestUsedStore(t *testing.T) {
+	a, _ := newSigner("secret")
+	b, _ := newSigner("secret")
+	token, _ := a.Issue(&redirect.Session{UserID: 42})
+
+	// Each signer only knows the tokens it saw by default.
+	if _, err := a.Verify(token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Verify(token); err != nil {
+		t.Errorf("verifying on another server returned %v", err)
+	}
+
+	b.Used = a.Used
+	token, _ = a.Issue(&redirect.Session{UserID: 42})
+	if _, err := a.Verify(token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Verify(token); !errors.Is(err, redirect.ErrTokenReused) {
+		t.Errorf("verifying on a server sharing the store returned %v", err)
+	}
+
+	b.Used = failingStore{}
+	token, _ = a.Issue(&redirect.Session{UserID: 42})
+	if _, err := b.Verify(token); err == nil || errors.Is(err, redirect.ErrTokenReused) {
+		t.Errorf("verifying with an unreachable store returned %v", err)
+	}
+}
+
+// loginFrom builds the login a client sends after receiving the call
+func loginFrom(t *testing.T, call *gamepacket.TankPacket) *login.LoginInfo {
+	t.Helper()
+
+	var list gamepacket.VariantList
+	if err := list.UnmarshalBinary(call.ExtraData); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 7 || list[0] != "OnSendToServer" || list[1] != int32(17092) {
+		t.Fatalf("call %v", list)
+	}
+	parts := strings.Split(list[4].(string), "|")
+	if len(parts) != 3 || parts[0] != "10.0.0.2" || parts[1] != "EXIT" {
+		t.Fatalf("address %q", list[4])
+	}
+	text := fmt.Sprintf("tankIDName|%s\nprotocol|209\ngame_version|4.61\ntoken|%d\nuser|%d\nUUIDToken|%s\ndoorID|%s\nlmode|1",
+		list[6], list[2], list[3], parts[2], parts[1])
+	info, err := login.ParseLoginInfo(gamepacket.ParseText([]byte(text)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
+
+func TestValidate(t *testing.T) {
+	s, _ := newSigner("secret")
+	call, err := s.NewCall("10.0.0.2", 17092, &redirect.Session{UserID: 42, Name: "Seth", Door: "EXIT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := loginFrom(t, call)
+
+	forged := *info
+	forged.Fields = append(gamepacket.Text(nil), info.Fields...)
+	forged.Fields.Set("user", "1")
+	if _, err := s.Validate(&forged); !errors.Is(err, redirect.ErrInvalidToken) {
+		t.Errorf("validating another user returned %v", err)
+	}
+
+	sess, err := s.Validate(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.UserID != 42 || sess.Name != "Seth" || sess.Door != "EXIT" {
+		t.Errorf("validated %+v", *sess)
+	}
+
+	info.Fields.Set("UUIDToken", "")
+	if _, err := s.Validate(info); !errors.Is(err, redirect.ErrNoToken) {
+		t.Errorf("validating without a token returned %v", err)
+	}
+}
+
+func TestAuthenticator(t *testing.T) {
+	s, _ := newSigner("secret")
+	call, err := s.NewCall("10.0.0.2", 17092, &redirect.Session{UserID: 7, Name: "Seth", Door: "EXIT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := loginFrom(t, call)
+
+	var accepted *redirect.Session
+	auth := s.Authenticator(nil, func(peer enet.Peer, sess *redirect.Session) { accepted = sess })
+	if err := auth.Authenticate(nil, info); err != nil || accepted == nil || accepted.UserID != 7 {
+		t.Errorf("authenticating returned %v, accepted %v", err, accepted)
+	}
+	if err := auth.Authenticate(nil, info); err == nil {
+		t.Error("authenticating with a used token succeeded")
+	}
+
+	info.Fields.Set("UUIDToken", "")
+	if err := auth.Authenticate(nil, info); err == nil {
+		t.Error("authenticating without a token succeeded with no fallback")
+	}
+	fallback := s.Authenticator(login.AuthFunc(func(enet.Peer, *login.LoginInfo) error { return nil }), nil)
+	if err := fallback.Authenticate(nil, info); err != nil {
+		t.Errorf("fallback returned %v", err)
+	}
+}
+
+func FuzzVerify(f *testing.F) {
+	s, _ := newSigner("secret")
+	token, err := s.Issue(&redirect.Session{UserID: 42, Name: "Seth", World: "START"})
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(token)
+	f.Add(token[:len(token)-1])
+	f.Add("." + token)
+	f.Fuzz(func(t *testing.T, token string) {
+		// A fresh signer with the same key hasn't seen the token yet.
+		s, _ := newSigner("secret")
+		if _, err := s.Verify(token); err != nil {
+			return
+		}
+		// Another spelling of the token must not let it be used twice.
+		enc := base64.RawURLEncoding
+		payload, mac, _ := strings.Cut(token, ".")
+		p, _ := enc.DecodeString(payload)
+		m, _ := enc.DecodeString(mac)
+		canonical := enc.EncodeToString(p) + "." + enc.EncodeToString(m)
+		if _, err := s.Verify(canonical); !errors.Is(err, redirect.ErrTokenReused) {
+			t.Fatalf("%q verified, then %q gave %v", token, canonical, err)
+		}
+	})
+}
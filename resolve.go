@@ -0,0 +1,126 @@
+package enet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+)
+
+// ErrNoAddress is returned when a host has no address of the requested
+// family
+var ErrNoAddress = errors.New("enet: no address of the requested family")
+
+// Resolver resolves host names to addresses with Go's resolver
+type Resolver struct {
+	// Resolver is the resolver used. The default is net.DefaultResolver.
+	Resolver *net.Resolver
+
+	// Prefer orders the addresses of a family first when resolving for
+	// both. The default keeps the order of the resolver.
+	Prefer ENetAddressType
+}
+
+// DefaultResolver is the resolver used by ResolveAddress and
+// ResolveAddresses
+var DefaultResolver = &Resolver{}
+
+// Resolve returns every address of host. network is "udp" for both IPv4 and
+// IPv6 addresses, "udp4" or "udp6" for a single family. IPv4-mapped IPv6
+// addresses are unmapped.
+func (r *Resolver) Resolve(ctx context.Context, network, host string, port uint16) ([]Address, error) {
+	var ipNetwork string
+	switch network {
+	case "udp":
+		ipNetwork = "ip"
+	case "udp4":
+		ipNetwork = "ip4"
+	case "udp6":
+		ipNetwork = "ip6"
+	default:
+		return nil, net.UnknownNetworkError(network)
+	}
+
+	ips, err := r.lookup(ctx, ipNetwork, host)
+	if err != nil {
+		return nil, fmt.Errorf("enet: resolving %q: %w", host, err)
+	}
+
+	candidates := make([]netip.Addr, 0, len(ips))
+	for _, ip := range ips {
+		ip = ip.Unmap()
+		if (ipNetwork == "ip4" && !ip.Is4()) || (ipNetwork == "ip6" && ip.Is4()) {
+			continue
+		}
+		candidates = append(candidates, ip)
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("%w: %q", ErrNoAddress, host)
+	}
+	if r.Prefer != ENET_ADDRESS_TYPE_ANY {
+		preferred := func(ip netip.Addr) bool {
+			return ip.Is4() == (r.Prefer == ENET_ADDRESS_TYPE_IPV4)
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return preferred(candidates[i]) && !preferred(candidates[j])
+		})
+	}
+
+	ret := make([]Address, len(candidates))
+	for i, ip := range candidates {
+		ret[i] = NewAddressFromAddrPort(netip.AddrPortFrom(ip, port))
+	}
+	return ret, nil
+}
+
+// lookup returns the IP addresses of host. IP addresses are returned as is.
+func (r *Resolver) lookup(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return []netip.Addr{ip}, nil
+	}
+	resolver := r.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	return resolver.LookupNetIP(ctx, network, host)
+}
+
+// ResolveAddresses returns every address of host, as DefaultResolver.Resolve
+func ResolveAddresses(ctx context.Context, network, host string, port uint16) ([]Address, error) {
+	return DefaultResolver.Resolve(ctx, network, host, port)
+}
+
+// ResolveAddress returns the first address of host. network is "udp" for
+// either IPv4 or IPv6, "udp4" or "udp6" for a single family.
+func ResolveAddress(ctx context.Context, network, host string, port uint16) (Address, error) {
+	addrs, err := DefaultResolver.Resolve(ctx, network, host, port)
+	if err != nil {
+		return nil, err
+	}
+	return addrs[0], nil
+}
+
+// LookupAddress is like NewAddress, but reports hosts that can't be
+// resolved instead of returning an unset address
+func LookupAddress(addressType ENetAddressType, host string, port uint16) (Address, error) {
+	network := "udp"
+	switch addressType {
+	case ENET_ADDRESS_TYPE_IPV4:
+		network = "udp4"
+	case ENET_ADDRESS_TYPE_IPV6:
+		network = "udp6"
+	}
+	return ResolveAddress(context.Background(), network, host, port)
+}
+
+// ParseAddress parses an IP address and port, such as "127.0.0.1:17091" or
+// "[::1]:17091", without resolving anything
+func ParseAddress(s string) (Address, error) {
+	addrPort, err := netip.ParseAddrPort(s)
+	if err != nil {
+		return nil, fmt.Errorf("enet: %w", err)
+	}
+	return NewAddressFromAddrPort(addrPort), nil
+}
@@ -0,0 +1,78 @@
+package enet_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	enet "github.com/eikarna/gotops"
+)
+
+func TestResolveAddress(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	addr, err := enet.ResolveAddress(ctx, "udp", "127.0.0.1", 17091)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr.HostPort() != "127.0.0.1:17091" {
+		t.Errorf("resolved %s", addr.HostPort())
+	}
+
+	if _, err := enet.ResolveAddress(ctx, "udp6", "127.0.0.1", 17091); !errors.Is(err, enet.ErrNoAddress) {
+		t.Errorf("resolving an IPv4 address as IPv6 returned %v", err)
+	}
+	if _, err := enet.ResolveAddress(ctx, "tcp", "127.0.0.1", 17091); err == nil {
+		t.Error("resolving for tcp succeeded")
+	}
+
+	var dnsErr *net.DNSError
+	if _, err := enet.ResolveAddress(ctx, "udp", "gotops.invalid", 17091); !errors.As(err, &dnsErr) {
+		t.Errorf("resolving an invalid host returned %v", err)
+	}
+	if _, err := enet.LookupAddress(enet.ENET_ADDRESS_TYPE_IPV4, "gotops.invalid", 17091); err == nil {
+		t.Error("looking up an invalid host succeeded")
+	}
+
+	canceled, cancelNow := context.WithCancel(ctx)
+	cancelNow()
+	if _, err := enet.ResolveAddress(canceled, "udp", "example.com", 17091); err == nil {
+		t.Error("resolving with a canceled context succeeded")
+	}
+}
+
+func TestResolverPrefer(t *testing.T) {
+	ctx := context.Background()
+	for _, prefer := range []enet.ENetAddressType{enet.ENET_ADDRESS_TYPE_IPV4, enet.ENET_ADDRESS_TYPE_IPV6} {
+		r := &enet.Resolver{Prefer: prefer}
+		addrs, err := r.Resolve(ctx, "udp", "localhost", 1)
+		if err != nil {
+			t.Skip(err)
+		}
+		seenOther := false
+		for _, addr := range addrs {
+			is4 := addr.AddrPort().Addr().Is4()
+			if is4 != (prefer == enet.ENET_ADDRESS_TYPE_IPV4) {
+				seenOther = true
+			} else if seenOther {
+				t.Errorf("preferring %d, resolved %v out of order", prefer, addrs)
+			}
+		}
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	addr, err := enet.ParseAddress("[::1]:17091")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr.String() != "[::1]:17091" {
+		t.Errorf("parsed %s", addr.HostPort())
+	}
+	if _, err := enet.ParseAddress("localhost:17091"); err == nil {
+		t.Error("parsing a host name succeeded")
+	}
+}
@@ -0,0 +1,6 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package enet
+
+// soReusePort is SO_REUSEPORT
+const soReusePort = 0x200
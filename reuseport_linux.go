@@ -0,0 +1,4 @@
+package enet
+
+// soReusePort is SO_REUSEPORT, which package syscall lacks on Linux
+const soReusePort = 0xf
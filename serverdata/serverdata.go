@@ -0,0 +1,131 @@
+// Package serverdata answers the server_data.php request clients make to
+// discover the address of the game server, so that local servers need no
+// external web server.
+package serverdata
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	enet "github.com/eikarna/gotops"
+)
+
+// Path is the path clients request the server data from
+const Path = "/growtopia/server_data.php"
+
+// endMarker ends every server data response
+const endMarker = "RTENDMARKERBS1001"
+
+// Config describes the server advertised to clients
+type Config struct {
+	// Address is the address the enet host is bound to
+	Address enet.Address
+
+	// Host is the address clients connect to. The default is the host of
+	// Address, or 127.0.0.1 when it is bound to every interface.
+	Host string
+
+	// Type is the server type. The default is 1.
+	Type int
+
+	// LoginURL is the address of the login web page, if any
+	LoginURL string
+
+	// Maintenance is shown to clients instead of letting them connect when
+	// not empty
+	Maintenance string
+
+	// Meta returns the meta value sent back by the client when logging in.
+	// The default is the host of the request.
+	Meta func(r *http.Request) string
+}
+
+// Handler is an http.Handler answering server_data.php requests
+type Handler struct {
+	mu     sync.RWMutex
+	config Config
+}
+
+// NewHandler creates a handler advertising the server described by config
+func NewHandler(config Config) *Handler {
+	return &Handler{config: config}
+}
+
+// SetMaintenance turns maintenance mode on with the given message, or off
+// when the message is empty
+func (h *Handler) SetMaintenance(msg string) {
+	h.mu.Lock()
+	h.config.Maintenance = msg
+	h.mu.Unlock()
+}
+
+// host returns the address advertised to clients
+func (config *Config) host() string {
+	if config.Host != "" {
+		return config.Host
+	}
+	if config.Address == nil {
+		return "127.0.0.1"
+	}
+	host := config.Address.AddrPort().Addr()
+	if !host.IsValid() || host.IsUnspecified() {
+		return "127.0.0.1"
+	}
+	return host.WithZone("").String()
+}
+
+// Response builds the server data response to a request
+func (h *Handler) Response(r *http.Request) string {
+	h.mu.RLock()
+	config := h.config
+	h.mu.RUnlock()
+
+	typ := config.Type
+	if typ == 0 {
+		typ = 1
+	}
+	var port uint16
+	if config.Address != nil {
+		port = config.Address.GetPort()
+	}
+	meta := r.Host
+	if config.Meta != nil {
+		meta = config.Meta(r)
+	}
+
+	var b strings.Builder
+	line := func(key, value string) {
+		b.WriteString(key)
+		b.WriteByte('|')
+		b.WriteString(value)
+		b.WriteByte('\n')
+	}
+	line("server", config.host())
+	line("port", strconv.Itoa(int(port)))
+	line("type", strconv.Itoa(typ))
+	if config.Maintenance != "" {
+		line("maint", config.Maintenance)
+	} else {
+		line("#maint", "")
+	}
+	if config.LoginURL != "" {
+		line("loginurl", config.LoginURL)
+	}
+	line("meta", meta)
+	b.WriteString(endMarker)
+	b.WriteByte('\n')
+	return b.String()
+}
+
+// ServeHTTP answers GET and POST requests with the server data
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	w.Write([]byte(h.Response(r)))
+}
@@ -0,0 +1,101 @@
+package serverdata_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	enet "github.com/eikarna/gotops"
+	"github.com/eikarna/gotops/gamepacket"
+	"github.com/eikarna/gotops/serverdata"
+)
+
+// post requests the server data like a client does
+func post(t *testing.T, srv *httptest.Server) (int, gamepacket.Text) {
+	t.Helper()
+
+	form := url.Values{"version": {"4.61"}, "platform": {"0"}, "protocol": {"209"}}
+	resp, err := srv.Client().PostForm(srv.URL+serverdata.Path, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode == http.StatusOK && !strings.HasSuffix(string(body), "RTENDMARKERBS1001\n") {
+		t.Errorf("response %q doesn't end with the end marker", body)
+	}
+	return resp.StatusCode, gamepacket.ParseText(body)
+}
+
+func TestHandler(t *testing.T) {
+	handler := serverdata.NewHandler(serverdata.Config{
+		Address:  enet.NewListenAddress(enet.ENET_ADDRESS_TYPE_IPV4, 17091),
+		LoginURL: "login.example.com",
+		Meta: func(r *http.Request) string {
+			return "local-" + r.PostFormValue("platform")
+		},
+	})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	status, text := post(t, srv)
+	if status != http.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+	want := map[string]string{
+		"server":   "127.0.0.1",
+		"port":     "17091",
+		"type":     "1",
+		"loginurl": "login.example.com",
+		"meta":     "local-0",
+	}
+	for key, value := range want {
+		if got := text.Value(key); got != value {
+			t.Errorf("%s is %q, want %q", key, got, value)
+		}
+	}
+	if _, ok := text.Get("maint"); ok {
+		t.Error("maintenance is on")
+	}
+
+	handler.SetMaintenance("Back soon!")
+	if _, text = post(t, srv); text.Value("maint") != "Back soon!" {
+		t.Errorf("maint is %q", text.Value("maint"))
+	}
+	handler.SetMaintenance("")
+	if _, text = post(t, srv); text.Value("maint") != "" {
+		t.Errorf("maint is %q after maintenance", text.Value("maint"))
+	}
+}
+
+func TestHandlerAddress(t *testing.T) {
+	handler := serverdata.NewHandler(serverdata.Config{
+		Address: enet.NewAddress(enet.ENET_ADDRESS_TYPE_IPV4, "10.0.0.2", 17000),
+		Type:    2,
+	})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	_, text := post(t, srv)
+	if text.Value("server") != "10.0.0.2" || text.Value("port") != "17000" || text.Value("type") != "2" {
+		t.Errorf("response %q", text)
+	}
+	if !strings.HasPrefix(text.Value("meta"), "127.0.0.1:") {
+		t.Errorf("default meta is %q, want the request host", text.Value("meta"))
+	}
+
+	resp, err := srv.Client().Head(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("HEAD returned status %d", resp.StatusCode)
+	}
+}
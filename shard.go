@@ -0,0 +1,248 @@
+package enet
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// Errors returned by a ShardedServer
+var (
+	ErrUnknownShard = errors.New("enet: unknown shard")
+	ErrServerClosed = errors.New("enet: server closed")
+)
+
+// PeerID identifies a peer of a ShardedServer from any goroutine
+type PeerID struct {
+	Shard     int
+	ConnectID uint32
+}
+
+// ShardEvent is an event returned by a shard of a ShardedServer
+type ShardEvent struct {
+	Event
+
+	// Shard is the index of the shard the event comes from
+	Shard int
+
+	// ID identifies the peer of the event across shards
+	ID PeerID
+}
+
+// shardCommand is work queued on a shard by another goroutine
+type shardCommand func(sh *shard)
+
+// shard is a host of a ShardedServer along with the goroutine servicing it
+type shard struct {
+	index int
+	host  Host
+
+	// mu guards commands, which other goroutines append to without ever
+	// waiting for the shard
+	mu       sync.Mutex
+	commands []shardCommand
+
+	// peers and ids map connect IDs to peers and back. The ID of a peer is
+	// remembered, as peers are reset by the time their disconnect event is
+	// returned.
+	peers map[uint32]Peer
+	ids   map[Peer]uint32
+}
+
+// ShardedServer runs several hosts bound to the same port with SO_REUSEPORT,
+// each serviced by its own goroutine locked to an OS thread, so that a server
+// uses more than one core. The system spreads peers between the hosts.
+//
+// Events of every shard are passed to a single handler. The handler runs on
+// the goroutine of the shard the event comes from, so it may use the peer
+// of the event directly, and is called concurrently for events of
+// different shards: state shared between shards must be synchronized by
+// the handler. Peers of other shards are reached through Send and
+// Disconnect, which are safe to call from any goroutine and never wait for
+// a shard.
+type ShardedServer struct {
+	shards  []*shard
+	handler func(ev ShardEvent)
+
+	// ServiceTimeout is the number of milliseconds a shard waits for an
+	// event before running queued commands. Commands run at the latest
+	// after that long.
+	ServiceTimeout uint32
+
+	mu      sync.Mutex
+	stop    chan struct{}
+	wg      sync.WaitGroup
+	started bool
+	closed  bool
+}
+
+// NewShardedServer creates count hosts as described by config, with
+// SO_REUSEPORT set, passing their events to handler once started. As with
+// Host.Service, the handler must destroy the packets of receive events.
+func NewShardedServer(config HostConfig, count int, handler func(ev ShardEvent)) (*ShardedServer, error) {
+	if count < 1 {
+		return nil, fmt.Errorf("enet: %d shards", count)
+	}
+	config.Socket.ReusePort = true
+
+	server := &ShardedServer{
+		handler:        handler,
+		ServiceTimeout: 1,
+		stop:           make(chan struct{}),
+	}
+	for i := 0; i < count; i++ {
+		host, err := NewHostFromConfig(config)
+		if err != nil {
+			for _, sh := range server.shards {
+				sh.host.Destroy()
+			}
+			return nil, fmt.Errorf("enet: creating shard %d: %w", i, err)
+		}
+		server.shards = append(server.shards, &shard{
+			index: i,
+			host:  host,
+			peers: make(map[uint32]Peer),
+			ids:   make(map[Peer]uint32),
+		})
+	}
+	return server, nil
+}
+
+// Shards returns the number of shards
+func (server *ShardedServer) Shards() int {
+	return len(server.shards)
+}
+
+// Host returns the host of a shard. It must only be used from the handler
+// of events of that shard, or with the server stopped.
+func (server *ShardedServer) Host(shard int) Host {
+	return server.shards[shard].host
+}
+
+// Start starts servicing the shards
+func (server *ShardedServer) Start() {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.started || server.closed {
+		return
+	}
+	server.started = true
+	for _, sh := range server.shards {
+		server.wg.Add(1)
+		go server.run(sh)
+	}
+}
+
+// Close stops servicing the shards and destroys their hosts
+func (server *ShardedServer) Close() {
+	server.mu.Lock()
+	if server.closed {
+		server.mu.Unlock()
+		return
+	}
+	server.closed = true
+	close(server.stop)
+	server.mu.Unlock()
+
+	server.wg.Wait()
+	for _, sh := range server.shards {
+		sh.host.Destroy()
+	}
+}
+
+// run services a shard until the server is closed
+func (server *ShardedServer) run(sh *shard) {
+	defer server.wg.Done()
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	for {
+		select {
+		case <-server.stop:
+			return
+		default:
+		}
+
+		sh.mu.Lock()
+		commands := sh.commands
+		sh.commands = nil
+		sh.mu.Unlock()
+		for _, cmd := range commands {
+			cmd(sh)
+		}
+
+		ev := sh.host.Service(server.ServiceTimeout)
+		if ev.GetType() == EventNone {
+			continue
+		}
+		peer := ev.GetPeer()
+		id := PeerID{Shard: sh.index}
+		switch ev.GetType() {
+		case EventConnect:
+			id.ConnectID = peer.GetConnectID()
+			sh.peers[id.ConnectID] = peer
+			sh.ids[peer] = id.ConnectID
+		case EventDisconnect:
+			id.ConnectID = sh.ids[peer]
+			delete(sh.peers, id.ConnectID)
+			delete(sh.ids, peer)
+		default:
+			id.ConnectID = sh.ids[peer]
+		}
+
+		server.handler(ShardEvent{Event: ev, Shard: sh.index, ID: id})
+	}
+}
+
+// queue runs a command on the goroutine of a shard. It never waits for the
+// shard, so that handlers of shards sending to each other can't deadlock.
+func (server *ShardedServer) queue(shard int, cmd shardCommand) error {
+	if shard < 0 || shard >= len(server.shards) {
+		return fmt.Errorf("%w %d", ErrUnknownShard, shard)
+	}
+	select {
+	case <-server.stop:
+		return ErrServerClosed
+	default:
+	}
+	sh := server.shards[shard]
+	sh.mu.Lock()
+	sh.commands = append(sh.commands, cmd)
+	sh.mu.Unlock()
+	return nil
+}
+
+// Send queues data to be sent to a peer of any shard. Data for peers that
+// have disconnected by then is dropped.
+func (server *ShardedServer) Send(id PeerID, data []byte, channel uint8, flags PacketFlags) error {
+	data = append([]byte(nil), data...)
+	return server.queue(id.Shard, func(sh *shard) {
+		if peer, ok := sh.peers[id.ConnectID]; ok {
+			peer.SendBytes(data, channel, flags&^PacketFlagNoAllocate)
+		}
+	})
+}
+
+// Disconnect queues the disconnection of a peer of any shard
+func (server *ShardedServer) Disconnect(id PeerID, data uint32) error {
+	return server.queue(id.Shard, func(sh *shard) {
+		if peer, ok := sh.peers[id.ConnectID]; ok {
+			peer.Disconnect(data)
+		}
+	})
+}
+
+// Broadcast queues data to be sent to the connected peers of every shard
+func (server *ShardedServer) Broadcast(data []byte, channel uint8, flags PacketFlags) error {
+	data = append([]byte(nil), data...)
+	for i := range server.shards {
+		err := server.queue(i, func(sh *shard) {
+			sh.host.BroadcastBytes(data, channel, flags&^PacketFlagNoAllocate)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
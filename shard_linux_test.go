@@ -0,0 +1,183 @@
+package enet_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	enet "github.com/eikarna/gotops"
+)
+
+func TestShardedServer(t *testing.T) {
+	port := getFreePort()
+	config := enet.HostConfig{
+		AddressType:  enet.ENET_ADDRESS_TYPE_IPV4,
+		Address:      enet.NewListenAddress(enet.ENET_ADDRESS_TYPE_IPV4, port),
+		PeerCount:    16,
+		ChannelLimit: 1,
+	}
+
+	var mu sync.Mutex
+	ids := map[uint32]enet.PeerID{}
+	var disconnected []enet.PeerID
+	var server *enet.ShardedServer
+	server, err := enet.NewShardedServer(config, 2, func(ev enet.ShardEvent) {
+		if ev.ID.Shard != ev.Shard {
+			t.Errorf("event of shard %d has the ID of shard %d", ev.Shard, ev.ID.Shard)
+		}
+		switch ev.GetType() {
+		case enet.EventConnect:
+			mu.Lock()
+			ids[ev.GetData()] = ev.ID
+			mu.Unlock()
+		case enet.EventDisconnect:
+			mu.Lock()
+			disconnected = append(disconnected, ev.ID)
+			mu.Unlock()
+		case enet.EventReceive:
+			// Relay to every peer, whatever their shard.
+			mu.Lock()
+			for _, id := range ids {
+				if err := server.Send(id, ev.GetPacket().GetData(), 0, enet.PacketFlagReliable); err != nil {
+					t.Error(err)
+				}
+			}
+			mu.Unlock()
+			ev.GetPacket().Destroy()
+		}
+	})
+	if errors.Is(err, enet.ErrUnsupportedOption) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	if server.Shards() != 2 {
+		t.Fatalf("%d shards, want 2", server.Shards())
+	}
+	server.Start()
+
+	clients := make([]enet.Host, 6)
+	peers := make([]enet.Peer, len(clients))
+	for i := range clients {
+		if clients[i], err = enet.NewHost(enet.ENET_ADDRESS_TYPE_IPV4, nil, 1, 1, 0, 0); err != nil {
+			t.Fatal(err)
+		}
+		defer clients[i].Destroy()
+		if peers[i], err = clients[i].Connect(enet.NewAddress(enet.ENET_ADDRESS_TYPE_IPV4, "127.0.0.1", port), 1, uint32(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	received := make([]int, len(clients))
+	service := func(done func() bool) bool {
+		for start := time.Now(); time.Since(start) < 2*time.Second; {
+			for i, client := range clients {
+				if ev := client.Service(1); ev.GetType() == enet.EventReceive {
+					received[i]++
+					ev.GetPacket().Destroy()
+				}
+			}
+			if done() {
+				return true
+			}
+		}
+		return false
+	}
+	if !service(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(ids) == len(clients)
+	}) {
+		t.Fatal("clients didn't connect")
+	}
+
+	peers[0].SendBytes([]byte("hello"), 0, enet.PacketFlagReliable)
+	if !service(func() bool {
+		for _, n := range received {
+			if n < 1 {
+				return false
+			}
+		}
+		return true
+	}) {
+		t.Fatalf("relayed message not received by every client: %v", received)
+	}
+
+	if err := server.Broadcast([]byte("all"), 0, enet.PacketFlagReliable); err != nil {
+		t.Fatal(err)
+	}
+	if !service(func() bool {
+		for _, n := range received {
+			if n < 2 {
+				return false
+			}
+		}
+		return true
+	}) {
+		t.Fatalf("broadcast not received by every client: %v", received)
+	}
+
+	// Disconnects carry the ID the peer connected with, whichever side
+	// disconnects.
+	peers[1].Disconnect(0)
+	mu.Lock()
+	byServer := ids[2]
+	mu.Unlock()
+	if err := server.Disconnect(byServer, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !service(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(disconnected) == 2
+	}) {
+		t.Fatalf("%d disconnects handled, want 2", len(disconnected))
+	}
+	mu.Lock()
+	for _, want := range []enet.PeerID{ids[1], byServer} {
+		found := false
+		for _, id := range disconnected {
+			found = found || id == want
+		}
+		if !found {
+			t.Errorf("no disconnect of %+v in %+v", want, disconnected)
+		}
+	}
+	mu.Unlock()
+
+	if err := server.Send(enet.PeerID{Shard: 2}, nil, 0, 0); !errors.Is(err, enet.ErrUnknownShard) {
+		t.Errorf("sending to shard 2 returned %v", err)
+	}
+}
+
+func TestShardedServerNotStarted(t *testing.T) {
+	config := enet.HostConfig{
+		AddressType:  enet.ENET_ADDRESS_TYPE_IPV4,
+		Address:      enet.NewListenAddress(enet.ENET_ADDRESS_TYPE_IPV4, getFreePort()),
+		PeerCount:    1,
+		ChannelLimit: 1,
+	}
+	server, err := enet.NewShardedServer(config, 1, func(ev enet.ShardEvent) {})
+	if errors.Is(err, enet.ErrUnsupportedOption) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Commands pile up without a shard running them, but never block.
+	for i := 0; i < 2000; i++ {
+		if err := server.Send(enet.PeerID{}, []byte("queued"), 0, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server.Close()
+	if err := server.Send(enet.PeerID{}, nil, 0, 0); !errors.Is(err, enet.ErrServerClosed) {
+		t.Errorf("sending after Close returned %v", err)
+	}
+	server.Start()
+	server.Close()
+}
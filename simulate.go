@@ -0,0 +1,267 @@
+package enet
+
+import (
+	"errors"
+	"math/rand"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Conditions describe how a simulated link degrades traffic in one
+// direction. The zero value leaves traffic untouched.
+type Conditions struct {
+	// Latency delays every datagram
+	Latency time.Duration
+
+	// Jitter adds a random delay between 0 and Jitter to every datagram,
+	// which may reorder them
+	Jitter time.Duration
+
+	// Loss is the probability, between 0 and 1, of dropping a datagram
+	Loss float64
+
+	// Duplicate is the probability, between 0 and 1, of delivering a
+	// datagram twice
+	Duplicate float64
+
+	// Bandwidth caps the throughput in bytes per second, 0 for unlimited.
+	// Datagrams queue up behind each other once the cap is reached.
+	Bandwidth uint32
+}
+
+// Errors returned by SimulateNetwork
+var (
+	ErrSimulationUnsupported = errors.New("enet: network simulation needs the pure-Go backend")
+	ErrSimulatorAttached     = errors.New("enet: simulator is already attached to a host")
+)
+
+// simDirection is the direction of a datagram through a Simulator
+type simDirection int
+
+const (
+	simIncoming simDirection = iota
+	simOutgoing
+)
+
+// simDatagram is a datagram held back by a Simulator. addr is the address
+// of the remote end.
+type simDatagram struct {
+	data []byte
+	addr netip.AddrPort
+	at   time.Time
+}
+
+// Simulator degrades the traffic of a real host to mimic bad networks, such
+// as players on mobile links. Conditions apply to the whole host or to
+// single peers and can be changed or toggled at any time.
+//
+// Attach a simulator to a host with SimulateNetwork. Held back datagrams are
+// released when the host is serviced, so hosts must be serviced regularly.
+// Only the pure-Go backend can be simulated: enet has no hook on the send
+// path of the cgo backend.
+type Simulator struct {
+	mu         sync.Mutex
+	enabled    bool
+	attached   bool
+	random     *rand.Rand
+	conditions [2]Conditions
+	peers      map[netip.AddrPort][2]Conditions
+	busy       [2]map[netip.AddrPort]time.Time
+	queues     [2][]simDatagram
+}
+
+// NewSimulator creates an enabled simulator leaving traffic untouched until
+// conditions are set. The seed makes loss, jitter and duplication
+// reproducible.
+func NewSimulator(seed int64) *Simulator {
+	return &Simulator{
+		enabled: true,
+		random:  rand.New(rand.NewSource(seed)),
+		peers:   make(map[netip.AddrPort][2]Conditions),
+		busy:    [2]map[netip.AddrPort]time.Time{make(map[netip.AddrPort]time.Time), make(map[netip.AddrPort]time.Time)},
+	}
+}
+
+// SetEnabled toggles the simulation. While disabled, traffic flows
+// untouched, datagrams already held back are still released on time.
+func (sim *Simulator) SetEnabled(enabled bool) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	sim.enabled = enabled
+}
+
+// Enabled returns whether the simulation is enabled
+func (sim *Simulator) Enabled() bool {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	return sim.enabled
+}
+
+// SetConditions sets the conditions of the traffic received and sent by
+// the host, for peers without their own conditions
+func (sim *Simulator) SetConditions(incoming, outgoing Conditions) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	sim.conditions = [2]Conditions{incoming, outgoing}
+}
+
+// SetPeerConditions sets the conditions of the traffic received from and
+// sent to the peer at addr, overriding the conditions of the host
+func (sim *Simulator) SetPeerConditions(addr Address, incoming, outgoing Conditions) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	sim.peers[addr.AddrPort()] = [2]Conditions{incoming, outgoing}
+}
+
+// ResetPeerConditions makes the peer at addr use the conditions of the
+// host again
+func (sim *Simulator) ResetPeerConditions(addr Address) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	key := addr.AddrPort()
+	delete(sim.peers, key)
+	delete(sim.busy[simIncoming], key)
+	delete(sim.busy[simOutgoing], key)
+}
+
+// SimulateNetwork attaches a simulator to a host created by NewHost, or
+// detaches the simulator of the host if sim is nil. A simulator may only be
+// attached to one host at a time, attaching it to another fails with
+// ErrSimulatorAttached. Datagrams still held back when a simulator is
+// detached are delivered right away. With the cgo backend, attaching fails
+// with ErrSimulationUnsupported.
+func SimulateNetwork(host Host, sim *Simulator) error {
+	h, ok := host.(*enetHost)
+	if !ok {
+		return errors.New("couldn't simulate the network of a host not created by NewHost")
+	}
+	return h.simulate(sim)
+}
+
+// attach marks the simulator as attached to a host
+func (sim *Simulator) attach() error {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	if sim.attached {
+		return ErrSimulatorAttached
+	}
+	sim.attached = true
+	return nil
+}
+
+// detach marks the simulator as detached and returns the datagrams it held
+// back in each direction, in order of due time
+func (sim *Simulator) detach() [2][]simDatagram {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	queues := sim.queues
+	sim.attached = false
+	sim.queues = [2][]simDatagram{}
+	return queues
+}
+
+// submit passes a datagram to or from the peer at addr through the
+// simulated link, holding back copies of it until they are due. It
+// returns false if the simulation is disabled, in which case the datagram
+// should be handled as usual.
+func (sim *Simulator) submit(dir simDirection, addr netip.AddrPort, data []byte, now time.Time) bool {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	if !sim.enabled {
+		return false
+	}
+
+	key := addr
+	conditions, ok := sim.peers[key]
+	if !ok {
+		key = netip.AddrPort{}
+		conditions = sim.conditions
+	}
+	cond := conditions[dir]
+
+	if cond.Loss > 0 && sim.random.Float64() < cond.Loss {
+		return true
+	}
+
+	// Datagrams leave the link one after the other at the capped rate.
+	sent := now
+	if cond.Bandwidth > 0 {
+		if busy := sim.busy[dir][key]; busy.After(sent) {
+			sent = busy
+		}
+		sent = sent.Add(time.Duration(len(data)) * time.Second / time.Duration(cond.Bandwidth))
+		sim.busy[dir][key] = sent
+	}
+
+	copies := 1
+	if cond.Duplicate > 0 && sim.random.Float64() < cond.Duplicate {
+		copies = 2
+	}
+	for i := 0; i < copies; i++ {
+		at := sent.Add(cond.Latency)
+		if cond.Jitter > 0 {
+			at = at.Add(time.Duration(sim.random.Int63n(int64(cond.Jitter) + 1)))
+		}
+		sim.push(dir, simDatagram{
+			data: append([]byte(nil), data...),
+			addr: addr,
+			at:   at,
+		})
+	}
+	return true
+}
+
+// push queues a datagram in order of due time. sim.mu must be held.
+func (sim *Simulator) push(dir simDirection, d simDatagram) {
+	queue := sim.queues[dir]
+	i := sort.Search(len(queue), func(i int) bool {
+		return queue[i].at.After(d.at)
+	})
+	queue = append(queue, simDatagram{})
+	copy(queue[i+1:], queue[i:])
+	queue[i] = d
+	sim.queues[dir] = queue
+}
+
+// next pops the next datagram due by now
+func (sim *Simulator) next(dir simDirection, now time.Time) (simDatagram, bool) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	queue := sim.queues[dir]
+	if len(queue) == 0 || queue[0].at.After(now) {
+		return simDatagram{}, false
+	}
+	d := queue[0]
+	queue[0] = simDatagram{}
+	sim.queues[dir] = queue[1:]
+	return d, true
+}
+
+// nextDue returns when the next held back datagram is due
+func (sim *Simulator) nextDue(dir simDirection) (time.Time, bool) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	if len(sim.queues[dir]) == 0 {
+		return time.Time{}, false
+	}
+	return sim.queues[dir][0].at, true
+}
+
+// wait shortens a wait from now so that it ends when the next held back
+// datagram is due
+func (sim *Simulator) wait(now time.Time, wait time.Duration) time.Duration {
+	for _, dir := range []simDirection{simIncoming, simOutgoing} {
+		if due, ok := sim.nextDue(dir); ok && due.Sub(now) < wait {
+			wait = due.Sub(now)
+		}
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return wait
+}
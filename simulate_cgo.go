@@ -0,0 +1,13 @@
+//go:build cgo && !purego
+
+package enet
+
+// simulate fails, as enet has no hook on its send path to hold datagrams
+// back. Simulating only the receive path would quietly ignore half of the
+// conditions.
+func (host *enetHost) simulate(sim *Simulator) error {
+	if sim == nil {
+		return nil
+	}
+	return ErrSimulationUnsupported
+}
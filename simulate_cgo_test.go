@@ -0,0 +1,25 @@
+//go:build cgo && !purego
+
+package enet_test
+
+import (
+	"errors"
+	"testing"
+
+	enet "github.com/eikarna/gotops"
+)
+
+func TestSimulationUnsupported(t *testing.T) {
+	host, err := enet.NewHost(enet.ENET_ADDRESS_TYPE_IPV4, nil, 1, 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer host.Destroy()
+
+	if err := enet.SimulateNetwork(host, enet.NewSimulator(1)); !errors.Is(err, enet.ErrSimulationUnsupported) {
+		t.Errorf("attaching a simulator: %v", err)
+	}
+	if err := enet.SimulateNetwork(host, nil); err != nil {
+		t.Errorf("detaching no simulator: %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+//go:build !cgo || purego
+
+package enet
+
+import (
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/eikarna/gotops/internal/protocol"
+)
+
+// simConn is the socket of a host, passing datagrams through the attached
+// Simulator if any
+type simConn struct {
+	protocol.Conn
+	sim atomic.Pointer[Simulator]
+
+	// pending holds the received datagrams a simulator still held back
+	// when it was detached, delivered before any other
+	mu      sync.Mutex
+	pending []simDatagram
+}
+
+// ReadFrom returns the next received datagram that is due, waiting up to
+// timeout for one. Held back outgoing datagrams that are due are sent
+// meanwhile.
+func (conn *simConn) ReadFrom(b []byte, timeout time.Duration) (int, netip.AddrPort, error) {
+	if d, ok := conn.nextPending(); ok {
+		return copy(b, d.data), d.addr, nil
+	}
+	sim := conn.sim.Load()
+	if sim == nil {
+		return conn.Conn.ReadFrom(b, timeout)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		now := time.Now()
+		conn.flush(sim, now)
+		if d, ok := sim.next(simIncoming, now); ok {
+			return copy(b, d.data), d.addr, nil
+		}
+
+		n, addr, err := conn.Conn.ReadFrom(b, sim.wait(now, deadline.Sub(now)))
+		if err == protocol.ErrTimeout {
+			if !time.Now().Before(deadline) {
+				return 0, netip.AddrPort{}, err
+			}
+			continue
+		} else if err != nil {
+			return 0, netip.AddrPort{}, err
+		}
+		if !sim.submit(simIncoming, addr, b[:n], time.Now()) {
+			return n, addr, nil
+		}
+	}
+}
+
+// WriteTo sends a datagram, or holds it back until it is due
+func (conn *simConn) WriteTo(b []byte, addr netip.AddrPort) error {
+	sim := conn.sim.Load()
+	if sim == nil {
+		return conn.Conn.WriteTo(b, addr)
+	}
+
+	now := time.Now()
+	if !sim.submit(simOutgoing, addr, b, now) {
+		return conn.Conn.WriteTo(b, addr)
+	}
+	conn.flush(sim, now)
+	return nil
+}
+
+// flush sends the held back outgoing datagrams that are due
+func (conn *simConn) flush(sim *Simulator, now time.Time) {
+	for {
+		d, ok := sim.next(simOutgoing, now)
+		if !ok {
+			return
+		}
+		conn.Conn.WriteTo(d.data, d.addr)
+	}
+}
+
+// nextPending pops the next datagram left by a detached simulator
+func (conn *simConn) nextPending() (simDatagram, bool) {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if len(conn.pending) == 0 {
+		return simDatagram{}, false
+	}
+	d := conn.pending[0]
+	conn.pending = conn.pending[1:]
+	return d, true
+}
+
+// simulate attaches a simulator to the host, detaching the previous one
+func (host *enetHost) simulate(sim *Simulator) error {
+	conn := host.conn
+	old := conn.sim.Load()
+	if old == sim {
+		return nil
+	}
+	if sim != nil {
+		if err := sim.attach(); err != nil {
+			return err
+		}
+	}
+	conn.sim.Store(sim)
+	if old != nil {
+		// Nothing held back is lost: outgoing datagrams are sent now and
+		// received ones are read next.
+		queues := old.detach()
+		for _, d := range queues[simOutgoing] {
+			conn.Conn.WriteTo(d.data, d.addr)
+		}
+		conn.mu.Lock()
+		conn.pending = append(conn.pending, queues[simIncoming]...)
+		conn.mu.Unlock()
+	}
+	return nil
+}
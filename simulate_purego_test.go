@@ -0,0 +1,165 @@
+//go:build !cgo || purego
+
+package enet_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	enet "github.com/eikarna/gotops"
+)
+
+// simulatedPair creates a server with a simulator attached and a client
+// connecting to it, returning how long the server took to see the client
+// connect, or 0 if it didn't within limit
+func simulatedPair(t *testing.T, sim *enet.Simulator, limit time.Duration) time.Duration {
+	t.Helper()
+
+	port := getFreePort()
+	server, err := enet.NewHost(enet.ENET_ADDRESS_TYPE_IPV4, enet.NewListenAddress(enet.ENET_ADDRESS_TYPE_IPV4, port), 10, 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Destroy()
+	if err := enet.SimulateNetwork(server, sim); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := enet.NewHost(enet.ENET_ADDRESS_TYPE_IPV4, nil, 1, 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Destroy()
+
+	start := time.Now()
+	if _, err := client.Connect(enet.NewAddress(enet.ENET_ADDRESS_TYPE_IPV4, "127.0.0.1", port), 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	for time.Since(start) < limit {
+		client.Service(1)
+		if server.Service(1).GetType() == enet.EventConnect {
+			return time.Since(start)
+		}
+	}
+	return 0
+}
+
+func TestSimulatorLatency(t *testing.T) {
+	sim := enet.NewSimulator(1)
+	sim.SetConditions(enet.Conditions{Latency: 100 * time.Millisecond}, enet.Conditions{})
+
+	elapsed := simulatedPair(t, sim, 2*time.Second)
+	if elapsed == 0 {
+		t.Fatal("client didn't connect")
+	}
+	if elapsed < 100*time.Millisecond {
+		t.Errorf("client connected after %v despite 100ms of latency", elapsed)
+	}
+}
+
+func TestSimulatorLoss(t *testing.T) {
+	sim := enet.NewSimulator(1)
+	sim.SetConditions(enet.Conditions{Loss: 1}, enet.Conditions{})
+	if elapsed := simulatedPair(t, sim, 300*time.Millisecond); elapsed != 0 {
+		t.Fatalf("client connected after %v despite losing everything", elapsed)
+	}
+
+	sim.SetEnabled(false)
+	if elapsed := simulatedPair(t, sim, 2*time.Second); elapsed == 0 {
+		t.Fatal("client didn't connect with the simulation disabled")
+	}
+}
+
+func TestSimulateNetworkUnsupportedHost(t *testing.T) {
+	var host enet.Host
+	if err := enet.SimulateNetwork(host, enet.NewSimulator(1)); err == nil {
+		t.Error("expected an error simulating the network of a foreign host")
+	}
+}
+
+func TestSimulatorAttachedOnce(t *testing.T) {
+	var hosts [2]enet.Host
+	for i := range hosts {
+		host, err := enet.NewHost(enet.ENET_ADDRESS_TYPE_IPV4, nil, 1, 1, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer host.Destroy()
+		hosts[i] = host
+	}
+
+	sim := enet.NewSimulator(1)
+	if err := enet.SimulateNetwork(hosts[0], sim); err != nil {
+		t.Fatal(err)
+	}
+	if err := enet.SimulateNetwork(hosts[0], sim); err != nil {
+		t.Errorf("attaching a simulator to its host again: %v", err)
+	}
+	if err := enet.SimulateNetwork(hosts[1], sim); !errors.Is(err, enet.ErrSimulatorAttached) {
+		t.Errorf("attaching a simulator to a second host: %v", err)
+	}
+	if err := enet.SimulateNetwork(hosts[0], nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := enet.SimulateNetwork(hosts[1], sim); err != nil {
+		t.Errorf("attaching a detached simulator: %v", err)
+	}
+}
+
+// TestSimulatorDetachDelivers checks that the datagrams a simulator holds
+// back are delivered, not dropped, when it is detached
+func TestSimulatorDetachDelivers(t *testing.T) {
+	long := enet.Conditions{Latency: time.Hour}
+	for _, tc := range []struct {
+		name               string
+		incoming, outgoing enet.Conditions
+		onServer           bool
+	}{
+		{"incoming", long, enet.Conditions{}, true},
+		{"outgoing", enet.Conditions{}, long, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			port := getFreePort()
+			server, err := enet.NewHost(enet.ENET_ADDRESS_TYPE_IPV4, enet.NewListenAddress(enet.ENET_ADDRESS_TYPE_IPV4, port), 1, 1, 0, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer server.Destroy()
+			client, err := enet.NewHost(enet.ENET_ADDRESS_TYPE_IPV4, nil, 1, 1, 0, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Destroy()
+
+			simulated := client
+			if tc.onServer {
+				simulated = server
+			}
+			sim := enet.NewSimulator(1)
+			sim.SetConditions(tc.incoming, tc.outgoing)
+			if err := enet.SimulateNetwork(simulated, sim); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := client.Connect(enet.NewAddress(enet.ENET_ADDRESS_TYPE_IPV4, "127.0.0.1", port), 1, 0); err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 20; i++ {
+				client.Service(1)
+				server.Service(1)
+			}
+
+			// The connect command is held back, and only sent once.
+			if err := enet.SimulateNetwork(simulated, nil); err != nil {
+				t.Fatal(err)
+			}
+			for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); {
+				client.Service(1)
+				if server.Service(1).GetType() == enet.EventConnect {
+					return
+				}
+			}
+			t.Fatal("the held back connect wasn't delivered")
+		})
+	}
+}
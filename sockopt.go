@@ -0,0 +1,63 @@
+package enet
+
+import "errors"
+
+// Errors returned when configuring the socket of a host
+var (
+	ErrNoSocket          = errors.New("enet: host has no socket")
+	ErrUnsupportedOption = errors.New("enet: socket option isn't supported on this platform")
+)
+
+// SocketOptions are options of the UDP socket of a host. Zero values leave
+// the system defaults.
+type SocketOptions struct {
+	// ReceiveBuffer and SendBuffer are the sizes of the socket buffers in
+	// bytes. Raising ReceiveBuffer avoids dropping bursts of datagrams.
+	ReceiveBuffer int
+	SendBuffer    int
+
+	// TTL is the time to live, or hop limit, of outgoing datagrams
+	TTL int
+
+	// DSCP is the differentiated services code point outgoing datagrams
+	// are marked with, from 0 to 63, such as 46 for expedited forwarding
+	DSCP uint8
+
+	// ReusePort lets several hosts bind to the same port, the system
+	// spreading incoming peers between them
+	ReusePort bool
+}
+
+// HostConfig describes a host to create with NewHostFromConfig. Its fields
+// match the arguments of NewHost.
+type HostConfig struct {
+	AddressType       ENetAddressType
+	Address           Address
+	PeerCount         uint64
+	ChannelLimit      uint64
+	IncomingBandwidth uint32
+	OutgoingBandwidth uint32
+
+	// Socket holds the options of the socket of the host
+	Socket SocketOptions
+
+	// ReceivedEvents makes the host return *ReceivedEvent values, as
+	// Host.UsingReceivedEvents
+	ReceivedEvents bool
+}
+
+// isZero tells whether no option is set
+func (opts *SocketOptions) isZero() bool {
+	return *opts == SocketOptions{}
+}
+
+// validate checks the ranges of the options
+func (opts *SocketOptions) validate() error {
+	if opts.DSCP > 63 {
+		return errors.New("enet: DSCP must be between 0 and 63")
+	}
+	if opts.ReceiveBuffer < 0 || opts.SendBuffer < 0 || opts.TTL < 0 || opts.TTL > 255 {
+		return errors.New("enet: invalid socket option")
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+//go:build cgo && !purego
+
+package enet
+
+// #include <enet/enet.h>
+import "C"
+import (
+	"fmt"
+	"net/netip"
+)
+
+// setHostSocketOptions sets the options of the sockets of a host. With
+// ReusePort, the sockets are replaced by sockets bound to addr with the
+// option set, as it only applies to sockets bound after it is set.
+func setHostSocketOptions(host *C.ENetHost, addr netip.AddrPort, opts SocketOptions) error {
+	sockets := []struct {
+		socket *C.ENetSocket
+		ipv6   bool
+	}{
+		{&host.socket4, false},
+		{&host.socket6, true},
+	}
+	for _, s := range sockets {
+		if *s.socket == C.ENET_SOCKET_NULL {
+			continue
+		}
+
+		if opts.ReusePort {
+			bind := netip.AddrPortFrom(netip.IPv4Unspecified(), addr.Port())
+			if s.ipv6 {
+				bind = netip.AddrPortFrom(netip.IPv6Unspecified(), addr.Port())
+			}
+			if addr.Addr().IsValid() && !addr.Addr().IsUnspecified() && addr.Addr().Is4() != s.ipv6 {
+				bind = addr
+			}
+			fd, err := bindSocket(bind, opts)
+			if err != nil {
+				return err
+			}
+			C.enet_socket_destroy(*s.socket)
+			*s.socket = C.ENetSocket(fd)
+			C.enet_socket_set_option(*s.socket, C.ENET_SOCKOPT_NONBLOCK, 1)
+			C.enet_socket_set_option(*s.socket, C.ENET_SOCKOPT_BROADCAST, 1)
+			continue
+		}
+
+		for _, opt := range []struct {
+			name  string
+			value int
+			cOpt  C.ENetSocketOption
+		}{
+			{"receive buffer", opts.ReceiveBuffer, C.ENET_SOCKOPT_RCVBUF},
+			{"send buffer", opts.SendBuffer, C.ENET_SOCKOPT_SNDBUF},
+			{"TTL", opts.TTL, C.ENET_SOCKOPT_TTL},
+		} {
+			if opt.value > 0 && C.enet_socket_set_option(*s.socket, opt.cOpt, C.int(opt.value)) < 0 {
+				return fmt.Errorf("enet: setting the %s failed", opt.name)
+			}
+		}
+		if err := setSocketOptions(int(*s.socket), s.ipv6, SocketOptions{DSCP: opts.DSCP}); err != nil {
+			return err
+		}
+	}
+
+	if opts.ReusePort {
+		host.address.port = C.enet_uint16(addr.Port())
+	}
+	return nil
+}
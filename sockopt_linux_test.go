@@ -0,0 +1,121 @@
+package enet_test
+
+import (
+	"syscall"
+	"testing"
+
+	enet "github.com/eikarna/gotops"
+)
+
+func TestSocketOptions(t *testing.T) {
+	port := getFreePort()
+	config := enet.HostConfig{
+		AddressType:  enet.ENET_ADDRESS_TYPE_IPV4,
+		Address:      enet.NewListenAddress(enet.ENET_ADDRESS_TYPE_IPV4, port),
+		PeerCount:    4,
+		ChannelLimit: 1,
+		Socket: enet.SocketOptions{
+			ReceiveBuffer: 1 << 16,
+			TTL:           32,
+			DSCP:          46,
+			ReusePort:     true,
+		},
+	}
+	host, err := enet.NewHostFromConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer host.Destroy()
+
+	fd, err := host.SocketFD()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, opt := range []struct {
+		name       string
+		level, opt int
+		want       int
+	}{
+		{"SO_REUSEPORT", syscall.SOL_SOCKET, 0xf, 1},
+		{"IP_TTL", syscall.IPPROTO_IP, syscall.IP_TTL, 32},
+		{"IP_TOS", syscall.IPPROTO_IP, syscall.IP_TOS, 46 << 2},
+	} {
+		got, err := syscall.GetsockoptInt(int(fd), opt.level, opt.opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != opt.want {
+			t.Errorf("%s is %d, want %d", opt.name, got, opt.want)
+		}
+	}
+	if got, _ := syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_RCVBUF); got < 1<<16 {
+		t.Errorf("SO_RCVBUF is %d, want at least %d", got, 1<<16)
+	}
+
+	// A second host may bind to the same port.
+	second, err := enet.NewHostFromConfig(config)
+	if err != nil {
+		t.Fatalf("binding a second host to the port: %v", err)
+	}
+	second.Destroy()
+
+	config.Socket = enet.SocketOptions{DSCP: 64}
+	if _, err := enet.NewHostFromConfig(config); err == nil {
+		t.Error("creating a host with an invalid DSCP succeeded")
+	}
+}
+
+// TestSocketOptionsDualStack checks that the options of a dual-stack
+// socket are set for both families
+func TestSocketOptionsDualStack(t *testing.T) {
+	host, err := enet.NewHostFromConfig(enet.HostConfig{
+		AddressType:  enet.ENET_ADDRESS_TYPE_ANY,
+		PeerCount:    1,
+		ChannelLimit: 1,
+		Socket:       enet.SocketOptions{TTL: 32, DSCP: 46},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer host.Destroy()
+
+	fd, err := host.SocketFD()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sa, err := syscall.Getsockname(int(fd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	type option struct {
+		name       string
+		level, opt int
+		want       int
+	}
+	want := []option{
+		{"IP_TTL", syscall.IPPROTO_IP, syscall.IP_TTL, 32},
+		{"IP_TOS", syscall.IPPROTO_IP, syscall.IP_TOS, 46 << 2},
+	}
+	if _, ok := sa.(*syscall.SockaddrInet6); ok {
+		v6only, err := syscall.GetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_V6ONLY)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v6only != 0 {
+			want = want[:0]
+		}
+		want = append(want,
+			option{"IPV6_UNICAST_HOPS", syscall.IPPROTO_IPV6, syscall.IPV6_UNICAST_HOPS, 32},
+			option{"IPV6_TCLASS", syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS, 46 << 2},
+		)
+	}
+	for _, opt := range want {
+		got, err := syscall.GetsockoptInt(int(fd), opt.level, opt.opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != opt.want {
+			t.Errorf("%s is %d, want %d", opt.name, got, opt.want)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package enet
+
+import "net/netip"
+
+// setSocketOptions reports that socket options can't be set on this
+// platform, unless none are asked for
+func setSocketOptions(fd int, ipv6 bool, opts SocketOptions) error {
+	if opts.isZero() {
+		return nil
+	}
+	return ErrUnsupportedOption
+}
+
+// bindSocket reports that sockets can't be created on this platform
+func bindSocket(addr netip.AddrPort, opts SocketOptions) (int, error) {
+	return -1, ErrUnsupportedOption
+}
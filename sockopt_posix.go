@@ -0,0 +1,115 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package enet
+
+import (
+	"net/netip"
+	"os"
+	"syscall"
+)
+
+// setSocketOptions sets options on a socket. Options of dual-stack IPv6
+// sockets are also set for IPv4, for their IPv4 peers.
+func setSocketOptions(fd int, ipv6 bool, opts SocketOptions) error {
+	set := func(level, name, value int) error {
+		return os.NewSyscallError("setsockopt", syscall.SetsockoptInt(fd, level, name, value))
+	}
+	dualStack := false
+	if ipv6 && (opts.TTL > 0 || opts.DSCP > 0) {
+		v6only, err := syscall.GetsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_V6ONLY)
+		if err != nil {
+			return os.NewSyscallError("getsockopt", err)
+		}
+		dualStack = v6only == 0
+	}
+
+	if opts.ReusePort {
+		if err := set(syscall.SOL_SOCKET, soReusePort, 1); err != nil {
+			return err
+		}
+	}
+	if opts.ReceiveBuffer > 0 {
+		if err := set(syscall.SOL_SOCKET, syscall.SO_RCVBUF, opts.ReceiveBuffer); err != nil {
+			return err
+		}
+	}
+	if opts.SendBuffer > 0 {
+		if err := set(syscall.SOL_SOCKET, syscall.SO_SNDBUF, opts.SendBuffer); err != nil {
+			return err
+		}
+	}
+	if opts.TTL > 0 {
+		if ipv6 {
+			if err := set(syscall.IPPROTO_IPV6, syscall.IPV6_UNICAST_HOPS, opts.TTL); err != nil {
+				return err
+			}
+			if dualStack {
+				if err := set(syscall.IPPROTO_IP, syscall.IP_TTL, opts.TTL); err != nil {
+					return err
+				}
+			}
+		} else if err := set(syscall.IPPROTO_IP, syscall.IP_TTL, opts.TTL); err != nil {
+			return err
+		}
+	}
+	if opts.DSCP > 0 {
+		tos := int(opts.DSCP) << 2
+		if ipv6 {
+			if err := set(syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS, tos); err != nil {
+				return err
+			}
+			if dualStack {
+				if err := set(syscall.IPPROTO_IP, syscall.IP_TOS, tos); err != nil {
+					return err
+				}
+			}
+		} else if err := set(syscall.IPPROTO_IP, syscall.IP_TOS, tos); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bindSocket creates a non-blocking UDP socket with the given options and
+// binds it. IPv6 sockets only handle IPv6, like the IPv6 socket of a C host.
+func bindSocket(addr netip.AddrPort, opts SocketOptions) (int, error) {
+	ipv6 := !addr.Addr().Is4()
+	family := syscall.AF_INET
+	if ipv6 {
+		family = syscall.AF_INET6
+	}
+
+	syscall.ForkLock.RLock()
+	fd, err := syscall.Socket(family, syscall.SOCK_DGRAM, syscall.IPPROTO_UDP)
+	if err == nil {
+		syscall.CloseOnExec(fd)
+	}
+	syscall.ForkLock.RUnlock()
+	if err != nil {
+		return -1, os.NewSyscallError("socket", err)
+	}
+
+	if ipv6 {
+		err = os.NewSyscallError("setsockopt", syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_V6ONLY, 1))
+	}
+	if err == nil {
+		err = setSocketOptions(fd, ipv6, opts)
+	}
+	if err == nil {
+		var sa syscall.Sockaddr
+		if ipv6 {
+			sa = &syscall.SockaddrInet6{Port: int(addr.Port()), Addr: addr.Addr().As16()}
+		} else {
+			sa = &syscall.SockaddrInet4{Port: int(addr.Port()), Addr: addr.Addr().As4()}
+		}
+		err = os.NewSyscallError("bind", syscall.Bind(fd, sa))
+	}
+	if err == nil {
+		err = os.NewSyscallError("setnonblock", syscall.SetNonblock(fd, true))
+	}
+	if err != nil {
+		syscall.Close(fd)
+		return -1, err
+	}
+	return fd, nil
+}
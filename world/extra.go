@@ -0,0 +1,139 @@
+package world
+
+import "fmt"
+
+// ExtraType is the type of the extra data of a tile
+type ExtraType uint8
+
+// Extra data types
+const (
+	ExtraDoor     ExtraType = 1
+	ExtraSign     ExtraType = 2
+	ExtraLock     ExtraType = 3
+	ExtraSeed     ExtraType = 4
+	ExtraDice     ExtraType = 8
+	ExtraProvider ExtraType = 9
+)
+
+// TileExtra is the extra data of a tile. It is implemented by Door, Sign,
+// Lock, Seed, Dice and Provider.
+type TileExtra interface {
+	ExtraType() ExtraType
+	encode(e *encoder)
+}
+
+// Door is the extra data of doors and entrances
+type Door struct {
+	Label string
+	Flags uint8
+}
+
+// Sign is the extra data of signs
+type Sign struct {
+	Text string
+}
+
+// Lock is the extra data of locks
+type Lock struct {
+	Flags        uint8
+	Owner        uint32
+	Access       []uint32
+	MinimumLevel uint8
+	Reserved     [7]byte
+}
+
+// Seed is the extra data of trees
+type Seed struct {
+	// Age is the number of seconds since the seed was planted
+	Age    uint32
+	Fruits uint8
+}
+
+// Dice is the extra data of dice blocks
+type Dice struct {
+	Symbol uint8
+}
+
+// Provider is the extra data of providers, such as chickens or cows
+type Provider struct {
+	// Age is the number of seconds since the provider was last harvested
+	Age uint32
+}
+
+func (*Door) ExtraType() ExtraType     { return ExtraDoor }
+func (*Sign) ExtraType() ExtraType     { return ExtraSign }
+func (*Lock) ExtraType() ExtraType     { return ExtraLock }
+func (*Seed) ExtraType() ExtraType     { return ExtraSeed }
+func (*Dice) ExtraType() ExtraType     { return ExtraDice }
+func (*Provider) ExtraType() ExtraType { return ExtraProvider }
+
+func (door *Door) encode(e *encoder) {
+	e.string(door.Label)
+	e.uint8(door.Flags)
+}
+
+func (sign *Sign) encode(e *encoder) {
+	e.string(sign.Text)
+	e.uint32(0xffffffff)
+}
+
+func (lock *Lock) encode(e *encoder) {
+	e.uint8(lock.Flags)
+	e.uint32(lock.Owner)
+	e.uint32(uint32(len(lock.Access)))
+	for _, uid := range lock.Access {
+		e.uint32(uid)
+	}
+	e.uint8(lock.MinimumLevel)
+	e.bytes(lock.Reserved[:])
+}
+
+func (seed *Seed) encode(e *encoder) {
+	e.uint32(seed.Age)
+	e.uint8(seed.Fruits)
+}
+
+func (dice *Dice) encode(e *encoder) {
+	e.uint8(dice.Symbol)
+}
+
+func (provider *Provider) encode(e *encoder) {
+	e.uint32(provider.Age)
+}
+
+// decodeExtra reads the extra data of a tile
+func decodeExtra(d *decoder) (TileExtra, error) {
+	typ := ExtraType(d.uint8())
+	switch typ {
+	case ExtraDoor:
+		return &Door{Label: d.string(), Flags: d.uint8()}, d.err
+	case ExtraSign:
+		sign := &Sign{Text: d.string()}
+		d.uint32()
+		return sign, d.err
+	case ExtraLock:
+		lock := &Lock{Flags: d.uint8(), Owner: d.uint32()}
+		count := d.uint32()
+		if d.err == nil && uint64(count)*4 > uint64(len(d.data)) {
+			return nil, ErrTruncated
+		}
+		lock.Access = make([]uint32, count)
+		for i := range lock.Access {
+			lock.Access[i] = d.uint32()
+		}
+		lock.MinimumLevel = d.uint8()
+		copy(lock.Reserved[:], d.next(len(lock.Reserved)))
+		return lock, d.err
+	case ExtraSeed:
+		return &Seed{Age: d.uint32(), Fruits: d.uint8()}, d.err
+	case ExtraDice:
+		return &Dice{Symbol: d.uint8()}, d.err
+	case ExtraProvider:
+		return &Provider{Age: d.uint32()}, d.err
+	default:
+		if d.err != nil {
+			return nil, d.err
+		}
+		return nil, fmt.Errorf("%w %d", ErrUnknownExtra, typ)
+	}
+}
@@ -0,0 +1,266 @@
+package world
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	enet "github.com/eikarna/gotops"
+	"github.com/eikarna/gotops/gamepacket"
+)
+
+// Errors returned when decoding a world
+var (
+	ErrTruncated    = errors.New("world: world data is truncated")
+	ErrUnknownExtra = errors.New("world: unknown tile extra data type")
+)
+
+// Sizes of the encoded structures without their strings and extra data
+const (
+	tileSize   = 8
+	objectSize = 16
+)
+
+// decoder reads little endian values, remembering the first error
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil || len(d.data) < n {
+		d.err = ErrTruncated
+		return make([]byte, n)
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) uint8() uint8 {
+	return d.next(1)[0]
+}
+
+func (d *decoder) uint16() uint16 {
+	return binary.LittleEndian.Uint16(d.next(2))
+}
+
+func (d *decoder) uint32() uint32 {
+	return binary.LittleEndian.Uint32(d.next(4))
+}
+
+func (d *decoder) float32() float32 {
+	return math.Float32frombits(d.uint32())
+}
+
+func (d *decoder) string() string {
+	return string(d.next(int(d.uint16())))
+}
+
+// encoder appends little endian values, remembering the first error
+type encoder struct {
+	b   []byte
+	err error
+}
+
+func (e *encoder) uint8(v uint8) {
+	e.b = append(e.b, v)
+}
+
+func (e *encoder) uint16(v uint16) {
+	e.b = binary.LittleEndian.AppendUint16(e.b, v)
+}
+
+func (e *encoder) uint32(v uint32) {
+	e.b = binary.LittleEndian.AppendUint32(e.b, v)
+}
+
+func (e *encoder) float32(v float32) {
+	e.uint32(math.Float32bits(v))
+}
+
+func (e *encoder) bytes(b []byte) {
+	e.b = append(e.b, b...)
+}
+
+func (e *encoder) string(s string) {
+	if len(s) > math.MaxUint16 && e.err == nil {
+		e.err = fmt.Errorf("world: string of %d bytes is too long", len(s))
+	}
+	e.uint16(uint16(len(s)))
+	e.b = append(e.b, s...)
+}
+
+// MarshalBinary encodes the world as the extra data of a TankSendMapData
+// packet
+func (w *World) MarshalBinary() ([]byte, error) {
+	if uint64(w.Width)*uint64(w.Height) != uint64(len(w.Tiles)) {
+		return nil, fmt.Errorf("world: %d tiles in a %dx%d world", len(w.Tiles), w.Width, w.Height)
+	}
+
+	version := w.Version
+	if version == 0 {
+		version = DefaultVersion
+	}
+
+	e := &encoder{b: make([]byte, 0, 64+len(w.Name)+len(w.Tiles)*tileSize+len(w.Objects)*objectSize)}
+	e.uint16(version)
+	e.uint32(w.Flags)
+	e.string(w.Name)
+	e.uint32(w.Width)
+	e.uint32(w.Height)
+	e.uint32(uint32(len(w.Tiles)))
+	e.bytes(make([]byte, 5))
+
+	for i := range w.Tiles {
+		tile := &w.Tiles[i]
+		flags := tile.Flags &^ TileFlagExtra
+		if tile.Extra != nil {
+			flags |= TileFlagExtra
+		}
+		e.uint16(tile.Foreground)
+		e.uint16(tile.Background)
+		e.uint16(tile.Parent)
+		e.uint16(uint16(flags))
+		if flags&TileFlagLocked != 0 {
+			e.uint16(tile.LockIndex)
+		}
+		if tile.Extra != nil {
+			e.uint8(uint8(tile.Extra.ExtraType()))
+			tile.Extra.encode(e)
+		}
+	}
+
+	e.bytes(make([]byte, 12))
+	e.uint32(uint32(len(w.Objects)))
+	e.uint32(w.LastObjectID)
+	for _, obj := range w.Objects {
+		e.uint16(obj.ItemID)
+		e.float32(obj.X)
+		e.float32(obj.Y)
+		e.uint8(obj.Count)
+		e.uint8(obj.Flags)
+		e.uint32(obj.UID)
+	}
+
+	e.uint16(w.BaseWeather)
+	e.uint16(0)
+	e.uint16(w.CurrentWeather)
+	e.uint16(0)
+	e.bytes(w.Trailer)
+
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.b, nil
+}
+
+// UnmarshalBinary decodes a world from the extra data of a
+// TankSendMapData packet. Counts are checked against the remaining data
+// before anything is allocated for them.
+func (w *World) UnmarshalBinary(data []byte) error {
+	d := &decoder{data: data}
+	ret := World{
+		Version: d.uint16(),
+		Flags:   d.uint32(),
+		Name:    d.string(),
+		Width:   d.uint32(),
+		Height:  d.uint32(),
+	}
+	count := d.uint32()
+	d.next(5)
+	if d.err != nil {
+		return d.err
+	}
+	if uint64(ret.Width)*uint64(ret.Height) != uint64(count) {
+		return fmt.Errorf("world: %d tiles in a %dx%d world", count, ret.Width, ret.Height)
+	}
+	if uint64(count)*tileSize > uint64(len(d.data)) {
+		return ErrTruncated
+	}
+
+	ret.Tiles = make([]Tile, count)
+	for i := range ret.Tiles {
+		tile := &ret.Tiles[i]
+		tile.Foreground = d.uint16()
+		tile.Background = d.uint16()
+		tile.Parent = d.uint16()
+		tile.Flags = TileFlags(d.uint16())
+		if tile.Flags&TileFlagLocked != 0 {
+			tile.LockIndex = d.uint16()
+		}
+		if tile.Flags&TileFlagExtra != 0 {
+			extra, err := decodeExtra(d)
+			if err != nil {
+				return fmt.Errorf("world: tile %d: %w", i, err)
+			}
+			tile.Extra = extra
+		}
+		if d.err != nil {
+			return d.err
+		}
+	}
+
+	d.next(12)
+	objects := d.uint32()
+	ret.LastObjectID = d.uint32()
+	if d.err != nil {
+		return d.err
+	}
+	if uint64(objects)*objectSize > uint64(len(d.data)) {
+		return ErrTruncated
+	}
+	ret.Objects = make([]Object, objects)
+	for i := range ret.Objects {
+		ret.Objects[i] = Object{
+			ItemID: d.uint16(),
+			X:      d.float32(),
+			Y:      d.float32(),
+			Count:  d.uint8(),
+			Flags:  d.uint8(),
+			UID:    d.uint32(),
+		}
+	}
+
+	ret.BaseWeather = d.uint16()
+	d.uint16()
+	ret.CurrentWeather = d.uint16()
+	d.uint16()
+	if d.err != nil {
+		return d.err
+	}
+	if len(d.data) > 0 {
+		ret.Trailer = append([]byte(nil), d.data...)
+	}
+
+	*w = ret
+	return nil
+}
+
+// NewPacket builds the TankSendMapData packet sending the world to a
+// client entering it
+func NewPacket(w *World) (*gamepacket.TankPacket, error) {
+	data, err := w.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &gamepacket.TankPacket{
+		Type:      gamepacket.TankSendMapData,
+		Flags:     gamepacket.TankFlagExtended,
+		ExtraData: data,
+	}, nil
+}
+
+// SendWorld sends the world to a peer entering it
+func SendWorld(peer enet.Peer, w *World) error {
+	tank, err := NewPacket(w)
+	if err != nil {
+		return err
+	}
+	data, err := tank.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return enet.SendRawPacket(peer, int32(gamepacket.MessageGamePacket), data)
+}
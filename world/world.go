@@ -0,0 +1,118 @@
+// Package world models Growtopia worlds and encodes them in the format
+// clients expect when entering a world.
+package world
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DefaultVersion is the world format version written when World.Version is 0
+const DefaultVersion = 0x14
+
+// TileFlags are the flags of a tile
+type TileFlags uint16
+
+// Tile flags
+const (
+	TileFlagExtra TileFlags = 1 << iota
+	TileFlagLocked
+	TileFlagSpliced
+	TileFlagWillSpawnSeedsToo
+	TileFlagSeedling
+	TileFlagFlipped
+	TileFlagOn
+	TileFlagPublic
+	TileFlagBackgroundOn
+	TileFlagAlternateMode
+	TileFlagWet
+	TileFlagGlued
+	TileFlagOnFire
+	TileFlagPaintedRed
+	TileFlagPaintedGreen
+	TileFlagPaintedBlue
+)
+
+// Tile is a tile of a world
+type Tile struct {
+	Foreground uint16
+	Background uint16
+
+	// Parent is the index of the tile holding the lock of this tile
+	Parent uint16
+
+	// Flags of the tile. TileFlagExtra is managed by the encoder according
+	// to Extra.
+	Flags TileFlags
+
+	// LockIndex is only encoded when TileFlagLocked is set
+	LockIndex uint16
+
+	// Extra is the extra data of the tile, such as the text of a sign
+	Extra TileExtra
+}
+
+// Object is an item dropped in a world
+type Object struct {
+	ItemID uint16
+	X, Y   float32
+	Count  uint8
+	Flags  uint8
+	UID    uint32
+}
+
+// World is a world: a grid of tiles stored row by row and the objects
+// dropped in it
+type World struct {
+	// Version is the format version, DefaultVersion if 0
+	Version uint16
+	Flags   uint32
+	Name    string
+	Width   uint32
+	Height  uint32
+	Tiles   []Tile
+
+	Objects      []Object
+	LastObjectID uint32
+
+	BaseWeather    uint16
+	CurrentWeather uint16
+
+	// Trailer holds the bytes following the weather, if any, so that they
+	// survive decoding and encoding
+	Trailer []byte
+}
+
+// ErrOutOfBounds is returned when accessing a tile outside of the world
+var ErrOutOfBounds = errors.New("world: tile out of bounds")
+
+// New creates an empty world of the given size
+func New(name string, width, height uint32) *World {
+	return &World{
+		Name:   name,
+		Width:  width,
+		Height: height,
+		Tiles:  make([]Tile, int(width)*int(height)),
+	}
+}
+
+// Tile returns the tile at x, y
+func (w *World) Tile(x, y int) (*Tile, error) {
+	if x < 0 || y < 0 || x >= int(w.Width) || y >= int(w.Height) || y*int(w.Width)+x >= len(w.Tiles) {
+		return nil, fmt.Errorf("%w: %d, %d", ErrOutOfBounds, x, y)
+	}
+	return &w.Tiles[y*int(w.Width)+x], nil
+}
+
+// Drop drops an object in the world and returns its ID
+func (w *World) Drop(itemID uint16, count uint8, x, y float32) uint32 {
+	w.LastObjectID++
+	w.Objects = append(w.Objects, Object{
+		ItemID: itemID,
+		X:      x,
+		Y:      y,
+		Count:  count,
+		UID:    w.LastObjectID,
+	})
+	return w.LastObjectID
+}
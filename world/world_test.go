@@ -0,0 +1,191 @@
+package world_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	enet "github.com/eikarna/gotops"
+	"github.com/eikarna/gotops/enettest"
+	"github.com/eikarna/gotops/gamepacket"
+	"github.com/eikarna/gotops/world"
+)
+
+func testWorld(t *testing.T) *world.World {
+	t.Helper()
+
+	w := world.New("START", 4, 3)
+	w.Version = world.DefaultVersion
+	set := func(x, y int, tile world.Tile) {
+		t.Helper()
+		ptr, err := w.Tile(x, y)
+		if err != nil {
+			t.Fatal(err)
+		}
+		*ptr = tile
+	}
+	set(0, 0, world.Tile{Foreground: 6, Background: 14, Flags: world.TileFlagExtra, Extra: &world.Door{Label: "EXIT"}})
+	set(1, 0, world.Tile{Foreground: 20, Flags: world.TileFlagExtra | world.TileFlagFlipped, Extra: &world.Sign{Text: "Welcome!"}})
+	set(2, 0, world.Tile{Foreground: 242, Flags: world.TileFlagExtra, Extra: &world.Lock{Owner: 1, Access: []uint32{2, 3}, MinimumLevel: 10}})
+	set(3, 0, world.Tile{Foreground: 3, Flags: world.TileFlagExtra | world.TileFlagSeedling, Extra: &world.Seed{Age: 30, Fruits: 2}})
+	set(0, 1, world.Tile{Foreground: 456, Flags: world.TileFlagExtra, Extra: &world.Dice{Symbol: 5}})
+	set(1, 1, world.Tile{Foreground: 872, Flags: world.TileFlagExtra, Extra: &world.Provider{Age: 3600}})
+	set(2, 1, world.Tile{Foreground: 2, Parent: 2, Flags: world.TileFlagLocked, LockIndex: 2})
+	for x := 0; x < 4; x++ {
+		set(x, 2, world.Tile{Foreground: 8, Background: 14})
+	}
+	w.Drop(112, 10, 32, 48)
+	w.Drop(2, 200, 64.5, 48)
+	w.BaseWeather = 3
+	w.CurrentWeather = 3
+	return w
+}
+
+func TestRoundTrip(t *testing.T) {
+	w := testWorld(t)
+	data, err := w.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded world.World
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&decoded, w) {
+		t.Errorf("decoded %+v, want %+v", decoded, *w)
+	}
+
+	again, err := decoded.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, again) {
+		t.Error("re-encoded world differs")
+	}
+}
+
+func TestTileBounds(t *testing.T) {
+	w := world.New("TEST", 2, 2)
+	for _, pos := range [][2]int{{-1, 0}, {0, -1}, {2, 0}, {0, 2}} {
+		if _, err := w.Tile(pos[0], pos[1]); !errors.Is(err, world.ErrOutOfBounds) {
+			t.Errorf("Tile(%d, %d) returned %v", pos[0], pos[1], err)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	data, err := testWorld(t).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var w world.World
+	for i := 0; i < len(data)-8; i++ {
+		if err := w.UnmarshalBinary(data[:i]); err == nil {
+			t.Fatalf("decoding %d of %d bytes succeeded", i, len(data))
+		}
+	}
+
+	// A huge world in a small packet must not allocate its tiles.
+	huge := world.World{Name: "HUGE", Width: 1 << 16, Height: 1 << 16}
+	if _, err := huge.MarshalBinary(); err == nil {
+		t.Error("encoding a world without its tiles succeeded")
+	}
+	header := append([]byte{0x14, 0, 0, 0, 0, 0, 4, 0}, "HUGE"...)
+	header = append(header, 0, 0x10, 0, 0, 0, 0x10, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0)
+	if err := w.UnmarshalBinary(header); !errors.Is(err, world.ErrTruncated) {
+		t.Errorf("decoding a huge world returned %v", err)
+	}
+}
+
+func TestSendWorld(t *testing.T) {
+	network := enettest.NewNetwork(1)
+	server, err := network.NewHost("server", 1, 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Destroy()
+	client, err := network.NewHost("", 1, 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Destroy()
+	if _, err := client.Connect(network.Address("server"), 1, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	w := testWorld(t)
+	for i := 0; i < 1000; i++ {
+		network.Advance(time.Millisecond)
+		if ev := server.Service(0); ev.GetType() == enet.EventConnect {
+			if err := world.SendWorld(ev.GetPeer(), w); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		ev := client.Service(0)
+		if ev.GetType() != enet.EventReceive {
+			continue
+		}
+		data := ev.GetPacket().GetData()
+		ev.GetPacket().Destroy()
+
+		typ, payload, err := gamepacket.Decode(data)
+		if err != nil || typ != gamepacket.MessageGamePacket {
+			t.Fatalf("received message %v, %v", typ, err)
+		}
+		var tank gamepacket.TankPacket
+		if err := tank.UnmarshalBinary(payload); err != nil {
+			t.Fatal(err)
+		}
+		if tank.Type != gamepacket.TankSendMapData {
+			t.Fatalf("received tank packet %v", tank.Type)
+		}
+		var received world.World
+		if err := received.UnmarshalBinary(tank.ExtraData); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(&received, w) {
+			t.Errorf("received %+v, want %+v", received, *w)
+		}
+		return
+	}
+	t.Fatal("client didn't receive the world")
+}
+
+func FuzzUnmarshalBinary(f *testing.F) {
+	w := world.New("START", 4, 3)
+	w.Drop(2, 200, 64.5, 48)
+	tile, _ := w.Tile(0, 0)
+	*tile = world.Tile{Foreground: 242, Flags: world.TileFlagExtra, Extra: &world.Lock{Owner: 1, Access: []uint32{2, 3}}}
+	tile, _ = w.Tile(1, 0)
+	*tile = world.Tile{Foreground: 20, Flags: world.TileFlagExtra, Extra: &world.Sign{Text: "Welcome!"}}
+	data, _ := w.MarshalBinary()
+	f.Add(data)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var decoded world.World
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			return
+		}
+		// Fields the encoder normalizes may change once, after which
+		// encoding is stable.
+		encoded, err := decoded.MarshalBinary()
+		if err != nil {
+			return
+		}
+		var again world.World
+		if err := again.UnmarshalBinary(encoded); err != nil {
+			t.Fatalf("re-encoded world doesn't decode: %v", err)
+		}
+		reencoded, err := again.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(encoded, reencoded) {
+			t.Fatalf("encoding of %x isn't stable", data)
+		}
+	})
+}